@@ -0,0 +1,188 @@
+/*
+The analytics package summarizes a blog's content: how much has been written, when, and about what.
+
+Analyze works from the same articles the blog renders (see weblog.LoadArticles),
+counting words in each article's abstract and body once HTML markup is stripped,
+and placing articles in time by their publication dates (see weblog.Descriptor.Date).
+Articles whose dates can't be understood are counted as undated, and left out of the figures concerning time.
+*/
+package analytics
+
+import (
+	"github.com/sam-falvo/sitehammer/weblog"
+	"html"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Count pairs a label, such as a year, a month, or a tag, with the number of articles it applies to.
+type Count struct {
+	Label    string `json:"label"`
+	Articles int    `json:"articles"`
+}
+
+// Length gives the length of one article, in words.
+type Length struct {
+	Id    uint   `json:"id"`
+	Title string `json:"title"`
+	Words int    `json:"words"`
+}
+
+// Gap describes the time between two consecutive articles.
+type Gap struct {
+	From, To time.Time
+}
+
+// Days answers the length of the gap in whole days.
+func (g Gap) Days() int {
+	return int(g.To.Sub(g.From).Hours() / 24)
+}
+
+// Report summarizes a blog's content.
+//
+// Articles counts every article, and Undated those whose publication dates couldn't be understood.
+// Words totals the words of every article; Lengths gives each article's length, longest first.
+// Years and Months count the articles published in each, in chronological order;
+// months are labeled as 2012-Jan.
+// Tags counts the articles bearing each tag, most popular first; articles may bear several tags, or none.
+// First and Last give the dates of the earliest and latest articles.
+// MeanGap gives the average time between consecutive articles, and LongestGap the longest;
+// both are zero unless at least two articles are dated.
+type Report struct {
+	Articles   int
+	Undated    int
+	Words      int
+	Lengths    []Length
+	Years      []Count
+	Months     []Count
+	Tags       []Count
+	First      time.Time
+	Last       time.Time
+	MeanGap    time.Duration
+	LongestGap Gap
+}
+
+// MeanWords answers the average length of an article, in words.
+func (r *Report) MeanWords() int {
+	if r.Articles == 0 {
+		return 0
+	}
+	return r.Words / r.Articles
+}
+
+// MedianWords answers the median length of an article, in words.
+func (r *Report) MedianWords() int {
+	if len(r.Lengths) == 0 {
+		return 0
+	}
+	return r.Lengths[len(r.Lengths)/2].Words
+}
+
+// Analyze summarizes the given articles.
+func Analyze(articles []weblog.Article) *Report {
+	r := &Report{Articles: len(articles)}
+	years := make(map[string]int)
+	months := make(map[string]int)
+	tags := make(map[string]int)
+	var dates []time.Time
+
+	for _, a := range articles {
+		words := Words(string(a.Abstract)) + Words(string(a.Body))
+		r.Words += words
+		r.Lengths = append(r.Lengths, Length{a.Id, a.Title, words})
+		for _, tag := range a.Tags {
+			tags[tag]++
+		}
+		date, ok := a.Date()
+		if !ok {
+			r.Undated++
+			continue
+		}
+		dates = append(dates, date)
+		years[date.Format("2006")]++
+		months[date.Format("2006-01")]++
+	}
+
+	sort.SliceStable(r.Lengths, func(i, j int) bool { return r.Lengths[i].Words > r.Lengths[j].Words })
+	r.Years = chronological(years, "2006", "2006")
+	r.Months = chronological(months, "2006-01", "2006-Jan")
+	r.Tags = byPopularity(tags)
+
+	sort.Slice(dates, func(i, j int) bool { return dates[i].Before(dates[j]) })
+	if len(dates) > 0 {
+		r.First, r.Last = dates[0], dates[len(dates)-1]
+	}
+	if len(dates) > 1 {
+		r.MeanGap = r.Last.Sub(r.First) / time.Duration(len(dates)-1)
+		for i := 1; i < len(dates); i++ {
+			gap := Gap{dates[i-1], dates[i]}
+			if gap.To.Sub(gap.From) > r.LongestGap.To.Sub(r.LongestGap.From) {
+				r.LongestGap = gap
+			}
+		}
+	}
+	return r
+}
+
+// chronological answers the counts, whose labels are written in the given layout, in chronological order,
+// relabeled in the display layout.
+func chronological(counts map[string]int, layout, display string) []Count {
+	var labels []string
+	for label := range counts {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+	var result []Count
+	for _, label := range labels {
+		t, _ := time.Parse(layout, label)
+		result = append(result, Count{t.Format(display), counts[label]})
+	}
+	return result
+}
+
+// byPopularity answers the counts in decreasing order, with ties broken alphabetically.
+func byPopularity(counts map[string]int) []Count {
+	var result []Count
+	for label, n := range counts {
+		result = append(result, Count{label, n})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Articles != result[j].Articles {
+			return result[i].Articles > result[j].Articles
+		}
+		return result[i].Label < result[j].Label
+	})
+	return result
+}
+
+// Words answers how many words a fragment of HTML holds, not counting its markup: its tags and comments.
+// Markup is scanned for directly, rather than matched by a regular expression, since a tag may run to megabytes,
+// as one embedding an image as a data URI does.
+// A < beginning no markup, because no > follows it, is taken as text.
+func Words(fragment string) int {
+	words := 0
+	for {
+		lt := strings.IndexByte(fragment, '<')
+		end := 0
+		if lt >= 0 {
+			markup := fragment[lt:]
+			end = strings.IndexByte(markup, '>') + 1
+			if strings.HasPrefix(markup, "<!--") {
+				if i := strings.Index(markup[4:], "-->"); i >= 0 {
+					end = 4 + i + 3
+				}
+			}
+		}
+		if end == 0 {
+			return words + textWords(fragment)
+		}
+		words += textWords(fragment[:lt])
+		fragment = fragment[lt+end:]
+	}
+}
+
+// textWords answers how many words text, between markup, holds.
+func textWords(text string) int {
+	return len(strings.Fields(html.UnescapeString(text)))
+}
@@ -0,0 +1,103 @@
+/*
+The assets package keeps track of static assets (stylesheets, scripts, images) whose published names carry a fingerprint of their contents.
+
+When an asset's name changes every time its content changes, web servers may safely tell browsers to cache it forever.
+The price is that nobody knows the asset's published name in advance.
+The static pass records the names it chose in an asset map,
+and the blog consults that map when templates ask where an asset lives.
+*/
+package assets
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/sam-falvo/sitehammer/directory"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+)
+
+// MapFilename names the file in which the static pass records fingerprinted asset names.
+// The leading underscore keeps the static pass from publishing the map along with the rest of the site.
+const MapFilename = "_assets.json"
+
+// fingerprintLength is the number of hexadecimal digits of the content hash that appear in a fingerprinted filename.
+const fingerprintLength = 10
+
+// fingerprintable lists the filename extensions of assets which receive fingerprints.
+// HTML is deliberately absent; pages must keep their well-known URLs.
+var fingerprintable = map[string]bool{
+	".css":  true,
+	".js":   true,
+	".png":  true,
+	".jpg":  true,
+	".jpeg": true,
+	".gif":  true,
+	".svg":  true,
+	".webp": true,
+	".ico":  true,
+}
+
+// Map relates an asset's logical name (e.g., theme/css.css) to its fingerprinted name (e.g., theme/css.0123456789.css).
+// Names are slash-separated and relative to the root of the site.
+type Map map[string]string
+
+// IsFingerprintable answers true if the named file is the kind of asset that should receive a fingerprint.
+func IsFingerprintable(name string) bool {
+	return fingerprintable[strings.ToLower(path.Ext(name))]
+}
+
+// Fingerprint computes the fingerprinted name for an asset with the given name and content.
+// The fingerprint sits between the base name and the extension, so theme/css.css becomes theme/css.<hash>.css.
+func Fingerprint(name string, content []byte) string {
+	sum := sha256.Sum256(content)
+	return FingerprintHash(name, hex.EncodeToString(sum[:]))
+}
+
+// FingerprintHash computes the same name as Fingerprint, but from the hexadecimal SHA-256 hash of the asset's content.
+// This lets callers who've already hashed the content (say, from a build cache) avoid reading it again.
+func FingerprintHash(name, hash string) string {
+	ext := path.Ext(name)
+	return fmt.Sprintf("%s.%s%s", strings.TrimSuffix(name, ext), hash[:fingerprintLength], ext)
+}
+
+// LoadMap reads an asset map previously written by Save.
+// A missing map file isn't an error; it simply means no assets have been fingerprinted, and an empty map results.
+func LoadMap(filename string) (m Map, err error) {
+	m = make(Map)
+	raw, err := ioutil.ReadFile(filename)
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+		}
+		return
+	}
+	err = json.Unmarshal(raw, &m)
+	return
+}
+
+// Save writes the asset map to the named file in JSON format.
+func (m Map) Save(filename string) error {
+	raw, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return directory.WriteFileAtomic(filename, raw, 0644)
+}
+
+// Lookup resolves an asset's logical name to its published name.
+// A leading slash, if present, is preserved, so templates may write absolute paths as they always have.
+// Assets not found in the map resolve to themselves.
+func (m Map) Lookup(name string) string {
+	prefix := ""
+	if strings.HasPrefix(name, "/") {
+		prefix = "/"
+	}
+	if fingerprinted, ok := m[strings.TrimPrefix(name, "/")]; ok {
+		return prefix + fingerprinted
+	}
+	return name
+}
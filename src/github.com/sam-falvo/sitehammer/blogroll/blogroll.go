@@ -0,0 +1,216 @@
+/*
+The blogroll package publishes the site's blogroll, the sites its author reads and recommends, from a single data file,
+both as a list templates render, for a links page or a sidebar, and as an OPML file, which feed readers subscribe to at once,
+so the two never drift apart.
+
+The data file, _blogroll.json unless the configuration's blogroll table names another, lists the links in JSON, like the article descriptors:
+
+	[
+	  {
+	    "Title": "Falvotech",
+	    "Url": "https://www.falvotech.com/",
+	    "Feed": "https://www.falvotech.com/feed.xml",
+	    "Description": "Forth, hardware, and the Kestrel",
+	    "Category": "Friends"
+	  }
+	]
+
+Title and Url are required; Url and Feed, which is optional, must be absolute http or https URLs.
+Links are kept in the order listed, grouped by Category, in the order each category first appears.
+
+Templates, whether the static pass's, the Markdown layout, or the blog's, call the Blogroll function to see the blogroll,
+which is nil if there's no data file:
+
+	{{with Blogroll}}
+	<h2>{{.Title}}</h2>
+	{{range .Categories}}<h3>{{.Name}}</h3>
+	<ul>{{range .Links}}<li><a href="{{.Url}}">{{.Title}}</a>{{with .Description}}: {{.}}{{end}}</li>{{end}}</ul>
+	{{end}}
+	<p><a href="{{.Opml}}">Subscribe to all of them</a></p>
+	{{end}}
+
+The OPML file, blogroll.opml unless configured otherwise, lists the same links, each category an outline holding its links' outlines;
+links with feeds are outlines of type rss, which feed readers import, and the rest, of type link.
+*/
+package blogroll
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"github.com/sam-falvo/sitehammer/config"
+	"github.com/sam-falvo/sitehammer/directory"
+	"github.com/sam-falvo/sitehammer/dryrun"
+	"github.com/sam-falvo/sitehammer/failure"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+)
+
+// Link describes a site on the blogroll.
+type Link struct {
+	Title       string
+	Url         string
+	Feed        string `json:",omitempty"`
+	Description string `json:",omitempty"`
+	Category    string `json:",omitempty"`
+}
+
+// Category gathers the links of a category, in the order they're listed; uncategorized links have a category with no Name.
+type Category struct {
+	Name  string
+	Links []Link
+}
+
+// Blogroll is what templates see of the blogroll: its Title, the site path of its OPML file, if one is published,
+// and its Links, both as listed, and grouped into Categories.
+type Blogroll struct {
+	Title      string
+	Opml       string
+	Links      []Link
+	Categories []Category
+}
+
+// Load reads the blogroll from the data file the configuration names, answering nil, without an error, if there isn't one.
+// A data file which isn't valid JSON, or lists a link without a title, or with a malformed URL, is a fault of the content.
+func Load(cfg *config.Config) (*Blogroll, error) {
+	filename := cfg.Blogroll.File
+	raw, err := ioutil.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var links []Link
+	err = json.Unmarshal(raw, &links)
+	if err != nil {
+		return nil, failure.Wrap(failure.Content, fmt.Errorf("The blogroll %s isn't a JSON list of links: %v", filename, err))
+	}
+	b := &Blogroll{Title: cfg.Blogroll.Title, Links: links}
+	if cfg.Blogroll.Opml != "" {
+		b.Opml = "/" + cfg.Blogroll.Opml
+	}
+	for i, l := range links {
+		if l.Title == "" {
+			return nil, failure.Wrap(failure.Content, fmt.Errorf("Link %d of the blogroll %s has no title.", i+1, filename))
+		}
+		if !absolute(l.Url) {
+			return nil, failure.Wrap(failure.Content, fmt.Errorf("Link %d of the blogroll %s, %s, needs an absolute http or https URL, not %q.", i+1, filename, l.Title, l.Url))
+		}
+		if l.Feed != "" && !absolute(l.Feed) {
+			return nil, failure.Wrap(failure.Content, fmt.Errorf("Link %d of the blogroll %s, %s, needs an absolute http or https feed URL, not %q.", i+1, filename, l.Title, l.Feed))
+		}
+		b.add(l)
+	}
+	return b, nil
+}
+
+// absolute answers true if s is an absolute http or https URL.
+func absolute(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// add files the link under its category, which is added after the others if it's not been seen before.
+func (b *Blogroll) add(l Link) {
+	for i := range b.Categories {
+		if b.Categories[i].Name == l.Category {
+			b.Categories[i].Links = append(b.Categories[i].Links, l)
+			return
+		}
+	}
+	b.Categories = append(b.Categories, Category{l.Category, []Link{l}})
+}
+
+// Options controls generation of the OPML file.
+//
+// OutputDir names the directory holding the built site, which may be a staging area;
+// DisplayDir names the same directory as the user knows it, for dry-run reports.
+type Options struct {
+	Config     *config.Config
+	OutputDir  string
+	DisplayDir string
+	DryRun     bool
+}
+
+// Generate writes the blogroll's OPML file into the output directory, refusing to replace any of the named outputs the build already produced.
+// It answers the slash-separated names of the files it's responsible for: none, if there's no blogroll, or no OPML file is configured.
+func Generate(opts Options, outputs map[string]bool) ([]string, error) {
+	name := opts.Config.Blogroll.Opml
+	b, err := Load(opts.Config)
+	if err != nil || b == nil || name == "" {
+		return nil, err
+	}
+	if outputs[name] {
+		return nil, failure.Wrap(failure.Content, fmt.Errorf("The site already has %s, which the blogroll's OPML file would replace; remove one or rename the other.", name))
+	}
+	content, err := b.opml(opts.Config.Blog.Author)
+	if err != nil {
+		return nil, err
+	}
+	if opts.DryRun {
+		dryrun.ReportWriteIfChanged(filepath.Join(opts.DisplayDir, filepath.FromSlash(name)), content)
+		return []string{name}, nil
+	}
+	filename := filepath.Join(opts.OutputDir, filepath.FromSlash(name))
+	err = directory.EnsureDirAll(filepath.Dir(filename), opts.Config.Output.DirPerm())
+	if err == nil {
+		err = directory.WriteFileAtomic(filename, content, opts.Config.Output.FilePerm(0644))
+	}
+	return []string{name}, err
+}
+
+// outline is an outline element of an OPML file.
+type outline struct {
+	Type        string    `xml:"type,attr,omitempty"`
+	Text        string    `xml:"text,attr"`
+	Title       string    `xml:"title,attr,omitempty"`
+	XmlUrl      string    `xml:"xmlUrl,attr,omitempty"`
+	HtmlUrl     string    `xml:"htmlUrl,attr,omitempty"`
+	Url         string    `xml:"url,attr,omitempty"`
+	Description string    `xml:"description,attr,omitempty"`
+	Outlines    []outline `xml:"outline"`
+}
+
+// opml answers the content of an OPML 2.0 file listing the blogroll, owned by the named author, if any.
+// It carries no dates, so it changes only when the blogroll does.
+func (b *Blogroll) opml(owner string) ([]byte, error) {
+	var doc struct {
+		XMLName xml.Name `xml:"opml"`
+		Version string   `xml:"version,attr"`
+		Head    struct {
+			Title     string `xml:"title"`
+			OwnerName string `xml:"ownerName,omitempty"`
+		} `xml:"head"`
+		Body struct {
+			Outlines []outline `xml:"outline"`
+		} `xml:"body"`
+	}
+	doc.Version = "2.0"
+	doc.Head.Title = b.Title
+	doc.Head.OwnerName = owner
+	for _, c := range b.Categories {
+		links := make([]outline, len(c.Links))
+		for i, l := range c.Links {
+			links[i] = outline{Type: "link", Text: l.Title, Url: l.Url, Description: l.Description}
+			if l.Feed != "" {
+				links[i] = outline{Type: "rss", Text: l.Title, Title: l.Title, XmlUrl: l.Feed, HtmlUrl: l.Url, Description: l.Description}
+			}
+		}
+		if c.Name == "" {
+			doc.Body.Outlines = append(doc.Body.Outlines, links...)
+			continue
+		}
+		doc.Body.Outlines = append(doc.Body.Outlines, outline{Text: c.Name, Title: c.Name, Outlines: links})
+	}
+	var out bytes.Buffer
+	out.WriteString(xml.Header)
+	encoder := xml.NewEncoder(&out)
+	encoder.Indent("", "  ")
+	err := encoder.Encode(doc)
+	out.WriteString("\n")
+	return out.Bytes(), err
+}
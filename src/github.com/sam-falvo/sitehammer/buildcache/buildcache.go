@@ -0,0 +1,203 @@
+/*
+The buildcache package remembers what previous builds did, so later builds can skip work whose inputs haven't changed.
+
+A cache tracks two things.
+For source files, it records size, modification time, and a content hash;
+as long as a file's size and modification time stay the same, its hash is trusted without re-reading the file.
+In a git repository, it's trusted as long as git knows the file to be unchanged since the last successful build, too,
+whatever its modification time, so a fresh clone with its caches restored needn't re-read every file; see Git.
+For outputs, it records a signature: a hash over everything that went into producing the output
+(the content of its sources, the templates used, the relevant configuration, and so on).
+When an output still exists and its signature hasn't changed, there's no need to regenerate it.
+*/
+package buildcache
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/sam-falvo/sitehammer/directory"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// DefaultDir names the directory, relative to the site's source directory, in which SiteHammer keeps its caches unless configured otherwise.
+const DefaultDir = ".sitehammer-cache"
+
+// Dir names the directory in which SiteHammer keeps its caches: relative to the site's source directory, unless it's absolute.
+// Commands set it from the configuration's cache table as they start.
+var Dir = DefaultDir
+
+// DirIn answers the cache directory of a site whose source directory is sourceDir.
+func DirIn(sourceDir string) string {
+	if filepath.IsAbs(Dir) {
+		return Dir
+	}
+	return filepath.Join(sourceDir, Dir)
+}
+
+// HashesFilename names the cache of output content hashes, relative to Dir, shared by the phases which run after the outputs are built.
+// See directory.HashCache.
+const HashesFilename = "hashes.json"
+
+// ForgetHashes discards what the cache of output content hashes in the cache directory cacheDir knows of the named outputs,
+// which a build has just rewritten, and saves the cache at once.
+// The cache takes a file whose size and modification time haven't changed to be unchanged,
+// but an output rewritten with content of the same size, and given back its source's modification time, has neither changed;
+// and saving at once keeps it from being taken as unchanged by a later build, should this one fail before it reads the output again.
+func ForgetHashes(cacheDir string, outputs map[string]bool) error {
+	if len(outputs) == 0 {
+		return nil
+	}
+	hashes := directory.OpenHashCache(filepath.Join(cacheDir, HashesFilename))
+	for name := range outputs {
+		hashes.Forget(name)
+	}
+	return hashes.Save()
+}
+
+// Source records what the cache knows about a source file.
+type Source struct {
+	Size    int64
+	ModTime time.Time
+	Hash    string
+}
+
+// Cache holds the state recorded by previous builds.
+// Outputs are named by slash-separated paths relative to Root, which defaults to the current directory.
+// Since Root isn't saved with the cache, outputs may be built in one place (say, a staging area) and checked for freshness in another.
+// Git, if not nil, vouches for source files whose modification times alone have changed; see HashFile.
+type Cache struct {
+	filename string
+	Root     string `json:"-"`
+	Git      *Git   `json:"-"`
+	Sources  map[string]Source
+	Outputs  map[string]string
+}
+
+// Open loads the named cache file.
+// A missing or unreadable cache isn't an error; it just means everything must be rebuilt, so an empty cache results.
+func Open(filename string) *Cache {
+	c := &Cache{
+		filename: filename,
+		Root:     ".",
+		Sources:  make(map[string]Source),
+		Outputs:  make(map[string]string),
+	}
+	raw, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return c
+	}
+	if json.Unmarshal(raw, c) != nil || c.Sources == nil || c.Outputs == nil {
+		c.Sources = make(map[string]Source)
+		c.Outputs = make(map[string]string)
+	}
+	return c
+}
+
+// Save writes the cache back to the file it was opened from, creating the cache directory if necessary.
+func (c *Cache) Save() error {
+	raw, err := json.MarshalIndent(c, "", " ")
+	if err != nil {
+		return err
+	}
+	err = directory.EnsureDirAll(filepath.Dir(c.filename), 0755)
+	if err != nil {
+		return err
+	}
+	return directory.WriteFileAtomic(c.filename, raw, 0644)
+}
+
+// Hash answers the hexadecimal SHA-256 hash of the contents of data.
+func Hash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Signature combines several hashes, strings, or other identifying parts into a single signature.
+// Changing any part, or their order, changes the signature.
+func Signature(parts ...string) string {
+	h := sha256.New()
+	for _, p := range parts {
+		h.Write([]byte(p))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// HashFile answers the content hash of the named file, whose os.FileInfo the caller has already obtained.
+// If the file's size and modification time match what the cache recorded earlier, the recorded hash is answered without reading the file,
+// as it is if the size alone matches, and Git knows the file to be unchanged; the new modification time is then recorded.
+// Otherwise, the file is read, hashed, and the cache updated.
+func (c *Cache) HashFile(name string, fi os.FileInfo) (string, error) {
+	if s, ok := c.Sources[name]; ok && s.Size == fi.Size() {
+		if s.ModTime.Equal(fi.ModTime()) {
+			return s.Hash, nil
+		}
+		if c.Git.Unchanged(name) {
+			c.Sources[name] = Source{Size: fi.Size(), ModTime: fi.ModTime(), Hash: s.Hash}
+			return s.Hash, nil
+		}
+	}
+	hash, err := directory.HashFile(name)
+	if err != nil {
+		return "", err
+	}
+	c.Sources[name] = Source{Size: fi.Size(), ModTime: fi.ModTime(), Hash: hash}
+	return hash, nil
+}
+
+// Fresh answers true if the named output exists and was last produced from inputs with the given signature.
+func (c *Cache) Fresh(output, signature string) bool {
+	if c.Outputs[output] != signature {
+		return false
+	}
+	_, err := os.Lstat(filepath.Join(c.Root, filepath.FromSlash(output)))
+	return err == nil
+}
+
+// Record notes that the named output has just been produced from inputs with the given signature.
+func (c *Cache) Record(output, signature string) {
+	c.Outputs[output] = signature
+}
+
+// Forget removes any record of the named output, ensuring that it'll be regenerated next time.
+func (c *Cache) Forget(output string) {
+	delete(c.Outputs, output)
+}
+
+// compareBlock is the size of the blocks in which Unchanged compares a file with content.
+const compareBlock = 64 << 10
+
+// Unchanged answers true if the named file already holds exactly the given content.
+// Writers use it to avoid rewriting outputs needlessly, which would disturb their modification times.
+// The file is compared a block at a time, rather than read whole, so comparing a large page costs little memory,
+// and a page that differs early costs little time, too.
+func Unchanged(filename string, content []byte) bool {
+	f, err := os.Open(filename)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil || fi.Size() != int64(len(content)) {
+		return false
+	}
+	block := make([]byte, compareBlock)
+	for len(content) > 0 {
+		n := len(block)
+		if len(content) < n {
+			n = len(content)
+		}
+		_, err = io.ReadFull(f, block[:n])
+		if err != nil || !bytes.Equal(block[:n], content[:n]) {
+			return false
+		}
+		content = content[n:]
+	}
+	return true
+}
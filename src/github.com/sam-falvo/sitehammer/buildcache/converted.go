@@ -0,0 +1,31 @@
+package buildcache
+
+import (
+	"github.com/sam-falvo/sitehammer/directory"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// ConvertedDirName names the directory, within the cache directory, holding the output of conversions cached by Convert.
+const ConvertedDirName = "converted"
+
+// Convert answers the output of a conversion, such as an external tool turning an article's body into HTML,
+// identified by a signature of everything it depends on: the input's content, the command converting it, and so on.
+// If a conversion with the same signature was cached in cacheDir, its output is answered without converting again;
+// otherwise, convert is called, and its output, if it succeeds, cached.
+// Failing to cache the output isn't an error, since it only means converting again next time.
+func Convert(cacheDir, signature string, convert func() ([]byte, error)) ([]byte, error) {
+	filename := filepath.Join(cacheDir, ConvertedDirName, signature)
+	if out, err := ioutil.ReadFile(filename); err == nil {
+		return out, nil
+	}
+	out, err := convert()
+	if err != nil {
+		return nil, err
+	}
+	if os.MkdirAll(filepath.Dir(filename), 0755) == nil {
+		directory.WriteFileAtomic(filename, out, 0644)
+	}
+	return out, nil
+}
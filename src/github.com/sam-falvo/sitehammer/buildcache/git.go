@@ -0,0 +1,144 @@
+package buildcache
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/sam-falvo/sitehammer/directory"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// GitFilename names the record of the git commit the last successful build was made from, relative to the cache directory.
+const GitFilename = "git.json"
+
+// gitRecord is what GitFilename holds: the commit built, and the tracked files which differed from it when it was built.
+type gitRecord struct {
+	Commit string   `json:"commit"`
+	Dirty  []string `json:"dirty,omitempty"`
+}
+
+// Git tells which source files are unchanged since the last successful build, as the git repository holding them knows,
+// so that their recorded hashes may be trusted even when their modification times have changed,
+// as they all have in a fresh clone, such as a CI service makes for each build, with the caches restored.
+// A file is unchanged if git tracks it, and it's the same now as in the commit the last build was made from,
+// and it was the same then, too, uncommitted changes being no part of any commit.
+// Untracked files, and ignored ones, are never known to be unchanged, and left to their modification times.
+//
+// Since a build recording hashes without recording its commit would leave them at odds with it,
+// the record is removed as a build begins, by Begin, and written again only once the build succeeds, by Save,
+// whether or not the source directory is in a git repository, or git can be run, this time.
+// A nil *Git knows nothing, as does one for a source directory which isn't in a git repository, so every file is left to its modification time.
+type Git struct {
+	filename string
+	head     string
+	dirty    []string
+	tracked  map[string]bool
+	changed  map[string]bool
+
+	// known is true if the last build's commit is known, and git could compare the files with it.
+	known bool
+}
+
+// OpenGit asks git, in sourceDir, which files have changed since the commit recorded in the given cache directory.
+// Unless sourceDir lies in a git repository with at least one commit, and git can be run, the Git answered knows nothing.
+// A commit git doesn't have, as in a shallow clone, leaves every file changed.
+func OpenGit(cacheDir, sourceDir string) *Git {
+	g := &Git{filename: filepath.Join(cacheDir, GitFilename), tracked: make(map[string]bool), changed: make(map[string]bool)}
+	head, err := gitOutput(sourceDir, "rev-parse", "--verify", "-q", "HEAD")
+	if err != nil || len(head) != 1 {
+		return g
+	}
+	tracked, err := gitOutput(sourceDir, "ls-files", "-z", "--", ".")
+	if err != nil {
+		return g
+	}
+	dirty, err := gitOutput(sourceDir, "diff", "--name-only", "--no-renames", "--relative", "-z", "HEAD", "--", ".")
+	if err != nil {
+		return g
+	}
+	g.head, g.dirty = head[0], dirty
+	for _, name := range tracked {
+		g.tracked[sourceName(sourceDir, name)] = true
+	}
+
+	var record gitRecord
+	raw, err := ioutil.ReadFile(g.filename)
+	if err != nil || json.Unmarshal(raw, &record) != nil || record.Commit == "" {
+		return g
+	}
+	changed, err := gitOutput(sourceDir, "diff", "--name-only", "--no-renames", "--relative", "-z", record.Commit, "--", ".")
+	if err != nil {
+		return g
+	}
+	for _, name := range append(changed, record.Dirty...) {
+		g.changed[sourceName(sourceDir, name)] = true
+	}
+	g.known = true
+	return g
+}
+
+// sourceName answers the slash-separated name, as the caches know it, of a file git names relative to sourceDir.
+func sourceName(sourceDir, name string) string {
+	return filepath.ToSlash(filepath.Join(sourceDir, filepath.FromSlash(name)))
+}
+
+// gitOutput runs git in dir, answering the names or lines it writes, separated by NUL characters if the arguments ask for -z, or else by lines.
+func gitOutput(dir string, args ...string) ([]string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+	separator := "\n"
+	for _, arg := range args {
+		if arg == "-z" {
+			separator = "\x00"
+		}
+	}
+	var names []string
+	for _, name := range strings.Split(string(bytes.TrimRight(out, "\n")), separator) {
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+// Unchanged answers true if git knows the named file, a slash-separated name as the caches know it, to be unchanged since the last successful build.
+func (g *Git) Unchanged(name string) bool {
+	name = filepath.ToSlash(name)
+	return g != nil && g.known && g.tracked[name] && !g.changed[name]
+}
+
+// Begin removes the record of the last successful build's commit, as a build begins to record hashes of the files as they are now.
+func (g *Git) Begin() error {
+	if g == nil {
+		return nil
+	}
+	err := os.Remove(g.filename)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// Save records the commit the build just finished was made from, along with the tracked files which differed from it as the build began.
+// Without a commit, there's nothing to record.
+func (g *Git) Save() error {
+	if g == nil || g.head == "" {
+		return nil
+	}
+	raw, err := json.MarshalIndent(gitRecord{g.head, g.dirty}, "", " ")
+	if err != nil {
+		return err
+	}
+	err = directory.EnsureDirAll(filepath.Dir(g.filename), 0755)
+	if err != nil {
+		return err
+	}
+	return directory.WriteFileAtomic(g.filename, raw, 0644)
+}
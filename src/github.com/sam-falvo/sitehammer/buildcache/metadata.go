@@ -0,0 +1,210 @@
+package buildcache
+
+import (
+	"encoding/json"
+	"github.com/sam-falvo/sitehammer/directory"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MetadataFilename names the metadata store, relative to the cache directory.
+const MetadataFilename = "metadata.json"
+
+// Entry records what the metadata store knows of a source file as of the last successful build:
+// its size, modification time, and content hash, and the outputs built from it.
+type Entry struct {
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mtime"`
+	Hash    string    `json:"sha256"`
+	Outputs []string  `json:"outputs,omitempty"`
+}
+
+// Metadata remembers, between builds, every source file the last successful build used and the outputs built from each.
+// Incremental builds ask it which sources have changed since; the clean pass asks it which outputs the build is responsible for.
+// Sources are named by slash-separated paths relative to Root, which defaults to the current directory.
+// Git, if not nil, vouches for sources whose modification times alone have changed, as it does for Cache.HashFile.
+// A Metadata store is safe to use from several goroutines.
+type Metadata struct {
+	Root     string
+	Git      *Git
+	filename string
+	mu       sync.Mutex
+	files    map[string]Entry
+	dirty    bool
+}
+
+// OpenMetadata loads the metadata store kept in the given cache directory.
+// As with Open, a missing or unreadable store isn't an error; an empty one results, in which every source appears changed.
+func OpenMetadata(cacheDir string) *Metadata {
+	m := &Metadata{Root: ".", filename: filepath.Join(cacheDir, MetadataFilename), files: make(map[string]Entry)}
+	raw, err := ioutil.ReadFile(m.filename)
+	if err != nil {
+		return m
+	}
+	if json.Unmarshal(raw, &m.files) != nil || m.files == nil {
+		m.files = make(map[string]Entry)
+	}
+	return m
+}
+
+// Lookup answers what's recorded of the named source, and whether anything is.
+func (m *Metadata) Lookup(name string) (Entry, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, ok := m.files[name]
+	return e, ok
+}
+
+// Changed answers true if the named source's content differs from that recorded, or if nothing is recorded of it.
+// A source whose size and modification time match the record is taken to be unchanged without being read;
+// one whose modification time alone differs is read and hashed, so merely touching a file doesn't count as a change.
+// A source which no longer exists has changed.
+func (m *Metadata) Changed(name string) (bool, error) {
+	e, ok := m.Lookup(name)
+	if !ok {
+		return true, nil
+	}
+	hash, _, err := m.hash(name, e)
+	if os.IsNotExist(err) {
+		return true, nil
+	}
+	return err != nil || hash != e.Hash, err
+}
+
+// hash answers the content hash of the named source, and its current state, trusting the hash in e if the source's size and modification time match it,
+// or if its size does, and Git knows it to be unchanged.
+func (m *Metadata) hash(name string, e Entry) (string, os.FileInfo, error) {
+	filename := filepath.Join(m.Root, filepath.FromSlash(name))
+	fi, err := os.Stat(filename)
+	if err != nil {
+		return "", nil, err
+	}
+	if e.Hash != "" && e.Size == fi.Size() && (e.ModTime.Equal(fi.ModTime()) || m.Git.Unchanged(filename)) {
+		return e.Hash, fi, nil
+	}
+	hash, err := directory.HashFile(filename)
+	return hash, fi, err
+}
+
+// Update records the named source's current state and the outputs just built from it.
+func (m *Metadata) Update(name string, outputs []string) error {
+	e, _ := m.Lookup(name)
+	hash, fi, err := m.hash(name, e)
+	if err != nil {
+		return err
+	}
+	outputs = append([]string(nil), outputs...)
+	sort.Strings(outputs)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.files[name] = Entry{Size: fi.Size(), ModTime: fi.ModTime(), Hash: hash, Outputs: outputs}
+	m.dirty = true
+	return nil
+}
+
+// Forget discards what's recorded of the named source.
+func (m *Metadata) Forget(name string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.files[name]; ok {
+		delete(m.files, name)
+		m.dirty = true
+	}
+}
+
+// Record replaces the store's contents with the picture of a complete build,
+// given as a map from each output to the sources it was built from.
+// Every source is updated, and sources the build no longer used are forgotten.
+// Sources which don't exist as files, such as generated inputs, are skipped.
+func (m *Metadata) Record(sources map[string][]string) error {
+	outputsOf := make(map[string][]string)
+	for output, names := range sources {
+		for _, name := range names {
+			outputsOf[name] = append(outputsOf[name], output)
+		}
+	}
+	for _, name := range m.Sources() {
+		if _, ok := outputsOf[name]; !ok {
+			m.Forget(name)
+		}
+	}
+	for name, outputs := range outputsOf {
+		err := m.Update(name, outputs)
+		if os.IsNotExist(err) {
+			m.Forget(name)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Sources answers the names of the sources recorded, sorted.
+func (m *Metadata) Sources() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	names := make([]string, 0, len(m.files))
+	for name := range m.files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Outputs answers the set of outputs built from any recorded source.
+func (m *Metadata) Outputs() map[string]bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	outputs := make(map[string]bool)
+	for _, e := range m.files {
+		for _, output := range e.Outputs {
+			outputs[output] = true
+		}
+	}
+	return outputs
+}
+
+// OutputSources answers, for each output built from any recorded source, the recorded sources it was built from, sorted.
+func (m *Metadata) OutputSources() map[string][]string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	sources := make(map[string][]string)
+	for name, e := range m.files {
+		for _, output := range e.Outputs {
+			sources[output] = append(sources[output], name)
+		}
+	}
+	for _, names := range sources {
+		sort.Strings(names)
+	}
+	return sources
+}
+
+// Save writes the store back to its file, creating the cache directory if necessary.
+// Nothing is written if nothing has changed.
+func (m *Metadata) Save() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.dirty {
+		return nil
+	}
+	raw, err := json.MarshalIndent(m.files, "", " ")
+	if err != nil {
+		return err
+	}
+	err = directory.EnsureDirAll(filepath.Dir(m.filename), 0755)
+	if err != nil {
+		return err
+	}
+	err = directory.WriteFileAtomic(m.filename, raw, 0644)
+	if err == nil {
+		m.dirty = false
+	}
+	return err
+}
@@ -0,0 +1,102 @@
+package buildcache
+
+import (
+	"encoding/json"
+	"github.com/sam-falvo/sitehammer/directory"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// ProgressFilename names the progress record, relative to the cache directory.
+const ProgressFilename = "progress.json"
+
+// Progress records how far a failed build got, so a later build can resume where it left off:
+// the pages rendered successfully, each with the signature of everything it was rendered from, and the pages that failed.
+// A build saves its progress only if it fails, and removes any saved progress once it succeeds.
+// Pages are named by slash-separated paths relative to the output directory.
+// A Progress record is safe to use from several goroutines.
+type Progress struct {
+	filename string
+	mu       sync.Mutex
+	Pages    map[string]string `json:"pages"`
+	Failed   []string          `json:"failed,omitempty"`
+}
+
+// OpenProgress loads the progress record kept in the given cache directory.
+// A missing or unreadable record isn't an error; an empty one results, from which nothing resumes.
+func OpenProgress(cacheDir string) *Progress {
+	p := &Progress{filename: filepath.Join(cacheDir, ProgressFilename), Pages: make(map[string]string)}
+	raw, err := ioutil.ReadFile(p.filename)
+	if err != nil {
+		return p
+	}
+	if json.Unmarshal(raw, p) != nil || p.Pages == nil {
+		p.Pages = make(map[string]string)
+		p.Failed = nil
+	}
+	return p
+}
+
+// Current answers true if the named page was rendered from inputs with the given signature, and so needn't be rendered again.
+func (p *Progress) Current(page, signature string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.Pages[page] == signature
+}
+
+// Record notes that the named page has just been rendered from inputs with the given signature.
+func (p *Progress) Record(page, signature string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.Pages[page] = signature
+}
+
+// Fail notes that the named page failed to render.
+func (p *Progress) Fail(page string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.Pages, page)
+	p.Failed = append(p.Failed, page)
+}
+
+// Counts answers how many pages the record holds as rendered, and how many as failed.
+func (p *Progress) Counts() (rendered, failed int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.Pages), len(p.Failed)
+}
+
+// Reset forgets the pages that failed, ready for a build to retry them and record its own failures.
+func (p *Progress) Reset() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.Failed = nil
+}
+
+// Save writes the progress record back to the cache directory, creating the directory if necessary.
+func (p *Progress) Save() error {
+	p.mu.Lock()
+	sort.Strings(p.Failed)
+	raw, err := json.MarshalIndent(p, "", " ")
+	p.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	err = directory.EnsureDirAll(filepath.Dir(p.filename), 0755)
+	if err != nil {
+		return err
+	}
+	return directory.WriteFileAtomic(p.filename, raw, 0644)
+}
+
+// Remove deletes the saved progress record, if there is one, once a build has succeeded.
+func (p *Progress) Remove() error {
+	err := os.Remove(p.filename)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
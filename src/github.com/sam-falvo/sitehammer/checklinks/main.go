@@ -0,0 +1,152 @@
+/*
+The checklinks command looks for dead external links in a built site.
+
+USAGE: checklinks [-refresh]
+
+Checklinks reads every HTML page in the output directory (./_site unless configured otherwise),
+collects the links leading off the site, and checks each one; see the linkcheck package for how.
+Links to the blog's own base URL aren't external, and aren't checked;
+neither are links beginning with any of the ignore prefixes in the linkcheck table of sitehammer.toml.
+
+Dead links are reported page by page, with the line on which each appears, like so:
+
+	articles/1234/index.html (article 1234):
+	  line 42: http://example.com/gone (404 Not Found)
+
+Results are cached for a week unless configured otherwise, so running checklinks after every build is cheap,
+and polite to the servers linked to.
+The -refresh flag ignores cached results, checking every link anew.
+
+Checklinks exits with status 1 if any dead links were found.
+Building the site doesn't check links; checklinks must be run explicitly.
+*/
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/sam-falvo/sitehammer/buildcache"
+	"github.com/sam-falvo/sitehammer/config"
+	"github.com/sam-falvo/sitehammer/directory"
+	"github.com/sam-falvo/sitehammer/htmlcheck"
+	"github.com/sam-falvo/sitehammer/linkcheck"
+	"github.com/sam-falvo/sitehammer/weblog"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// abend abnormally ends the program, usually as a result of some blocking error.
+// The specified diagnostic is printed before terminating the program.
+// The program stops with shell result code 1.
+func abend(reason error) {
+	if reason != nil {
+		fmt.Println(reason)
+		os.Exit(1)
+	}
+}
+
+// articlePage recognizes the pages of blog articles, capturing the article ID.
+var articlePage = regexp.MustCompile("^" + weblog.ArticleDirName + `/(\d+)/index\.html$`)
+
+// pageLinks collects the external links of every HTML page beneath root, keyed by the page's slash-separated name.
+func pageLinks(root string, isExternal func(string) bool) (map[string][]htmlcheck.Link, error) {
+	links := make(map[string][]htmlcheck.Link)
+	pages := directory.And(directory.IsFile, directory.HasExtension(".html"))
+	err := directory.Walk(root, directory.OnlyWalked(pages, func(name string, fi os.FileInfo) error {
+		content, err := ioutil.ReadFile(filepath.Join(root, filepath.FromSlash(name)))
+		if err != nil {
+			return err
+		}
+		for _, l := range htmlcheck.Links(content) {
+			if isExternal(l.Url) {
+				links[name] = append(links[name], l)
+			}
+		}
+		return nil
+	}))
+	return links, err
+}
+
+// externalTest answers a function telling whether a link leads off the site, and isn't to be ignored.
+func externalTest(cfg *config.Config) func(string) bool {
+	site, _ := url.Parse(cfg.Blog.BaseUrl)
+	return func(link string) bool {
+		u, err := url.Parse(link)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return false
+		}
+		if site != nil && strings.EqualFold(u.Host, site.Host) {
+			return false
+		}
+		for _, prefix := range cfg.LinkCheck.Ignore {
+			if strings.HasPrefix(link, prefix) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// describe names a page for the report, mentioning the article ID for blog articles.
+func describe(page string) string {
+	if m := articlePage.FindStringSubmatch(page); m != nil {
+		return fmt.Sprintf("%s (article %s)", page, m[1])
+	}
+	return page
+}
+
+func main() {
+	cfg, err := config.Load(config.Find())
+	abend(err)
+	buildcache.Dir = cfg.Cache.Dir
+	refresh := flag.Bool("refresh", false, "Checks every link anew, ignoring cached results.")
+	flag.Parse()
+
+	pages, err := pageLinks(cfg.Output.Dir, externalTest(cfg))
+	abend(err)
+	var urls []string
+	for _, links := range pages {
+		for _, l := range links {
+			urls = append(urls, l.Url)
+		}
+	}
+
+	ttl := cfg.LinkCheck.TTL
+	if *refresh {
+		ttl = 0
+	}
+	checker := linkcheck.Open(filepath.Join(buildcache.Dir, linkcheck.CacheFilename), ttl, cfg.LinkCheck.Timeout, cfg.LinkCheck.Workers)
+	results := checker.Check(urls)
+	checker.TTL = cfg.LinkCheck.TTL
+	abend(checker.Save())
+
+	var names []string
+	for name := range pages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	dead := 0
+	for _, name := range names {
+		reported := false
+		for _, l := range pages[name] {
+			r := results[l.Url]
+			if !r.Dead() {
+				continue
+			}
+			if !reported {
+				fmt.Printf("%s:\n", describe(name))
+				reported = true
+			}
+			fmt.Printf("  line %d: %s (%s)\n", l.Line, l.Url, r)
+			dead++
+		}
+	}
+	if dead > 0 {
+		abend(fmt.Errorf("%d dead link(s) found.", dead))
+	}
+}
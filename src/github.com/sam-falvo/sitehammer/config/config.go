@@ -0,0 +1,1184 @@
+/*
+The config package loads a site's SiteHammer configuration.
+
+Configuration lives in a TOML file named sitehammer.toml, usually at the root of the site's source directory.
+The file is optional; every setting has a sensible default.
+Find looks for it in each of these places, in order, taking the first it finds:
+
+	./sitehammer.toml
+	./_config/sitehammer.toml
+	$XDG_CONFIG_HOME/sitehammer/sitehammer.toml, or ~/.config/sitehammer/sitehammer.toml if XDG_CONFIG_HOME isn't set
+
+The last serves settings, such as the author's name, shared by every site on a machine.
+Wherever the file is found, the paths it holds remain relative to the current directory, the site's source directory.
+Below is a sample configuration file:
+
+	[output]
+	dir = "_site"
+	preserve_mtimes = false
+	dedupe = false
+	file_mode = "0644"
+	dir_mode = "0755"
+	manifest = "_manifest.json"
+
+	[cache]
+	dir = ".sitehammer-cache"
+
+	[blog]
+	base_url = "http://www.falvotech.com"
+	descriptors = "src/descs.json"
+	sources = "src"
+	article_template = "templates/blog-article.html"
+	index_template = "templates/blog-index.html"
+	author = "Samuel A. Falvo II"
+	email = "kc5tja@arrl.net"
+	git_history = false
+	json_api = false
+
+	[assets]
+	fingerprint = true
+	minify = true
+
+	[files]
+	symlinks = "follow"
+
+	[compress]
+	gzip = false
+	brotli = false
+	brotli_command = "brotli -c"
+	extensions = [".html", ".css", ".js", ".json", ".xml", ".svg", ".txt"]
+
+	[offline]
+	enabled = false
+	service_worker = "sw.js"
+	asset_manifest = "asset-manifest.json"
+	extensions = [".html", ".css", ".js", ".png", ".jpg", ".jpeg", ".gif", ".svg", ".webp", ".ico"]
+
+	[hosting]
+	hosts = ["netlify", "apache"]
+
+	[hosting.errors]
+	"404" = "/404.html"
+
+	[[hosting.cache_control]]
+	pattern = "*.html"
+	value = "no-cache"
+
+	[[hosting.header]]
+	path = "/*"
+	headers = ["Strict-Transport-Security: max-age=63072000", "Content-Security-Policy: default-src 'self'"]
+
+	[blogroll]
+	file = "_blogroll.json"
+	opml = "blogroll.opml"
+	title = "Blogroll"
+
+	[content_index]
+	enabled = false
+	file = "content-index.json"
+
+	[linkcheck]
+	ttl = "168h"
+	timeout = "10s"
+	workers = 4
+	ignore = ["http://localhost"]
+
+	[proofread]
+	spellchecker = "aspell list"
+	dictionary = "dictionary.txt"
+
+	[[proofread.rule]]
+	pattern = "(?i)\\bvery unique\\b"
+	message = "unique admits no degrees"
+
+	[markdown]
+	layout = "templates/page.html"
+	tables = false
+	strikethrough = false
+	task_lists = false
+	autolinks = false
+	definition_lists = false
+	smart_punctuation = false
+
+	[sass]
+	command = "sass --stdin"
+
+	[asciidoc]
+	command = "asciidoctor --embedded --out-file - -"
+
+	[rst]
+	command = "rst2html5"
+
+	[converters]
+	".tex" = "pandoc --from latex --to html"
+	".docx" = "pandoc --from docx --to html"
+
+	[images]
+	optimize = true
+
+	[images.commands]
+	".jpg" = "jpegtran -copy none -optimize"
+
+	[substitution]
+	files = ["*.css", "*.js", "manifest.webmanifest"]
+	version = "2.1"
+
+	[substitution.vars]
+	cdn = "https://cdn.example.com"
+
+	[hooks]
+	pre = ["npm run css"]
+	post = ["rsync -a _site/ www.example.com:/var/www/"]
+
+	[deploy.production]
+	target = "rsync"
+	destination = "deploy@www.example.com:/var/www/site/"
+	ssh = "ssh -p 2222"
+	exclude = ["/downloads/"]
+	delete = true
+
+	[deploy.pages]
+	target = "github"
+	repository = "git@github.com:example/example.github.io.git"
+	branch = "gh-pages"
+	dir = ""
+	cname = "www.example.com"
+	message = "Publish the site"
+	delete = true
+
+	[deploy.preview]
+	target = "netlify"
+	site = "${NETLIFY_SITE_ID}"
+	draft = true
+
+	[deploy.shared]
+	target = "sftp"
+	destination = "sftp://deploy@www.example.com/~/public_html"
+	delete = true
+
+	[deploy.neocities]
+	delete = true
+
+	[deploy.ipfs]
+	ipns = "blog"
+	dnslink = "www.example.com"
+	zone = "023e105f4ecef8ad9ca31a8372d0c353"
+
+	[deploy.s3]
+	bucket = "www.example.com"
+	region = "us-east-1"
+	prefix = ""
+	endpoint = ""
+	distribution = "E2QWRUHEXAMPLE"
+	delete = false
+
+	[[deploy.s3.cache_control]]
+	pattern = "*.html"
+	value = "max-age=300"
+
+	[[deploy.s3.cache_control]]
+	pattern = "*"
+	value = "max-age=31536000, immutable"
+
+	[[bundle]]
+	name = "theme/site.css"
+	files = ["theme/reset.css", "theme/css.css"]
+
+	[[bundle]]
+	name = "theme/site.js"
+	files = ["theme/jquery.js", "theme/menus.js"]
+
+	[[redirect]]
+	from = "/2012/hello.html"
+	to = "/articles/1234/"
+	status = 301
+
+The output table names the directory into which the site is built.
+When preserve_mtimes is true, each output takes its modification time from its sources rather than the time of the build,
+which helps rsync-based deployments and HTTP caches tell what really changed.
+The file_mode and dir_mode settings give, in octal, the permissions of the files and directories written there.
+If file_mode is left unset, copied files keep their sources' permissions and generated files get 0644;
+dir_mode defaults to 0755.
+Outputs whose content is unchanged are never rewritten, so their modification times stay accurate.
+When dedupe is true, an output byte-for-byte identical to another written in the same build is hard-linked to it rather than written anew;
+the two then share permissions and modification times.
+After a full build, the sitehammer command writes a manifest of every generated file to the file named by manifest,
+relative to the source directory; an empty name disables the manifest.
+See the manifest package for its format.
+
+The cache table names the directory in which SiteHammer keeps what it remembers between builds:
+the build caches of the static pass and precompression, which spare unchanged outputs (optimized images among them) from being built again,
+the metadata store, the record of the git commit last built, the record of a failed build's progress, the link checker's results,
+and the HTML converted from article bodies written in other formats.
+It's relative to the source directory, unless given as an absolute path, and must be neither the source nor the output directory.
+Nothing in it is published, wherever it is; keep it out of version control with an ignore rule, such as /.sitehammer-cache/ in .gitignore.
+Everything in it can be rebuilt, so sitehammer cache clean may remove it at any time.
+
+The blog table describes the blog:
+the URL at which it's published (with no trailing slash),
+the file holding its article descriptors,
+the directory holding each article's abstract and body (in subdirectories named for article IDs),
+and the templates used to render articles and the blog's index page.
+The values shown above are the defaults, except for author and email,
+which name the author of new articles created by sitehammer new post, and are empty unless set.
+The base URL is also the site's: it's substituted for @@base_url@@, as described below,
+and commands building the site let a -base-url flag override it for a single run, such as a preview published elsewhere.
+When git_history is true, and the sources are kept in a git repository, articles' templates see each article's history there,
+such as when it was last changed, and by whom; see the weblog package.
+It takes a single git log per build, but a shallow clone, as CI services often make, holds only part of the history.
+When json_api is true, the blog is published as JSON too, for client-side apps and other tools to read without scraping its pages:
+each article's fields and rendered body as index.json beside its page, and the lists of every article and every tag
+as articles.json and tags.json beside the index page; see the weblog package.
+
+The assets table controls how stylesheets, scripts, and images get published.
+When fingerprint is true, they're published under names carrying a hash of their contents.
+When minify is true (the default), bundles are minified as they're built.
+
+The files table controls how the static pass treats unusual files in the source directory.
+The symlinks setting chooses what happens to symbolic links:
+"follow" (the default) publishes a copy of whatever the link refers to,
+"link" recreates the link itself in the output directory,
+and "skip" ignores symbolic links altogether.
+Special files, such as FIFOs, sockets, and devices, are always skipped with a warning.
+
+The compress table controls precompression, for web servers able to serve precompressed files directly.
+When gzip or brotli is true, each output whose extension is listed gets a compressed variant alongside it,
+such as about.html.gz or about.html.br; see the precompress package.
+Brotli compression runs brotli_command, which must read standard input and write standard output.
+
+The offline table controls the service worker which lets readers browse the site offline.
+When enabled, the sitehammer command writes a service worker and an asset manifest into the output directory,
+under the names given, precaching every output whose extension is listed; see the offline package.
+
+The hosting table names the hosts the site is served by whose configuration files the sitehammer command writes,
+so that the redirect tables take effect as real redirects: netlify, whose _redirects file Netlify and Cloudflare Pages read;
+vercel, whose vercel.json Vercel reads; and apache, whose .htaccess Apache reads, as on classic shared hosting.
+With no hosts named, each redirect is a page refreshing to its destination instead; see the hosting package.
+The .htaccess also names the errors table's pages, by HTTP status, as error documents,
+compresses text as it's served, or serves precompressed variants, if the compress table asks for them,
+and gives the Cache-Control header of the first cache_control rule matching a file's path, if any,
+in the syntax of directory.MatchGlob. Without rules, pages must be revalidated, and fingerprinted assets are cached for a year.
+Each header table lists headers, written as they're sent, like "X-Frame-Options: DENY",
+to be sent with the files whose site paths match its path, in which * matches anything, so /theme/* matches everything beneath theme;
+a file gets the headers of every table matching it. Netlify's are written to a _headers file, which Cloudflare Pages reads too,
+and Vercel's and Apache's to their files, so that header policies, such as content security policies, live with the site.
+
+The blogroll table names the data file listing the blogroll's links, from which templates render the blogroll, wherever they like,
+and the sitehammer command publishes an OPML file of the same links, under the name opml, unless that's empty, titled title;
+without a data file, there's no blogroll. See the blogroll package.
+
+The content_index table controls the content index: when enabled, the sitehammer command describes every page, article, and tag,
+with their titles, dates, and the links between them, in a single compact JSON file, under the name given,
+for search services, recommendation scripts, and other tools to query; see the contentindex package.
+
+The linkcheck table controls the linkcheck command, which looks for dead external links.
+Results are cached for ttl, so links aren't rechecked on every run;
+each server gets timeout to answer; and at most workers links are checked at once.
+Links beginning with any of the ignore prefixes aren't checked.
+
+The proofread table controls the proof command, which checks the spelling and style of blog articles.
+The spellchecker must read text on standard input and list unrecognized words on standard output;
+an empty spellchecker skips spelling checks.
+The dictionary file lists, one per line, words the spellchecker doesn't know but the site uses anyway.
+Each rule gives a regular expression matching text to avoid, and a message explaining why.
+
+The markdown table names the template into which Markdown files are poured as they're converted to HTML.
+With no layout, a Markdown file is published as a bare HTML fragment.
+The other markdown settings turn on extensions to the Markdown dialect, since content written for, or imported from,
+other systems relies on their extensions: GitHub's tables, strikethrough, task lists, and autolinks of bare URLs,
+PHP Markdown Extra's definition lists, and SmartyPants' smart punctuation.
+All are off unless set; see Extensions in the markdown package.
+They apply to Markdown pages, and to the Markdown the import command converts.
+The sass table names the command that compiles Sass stylesheets; it must read the stylesheet from standard input
+and write CSS to standard output, as the reference sass command does when given --stdin (the default).
+The asciidoc table names the command that converts the bodies of blog articles written in AsciiDoc (body.adoc) into HTML;
+it must read AsciiDoc from standard input and write an HTML fragment, with no header or footer, to standard output,
+as asciidoctor does when given --embedded (the default).
+The rst table likewise names the command that converts bodies written in reStructuredText (body.rst),
+by default the rst2html5 command of Python's docutils; Sphinx's own directives and roles aren't understood by docutils alone.
+Either command may write a whole HTML document instead, in which case only the content of its body element is kept.
+The converters table names, by extension, commands converting bodies written in any other format, such as body.tex,
+so that whatever pandoc or another tool understands may become an article's body without waiting for SiteHammer to understand it.
+Each command reads the body on standard input and writes HTML on standard output, as the asciidoc and rst commands do,
+and its conversions are cached alike; a converter named for .adoc, .rst, .org, or .txt replaces the built-in conversion.
+
+The images table controls image optimization.
+When optimize is true, PNG, JPEG, and SVG images are shrunk losslessly as they're published; see the imageopt package.
+The commands table names external optimizers to use instead, by extension;
+each must read an image on standard input and write the optimized image on standard output.
+Optimized images are remembered by the build cache like any other output, so unchanged images aren't optimized again.
+
+The substitution table lets text assets carry values that differ from one build environment to another.
+In the source files matching any of the files patterns, references like @@base_url@@ are replaced as the files are published,
+whether on their own or as part of a bundle.
+Patterns are matched against paths relative to the source directory, as the directory package's MatchGlob does:
+a pattern without slashes, like *.css, matches files at any depth, while a path component of ** matches any number of directories.
+The variables are base_url, the blog's base URL; version, as given; build_date, the date of the build, like 2024-03-01;
+and those of the vars table, which may override the rest.
+Referring to an undefined variable is an error.
+
+The hooks table lists shell commands the build command runs before building (pre) and after a successful build (post).
+A failing hook fails the build; see the hooks package.
+
+The deploy table holds deployment profiles, each a table describing a place the deploy command publishes the built site,
+so that sitehammer deploy production publishes it as the production profile says.
+A profile's target names the kind of place: s3, an Amazon S3 bucket; rsync, a directory reached by rsync;
+github, a branch of a git repository published by GitHub Pages; netlify, a site hosted by Netlify;
+neocities, a site hosted by Neocities; sftp or ftp, a directory on a host reached by SFTP or plain FTP, as shared hosting often offers;
+or ipfs, IPFS, through a node's API;
+a profile with no target is taken to name its own, so the neocities, ipfs, and s3 profiles above deploy to Neocities, IPFS, and S3.
+When delete is true, published files which the build no longer produces are deleted; otherwise, they're left alone.
+
+An s3 profile names the bucket the output directory is copied into, the AWS region holding it (us-east-1 unless set),
+and a prefix placing the site beneath a key prefix of the bucket, such as "blog/", rather than at its root.
+An endpoint, such as http://localhost:9000, names an S3-compatible service to use in Amazon's place.
+If distribution names a CloudFront distribution, the paths of objects changed or deleted are invalidated in it;
+its origin is assumed to serve the bucket from the prefix.
+Each cache_control rule gives the Cache-Control header of objects whose paths match its pattern,
+in the syntax of directory.MatchGlob; the first rule matching wins, and objects matching none get no header.
+Credentials come from the environment, as they do for Amazon's own tools:
+AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY, and, for temporary credentials, AWS_SESSION_TOKEN.
+
+An rsync profile names the destination the output directory's content is copied into, in rsync's syntax,
+usually a directory on a remote host reached over SSH, like user@host:/var/www/site/.
+The ssh setting, if not empty, gives the command rsync reaches the host with, such as one naming a port or identity file.
+Files matching any of the exclude patterns, in rsync's syntax, are neither copied nor, with delete, deleted,
+so a pattern like /downloads/ protects a directory which exists only at the destination.
+
+A github profile names the git repository, usually on GitHub, whose branch GitHub Pages publishes;
+if it names none, it's the one the origin remote of the current directory's repository refers to.
+The site is committed to the branch, gh-pages unless set, which is begun if it doesn't exist yet, and pushed.
+The dir setting, such as docs, puts the site in that directory of the branch rather than at its root,
+for repositories publishing from their main branch's docs directory; the rest of the branch is left alone.
+A .nojekyll file is added beside the site, so GitHub serves it as it is, along with a CNAME file naming the domain it's served at:
+cname, or else the host of the base URL, unless that's a github.io or local host.
+The commit's message is message, or else one giving the time the site was built.
+
+A netlify profile names the Netlify site deployed to by its ID, or else by the NETLIFY_SITE_ID environment variable;
+the NETLIFY_AUTH_TOKEN environment variable gives the access token, as it does for Netlify's own command.
+Only the files whose content Netlify lacks are uploaded, and files the build no longer produces are never published, whatever delete says.
+When draft is true, each deploy is a draft, published at an address of its own for preview, rather than replacing the live site.
+An endpoint names an API to use in place of Netlify's own.
+
+A neocities profile needs nothing more: the NEOCITIES_API_KEY environment variable gives the API key, which tells which site is deployed to.
+Only the files whose content Neocities lacks are uploaded, leaving out precompressed variants, which Neocities refuses;
+an endpoint names an API to use in place of Neocities' own.
+
+An sftp or ftp profile names its destination by a URL, like sftp://user@host:port/path or ftp://user@host/path.
+An sftp path is absolute unless it begins with /~/, making it relative to the user's home directory,
+while an ftp path is relative to the login directory unless it begins with two slashes.
+SFTP runs the sftp command, which must log in with a key rather than a password;
+FTP logs in with the password the FTP_PASSWORD environment variable gives, or anonymously, if the URL names no user.
+A record of the manifest deployed, .sitehammer-deployed.json, is kept at the destination,
+so that only files changed since the last deployment are uploaded, and, with delete, only files deployed before are deleted.
+
+An ipfs profile adds the site to IPFS, as a directory pinned by the node whose API the endpoint names, or else by a local node, at http://127.0.0.1:5001;
+IPFS addresses content, so there's nothing to delete, whatever delete says, and the deploy command gives the directory's CID.
+If ipns names a key of the node, such as self, the key's IPNS name is published as the directory, so it always leads to the latest deployment.
+If dnslink names a domain, its DNSLink record, a TXT record of _dnslink beneath it, is pointed at the directory,
+through Cloudflare, which must hold the domain in the zone whose ID zone gives, with the API token the CLOUDFLARE_API_TOKEN environment variable gives.
+
+A subdirectory of the source directory is published only if it holds a directory configuration file, named _config.toml,
+whose settings override those of sitehammer.toml for everything beneath the subdirectory;
+its own subdirectories are published too, and may hold directory configurations of their own.
+Only the markdown, sass, images, and substitution tables may be overridden; the rest describe the whole site.
+An empty _config.toml simply publishes the subdirectory.
+
+Each bundle table declares one bundle:
+a single output file, called name, holding the concatenation of the listed files, in order.
+Templates refer to a bundle by its name, through the Asset function.
+
+Each redirect table redirects requests for the site path from, such as an article's old address, to the path or URL to,
+with the given status, 301, a permanent redirect, unless set; 302, 303, 307, and 308 are allowed too.
+
+Any string setting may refer to environment variables, which are expanded as the configuration loads,
+so that secrets, like deployment credentials, and values varying between environments, like base URLs,
+needn't be written into the file itself.
+A reference like ${DEPLOY_TOKEN} requires the variable be set; loading the configuration fails otherwise.
+A reference like ${BASE_URL:-http://localhost:8000} supplies a fallback, used if the variable is unset or empty.
+Write $$ for a literal dollar sign before a brace; a dollar sign followed by anything else stands for itself,
+so hook commands may still refer to shell variables as $HOME.
+
+The names of generated files, whether bundles, service workers, or asset manifests, are relative to the output directory,
+and may not climb out of it with .. components; a configuration breaking this rule is rejected.
+*/
+package config
+
+import (
+	"fmt"
+	"github.com/sam-falvo/sitehammer/directory"
+	"github.com/sam-falvo/sitehammer/markdown"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Filename names the configuration file, relative to the site's source directory.
+const Filename = "sitehammer.toml"
+
+// SearchPath answers the files Find looks for a configuration in, in the order it looks.
+func SearchPath() []string {
+	path := []string{Filename, filepath.Join("_config", Filename)}
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		if home, err := os.UserHomeDir(); err == nil {
+			dir = filepath.Join(home, ".config")
+		}
+	}
+	if dir != "" {
+		path = append(path, filepath.Join(dir, "sitehammer", Filename))
+	}
+	return path
+}
+
+// Find answers the first file on the search path that exists, or Filename if none does,
+// in which case Load answers the default configuration.
+func Find() string {
+	for _, name := range SearchPath() {
+		if _, err := os.Stat(name); err == nil {
+			return name
+		}
+	}
+	return Filename
+}
+
+// Config holds a site's complete configuration.
+type Config struct {
+	Output       Output       `toml:"output"`
+	Cache        Cache        `toml:"cache"`
+	Blog         Blog         `toml:"blog"`
+	Assets       Assets       `toml:"assets"`
+	Files        Files        `toml:"files"`
+	Compress     Compress     `toml:"compress"`
+	Offline      Offline      `toml:"offline"`
+	Hosting      Hosting      `toml:"hosting"`
+	Blogroll     Blogroll     `toml:"blogroll"`
+	ContentIndex ContentIndex `toml:"content_index"`
+	LinkCheck    LinkCheck    `toml:"linkcheck"`
+	Proofread    Proofread    `toml:"proofread"`
+	Markdown     Markdown     `toml:"markdown"`
+	Sass         Sass         `toml:"sass"`
+	AsciiDoc     AsciiDoc     `toml:"asciidoc"`
+	Rst          Rst          `toml:"rst"`
+	Converters   Converters   `toml:"converters"`
+	Images       Images       `toml:"images"`
+	Substitution Substitution `toml:"substitution"`
+	Hooks        Hooks        `toml:"hooks"`
+	Deploy       Deploy       `toml:"deploy"`
+	Bundles      []Bundle     `toml:"bundle"`
+	Redirects    []Redirect   `toml:"redirect"`
+
+	// refs records, by name, the settings of the configuration file written with references to environment variables, for Encode.
+	refs map[string]reference
+}
+
+// Output describes where the site is built.
+type Output struct {
+	Dir            string `toml:"dir"`
+	PreserveMtimes bool   `toml:"preserve_mtimes"`
+	FileMode       string `toml:"file_mode"`
+	DirMode        string `toml:"dir_mode"`
+	Manifest       string `toml:"manifest"`
+	Dedupe         bool   `toml:"dedupe"`
+}
+
+// Cache names the directory holding SiteHammer's caches.
+type Cache struct {
+	Dir string `toml:"dir"`
+}
+
+// FilePerm answers the permissions for an output file, or fallback if none are configured.
+func (o Output) FilePerm(fallback os.FileMode) os.FileMode {
+	if o.FileMode == "" {
+		return fallback.Perm()
+	}
+	mode, _ := parseMode(o.FileMode)
+	return mode
+}
+
+// DirPerm answers the permissions for an output directory.
+func (o Output) DirPerm() os.FileMode {
+	if o.DirMode == "" {
+		return 0755
+	}
+	mode, _ := parseMode(o.DirMode)
+	return mode
+}
+
+// parseMode interprets an octal permission string, such as "0644".
+func parseMode(s string) (os.FileMode, error) {
+	mode, err := strconv.ParseUint(s, 8, 32)
+	if err != nil || mode&^0777 != 0 {
+		return 0, fmt.Errorf("Permissions must be given in octal, between 0000 and 0777, not %q.", s)
+	}
+	return os.FileMode(mode), nil
+}
+
+// Blog describes the blog's location on the web and the whereabouts of its inputs.
+type Blog struct {
+	BaseUrl         string `toml:"base_url"`
+	Descriptors     string `toml:"descriptors"`
+	Sources         string `toml:"sources"`
+	ArticleTemplate string `toml:"article_template"`
+	IndexTemplate   string `toml:"index_template"`
+	Author          string `toml:"author"`
+	Email           string `toml:"email"`
+	GitHistory      bool   `toml:"git_history"`
+	JsonApi         bool   `toml:"json_api"`
+}
+
+// SetBaseUrl overrides the blog's base URL, which must be absolute, like https://example.com/blog.
+// Any trailing slash is dropped.
+func (c *Config) SetBaseUrl(u string) error {
+	parsed, err := url.Parse(u)
+	if err != nil || parsed.Scheme == "" || parsed.Host == "" {
+		return fmt.Errorf("Base URL %q must be absolute, like https://example.com.", u)
+	}
+	c.Blog.BaseUrl = strings.TrimSuffix(u, "/")
+	return nil
+}
+
+// BaseUrlValue lets a command-line flag override the blog's base URL, by way of SetBaseUrl;
+// it satisfies the flag.Value interface.
+type BaseUrlValue struct {
+	Config *Config
+}
+
+// String answers the base URL in effect.
+func (v BaseUrlValue) String() string {
+	if v.Config == nil {
+		return ""
+	}
+	return v.Config.Blog.BaseUrl
+}
+
+// Set overrides the base URL.
+func (v BaseUrlValue) Set(u string) error {
+	return v.Config.SetBaseUrl(u)
+}
+
+// Assets controls the treatment of stylesheets, scripts, and images.
+type Assets struct {
+	Fingerprint bool `toml:"fingerprint"`
+	Minify      bool `toml:"minify"`
+}
+
+// Policies for handling symbolic links found in the source directory.
+const (
+	SymlinksFollow = "follow"
+	SymlinksLink   = "link"
+	SymlinksSkip   = "skip"
+)
+
+// Files controls the treatment of unusual files in the source directory.
+// Symlinks holds one of the Symlinks policy constants.
+type Files struct {
+	Symlinks string `toml:"symlinks"`
+}
+
+// Compress controls the precompressed variants written alongside outputs.
+// Extensions lists, with leading dots, the extensions of outputs worth compressing.
+type Compress struct {
+	Gzip          bool     `toml:"gzip"`
+	Brotli        bool     `toml:"brotli"`
+	BrotliCommand string   `toml:"brotli_command"`
+	Extensions    []string `toml:"extensions"`
+}
+
+// Offline controls the service worker and asset manifest generated for offline reading.
+// ServiceWorker and AssetManifest name the generated files, relative to the output directory.
+// Extensions lists, with leading dots, the extensions of outputs worth precaching.
+type Offline struct {
+	Enabled       bool     `toml:"enabled"`
+	ServiceWorker string   `toml:"service_worker"`
+	AssetManifest string   `toml:"asset_manifest"`
+	Extensions    []string `toml:"extensions"`
+}
+
+// Hosting names the Hosts serving the site whose configuration files are generated, each one of the Host constants.
+// Errors maps HTTP statuses, such as "404", to the site paths of the pages served with them, and CacheControl lists rules
+// giving the Cache-Control headers of files, the first rule matching a file's path applying, for hosts whose files say so.
+// Headers lists the headers sent with files, by path.
+type Hosting struct {
+	Hosts        []string          `toml:"hosts"`
+	Errors       map[string]string `toml:"errors"`
+	CacheControl []CacheRule       `toml:"cache_control"`
+	Headers      []HeaderRule      `toml:"header"`
+}
+
+// Blogroll names the File listing the blogroll's links, relative to the source directory, and the Opml file published from it,
+// relative to the output directory, if not empty, with the given Title.
+type Blogroll struct {
+	File  string `toml:"file"`
+	Opml  string `toml:"opml"`
+	Title string `toml:"title"`
+}
+
+// ContentIndex controls the content index describing the built site, which is written to File, relative to the output directory, if Enabled.
+type ContentIndex struct {
+	Enabled bool   `toml:"enabled"`
+	File    string `toml:"file"`
+}
+
+// HeaderRule gives the Headers, each written as "Name: value", sent with files whose site paths match Path, in which * matches anything.
+type HeaderRule struct {
+	Path    string   `toml:"path"`
+	Headers []string `toml:"headers"`
+}
+
+// ParseHeader answers the name and value of the header h, written as "Name: value", and true, or false if it's malformed.
+func ParseHeader(h string) (name, value string, ok bool) {
+	i := strings.IndexByte(h, ':')
+	if i <= 0 {
+		return "", "", false
+	}
+	name, value = h[:i], strings.TrimSpace(h[i+1:])
+	if strings.ContainsAny(name, " \t\"()<>@,;\\/[]?={}") || strings.ContainsAny(value, "\r\n") || value == "" {
+		return "", "", false
+	}
+	return name, value, true
+}
+
+// Hosts whose configuration files may be generated.
+const (
+	HostNetlify = "netlify"
+	HostVercel  = "vercel"
+	HostApache  = "apache"
+)
+
+// LinkCheck controls the checking of external links.
+type LinkCheck struct {
+	TTL     time.Duration `toml:"ttl"`
+	Timeout time.Duration `toml:"timeout"`
+	Workers int           `toml:"workers"`
+	Ignore  []string      `toml:"ignore"`
+}
+
+// Proofread controls the checking of articles' spelling and style.
+// Dictionary names the site's custom dictionary, relative to the source directory.
+type Proofread struct {
+	Spellchecker string      `toml:"spellchecker"`
+	Dictionary   string      `toml:"dictionary"`
+	Rules        []StyleRule `toml:"rule"`
+}
+
+// StyleRule describes a style rule: text matching the regular expression Pattern is reported with Message.
+type StyleRule struct {
+	Pattern string `toml:"pattern"`
+	Message string `toml:"message"`
+}
+
+// Markdown controls the conversion of Markdown files into HTML pages.
+// Layout names the template each converted page is rendered through, relative to the source directory; if empty, no layout is applied.
+// The rest turn on extensions to the Markdown dialect.
+type Markdown struct {
+	Layout           string `toml:"layout"`
+	Tables           bool   `toml:"tables"`
+	Strikethrough    bool   `toml:"strikethrough"`
+	TaskLists        bool   `toml:"task_lists"`
+	Autolinks        bool   `toml:"autolinks"`
+	DefinitionLists  bool   `toml:"definition_lists"`
+	SmartPunctuation bool   `toml:"smart_punctuation"`
+}
+
+// Extensions answers the extensions to the Markdown dialect turned on.
+func (m Markdown) Extensions() markdown.Extensions {
+	return markdown.Extensions{
+		Tables:           m.Tables,
+		Strikethrough:    m.Strikethrough,
+		TaskLists:        m.TaskLists,
+		Autolinks:        m.Autolinks,
+		DefinitionLists:  m.DefinitionLists,
+		SmartPunctuation: m.SmartPunctuation,
+	}
+}
+
+// Sass controls the compilation of Sass stylesheets.
+// Command names the compiler and any arguments it needs to read a stylesheet from standard input.
+type Sass struct {
+	Command string `toml:"command"`
+}
+
+// AsciiDoc controls the conversion of articles' bodies written in AsciiDoc.
+// Command names the converter and any arguments it needs to read AsciiDoc from standard input and write embeddable HTML.
+type AsciiDoc struct {
+	Command string `toml:"command"`
+}
+
+// Rst controls the conversion of articles' bodies written in reStructuredText.
+// Command names the converter and any arguments it needs to read reStructuredText from standard input and write HTML.
+type Rst struct {
+	Command string `toml:"command"`
+}
+
+// Converters maps extensions, such as ".tex", to the commands converting articles' bodies written in those formats into HTML.
+type Converters map[string]string
+
+// Images controls the optimization of published images.
+// Commands maps extensions, such as ".png", to external optimizers used in place of the built-in ones.
+type Images struct {
+	Optimize bool              `toml:"optimize"`
+	Commands map[string]string `toml:"commands"`
+}
+
+// Substitution controls the substitution of build variables into text assets.
+// Files lists glob patterns, in the syntax of directory.MatchGlob, naming the source files subject to substitution.
+// Version supplies the version variable; Vars supplies any others.
+type Substitution struct {
+	Files   []string          `toml:"files"`
+	Version string            `toml:"version"`
+	Vars    map[string]string `toml:"vars"`
+}
+
+// Hooks lists the commands run before and after a build.
+type Hooks struct {
+	Pre  []string `toml:"pre"`
+	Post []string `toml:"post"`
+}
+
+// Deploy maps the names of deployment profiles to the profiles themselves.
+type Deploy map[string]DeployProfile
+
+// Targets of deployment, as deployment profiles name them.
+const (
+	TargetS3        = "s3"
+	TargetRsync     = "rsync"
+	TargetGitHub    = "github"
+	TargetNetlify   = "netlify"
+	TargetNeocities = "neocities"
+	TargetSftp      = "sftp"
+	TargetFtp       = "ftp"
+	TargetIpfs      = "ipfs"
+)
+
+// Profile answers the named deployment profile, if there is one, with its target filled in:
+// a profile naming no target is taken to name its own.
+func (d Deploy) Profile(name string) (DeployProfile, bool) {
+	p, ok := d[name]
+	if ok && p.Target == "" {
+		p.Target = name
+	}
+	return p, ok
+}
+
+// DeployProfile describes a place the deploy command publishes the site: Target names the kind of place, one of the Target constants.
+// Delete deletes published files the build no longer produces.
+//
+// S3 profiles publish to the Amazon S3 bucket Bucket, in Region, invalidating the paths changed in the CloudFront distribution Distribution, if any.
+// Prefix places the site beneath a key prefix of the bucket; Endpoint, if not empty, names an S3-compatible service to use instead of Amazon's.
+// CacheControl lists rules giving the Cache-Control headers of objects, the first rule matching an object's path applying.
+//
+// Rsync profiles publish to Destination, in rsync's syntax, reached with the Ssh command, if any; files matching Exclude's patterns are left alone.
+//
+// GitHub profiles publish to GitHub Pages, committing the site to Branch of the git repository Repository, beneath Dir, with Message;
+// Cname gives the domain written to the CNAME file, if the base URL's isn't right.
+//
+// Netlify profiles publish to the Netlify site Site, through the API at Endpoint, if not empty, as a draft if Draft is true.
+//
+// Neocities profiles publish to the Neocities site the environment's API key belongs to, through the API at Endpoint, if not empty.
+//
+// SFTP and FTP profiles publish to Destination, an sftp or ftp URL.
+//
+// IPFS profiles add the site to IPFS through the API of the node at Endpoint, if not empty, or a local node otherwise,
+// publishing it as the IPNS key Ipns, if not empty, and pointing the DNSLink record of the domain Dnslink, if not empty, at it,
+// through Cloudflare, which holds the record in the zone whose ID is Zone.
+type DeployProfile struct {
+	Target       string      `toml:"target"`
+	Delete       bool        `toml:"delete"`
+	Bucket       string      `toml:"bucket"`
+	Region       string      `toml:"region"`
+	Prefix       string      `toml:"prefix"`
+	Endpoint     string      `toml:"endpoint"`
+	Distribution string      `toml:"distribution"`
+	CacheControl []CacheRule `toml:"cache_control"`
+	Destination  string      `toml:"destination"`
+	Ssh          string      `toml:"ssh"`
+	Exclude      []string    `toml:"exclude"`
+	Repository   string      `toml:"repository"`
+	Branch       string      `toml:"branch"`
+	Dir          string      `toml:"dir"`
+	Cname        string      `toml:"cname"`
+	Message      string      `toml:"message"`
+	Site         string      `toml:"site"`
+	Draft        bool        `toml:"draft"`
+	Ipns         string      `toml:"ipns"`
+	Dnslink      string      `toml:"dnslink"`
+	Zone         string      `toml:"zone"`
+}
+
+// CacheRule gives the Cache-Control header Value of files whose paths match Pattern, in the syntax of directory.MatchGlob.
+type CacheRule struct {
+	Pattern string `toml:"pattern"`
+	Value   string `toml:"value"`
+}
+
+// Bundle describes a single CSS or JavaScript bundle.
+// Name gives the bundle's path relative to the site root; its extension decides how it's minified.
+// Files lists the bundle's constituent source files, in the order they're concatenated.
+type Bundle struct {
+	Name  string   `toml:"name"`
+	Files []string `toml:"files"`
+}
+
+// Redirect redirects requests for the site path From to To, a site path or URL, with the HTTP status Status, if it's not 0.
+type Redirect struct {
+	From   string `toml:"from"`
+	To     string `toml:"to"`
+	Status int    `toml:"status"`
+}
+
+// StatusCode answers the redirect's HTTP status, 301 unless it gives another.
+func (r Redirect) StatusCode() int {
+	if r.Status == 0 {
+		return 301
+	}
+	return r.Status
+}
+
+// Page answers the name of the page standing in for the redirect, where no host redirects it:
+// the index.html of the directory its path names, unless the path names a file with an extension.
+func (r Redirect) Page() string {
+	name := strings.TrimPrefix(r.From, "/")
+	if name == "" || strings.HasSuffix(name, "/") || path.Ext(name) == "" {
+		return strings.TrimSuffix(name, "/") + "/index.html"
+	}
+	return name
+}
+
+// Default answers the configuration used when no configuration file exists.
+func Default() *Config {
+	return &Config{
+		Output: Output{
+			Dir:      "_site",
+			DirMode:  "0755",
+			Manifest: "_manifest.json",
+		},
+		Cache: Cache{
+			Dir: ".sitehammer-cache",
+		},
+		Blog: Blog{
+			BaseUrl:         "http://www.falvotech.com",
+			Descriptors:     "src/descs.json",
+			Sources:         "src",
+			ArticleTemplate: "templates/blog-article.html",
+			IndexTemplate:   "templates/blog-index.html",
+		},
+		Assets: Assets{
+			Minify: true,
+		},
+		Files: Files{
+			Symlinks: SymlinksFollow,
+		},
+		Compress: Compress{
+			BrotliCommand: "brotli -c",
+			Extensions:    []string{".html", ".css", ".js", ".json", ".xml", ".svg", ".txt"},
+		},
+		Offline: Offline{
+			ServiceWorker: "sw.js",
+			AssetManifest: "asset-manifest.json",
+			Extensions:    []string{".html", ".css", ".js", ".png", ".jpg", ".jpeg", ".gif", ".svg", ".webp", ".ico"},
+		},
+		Blogroll: Blogroll{
+			File:  "_blogroll.json",
+			Opml:  "blogroll.opml",
+			Title: "Blogroll",
+		},
+		ContentIndex: ContentIndex{
+			File: "content-index.json",
+		},
+		LinkCheck: LinkCheck{
+			TTL:     7 * 24 * time.Hour,
+			Timeout: 10 * time.Second,
+			Workers: 4,
+		},
+		Proofread: Proofread{
+			Spellchecker: "aspell list",
+			Dictionary:   "dictionary.txt",
+		},
+		Sass: Sass{
+			Command: "sass --stdin",
+		},
+		AsciiDoc: AsciiDoc{
+			Command: "asciidoctor --embedded --out-file - -",
+		},
+		Rst: Rst{
+			Command: "rst2html5",
+		},
+	}
+}
+
+// Load reads the named configuration file, applying its settings over the defaults.
+// A missing file isn't an error; the default configuration results.
+func Load(filename string) (*Config, error) {
+	c := Default()
+	raw, err := ioutil.ReadFile(filename)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return c, nil
+		}
+		return nil, err
+	}
+	tree, err := parse(string(raw))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", filename, err.Error())
+	}
+	c.refs = make(map[string]reference)
+	err = decode(tree, c, c.refs)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", filename, err.Error())
+	}
+	return c, c.validate()
+}
+
+// validate performs a sanity check over the configuration.
+func (c *Config) validate() error {
+	if len(c.Output.Dir) == 0 {
+		return fmt.Errorf("The output directory must be named.")
+	}
+	switch cache := filepath.Clean(c.Cache.Dir); {
+	case len(c.Cache.Dir) == 0:
+		return fmt.Errorf("The cache directory must be named.")
+	case cache == "." || cache == ".." || cache == filepath.Dir(cache) || cache == filepath.Clean(c.Output.Dir):
+		return fmt.Errorf("The cache directory %q would hold more than caches; name a directory of its own.", c.Cache.Dir)
+	}
+	for _, mode := range []string{c.Output.FileMode, c.Output.DirMode} {
+		if mode == "" {
+			continue
+		}
+		if _, err := parseMode(mode); err != nil {
+			return err
+		}
+	}
+	err := ValidateSymlinkPolicy(c.Files.Symlinks)
+	if err != nil {
+		return err
+	}
+	if c.Offline.Enabled {
+		if c.Offline.ServiceWorker == "" || c.Offline.AssetManifest == "" {
+			return fmt.Errorf("The service worker and asset manifest must be named.")
+		}
+		for _, name := range []string{c.Offline.ServiceWorker, c.Offline.AssetManifest} {
+			if err := CheckOutputName(name); err != nil {
+				return err
+			}
+		}
+	}
+	if c.Blogroll.Opml != "" {
+		if err := CheckOutputName(c.Blogroll.Opml); err != nil {
+			return err
+		}
+	}
+	if c.ContentIndex.Enabled {
+		if c.ContentIndex.File == "" {
+			return fmt.Errorf("The content index must be named.")
+		}
+		if err := CheckOutputName(c.ContentIndex.File); err != nil {
+			return err
+		}
+	}
+	for i, r := range c.Proofread.Rules {
+		if _, err := regexp.Compile(r.Pattern); err != nil {
+			return fmt.Errorf("Style rule %d has a bad pattern: %s", i+1, err.Error())
+		}
+	}
+	for _, pattern := range c.Substitution.Files {
+		if _, err := directory.MatchGlob(pattern, ""); err != nil {
+			return fmt.Errorf("Substitution pattern %q is malformed.", pattern)
+		}
+	}
+	for ext, command := range c.Converters {
+		if !strings.HasPrefix(ext, ".") || len(ext) < 2 || strings.ContainsAny(ext, `/\`) {
+			return fmt.Errorf("Converter extension %q must be an extension, like \".tex\".", ext)
+		}
+		if len(strings.Fields(command)) == 0 {
+			return fmt.Errorf("The converter for %s names no command.", ext)
+		}
+	}
+	for name := range c.Deploy {
+		if err := c.validateProfile(name); err != nil {
+			return err
+		}
+	}
+	for i, b := range c.Bundles {
+		if len(b.Name) == 0 {
+			return fmt.Errorf("Bundle %d has no name.", i+1)
+		}
+		if len(b.Files) == 0 {
+			return fmt.Errorf("Bundle %s lists no files.", b.Name)
+		}
+		if err := CheckOutputName(b.Name); err != nil {
+			return err
+		}
+	}
+	for _, host := range c.Hosting.Hosts {
+		if host != HostNetlify && host != HostVercel && host != HostApache {
+			return fmt.Errorf("The hosting table names host %q; it must be %s, %s, or %s.", host, HostNetlify, HostVercel, HostApache)
+		}
+	}
+	for status, page := range c.Hosting.Errors {
+		if code, err := strconv.Atoi(status); err != nil || code < 400 || code > 599 {
+			return fmt.Errorf("The hosting table's errors give a page for %q, which isn't an HTTP error status.", status)
+		}
+		if !strings.HasPrefix(page, "/") || strings.ContainsAny(page, " \t") {
+			return fmt.Errorf("The hosting table's page for error %s must be a site path, like /404.html, but is %q.", status, page)
+		}
+	}
+	for i, r := range c.Hosting.CacheControl {
+		if _, err := directory.MatchGlob(r.Pattern, ""); err != nil || r.Pattern == "" {
+			return fmt.Errorf("Cache-Control rule %d of the hosting table has a malformed pattern, %q.", i+1, r.Pattern)
+		}
+		if r.Value == "" || strings.ContainsAny(r.Value, "\"\n") {
+			return fmt.Errorf("Cache-Control rule %d of the hosting table gives no value, or one holding quotes.", i+1)
+		}
+	}
+	for i, r := range c.Hosting.Headers {
+		if !strings.HasPrefix(r.Path, "/") || strings.ContainsAny(r.Path, " \t#?") {
+			return fmt.Errorf("Header table %d of the hosting table must give a site path, like /theme/*, but gives %q.", i+1, r.Path)
+		}
+		if len(r.Headers) == 0 {
+			return fmt.Errorf("Header table %d of the hosting table, for %s, lists no headers.", i+1, r.Path)
+		}
+		for _, h := range r.Headers {
+			if _, _, ok := ParseHeader(h); !ok {
+				return fmt.Errorf("Header table %d of the hosting table, for %s, lists %q, which isn't written like \"Name: value\".", i+1, r.Path, h)
+			}
+		}
+	}
+	from := make(map[string]bool)
+	for i, r := range c.Redirects {
+		switch {
+		case !strings.HasPrefix(r.From, "/") || strings.ContainsAny(r.From, "*?#: \t"):
+			return fmt.Errorf("Redirect %d must be from a site path, like /2012/hello.html, without wildcards, but is from %q.", i+1, r.From)
+		case r.To == "" || strings.ContainsAny(r.To, " \t"):
+			return fmt.Errorf("Redirect %d, from %s, must be to a path or URL, but is to %q.", i+1, r.From, r.To)
+		case from[r.From]:
+			return fmt.Errorf("Redirect %d, from %s, repeats an earlier redirect from the same path.", i+1, r.From)
+		}
+		switch r.StatusCode() {
+		case 301, 302, 303, 307, 308:
+		default:
+			return fmt.Errorf("Redirect %d, from %s, gives status %d, which isn't a redirect.", i+1, r.From, r.Status)
+		}
+		if err := CheckOutputName(r.Page()); err != nil {
+			return err
+		}
+		from[r.From] = true
+	}
+	return nil
+}
+
+// validateProfile performs a sanity check over the named deployment profile.
+func (c *Config) validateProfile(name string) error {
+	p, _ := c.Deploy.Profile(name)
+	switch p.Target {
+	case TargetS3, TargetRsync, TargetGitHub, TargetNetlify, TargetNeocities:
+	case TargetIpfs:
+		if p.Dnslink != "" && p.Zone == "" {
+			return fmt.Errorf("Deploy profile %s names the DNSLink domain %s, but not the zone holding it.", name, p.Dnslink)
+		}
+	case TargetSftp, TargetFtp:
+		if u, err := url.Parse(p.Destination); p.Destination != "" && (err != nil || u.Scheme != p.Target || u.Host == "") {
+			return fmt.Errorf("The destination %q of deploy profile %s must be an %s URL, like %s://user@host/path.", p.Destination, name, p.Target, p.Target)
+		}
+	default:
+		return fmt.Errorf("Deploy profile %s names target %q; it must be %s, %s, %s, %s, %s, %s, %s, or %s.",
+			name, p.Target, TargetS3, TargetRsync, TargetGitHub, TargetNetlify, TargetNeocities, TargetSftp, TargetFtp, TargetIpfs)
+	}
+	for i, r := range p.CacheControl {
+		if _, err := directory.MatchGlob(r.Pattern, ""); err != nil || r.Pattern == "" {
+			return fmt.Errorf("Cache-Control rule %d of deploy profile %s has a malformed pattern, %q.", i+1, name, r.Pattern)
+		}
+		if r.Value == "" {
+			return fmt.Errorf("Cache-Control rule %d of deploy profile %s gives no value.", i+1, name)
+		}
+	}
+	if e := p.Endpoint; e != "" && !strings.HasPrefix(e, "http://") && !strings.HasPrefix(e, "https://") {
+		return fmt.Errorf("The endpoint %q of deploy profile %s must be an http or https URL.", e, name)
+	}
+	for _, pattern := range p.Exclude {
+		if strings.TrimSpace(pattern) == "" {
+			return fmt.Errorf("Deploy profile %s excludes an empty pattern.", name)
+		}
+	}
+	return nil
+}
+
+// CheckOutputName answers an error unless name, which names a file to be generated, stays within the output directory.
+// The rule is filepath.IsLocal's, applied with backslashes taken as separators too, as on Windows, whatever the platform:
+// such names must be relative, and mustn't use .. to climb out of the output directory, nor name a drive;
+// otherwise, a careless configuration could overwrite files anywhere the build can write.
+func CheckOutputName(name string) error {
+	if !filepath.IsLocal(filepath.FromSlash(strings.ReplaceAll(name, `\`, "/"))) || hasDrive(name) {
+		return fmt.Errorf("Output %q must be named relative to the output directory, and stay within it.", name)
+	}
+	return nil
+}
+
+// hasDrive answers true if name begins with a Windows drive letter, like C:, whatever the platform,
+// since a configuration naming one means something different on Windows than elsewhere.
+func hasDrive(name string) bool {
+	return len(name) >= 2 && name[1] == ':' && (name[0] >= 'a' && name[0] <= 'z' || name[0] >= 'A' && name[0] <= 'Z')
+}
+
+// DirectoryFilename names the configuration file which overrides the site's configuration for a subdirectory of the source directory.
+const DirectoryFilename = "_config.toml"
+
+// overridable names the tables a directory configuration may set; the rest describe the site as a whole.
+var overridable = map[string]bool{"markdown": true, "sass": true, "images": true, "substitution": true}
+
+// Override answers a copy of the configuration with the settings of the named directory configuration applied over it.
+// A directory configuration may set only the markdown, sass, images, and substitution tables.
+// If the file doesn't exist, the configuration itself is answered.
+func (c *Config) Override(filename string) (*Config, error) {
+	raw, err := ioutil.ReadFile(filename)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return c, nil
+		}
+		return nil, err
+	}
+	tree, err := parse(string(raw))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", filename, err.Error())
+	}
+	for key := range tree {
+		if !overridable[key] {
+			return nil, fmt.Errorf("%s: Setting %s applies to the whole site; it can't be overridden for one directory.", filename, key)
+		}
+	}
+	o := *c
+	o.Images.Commands = copyMap(c.Images.Commands)
+	o.Substitution.Vars = copyMap(c.Substitution.Vars)
+	err = decode(tree, &o, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", filename, err.Error())
+	}
+	return &o, o.validate()
+}
+
+// copyMap answers a copy of m, so settings decoded into the copy leave m alone.
+func copyMap(m map[string]string) map[string]string {
+	if m == nil {
+		return nil
+	}
+	n := make(map[string]string, len(m))
+	for k, v := range m {
+		n[k] = v
+	}
+	return n
+}
+
+// ValidateSymlinkPolicy answers an error unless policy names one of the Symlinks policy constants.
+func ValidateSymlinkPolicy(policy string) error {
+	switch policy {
+	case SymlinksFollow, SymlinksLink, SymlinksSkip:
+		return nil
+	}
+	return fmt.Errorf("Symlink policy must be %s, %s, or %s, not %q.", SymlinksFollow, SymlinksLink, SymlinksSkip, policy)
+}
@@ -0,0 +1,40 @@
+package config
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckOutputName(t *testing.T) {
+	tests := []struct {
+		name string
+		ok   bool
+	}{
+		{"sw.js", true},
+		{"feeds/blogroll.opml", true},
+		{filepath.FromSlash("feeds/blogroll.opml"), true},
+		{`feeds\blogroll.opml`, true},
+		{"a/../b.json", true},
+		{`a\..\b.json`, true},
+		{"a/../../b.json", false},
+		{"", false},
+		{"..", false},
+		{"../outside.json", false},
+		{`..\outside.json`, false},
+		{`feeds\..\..\outside.json`, false},
+		{filepath.FromSlash("../outside.json"), false},
+		{"/etc/passwd", false},
+		{`\outside.json`, false},
+		{`C:\x`, false},
+		{"C:x", false},
+		{"c:/x", false},
+		{`\\host\share\x`, false},
+		{"//host/share/x", false},
+	}
+	for _, tc := range tests {
+		err := CheckOutputName(tc.name)
+		if (err == nil) != tc.ok {
+			t.Errorf("CheckOutputName(%q) = %v, want ok %v", tc.name, err, tc.ok)
+		}
+	}
+}
@@ -0,0 +1,201 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// decode stores the settings found in a parsed TOML tree into the structure v points at.
+// Struct fields bind to TOML keys through their toml tags.
+// Settings absent from the tree leave the corresponding fields untouched, so v may be pre-loaded with defaults.
+// Unknown keys are reported as errors, since they're almost always typos.
+// References to environment variables within strings are expanded as they're stored; see expandEnv.
+// Each string that expansion changes is recorded in refs, if it isn't nil, under the setting's name, as it was written.
+func decode(tree map[string]interface{}, v interface{}, refs map[string]reference) error {
+	d := decoder{refs}
+	return d.decodeValue("", tree, reflect.ValueOf(v).Elem())
+}
+
+// reference records a setting written with references to environment variables: the string as written, and what it expanded to.
+type reference struct {
+	raw, expanded string
+}
+
+// decoder holds the state of a decoding in progress: the references to environment variables expanded so far.
+type decoder struct {
+	refs map[string]reference
+}
+
+// expand expands the references to environment variables in s, the value of the named setting, recording them if there are any.
+func (d decoder) expand(name, s string) (string, error) {
+	expanded, err := expandEnv(name, s)
+	if err == nil && expanded != s && d.refs != nil {
+		d.refs[name] = reference{s, expanded}
+	}
+	return expanded, err
+}
+
+func (d decoder) decodeValue(name string, in interface{}, out reflect.Value) error {
+	if out.Type() == durationType {
+		s, ok := in.(string)
+		if !ok {
+			return fmt.Errorf("Setting %s must be a duration, such as \"90s\" or \"24h\".", name)
+		}
+		s, err := d.expand(name, s)
+		if err != nil {
+			return err
+		}
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return fmt.Errorf("Setting %s: %s", name, err.Error())
+		}
+		out.SetInt(int64(d))
+		return nil
+	}
+
+	switch out.Kind() {
+	case reflect.Ptr:
+		if out.IsNil() {
+			out.Set(reflect.New(out.Type().Elem()))
+		}
+		return d.decodeValue(name, in, out.Elem())
+
+	case reflect.String:
+		s, ok := in.(string)
+		if !ok {
+			return fmt.Errorf("Setting %s must be a string.", name)
+		}
+		s, err := d.expand(name, s)
+		if err != nil {
+			return err
+		}
+		out.SetString(s)
+
+	case reflect.Bool:
+		b, ok := in.(bool)
+		if !ok {
+			return fmt.Errorf("Setting %s must be true or false.", name)
+		}
+		out.SetBool(b)
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, ok := in.(int64)
+		if !ok {
+			return fmt.Errorf("Setting %s must be an integer.", name)
+		}
+		out.SetInt(i)
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		i, ok := in.(int64)
+		if !ok || i < 0 {
+			return fmt.Errorf("Setting %s must be a non-negative integer.", name)
+		}
+		out.SetUint(uint64(i))
+
+	case reflect.Float32, reflect.Float64:
+		switch f := in.(type) {
+		case float64:
+			out.SetFloat(f)
+		case int64:
+			out.SetFloat(float64(f))
+		default:
+			return fmt.Errorf("Setting %s must be a number.", name)
+		}
+
+	case reflect.Slice:
+		return d.decodeSlice(name, in, out)
+
+	case reflect.Map:
+		t, ok := in.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("Setting %s must be a table.", name)
+		}
+		if out.IsNil() {
+			out.Set(reflect.MakeMap(out.Type()))
+		}
+		for _, k := range sortedKeys(t) {
+			elem := reflect.New(out.Type().Elem()).Elem()
+			if existing := out.MapIndex(reflect.ValueOf(k)); existing.IsValid() {
+				elem.Set(existing)
+			}
+			if err := d.decodeValue(qualify(name, k), t[k], elem); err != nil {
+				return err
+			}
+			out.SetMapIndex(reflect.ValueOf(k), elem)
+		}
+
+	case reflect.Struct:
+		t, ok := in.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("Setting %s must be a table.", name)
+		}
+		for _, k := range sortedKeys(t) {
+			field, found := fieldFor(out, k)
+			if !found {
+				return fmt.Errorf("Unknown setting %s.", qualify(name, k))
+			}
+			if err := d.decodeValue(qualify(name, k), t[k], field); err != nil {
+				return err
+			}
+		}
+
+	default:
+		return fmt.Errorf("Setting %s has unsupported type %s.", name, out.Type())
+	}
+	return nil
+}
+
+func (d decoder) decodeSlice(name string, in interface{}, out reflect.Value) error {
+	var items []interface{}
+	switch a := in.(type) {
+	case []interface{}:
+		items = a
+	case []map[string]interface{}:
+		for _, t := range a {
+			items = append(items, t)
+		}
+	default:
+		return fmt.Errorf("Setting %s must be an array.", name)
+	}
+	s := reflect.MakeSlice(out.Type(), len(items), len(items))
+	for i, item := range items {
+		if err := d.decodeValue(fmt.Sprintf("%s[%d]", name, i), item, s.Index(i)); err != nil {
+			return err
+		}
+	}
+	out.Set(s)
+	return nil
+}
+
+// fieldFor locates the struct field bound to the given TOML key.
+func fieldFor(v reflect.Value, key string) (reflect.Value, bool) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		tag := strings.Split(t.Field(i).Tag.Get("toml"), ",")[0]
+		if tag == key && exported(t.Field(i)) {
+			return v.Field(i), true
+		}
+	}
+	return reflect.Value{}, false
+}
+
+func qualify(name, key string) string {
+	if name == "" {
+		return key
+	}
+	return name + "." + key
+}
+
+func sortedKeys(t map[string]interface{}) []string {
+	keys := make([]string, 0, len(t))
+	for k := range t {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
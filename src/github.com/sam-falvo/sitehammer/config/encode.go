@@ -0,0 +1,222 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Encode answers the configuration written as TOML, every setting spelled out, in a form Load reads back unchanged.
+// Tables appear in the order of Config's fields, and settings in the order of their tables' fields;
+// map keys are sorted, and maps of tables, such as the deployment profiles, written as a table per key.
+// Settings the configuration file wrote with references to environment variables are written as they were, references and all,
+// so that secrets supplied through the environment stay out of the file; see expandEnv.
+// Dollar signs in other settings that Load would take for environment variable references are doubled,
+// so the values written are the values in effect.
+func (c *Config) Encode() []byte {
+	e := encoder{refs: c.refs}
+	e.encodeTable("", "", reflect.ValueOf(c).Elem())
+	return bytes.TrimLeft(e.b.Bytes(), "\n")
+}
+
+// encoder holds the state of an encoding in progress: the TOML written so far,
+// and the settings of the configuration file written with references to environment variables.
+type encoder struct {
+	b    bytes.Buffer
+	refs map[string]reference
+}
+
+// encodeTable writes the fields of the struct v, the table whose header is called header: first its settings, then its subtables,
+// each under a header of its own.
+// Settings are named, for looking up their references, as decode names them, beneath name.
+func (e *encoder) encodeTable(header, name string, v reflect.Value) {
+	b := &e.b
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := v.Field(i)
+		if !exported(t.Field(i)) || isTable(field) || isTableArray(field) {
+			continue
+		}
+		fmt.Fprintf(b, "%s = %s\n", encodeKey(tagOf(t.Field(i))), e.encodeScalar(qualify(name, tagOf(t.Field(i))), field))
+	}
+	for i := 0; i < t.NumField(); i++ {
+		if !exported(t.Field(i)) {
+			continue
+		}
+		field := v.Field(i)
+		key := qualify(header, encodeKey(tagOf(t.Field(i))))
+		setting := qualify(name, tagOf(t.Field(i)))
+		switch {
+		case isTableArray(field):
+			for j := 0; j < field.Len(); j++ {
+				fmt.Fprintf(b, "\n[[%s]]\n", key)
+				e.encodeTable(key, fmt.Sprintf("%s[%d]", setting, j), field.Index(j))
+			}
+		case field.Kind() == reflect.Struct:
+			fmt.Fprintf(b, "\n[%s]\n", key)
+			e.encodeTable(key, setting, field)
+		case field.Kind() == reflect.Map:
+			if field.Len() == 0 {
+				continue
+			}
+			var keys []string
+			for _, k := range field.MapKeys() {
+				keys = append(keys, k.String())
+			}
+			sort.Strings(keys)
+			if field.Type().Elem().Kind() == reflect.Struct {
+				for _, k := range keys {
+					fmt.Fprintf(b, "\n[%s]\n", qualify(key, encodeKey(k)))
+					e.encodeTable(qualify(key, encodeKey(k)), qualify(setting, k), field.MapIndex(reflect.ValueOf(k)))
+				}
+				continue
+			}
+			fmt.Fprintf(b, "\n[%s]\n", key)
+			for _, k := range keys {
+				fmt.Fprintf(b, "%s = %s\n", encodeKey(k), e.encodeScalar(qualify(setting, k), field.MapIndex(reflect.ValueOf(k))))
+			}
+		}
+	}
+}
+
+// isTable answers true if v is written as a table of its own: a struct or a map.
+func isTable(v reflect.Value) bool {
+	return v.Kind() == reflect.Struct || v.Kind() == reflect.Map
+}
+
+// isTableArray answers true if v is written as an array of tables: a slice of structs.
+func isTableArray(v reflect.Value) bool {
+	return v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Struct
+}
+
+// exported answers true if f is an exported field, and so a setting; unexported fields hold what's known of the settings.
+func exported(f reflect.StructField) bool {
+	return f.PkgPath == ""
+}
+
+// tagOf answers the TOML key a struct field binds to.
+func tagOf(f reflect.StructField) string {
+	return strings.Split(f.Tag.Get("toml"), ",")[0]
+}
+
+// encodeKey answers a key as TOML writes it: bare if it can be, quoted otherwise.
+func encodeKey(k string) string {
+	if k == "" {
+		return `""`
+	}
+	for i := 0; i < len(k); i++ {
+		if !isBareKeyChar(k[i]) {
+			return encodeString(k)
+		}
+	}
+	return k
+}
+
+// encodeScalar answers the TOML for the value of the named setting: a string, boolean, number, duration, or array of them.
+func (e *encoder) encodeScalar(name string, v reflect.Value) string {
+	if raw, ok := e.reference(name, v); ok {
+		return encodeString(raw)
+	}
+	if v.Type() == durationType {
+		return encodeString(encodeDuration(time.Duration(v.Int())))
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return encodeString(escapeEnv(v.String()))
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10)
+	case reflect.Float32, reflect.Float64:
+		s := strconv.FormatFloat(v.Float(), 'g', -1, 64)
+		if !strings.ContainsAny(s, ".eE") {
+			s += ".0"
+		}
+		return s
+	case reflect.Slice:
+		items := make([]string, v.Len())
+		for i := range items {
+			items[i] = e.encodeScalar(fmt.Sprintf("%s[%d]", name, i), v.Index(i))
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	case reflect.Ptr:
+		return e.encodeScalar(name, v.Elem())
+	}
+	panic(fmt.Sprintf("config: cannot encode a setting of type %s", v.Type()))
+}
+
+// reference answers the named setting as the configuration file wrote it, if it was written with references to environment variables,
+// and still holds the value they expanded to.
+func (e *encoder) reference(name string, v reflect.Value) (string, bool) {
+	ref, ok := e.refs[name]
+	if !ok {
+		return "", false
+	}
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(ref.expanded)
+		return ref.raw, err == nil && d == time.Duration(v.Int())
+	}
+	return ref.raw, v.Kind() == reflect.String && v.String() == ref.expanded
+}
+
+// encodeDuration writes d as time.ParseDuration reads it, without the trailing zero units time.Duration.String adds,
+// so that a week reads 168h rather than 168h0m0s.
+func encodeDuration(d time.Duration) string {
+	s := d.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = strings.TrimSuffix(s, "0s")
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return s
+}
+
+// escapeEnv doubles the dollar signs in s that expandEnv would otherwise take for the start of a reference or an escape.
+func escapeEnv(s string) string {
+	if !strings.Contains(s, "$") {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		b.WriteByte(s[i])
+		if s[i] == '$' && i+1 < len(s) && (s[i+1] == '$' || s[i+1] == '{') {
+			b.WriteByte('$')
+		}
+	}
+	return b.String()
+}
+
+// encodeString answers s as a TOML basic string.
+func encodeString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		default:
+			if r < 0x20 || r == 0x7f {
+				fmt.Fprintf(&b, `\u%04X`, r)
+			} else {
+				b.WriteRune(r)
+			}
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
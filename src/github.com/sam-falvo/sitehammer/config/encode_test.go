@@ -0,0 +1,78 @@
+package config
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// load writes content to a configuration file in a temporary directory and loads it.
+func load(t *testing.T, content string) *Config {
+	t.Helper()
+	filename := filepath.Join(t.TempDir(), Filename)
+	err := ioutil.WriteFile(filename, []byte(content), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := Load(filename)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	return c
+}
+
+func TestEncodeKeepsReferences(t *testing.T) {
+	t.Setenv("SITEHAMMER_TEST_URL", "https://secret.example")
+	t.Setenv("SITEHAMMER_TEST_TARGET", "/new")
+	c := load(t, `
+[blog]
+base_url = "${SITEHAMMER_TEST_URL:-http://localhost:8000}"
+author = "Price $$5"
+
+[linkcheck]
+ttl = "${SITEHAMMER_TEST_TTL:-48h}"
+
+[compress]
+extensions = [".html", "${SITEHAMMER_TEST_EXT:-.css}"]
+
+[[redirect]]
+from = "/old"
+to = "${SITEHAMMER_TEST_TARGET}"
+`)
+	if c.Blog.BaseUrl != "https://secret.example" {
+		t.Fatalf("base_url expanded to %q", c.Blog.BaseUrl)
+	}
+	encoded := string(c.Encode())
+	for _, want := range []string{
+		`base_url = "${SITEHAMMER_TEST_URL:-http://localhost:8000}"`,
+		`author = "Price $$5"`,
+		`ttl = "${SITEHAMMER_TEST_TTL:-48h}"`,
+		`extensions = [".html", "${SITEHAMMER_TEST_EXT:-.css}"]`,
+		`to = "${SITEHAMMER_TEST_TARGET}"`,
+	} {
+		if !strings.Contains(encoded, want) {
+			t.Errorf("Encode lacks %s", want)
+		}
+	}
+	for _, secret := range []string{"secret.example", `"/new"`} {
+		if strings.Contains(encoded, secret) {
+			t.Errorf("Encode writes the expanded value %s", secret)
+		}
+	}
+
+	again := load(t, encoded)
+	if string(again.Encode()) != encoded {
+		t.Errorf("Encoding what Encode wrote changes it")
+	}
+}
+
+func TestEncodeChangedSettingsLoseReferences(t *testing.T) {
+	t.Setenv("SITEHAMMER_TEST_URL", "https://secret.example")
+	c := load(t, "[blog]\nbase_url = \"${SITEHAMMER_TEST_URL}\"\n")
+	c.Blog.BaseUrl = "http://localhost:8000"
+	encoded := string(c.Encode())
+	if !strings.Contains(encoded, `base_url = "http://localhost:8000"`) {
+		t.Errorf("Encode doesn't write the changed base URL:\n%s", encoded)
+	}
+}
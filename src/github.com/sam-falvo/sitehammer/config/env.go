@@ -0,0 +1,74 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// expandEnv answers s with its references to environment variables replaced by their values,
+// for the setting called name.
+// A reference of the form ${NAME} requires the variable be set, answering an error otherwise;
+// one of the form ${NAME:-fallback} answers fallback if the variable is unset or empty.
+// $$ stands for a single dollar sign; any other dollar sign is left alone.
+func expandEnv(name, s string) (string, error) {
+	if !strings.Contains(s, "$") {
+		return s, nil
+	}
+	var b strings.Builder
+	for {
+		i := strings.IndexByte(s, '$')
+		if i < 0 || i+1 == len(s) {
+			b.WriteString(s)
+			return b.String(), nil
+		}
+		b.WriteString(s[:i])
+		s = s[i+1:]
+		switch s[0] {
+		case '$':
+			b.WriteByte('$')
+			s = s[1:]
+			continue
+		case '{':
+		default:
+			b.WriteByte('$')
+			continue
+		}
+
+		end := strings.IndexByte(s, '}')
+		if end < 0 {
+			return "", fmt.Errorf("Setting %s has an unterminated ${ reference.", name)
+		}
+		ref := s[1:end]
+		s = s[end+1:]
+		variable, fallback, hasFallback := ref, "", false
+		if j := strings.Index(ref, ":-"); j >= 0 {
+			variable, fallback, hasFallback = ref[:j], ref[j+2:], true
+		}
+		if !isVariableName(variable) {
+			return "", fmt.Errorf("Setting %s refers to ${%s}, which isn't a valid environment variable name.", name, ref)
+		}
+		value, set := os.LookupEnv(variable)
+		switch {
+		case hasFallback && value == "":
+			value = fallback
+		case !set:
+			return "", fmt.Errorf("Setting %s refers to environment variable %s, which isn't set; set it, or write ${%s:-fallback} to supply a default.", name, variable, variable)
+		}
+		b.WriteString(value)
+	}
+}
+
+// isVariableName answers true if s is a well-formed environment variable name:
+// letters, digits, and underscores, not beginning with a digit.
+func isVariableName(s string) bool {
+	if s == "" || (s[0] >= '0' && s[0] <= '9') {
+		return false
+	}
+	for _, c := range []byte(s) {
+		if !(c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9') {
+			return false
+		}
+	}
+	return true
+}
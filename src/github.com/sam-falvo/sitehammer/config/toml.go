@@ -0,0 +1,30 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"github.com/BurntSushi/toml"
+)
+
+// parse turns TOML text into a tree of tables, as github.com/BurntSushi/toml decodes it.
+// Tables appear as map[string]interface{}, arrays of tables as []map[string]interface{},
+// and other arrays as []interface{}.
+// Scalars appear as string, int64, float64, or bool; dates and times as time.Time, which no setting takes, so quote them as strings instead.
+// Errors are answered as "line n: problem", for Load to prefix with the file's name.
+func parse(text string) (map[string]interface{}, error) {
+	tree := make(map[string]interface{})
+	_, err := toml.Decode(text, &tree)
+	var pe toml.ParseError
+	if errors.As(err, &pe) {
+		return nil, fmt.Errorf("line %d: %s", pe.Position.Line, pe.Message)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return tree, nil
+}
+
+// isBareKeyChar answers true if c may appear in a bare key, one written without quotes.
+func isBareKeyChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-'
+}
@@ -0,0 +1,117 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// tree abbreviates the tables parse answers.
+type tree = map[string]interface{}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want tree
+	}{
+		{"empty", "", tree{}},
+		{"comments and blank lines", "# a comment\n\n  # another\n", tree{}},
+		{"scalars", "s = \"x\"\ni = 42\nn = -7\nf = 1.5\nb = true\nh = 0x1F\nu = 1_000",
+			tree{"s": "x", "i": int64(42), "n": int64(-7), "f": 1.5, "b": true, "h": int64(31), "u": int64(1000)}},
+		{"basic string escapes", `s = "tab\there \"quoted\" back\\slash \u00e9 \U0001F600 nl\n"`,
+			tree{"s": "tab\there \"quoted\" back\\slash é 😀 nl\n"}},
+		{"literal string", `s = 'C:\Users\${HOME}'`, tree{"s": `C:\Users\${HOME}`}},
+		{"multi-line basic string", "s = \"\"\"\nfirst\\\n   second\n\"\"\"", tree{"s": "firstsecond\n"}},
+		{"multi-line literal string", "s = '''\nkeep \\n as is\n'''", tree{"s": "keep \\n as is\n"}},
+		{"quoted keys", `"a.b" = 1` + "\n" + `'c d' = 2`, tree{"a.b": int64(1), "c d": int64(2)}},
+		{"arrays", "a = [1, 2, 3]\nm = [\"x\", 1, [true]]\ne = []\nt = [\n  \"trailing\",\n]",
+			tree{"a": []interface{}{int64(1), int64(2), int64(3)}, "m": []interface{}{"x", int64(1), []interface{}{true}},
+				"e": []interface{}{}, "t": []interface{}{"trailing"}}},
+		{"tables", "top = 1\n[output]\ndir = \"_site\"\n[blog.feed]\nlimit = 10",
+			tree{"top": int64(1), "output": tree{"dir": "_site"}, "blog": tree{"feed": tree{"limit": int64(10)}}}},
+		{"dotted keys", "blog.feed.limit = 10\nblog.base_url = \"x\"",
+			tree{"blog": tree{"feed": tree{"limit": int64(10)}, "base_url": "x"}}},
+		{"inline tables", "a = { x = 1, y = { z = \"deep\" } }\nl = [{ k = 1 }, { k = 2 }]",
+			tree{"a": tree{"x": int64(1), "y": tree{"z": "deep"}}, "l": []interface{}{tree{"k": int64(1)}, tree{"k": int64(2)}}}},
+		{"arrays of tables", "[[bundle]]\noutput = \"a.css\"\n[[bundle]]\noutput = \"b.css\"\n[bundle.extra]\nx = 1",
+			tree{"bundle": []map[string]interface{}{{"output": "a.css"}, {"output": "b.css", "extra": tree{"x": int64(1)}}}}},
+	}
+	for _, tc := range tests {
+		got, err := parse(tc.text)
+		if err != nil {
+			t.Errorf("%s: parse answered error %v", tc.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: parse = %#v, want %#v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		// want is the start of the error expected, giving the line at fault.
+		want string
+	}{
+		{"duplicate key", "a = 1\nb = 2\na = 3", "line 3: "},
+		{"duplicate table", "[output]\ndir = \"a\"\n[output]", "line 3: "},
+		{"key redefined as table", "blog = 1\n[blog]", "line 2: "},
+		{"dotted key redefining a value", "a = 1\na.b = 2", "line 2: "},
+		{"inline table extended", "a = { x = 1 }\n[a]\ny = 2", "line 2: "},
+		{"unterminated string", "\na = \"open", "line 2: "},
+		{"unterminated array", "a = [1, 2", "line 1: "},
+		{"newline in basic string", "a = \"one\ntwo\"", "line 1: "},
+		{"bad escape", `a = "\q"`, "line 1: "},
+		{"missing value", "a =", "line 1: "},
+		{"missing equals", "a 1", "line 1: "},
+		{"bare word value", "a = yes", "line 1: "},
+		{"unclosed header", "dir = 1\n[output", "line 2: "},
+		{"two values on a line", "a = 1 b = 2", "line 1: "},
+	}
+	for _, tc := range tests {
+		got, err := parse(tc.text)
+		if err == nil {
+			t.Errorf("%s: parse = %#v, want an error", tc.name, got)
+			continue
+		}
+		if !strings.HasPrefix(err.Error(), tc.want) {
+			t.Errorf("%s: parse error %q, want one starting %q", tc.name, err, tc.want)
+		}
+	}
+}
+
+// TestParseShapes checks that parse answers the shapes decode expects, by decoding a configuration that uses each of them.
+func TestParseShapes(t *testing.T) {
+	c := load(t, `
+compress.gzip = true
+redirect = [{ from = "/old", to = "/new", status = 301 }]
+
+[output]
+dir = "out"
+
+[[bundle]]
+name = "all.css"
+files = ["a.css", "b.css"]
+
+[[bundle]]
+name = "all.js"
+files = ["a.js"]
+
+[blog]
+base_url = 'https://example.com'
+`)
+	if c.Output.Dir != "out" || !c.Compress.Gzip || c.Blog.BaseUrl != "https://example.com" {
+		t.Errorf("decoded output %+v, compress %+v, blog base_url %q", c.Output, c.Compress, c.Blog.BaseUrl)
+	}
+	wantBundles := []Bundle{{"all.css", []string{"a.css", "b.css"}}, {"all.js", []string{"a.js"}}}
+	if !reflect.DeepEqual(c.Bundles, wantBundles) {
+		t.Errorf("decoded bundles %+v, want %+v", c.Bundles, wantBundles)
+	}
+	wantRedirects := []Redirect{{"/old", "/new", 301}}
+	if !reflect.DeepEqual(c.Redirects, wantRedirects) {
+		t.Errorf("decoded redirects %+v, want %+v", c.Redirects, wantRedirects)
+	}
+}
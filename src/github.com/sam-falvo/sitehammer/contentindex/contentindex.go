@@ -0,0 +1,283 @@
+/*
+The contentindex package describes a built site in a single compact JSON file, content-index.json unless configured otherwise,
+for tools such as search services and recommendation scripts to query without parsing the site's pages again.
+
+The index lists every page, every article of the blog, and every tag, along with how they relate:
+
+	{
+	  "base_url": "https://example.com",
+	  "pages": [
+	    {"path": "/about.html", "url": "https://example.com/about.html", "title": "About",
+	     "sources": ["about.html.tmpl"], "links_to": ["/index.html"], "linked_from": ["/index.html"]},
+	    {"path": "/articles/1/index.html", "url": "https://example.com/articles/1/", "title": "Hello",
+	     "article": 1, "sources": ["src/descs.json", "templates/blog-article.html", "src/1/abstract"]}
+	  ],
+	  "articles": [
+	    {"id": 1, "title": "Hello", "author": "Sam", "published": "2012-Jan-01", "date": "2012-01-01T00:00:00Z",
+	     "tags": ["go"], "path": "/articles/1/index.html", "words": 812, "next": 2}
+	  ],
+	  "tags": [
+	    {"name": "go", "articles": [1, 2]}
+	  ]
+	}
+
+Pages are the site's HTML outputs, by site path, in order, each with its title, the sources it was built from,
+the pages it links to, and those linking to it; links are followed only between the site's own pages.
+Articles are listed in the descriptors' order, each with its dates, tags, page, length in words, and the IDs of the articles before and after it;
+if the blog table's git_history setting is true, the dates it was first and last committed as well, as created and updated.
+Tags are listed by name, each with the IDs of the articles under it.
+The file carries no build times, so it changes only when the site does.
+*/
+package contentindex
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/sam-falvo/sitehammer/analytics"
+	"github.com/sam-falvo/sitehammer/config"
+	"github.com/sam-falvo/sitehammer/directory"
+	"github.com/sam-falvo/sitehammer/dryrun"
+	"github.com/sam-falvo/sitehammer/failure"
+	"github.com/sam-falvo/sitehammer/htmlcheck"
+	"github.com/sam-falvo/sitehammer/weblog"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Index is the content of the index file.
+type Index struct {
+	BaseUrl  string    `json:"base_url"`
+	Pages    []Page    `json:"pages"`
+	Articles []Article `json:"articles"`
+	Tags     []Tag     `json:"tags"`
+}
+
+// Page describes one of the site's HTML pages, by its site path.
+// Article gives the ID of the article the page renders, if it renders one.
+type Page struct {
+	Path       string   `json:"path"`
+	Url        string   `json:"url"`
+	Title      string   `json:"title,omitempty"`
+	Article    *uint    `json:"article,omitempty"`
+	Sources    []string `json:"sources,omitempty"`
+	LinksTo    []string `json:"links_to,omitempty"`
+	LinkedFrom []string `json:"linked_from,omitempty"`
+}
+
+// Article describes one of the blog's articles.
+// Date gives its publication date in RFC 3339 form, if weblog.Descriptor.Date understands it,
+// and Created and Updated, the dates of its first and last commits, if it has a history.
+// Previous and Next give the IDs of its neighbors, as its page links to them.
+type Article struct {
+	Id        uint     `json:"id"`
+	Title     string   `json:"title"`
+	Author    string   `json:"author"`
+	Published string   `json:"published"`
+	Date      string   `json:"date,omitempty"`
+	Created   string   `json:"created,omitempty"`
+	Updated   string   `json:"updated,omitempty"`
+	Tags      []string `json:"tags"`
+	Path      string   `json:"path"`
+	Words     int      `json:"words"`
+	Previous  *uint    `json:"previous,omitempty"`
+	Next      *uint    `json:"next,omitempty"`
+}
+
+// Tag names a tag, and lists the IDs of the articles under it, in the descriptors' order.
+type Tag struct {
+	Name     string `json:"name"`
+	Articles []uint `json:"articles"`
+}
+
+// Options controls generation of the index.
+//
+// OutputDir names the directory holding the built site, which may be a staging area;
+// DisplayDir names the same directory as the user knows it, for dry-run reports.
+// Sources maps the name of each output to the files it was built from, and Articles lists the blog's articles, if it has any.
+type Options struct {
+	Config     *config.Config
+	OutputDir  string
+	DisplayDir string
+	DryRun     bool
+	Sources    map[string][]string
+	Articles   []weblog.Article
+}
+
+// Generate writes the index of the named outputs into the output directory, refusing to replace any of them.
+// It answers the slash-separated name of the file it's responsible for.
+// In a dry run, pages not yet written are indexed without their titles or links.
+func Generate(opts Options, outputs map[string]bool) ([]string, error) {
+	name := opts.Config.ContentIndex.File
+	if outputs[name] {
+		return nil, failure.Wrap(failure.Content, fmt.Errorf("The site already has %s, which the content index would replace; remove one or rename the other.", name))
+	}
+	index, err := build(opts, outputs)
+	if err != nil {
+		return nil, err
+	}
+	content, err := json.Marshal(index)
+	if err != nil {
+		return nil, err
+	}
+	content = append(content, '\n')
+	if opts.DryRun {
+		dryrun.ReportWriteIfChanged(filepath.Join(opts.DisplayDir, filepath.FromSlash(name)), content)
+		return []string{name}, nil
+	}
+	filename := filepath.Join(opts.OutputDir, filepath.FromSlash(name))
+	err = directory.EnsureDirAll(filepath.Dir(filename), opts.Config.Output.DirPerm())
+	if err == nil {
+		err = directory.WriteFileAtomic(filename, content, opts.Config.Output.FilePerm(0644))
+	}
+	return []string{name}, err
+}
+
+// build reads the site's pages, answering the index of them and the blog's articles.
+func build(opts Options, outputs map[string]bool) (*Index, error) {
+	index := &Index{BaseUrl: opts.Config.Blog.BaseUrl, Pages: []Page{}, Articles: []Article{}, Tags: []Tag{}}
+	var names []string
+	for name := range outputs {
+		if strings.EqualFold(path.Ext(name), ".html") {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	articles := make(map[string]uint)
+	for _, a := range opts.Articles {
+		articles[articleName(a.Id)] = a.Id
+	}
+	resolve := resolver(opts.Config.Blog.BaseUrl, outputs)
+	for _, name := range names {
+		p := Page{Path: "/" + name, Url: strings.TrimSuffix(opts.Config.Blog.BaseUrl+"/"+name, "index.html"), Sources: opts.Sources[name]}
+		if id, ok := articles[name]; ok {
+			p.Article = &id
+		}
+		content, err := ioutil.ReadFile(filepath.Join(opts.OutputDir, filepath.FromSlash(name)))
+		if opts.DryRun && os.IsNotExist(err) {
+			content, err = nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		p.Title = htmlcheck.Title(content)
+		seen := map[string]bool{name: true}
+		for _, l := range htmlcheck.Links(content) {
+			target := resolve(name, l.Url)
+			if target != "" && !seen[target] {
+				seen[target] = true
+				p.LinksTo = append(p.LinksTo, "/"+target)
+			}
+		}
+		sort.Strings(p.LinksTo)
+		index.Pages = append(index.Pages, p)
+	}
+	pages := make(map[string]*Page)
+	for i := range index.Pages {
+		pages[index.Pages[i].Path] = &index.Pages[i]
+	}
+	// Pages are visited in order, so each page's referrers are, too.
+	for _, p := range index.Pages {
+		for _, target := range p.LinksTo {
+			pages[target].LinkedFrom = append(pages[target].LinkedFrom, p.Path)
+		}
+	}
+
+	tags := make(map[string]*Tag)
+	for i, a := range opts.Articles {
+		entry := Article{
+			Id:        a.Id,
+			Title:     a.Title,
+			Author:    a.Author,
+			Published: a.Published,
+			Tags:      a.Tags,
+			Path:      "/" + articleName(a.Id),
+			Words:     analytics.Words(string(a.Abstract)) + analytics.Words(string(a.Body)),
+		}
+		if entry.Tags == nil {
+			entry.Tags = []string{}
+		}
+		if t, ok := a.Date(); ok {
+			entry.Date = t.Format(time.RFC3339)
+		}
+		if a.History != nil {
+			entry.Created = a.History.Created.Format(time.RFC3339)
+			entry.Updated = a.History.Updated.Format(time.RFC3339)
+		}
+		if i > 0 {
+			entry.Previous = &opts.Articles[i-1].Id
+		}
+		if i+1 < len(opts.Articles) {
+			entry.Next = &opts.Articles[i+1].Id
+		}
+		index.Articles = append(index.Articles, entry)
+		for _, name := range a.Tags {
+			if tags[name] == nil {
+				tags[name] = &Tag{Name: name}
+			}
+			tags[name].Articles = append(tags[name].Articles, a.Id)
+		}
+	}
+	for _, t := range tags {
+		index.Tags = append(index.Tags, *t)
+	}
+	sort.Slice(index.Tags, func(i, j int) bool { return index.Tags[i].Name < index.Tags[j].Name })
+	return index, nil
+}
+
+// articleName answers the slash-separated name of the page of the article with the given ID.
+func articleName(id uint) string {
+	return fmt.Sprintf("%s/%d/%s", weblog.ArticleDirName, id, weblog.IndexFilename)
+}
+
+// resolver answers a function resolving a link on the named page to the name of the page it leads to, among the outputs,
+// or an empty string if it leads elsewhere.
+// Links are taken as the site's own if they're relative, or lead to the base URL's host, beneath its path;
+// a link to a directory leads to its index.html.
+func resolver(baseUrl string, outputs map[string]bool) func(page, link string) string {
+	var host, base string
+	if u, err := url.Parse(baseUrl); err == nil {
+		host, base = u.Host, strings.TrimSuffix(u.Path, "/")
+	}
+	return func(page, link string) string {
+		u, err := url.Parse(link)
+		if err != nil || u.Opaque != "" {
+			return ""
+		}
+		p := u.Path
+		switch {
+		case u.Scheme != "" || u.Host != "":
+			if (u.Scheme != "http" && u.Scheme != "https" && u.Scheme != "") || !strings.EqualFold(u.Host, host) {
+				return ""
+			}
+			fallthrough
+		case strings.HasPrefix(p, "/"):
+			if base != "" && (p == base || strings.HasPrefix(p, base+"/")) {
+				p = strings.TrimPrefix(p, base)
+			}
+		case p == "":
+			return ""
+		case strings.HasSuffix(p, "/"):
+			p = path.Join(path.Dir("/"+page), p) + "/"
+		default:
+			p = path.Join(path.Dir("/"+page), p)
+		}
+		name := strings.TrimPrefix(path.Clean("/"+p), "/")
+		candidates := []string{name, path.Join(name, weblog.IndexFilename)}
+		if name == "" || strings.HasSuffix(p, "/") {
+			candidates = candidates[1:]
+		}
+		for _, c := range candidates {
+			if outputs[c] && strings.EqualFold(path.Ext(c), ".html") {
+				return c
+			}
+		}
+		return ""
+	}
+}
@@ -0,0 +1,80 @@
+/*
+The deploy package publishes a built site where it's served, copying only what changed since it was last published.
+
+The files published are exactly those the build's manifest lists (see the manifest package),
+read from the output directory, so that leftovers of earlier builds aren't published along with them.
+
+S3 publishes the site to an Amazon S3 bucket, or an S3-compatible service.
+Each object uploaded gets a Content-Type suited to its extension, and the Cache-Control header of the first rule matching its path, if any.
+Objects whose content already matches the file's, as told by their ETags, aren't uploaded again, unless their headers differ from those,
+as when the cache_control rules have changed; telling so takes a HEAD request of each.
+Objects the build no longer produces are deleted, if the configuration asks for it,
+and the paths of objects changed or deleted are invalidated in the CloudFront distribution serving the bucket, if one is configured,
+so readers needn't wait for stale copies to expire.
+Requests are signed with AWS Signature Version 4, using the credentials the environment gives.
+
+Rsync publishes the site with rsync, usually to a directory on a remote host reached over SSH,
+leaving rsync to work out which files changed, and to copy only what changed within them.
+Files the output directory holds but the manifest doesn't list are hidden from rsync,
+and so deleted from the destination, like the files the build no longer produces, if the configuration asks for it.
+
+GitHub publishes the site to GitHub Pages, committing it to the branch GitHub publishes from, in a scratch clone of the repository,
+and pushing the branch; git itself works out what changed.
+It adds the .nojekyll and CNAME files GitHub Pages looks for, so the site needn't carry them.
+
+Netlify publishes the site to Netlify as a new deploy, through Netlify's API, uploading only the files whose content Netlify lacks,
+as told by their SHA-1 digests; a draft deploy is published at an address of its own, for preview, leaving the live site alone.
+
+Neocities publishes the site to Neocities, through its API, uploading only the files whose content, as told by their SHA-1 digests, differs from the site's.
+
+Sftp and Ftp publish the site to a directory on a host reached by SFTP, through the sftp command, or by plain FTP, as shared hosting offers.
+Neither protocol tells cheaply what a file holds, so a copy of the manifest deployed is kept beside the site,
+and the next deployment uploads only the files the build's manifest lists differently, as manifest.Diff tells.
+
+Ipfs adds the site to IPFS, as a directory, through the API of an IPFS node, which stores only the blocks it lacks;
+the directory's CID may then be published under an IPNS name, or in the DNSLink record of a domain, through Cloudflare's API.
+*/
+package deploy
+
+import (
+	"context"
+	"github.com/sam-falvo/sitehammer/manifest"
+)
+
+// Options controls a deployment.
+// Profile names the deployment profile whose settings are followed, for messages to name; BaseUrl gives the site's base URL.
+// Manifest lists the files to publish, which are read from OutputDir.
+// If DryRun is true, nothing is changed; what would be is reported instead.
+// Draft asks for a draft deployment, for preview, where the target supports them, as Netlify does.
+// Cancelling Context stops the deployment before the next file.
+type Options struct {
+	Profile   string
+	BaseUrl   string
+	OutputDir string
+	Manifest  *manifest.Manifest
+	DryRun    bool
+	Draft     bool
+	Context   context.Context
+}
+
+// context answers the deployment's context, or a background context if none is given.
+func (o Options) context() context.Context {
+	if o.Context == nil {
+		return context.Background()
+	}
+	return o.Context
+}
+
+// Result summarizes a deployment: how many files were uploaded, how many published files were deleted,
+// and how many were already up to date, along with the ID of the CloudFront invalidation made, if any,
+// the CID of the site added to IPFS, if it was,
+// and the address at which the deployment may be seen, if the target gives one, as Netlify does.
+// A dry run counts what it would have done.
+type Result struct {
+	Uploaded     int
+	Deleted      int
+	Unchanged    int
+	Invalidation string
+	Cid          string
+	Url          string
+}
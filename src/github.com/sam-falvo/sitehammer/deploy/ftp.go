@@ -0,0 +1,242 @@
+package deploy
+
+import (
+	"context"
+	"fmt"
+	"github.com/sam-falvo/sitehammer/config"
+	"github.com/sam-falvo/sitehammer/failure"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/textproto"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ftpPasswordVar names the environment variable giving the password of an FTP deployment.
+const ftpPasswordVar = "FTP_PASSWORD"
+
+// ftpDialTimeout limits how long an FTP connection, whether for commands or data, takes to open.
+const ftpDialTimeout = 30 * time.Second
+
+// Ftp publishes the files the manifest lists to the profile's destination, an ftp URL, over plain FTP,
+// logging in as the user the URL names, or anonymously, with the password the FTP_PASSWORD environment variable gives.
+// The URL's path is taken relative to the login directory, as RFC 1738 has it, so ftp://host/public_html names public_html there,
+// while ftp://host//var/www names /var/www.
+// Only the files which changed since the last deployment there are uploaded; see transferChanges.
+// FTP sends the password, and everything else, unencrypted, so it's for hosts offering nothing better.
+func Ftp(cfg config.DeployProfile, opts Options) (*Result, error) {
+	u, err := destinationUrl(cfg, opts)
+	if err != nil {
+		return nil, err
+	}
+	user, password := "anonymous", "sitehammer@"
+	if u.User != nil {
+		user, password = u.User.Username(), ""
+	}
+	if p, ok := os.LookupEnv(ftpPasswordVar); ok {
+		password = p
+	}
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), "21")
+	}
+	c, err := dialFtp(opts.context(), host, user, password)
+	if err != nil {
+		return nil, failure.Wrap(failure.IO, err)
+	}
+	defer c.close()
+	return transferChanges(c, strings.TrimPrefix(u.Path, "/"), cfg, opts)
+}
+
+// ftpClient speaks FTP over a control connection, opening a passive data connection for each file transferred.
+type ftpClient struct {
+	host string
+	text *textproto.Conn
+	ctx  context.Context
+	stop chan struct{}
+}
+
+// dialFtp connects to the FTP server at addr, and logs in as user, for binary transfers.
+// Cancelling ctx closes the connection, stopping whatever the client's doing.
+func dialFtp(ctx context.Context, addr, user, password string) (*ftpClient, error) {
+	dialer := net.Dialer{Timeout: ftpDialTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("Cannot connect to %s: %v", addr, err)
+	}
+	host, _, _ := net.SplitHostPort(addr)
+	c := &ftpClient{host, textproto.NewConn(conn), ctx, make(chan struct{})}
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-c.stop:
+		}
+	}()
+	_, _, err = c.text.ReadResponse(2)
+	if err == nil {
+		var code int
+		code, _, err = c.command(0, "USER %s", user)
+		switch {
+		case err == nil && code == 331:
+			_, _, err = c.command(2, "PASS %s", password)
+		case err == nil && code/100 != 2:
+			err = fmt.Errorf("the server refused the user %s (%d)", user, code)
+		}
+	}
+	if err == nil {
+		_, _, err = c.command(2, "TYPE I")
+	}
+	if err != nil {
+		c.close()
+		return nil, c.failed(fmt.Errorf("Cannot log in to %s: %v", addr, err))
+	}
+	return c, nil
+}
+
+// command sends a command, answering the server's response, which is an error unless its code begins with expect, if it's not 0.
+func (c *ftpClient) command(expect int, format string, args ...interface{}) (int, string, error) {
+	err := c.text.PrintfLine(format, args...)
+	if err != nil {
+		return 0, "", err
+	}
+	return c.text.ReadResponse(expect)
+}
+
+// fetch retrieves the named file, answering nil if the server says there's no such file.
+func (c *ftpClient) fetch(name string) ([]byte, error) {
+	data, err := c.passive()
+	if err != nil {
+		return nil, c.failed(err)
+	}
+	defer data.Close()
+	code, _, err := c.command(1, "RETR %s", name)
+	if code == 550 {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, c.failed(err)
+	}
+	content, err := ioutil.ReadAll(data)
+	data.Close()
+	if err == nil {
+		_, _, err = c.text.ReadResponse(2)
+	}
+	return content, c.failed(err)
+}
+
+// apply performs the steps one by one, calling done as each is done.
+func (c *ftpClient) apply(steps []transferStep, done func(transferStep)) error {
+	for _, step := range steps {
+		var code int
+		var err error
+		switch step.Op {
+		case makeDirectory:
+			code, _, err = c.command(2, "MKD %s", step.To)
+		case putFile:
+			err = c.store(step.From, step.To)
+		case removeFile:
+			code, _, err = c.command(2, "DELE %s", step.To)
+		}
+		if code == 550 {
+			// The directory exists, or the file doesn't.
+			err = nil
+		}
+		if err != nil {
+			return c.failed(fmt.Errorf("%s %s: %v", step.Op, step.To, err))
+		}
+		done(step)
+	}
+	_, _, err := c.command(2, "QUIT")
+	return c.failed(err)
+}
+
+// store uploads the local file from as the remote file to.
+func (c *ftpClient) store(from, to string) error {
+	f, err := os.Open(from)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	data, err := c.passive()
+	if err != nil {
+		return err
+	}
+	defer data.Close()
+	_, _, err = c.command(1, "STOR %s", to)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(data, f)
+	if err != nil {
+		return err
+	}
+	err = data.Close()
+	if err != nil {
+		return err
+	}
+	_, _, err = c.text.ReadResponse(2)
+	return err
+}
+
+// passive opens a data connection, as the server says to with its answer to EPSV, or, if it doesn't understand that, PASV.
+// The data connection goes to the host the control connection does, whatever address PASV gives,
+// since servers behind NAT often give their private addresses.
+func (c *ftpClient) passive() (net.Conn, error) {
+	var port int
+	code, message, err := c.command(2, "EPSV")
+	if err == nil {
+		// Entering Extended Passive Mode (|||6446|)
+		fields := strings.Split(message, "|")
+		if len(fields) != 5 {
+			return nil, fmt.Errorf("malformed EPSV response, %q", message)
+		}
+		port, err = strconv.Atoi(fields[3])
+	} else if code/100 == 5 {
+		// Entering Passive Mode (h1,h2,h3,h4,p1,p2)
+		_, message, err = c.command(2, "PASV")
+		if err != nil {
+			return nil, err
+		}
+		start, end := strings.IndexByte(message, '('), strings.IndexByte(message, ')')
+		if start < 0 || end < start {
+			return nil, fmt.Errorf("malformed PASV response, %q", message)
+		}
+		fields := strings.Split(message[start+1:end], ",")
+		if len(fields) != 6 {
+			return nil, fmt.Errorf("malformed PASV response, %q", message)
+		}
+		high, err1 := strconv.Atoi(fields[4])
+		low, err2 := strconv.Atoi(fields[5])
+		if err1 != nil || err2 != nil {
+			return nil, fmt.Errorf("malformed PASV response, %q", message)
+		}
+		port = high<<8 | low
+	}
+	if err != nil {
+		return nil, err
+	}
+	dialer := net.Dialer{Timeout: ftpDialTimeout}
+	return dialer.DialContext(c.ctx, "tcp", net.JoinHostPort(c.host, strconv.Itoa(port)))
+}
+
+// failed answers err, or the context's error, if the context was cancelled, so that an interruption isn't reported as a failure.
+func (c *ftpClient) failed(err error) error {
+	if err != nil && c.ctx.Err() != nil {
+		return c.ctx.Err()
+	}
+	return err
+}
+
+// close closes the control connection.
+func (c *ftpClient) close() {
+	select {
+	case <-c.stop:
+	default:
+		close(c.stop)
+	}
+	c.text.Close()
+}
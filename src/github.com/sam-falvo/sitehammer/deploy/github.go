@@ -0,0 +1,233 @@
+package deploy
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/sam-falvo/sitehammer/config"
+	"github.com/sam-falvo/sitehammer/dryrun"
+	"github.com/sam-falvo/sitehammer/failure"
+	"github.com/sam-falvo/sitehammer/report"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// defaultBranch names the branch GitHub Pages publishes from, unless the profile names another.
+const defaultBranch = "gh-pages"
+
+// GitHub publishes the files the manifest lists to GitHub Pages, committing them to a branch of a git repository and pushing it.
+// The repository is the profile's, or else the one the current directory's origin remote refers to,
+// and the branch is the profile's, or else gh-pages, which is begun afresh if it doesn't exist.
+// The files go at the root of the branch, or in the profile's directory, such as docs, leaving the rest of the branch alone.
+// A .nojekyll file is added, so GitHub publishes the files as they are, rather than through Jekyll,
+// along with a CNAME file naming the site's domain: the profile's cname, or else the host of the base URL, unless it's a github.io host.
+// If the profile asks for it, files on the branch (or in its directory) which the manifest doesn't list are deleted.
+// Each file added, changed, or deleted is reported, or, in a dry run, reported as it would be, without anything being committed.
+func GitHub(cfg config.DeployProfile, opts Options) (*Result, error) {
+	if cfg.Branch == "" {
+		cfg.Branch = defaultBranch
+	}
+	dir := strings.Trim(path.Clean("/"+filepath.ToSlash(cfg.Dir)), "/")
+	g := gitRunner{ctx: opts.context()}
+	repository := cfg.Repository
+	if repository == "" {
+		origin, err := g.run(".", "remote", "get-url", "origin")
+		if err != nil {
+			return nil, failure.Wrap(failure.Usage, fmt.Errorf("Deploy profile %s names no repository, and the current directory's has no origin remote: %v", opts.Profile, err))
+		}
+		repository = strings.TrimSpace(origin)
+	}
+	if _, err := os.Stat(repository); err == nil {
+		// A repository on the local filesystem, which git is about to be run elsewhere than here.
+		repository, _ = filepath.Abs(repository)
+	}
+
+	work, err := ioutil.TempDir("", "sitehammer-pages")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(work)
+	_, err = g.run(work, "init", "--quiet")
+	if err != nil {
+		return nil, err
+	}
+	heads, err := g.run(work, "ls-remote", "--heads", repository, cfg.Branch)
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(heads) == "" {
+		_, err = g.run(work, "checkout", "--quiet", "--orphan", cfg.Branch)
+	} else {
+		_, err = g.run(work, "fetch", "--quiet", "--depth", "1", repository, cfg.Branch)
+		if err == nil {
+			_, err = g.run(work, "checkout", "--quiet", "-B", cfg.Branch, "FETCH_HEAD")
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	site := filepath.Join(work, filepath.FromSlash(dir))
+	if cfg.Delete {
+		err = removeAllBut(site, ".git")
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, e := range opts.Manifest.Files {
+		if err := g.ctx.Err(); err != nil {
+			return nil, err
+		}
+		err = copyFile(filepath.Join(opts.OutputDir, filepath.FromSlash(e.Path)), filepath.Join(site, filepath.FromSlash(e.Path)))
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = ioutil.WriteFile(filepath.Join(site, ".nojekyll"), nil, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if cname := cnameFor(cfg, opts.BaseUrl); cname != "" {
+		err = ioutil.WriteFile(filepath.Join(site, "CNAME"), []byte(cname+"\n"), 0644)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	_, err = g.run(work, "add", "--all")
+	if err != nil {
+		return nil, err
+	}
+	changes, err := g.run(work, "diff", "--cached", "--name-status", "--no-renames", "-z")
+	if err != nil {
+		return nil, err
+	}
+	result := new(Result)
+	target := repository + "#" + cfg.Branch + ":"
+	fields := strings.Split(strings.TrimSuffix(changes, "\x00"), "\x00")
+	for i := 0; i+1 < len(fields); i += 2 {
+		status, name := fields[i], fields[i+1]
+		verb, reason := dryrun.Overwrite, dryrun.Changed
+		switch status {
+		case "A":
+			verb, reason = dryrun.Create, dryrun.New
+		case "D":
+			verb, reason = dryrun.Remove, dryrun.Orphaned
+		}
+		if verb == dryrun.Remove {
+			result.Deleted++
+		} else {
+			result.Uploaded++
+		}
+		switch {
+		case opts.DryRun:
+			dryrun.Report(verb, target+name, reason)
+		case verb == dryrun.Remove:
+			report.Event("delete", fmt.Sprintf("deleted %s (no longer built)", name), report.Fields{"path": name})
+		default:
+			report.Event("upload", fmt.Sprintf("committed %s (%s)", name, reason), report.Fields{"path": name, "reason": reason})
+		}
+	}
+	if unchanged := len(opts.Manifest.Files) - result.Uploaded; unchanged > 0 {
+		result.Unchanged = unchanged
+	}
+	if opts.DryRun || result.Uploaded+result.Deleted == 0 {
+		return result, nil
+	}
+
+	message := cfg.Message
+	if message == "" {
+		message = "Deploy the site, as built " + opts.Manifest.Built.UTC().Format("2006-01-02 15:04:05 MST")
+	}
+	if name, _ := g.run(work, "config", "user.name"); strings.TrimSpace(name) == "" {
+		g.env = []string{"GIT_AUTHOR_NAME=SiteHammer", "GIT_AUTHOR_EMAIL=sitehammer@localhost",
+			"GIT_COMMITTER_NAME=SiteHammer", "GIT_COMMITTER_EMAIL=sitehammer@localhost"}
+	}
+	_, err = g.run(work, "commit", "--quiet", "--message", message)
+	if err == nil {
+		_, err = g.run(work, "push", "--quiet", repository, "HEAD:refs/heads/"+cfg.Branch)
+	}
+	return result, err
+}
+
+// cnameFor answers the domain the site is published at, for GitHub Pages' CNAME file:
+// the profile's cname, or else the host of the site's base URL, unless it's GitHub's own, or local, in which case none is answered.
+func cnameFor(cfg config.DeployProfile, baseUrl string) string {
+	if cfg.Cname != "" {
+		return cfg.Cname
+	}
+	u, err := url.Parse(baseUrl)
+	if err != nil {
+		return ""
+	}
+	host := u.Hostname()
+	if host == "" || host == "localhost" || net.ParseIP(host) != nil || strings.HasSuffix(host, ".github.io") {
+		return ""
+	}
+	return host
+}
+
+// removeAllBut removes everything in dir, except the entry named keep, if dir exists.
+func removeAllBut(dir, keep string) error {
+	entries, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if e.Name() != keep {
+			err = os.RemoveAll(filepath.Join(dir, e.Name()))
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// copyFile copies the file from, or the file a link there refers to, to the file to, creating the directories it lies in.
+// The copy keeps the original's permissions.
+func copyFile(from, to string) error {
+	content, err := ioutil.ReadFile(from)
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(from)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(to), 0755)
+	if err != nil {
+		return err
+	}
+	os.Remove(to)
+	return ioutil.WriteFile(to, content, info.Mode().Perm())
+}
+
+// gitRunner runs git commands, which stop when its context is cancelled, with the given variables added to their environment.
+type gitRunner struct {
+	ctx context.Context
+	env []string
+}
+
+// run runs git with the given arguments in dir, answering its output, or an error carrying what it said if it fails.
+func (g gitRunner) run(dir string, args ...string) (string, error) {
+	cmd := exec.CommandContext(g.ctx, "git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(append(os.Environ(), "GIT_TERMINAL_PROMPT=0"), g.env...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", failure.Wrap(failure.IO, fmt.Errorf("git %s failed: %v %s", args[0], err, strings.TrimSpace(stderr.String())))
+	}
+	return string(out), nil
+}
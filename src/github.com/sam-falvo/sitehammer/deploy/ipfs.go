@@ -0,0 +1,275 @@
+package deploy
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/sam-falvo/sitehammer/config"
+	"github.com/sam-falvo/sitehammer/dryrun"
+	"github.com/sam-falvo/sitehammer/failure"
+	"github.com/sam-falvo/sitehammer/report"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ipfsEndpoint is the URL of a local IPFS node's API, unless the profile names another.
+const ipfsEndpoint = "http://127.0.0.1:5001"
+
+// ipfsRoot names the directory holding the site's files as they're added to IPFS.
+const ipfsRoot = "site"
+
+// cloudflareEndpoint is the URL of Cloudflare's API, which updates DNSLink records.
+const cloudflareEndpoint = "https://api.cloudflare.com/client/v4"
+
+// cloudflareTokenVar names the environment variable giving the Cloudflare API token which updates DNSLink records.
+const cloudflareTokenVar = "CLOUDFLARE_API_TOKEN"
+
+// Ipfs adds the files the manifest lists to IPFS, through the API of an IPFS node, such as Kubo, pinning them there,
+// and answers, as Result.Cid, the CID of the directory holding them, at which the site may be reached.
+// IPFS addresses content, so files the node holds already aren't stored again, but a deployment counts every file as uploaded.
+// If the profile names an IPNS key, the key's name is published as the directory, so it always leads to the latest deployment;
+// if it names a DNSLink domain, the domain's DNSLink record, kept by Cloudflare in the profile's zone, is updated to name the directory,
+// with the API token the CLOUDFLARE_API_TOKEN environment variable gives.
+// A dry run only works out the CID, storing nothing, and publishing nothing.
+func Ipfs(cfg config.DeployProfile, opts Options) (*Result, error) {
+	token := os.Getenv(cloudflareTokenVar)
+	if cfg.Dnslink != "" && token == "" {
+		return nil, failure.Wrap(failure.Usage, fmt.Errorf("No Cloudflare API token is set, to update the DNSLink record of %s; set %s.", cfg.Dnslink, cloudflareTokenVar))
+	}
+	endpoint := cfg.Endpoint
+	if endpoint == "" {
+		endpoint = ipfsEndpoint
+	}
+	ctx := opts.context()
+	c := &http.Client{Timeout: requestTimeout}
+
+	cid, err := ipfsAdd(ctx, c, strings.TrimSuffix(endpoint, "/"), opts)
+	if err != nil {
+		return nil, failure.Wrap(failure.IO, fmt.Errorf("Cannot add the site to IPFS: %v", err))
+	}
+	result := &Result{Uploaded: len(opts.Manifest.Files), Cid: cid, Url: "https://" + cid + ".ipfs.dweb.link/"}
+	if opts.DryRun {
+		if cfg.Ipns != "" {
+			dryrun.Report(dryrun.Overwrite, "ipns:"+cfg.Ipns, dryrun.Changed)
+		}
+		if cfg.Dnslink != "" {
+			dryrun.Report(dryrun.Overwrite, "dnslink:"+cfg.Dnslink, dryrun.Changed)
+		}
+		return result, nil
+	}
+	report.Event("add", fmt.Sprintf("added the site to IPFS as %s", cid), report.Fields{"cid": cid})
+
+	if cfg.Ipns != "" {
+		var published struct {
+			Name string
+		}
+		query := url.Values{"arg": {"/ipfs/" + cid}, "key": {cfg.Ipns}}
+		err = ipfsCall(ctx, c, strings.TrimSuffix(endpoint, "/")+"/api/v0/name/publish?"+query.Encode(), nil, "", &published)
+		if err != nil {
+			return result, failure.Wrap(failure.IO, fmt.Errorf("Cannot publish IPNS key %s: %v", cfg.Ipns, err))
+		}
+		report.Event("publish", fmt.Sprintf("published /ipns/%s as /ipfs/%s", published.Name, cid), report.Fields{"ipns": published.Name, "cid": cid})
+	}
+	if cfg.Dnslink != "" {
+		err = updateDnslink(ctx, c, token, cfg.Zone, cfg.Dnslink, cid)
+		if err != nil {
+			return result, failure.Wrap(failure.IO, fmt.Errorf("Cannot update the DNSLink record of %s: %v", cfg.Dnslink, err))
+		}
+		report.Event("dnslink", fmt.Sprintf("pointed the DNSLink record of %s at /ipfs/%s", cfg.Dnslink, cid), report.Fields{"domain": cfg.Dnslink, "cid": cid})
+	}
+	return result, nil
+}
+
+// ipfsAdd adds the files the manifest lists, within a directory, to IPFS, through the node's API at endpoint,
+// answering the directory's CID; in a dry run, the node works out the CID without storing anything.
+// The files are sent as they're read, along with each directory holding them, in depth-first order, as the API requires.
+func ipfsAdd(ctx context.Context, c *http.Client, endpoint string, opts Options) (string, error) {
+	names := make([]string, 0, len(opts.Manifest.Files))
+	for _, e := range opts.Manifest.Files {
+		names = append(names, e.Path)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		// Sorting slashes before anything else keeps each directory's entries together.
+		return strings.Replace(names[i], "/", "\x00", -1) < strings.Replace(names[j], "/", "\x00", -1)
+	})
+
+	body, writer := io.Pipe()
+	form := multipart.NewWriter(writer)
+	go func() {
+		part := func(name, contentType string) (io.Writer, error) {
+			header := make(textproto.MIMEHeader)
+			header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="file"; filename="%s"`, url.QueryEscape(name)))
+			header.Set("Content-Type", contentType)
+			return form.CreatePart(header)
+		}
+		_, err := part(ipfsRoot, "application/x-directory")
+		made := map[string]bool{".": true}
+		for _, name := range names {
+			if err != nil {
+				break
+			}
+			var dirs []string
+			for dir := path.Dir(name); !made[dir]; dir = path.Dir(dir) {
+				dirs = append([]string{dir}, dirs...)
+				made[dir] = true
+			}
+			for _, dir := range dirs {
+				if _, err = part(ipfsRoot+"/"+dir, "application/x-directory"); err != nil {
+					break
+				}
+			}
+			var w io.Writer
+			var f *os.File
+			if err == nil {
+				w, err = part(ipfsRoot+"/"+name, "application/octet-stream")
+			}
+			if err == nil {
+				f, err = os.Open(filepath.Join(opts.OutputDir, filepath.FromSlash(name)))
+			}
+			if err == nil {
+				_, err = io.Copy(w, f)
+				f.Close()
+			}
+		}
+		if err == nil {
+			err = form.Close()
+		}
+		writer.CloseWithError(err)
+	}()
+
+	query := url.Values{"pin": {"true"}, "cid-version": {"1"}, "quiet": {"true"}}
+	if opts.DryRun {
+		query.Set("only-hash", "true")
+	}
+	var cid string
+	err := ipfsCall(ctx, c, endpoint+"/api/v0/add?"+query.Encode(), body, form.FormDataContentType(), func(line []byte) error {
+		var added struct {
+			Name string
+			Hash string
+		}
+		err := json.Unmarshal(line, &added)
+		if added.Name == ipfsRoot {
+			cid = added.Hash
+		}
+		return err
+	})
+	body.Close()
+	if err == nil && cid == "" {
+		err = fmt.Errorf("the node didn't answer the site's CID")
+	}
+	return cid, err
+}
+
+// ipfsCall calls the IPFS API at the given URL, sending the body, of the given type, if any.
+// The answer is decoded into answer, unless it's nil, or, if it's a function, passed to it a line at a time, as the API streams it.
+func ipfsCall(ctx context.Context, c *http.Client, u string, body io.Reader, contentType string, answer interface{}) error {
+	req, err := http.NewRequest("POST", u, body)
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("User-Agent", userAgent)
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		raw, _ := ioutil.ReadAll(resp.Body)
+		var problem struct {
+			Message string
+		}
+		if json.Unmarshal(raw, &problem) == nil && problem.Message != "" {
+			return fmt.Errorf("%s (%s)", problem.Message, resp.Status)
+		}
+		return fmt.Errorf("the node answered %s", resp.Status)
+	}
+	switch a := answer.(type) {
+	case nil:
+		return nil
+	case func([]byte) error:
+		lines := bufio.NewScanner(resp.Body)
+		for lines.Scan() {
+			if err := a(lines.Bytes()); err != nil {
+				return err
+			}
+		}
+		return lines.Err()
+	default:
+		return json.NewDecoder(resp.Body).Decode(answer)
+	}
+}
+
+// updateDnslink points the DNSLink record of the domain, a TXT record of _dnslink beneath it, in the Cloudflare zone, at the CID,
+// changing the record if there is one, and adding one otherwise.
+func updateDnslink(ctx context.Context, c *http.Client, token, zone, domain, cid string) error {
+	name := "_dnslink." + strings.TrimSuffix(domain, ".")
+	records := cloudflareEndpoint + "/zones/" + url.PathEscape(zone) + "/dns_records"
+	call := func(method, u string, body interface{}, answer interface{}) error {
+		var content io.Reader
+		if body != nil {
+			encoded, err := json.Marshal(body)
+			if err != nil {
+				return err
+			}
+			content = bytes.NewReader(encoded)
+		}
+		req, err := http.NewRequest(method, u, content)
+		if err != nil {
+			return err
+		}
+		req = req.WithContext(ctx)
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("User-Agent", userAgent)
+		resp, err := c.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		var outcome struct {
+			Success bool
+			Errors  []struct {
+				Message string
+			}
+			Result json.RawMessage
+		}
+		err = json.NewDecoder(resp.Body).Decode(&outcome)
+		switch {
+		case err == nil && !outcome.Success && len(outcome.Errors) > 0:
+			return fmt.Errorf("%s (%s)", outcome.Errors[0].Message, resp.Status)
+		case err != nil || !outcome.Success:
+			return fmt.Errorf("the service answered %s", resp.Status)
+		case answer != nil:
+			return json.Unmarshal(outcome.Result, answer)
+		}
+		return nil
+	}
+
+	var existing []struct {
+		Id string
+	}
+	err := call("GET", records+"?"+url.Values{"type": {"TXT"}, "name": {name}}.Encode(), nil, &existing)
+	if err != nil {
+		return err
+	}
+	record := map[string]interface{}{"type": "TXT", "name": name, "content": "dnslink=/ipfs/" + cid, "ttl": 1}
+	if len(existing) == 0 {
+		return call("POST", records, record, nil)
+	}
+	return call("PUT", records+"/"+url.PathEscape(existing[0].Id), record, nil)
+}
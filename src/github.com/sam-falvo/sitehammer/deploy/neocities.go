@@ -0,0 +1,195 @@
+package deploy
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/sam-falvo/sitehammer/config"
+	"github.com/sam-falvo/sitehammer/dryrun"
+	"github.com/sam-falvo/sitehammer/failure"
+	"github.com/sam-falvo/sitehammer/precompress"
+	"github.com/sam-falvo/sitehammer/report"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// neocitiesEndpoint is the URL of the Neocities API, unless the profile names another.
+const neocitiesEndpoint = "https://neocities.org/api"
+
+// neocitiesKeyVar names the environment variable giving the API key of the Neocities site deployed to.
+const neocitiesKeyVar = "NEOCITIES_API_KEY"
+
+// neocitiesClient makes requests of the Neocities API.
+type neocitiesClient struct {
+	endpoint string
+	key      string
+	http     *http.Client
+	ctx      context.Context
+}
+
+// Neocities publishes the files the manifest lists to a Neocities site, through the Neocities API,
+// with the API key the NEOCITIES_API_KEY environment variable gives, which also tells which site.
+// Files are uploaded unless the site holds them already, as told by the SHA-1 digests of their content;
+// precompressed variants of files never are, since Neocities compresses what it serves itself, and refuses them.
+// If the profile asks for it, the site's files which the manifest doesn't list are deleted.
+// Each file uploaded or deleted is reported, or, in a dry run, reported as it would be, without anything being changed.
+func Neocities(cfg config.DeployProfile, opts Options) (*Result, error) {
+	key := os.Getenv(neocitiesKeyVar)
+	if key == "" {
+		return nil, failure.Wrap(failure.Usage, fmt.Errorf("No Neocities API key is set; set %s.", neocitiesKeyVar))
+	}
+	endpoint := cfg.Endpoint
+	if endpoint == "" {
+		endpoint = neocitiesEndpoint
+	}
+	c := &neocitiesClient{strings.TrimSuffix(endpoint, "/"), key, &http.Client{Timeout: requestTimeout}, opts.context()}
+
+	var listing struct {
+		Files []struct {
+			Path        string
+			IsDirectory bool   `json:"is_directory"`
+			Sha1Hash    string `json:"sha1_hash"`
+		}
+	}
+	err := c.do("GET", "/list", nil, "", &listing)
+	if err != nil {
+		return nil, failure.Wrap(failure.IO, fmt.Errorf("Cannot list the files of the Neocities site: %v", err))
+	}
+	published := make(map[string]string, len(listing.Files))
+	for _, f := range listing.Files {
+		if !f.IsDirectory {
+			published[f.Path] = f.Sha1Hash
+		}
+	}
+
+	listed := make(map[string]bool, len(opts.Manifest.Files))
+	for _, e := range opts.Manifest.Files {
+		listed[e.Path] = true
+	}
+	result := new(Result)
+	for _, e := range opts.Manifest.Files {
+		if err := c.ctx.Err(); err != nil {
+			return result, err
+		}
+		if ext := path.Ext(e.Path); (ext == precompress.GzipExt || ext == precompress.BrotliExt) && listed[strings.TrimSuffix(e.Path, ext)] {
+			// Neocities compresses what it serves itself, and refuses such files.
+			continue
+		}
+		filename := filepath.Join(opts.OutputDir, filepath.FromSlash(e.Path))
+		content, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return result, err
+		}
+		sum := sha1.Sum(content)
+		digest, ok := published[e.Path]
+		delete(published, e.Path)
+		verb, reason := dryrun.Create, dryrun.New
+		switch {
+		case ok && digest == hex.EncodeToString(sum[:]):
+			result.Unchanged++
+			continue
+		case ok:
+			verb, reason = dryrun.Overwrite, dryrun.Changed
+		}
+		result.Uploaded++
+		if opts.DryRun {
+			dryrun.Report(verb, "neocities:"+e.Path, reason)
+			continue
+		}
+		err = c.upload(e.Path, content)
+		if err != nil {
+			return result, failure.Wrap(failure.IO, fmt.Errorf("Cannot upload %s to Neocities: %v", e.Path, err))
+		}
+		report.Event("upload", fmt.Sprintf("uploaded %s (%s)", e.Path, reason), report.Fields{"path": e.Path, "reason": reason})
+	}
+	if !cfg.Delete {
+		return result, nil
+	}
+
+	removed := make([]string, 0, len(published))
+	for name := range published {
+		removed = append(removed, name)
+	}
+	sort.Strings(removed)
+	for _, name := range removed {
+		result.Deleted++
+		if opts.DryRun {
+			dryrun.Report(dryrun.Remove, "neocities:"+name, dryrun.Orphaned)
+			continue
+		}
+		form := url.Values{"filenames[]": {name}}
+		err = c.do("POST", "/delete", strings.NewReader(form.Encode()), "application/x-www-form-urlencoded", nil)
+		if err != nil {
+			return result, failure.Wrap(failure.IO, fmt.Errorf("Cannot delete %s from Neocities: %v", name, err))
+		}
+		report.Event("delete", fmt.Sprintf("deleted %s (no longer built)", name), report.Fields{"path": name})
+	}
+	return result, nil
+}
+
+// upload uploads content as that of the site's file name, as a form field named for the file.
+func (c *neocitiesClient) upload(name string, content []byte) error {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile(name, path.Base(name))
+	if err == nil {
+		_, err = part.Write(content)
+	}
+	if err == nil {
+		err = form.Close()
+	}
+	if err != nil {
+		return err
+	}
+	return c.do("POST", "/upload", &body, form.FormDataContentType(), nil)
+}
+
+// do makes a request of the Neocities API, given the path of the resource within it, and the body, of the given type, if any,
+// decoding the JSON answered into answer, unless it's nil.
+// A response which isn't a success is answered as an error, described as Neocities describes it.
+func (c *neocitiesClient) do(method, resource string, body io.Reader, contentType string, answer interface{}) error {
+	req, err := http.NewRequest(method, c.endpoint+resource, body)
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(c.ctx)
+	req.Header.Set("Authorization", "Bearer "+c.key)
+	req.Header.Set("User-Agent", userAgent)
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	raw, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	var outcome struct {
+		Result  string
+		Message string
+	}
+	if json.Unmarshal(raw, &outcome) != nil || outcome.Result != "success" {
+		if outcome.Message != "" {
+			return fmt.Errorf("%s (%s)", strings.TrimSuffix(outcome.Message, "."), resp.Status)
+		}
+		return fmt.Errorf("the service answered %s", resp.Status)
+	}
+	if answer == nil {
+		return nil
+	}
+	return json.Unmarshal(raw, answer)
+}
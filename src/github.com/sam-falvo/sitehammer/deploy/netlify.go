@@ -0,0 +1,269 @@
+package deploy
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/sam-falvo/sitehammer/config"
+	"github.com/sam-falvo/sitehammer/dryrun"
+	"github.com/sam-falvo/sitehammer/failure"
+	"github.com/sam-falvo/sitehammer/report"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// netlifyEndpoint is the URL of Netlify's API, unless the profile names another.
+const netlifyEndpoint = "https://api.netlify.com/api/v1"
+
+// Environment variables giving Netlify's credentials, as Netlify's own command does.
+const (
+	netlifySiteVar  = "NETLIFY_SITE_ID"
+	netlifyTokenVar = "NETLIFY_AUTH_TOKEN"
+)
+
+// netlifyReadyTimeout limits how long a deployment waits for Netlify to finish processing the files uploaded.
+const netlifyReadyTimeout = 2 * time.Minute
+
+// netlifyClient makes requests of Netlify's API.
+type netlifyClient struct {
+	endpoint string
+	token    string
+	http     *http.Client
+	ctx      context.Context
+}
+
+// netlifyDeploy is the part of a Netlify deploy of interest.
+type netlifyDeploy struct {
+	Id           string
+	State        string
+	Required     []string
+	ErrorMessage string `json:"error_message"`
+	SslUrl       string `json:"ssl_url"`
+	DeploySslUrl string `json:"deploy_ssl_url"`
+}
+
+// Netlify publishes the files the manifest lists to a Netlify site, through Netlify's API, as a new deploy.
+// The site is the profile's, or else the one the NETLIFY_SITE_ID environment variable names;
+// the NETLIFY_AUTH_TOKEN environment variable gives the personal access token authorizing the deploy.
+// A deploy lists every file by the SHA-1 digest of its content, and only the files whose content Netlify lacks are uploaded;
+// those the deploy doesn't list, which the build no longer produces, are no longer published, whatever the profile says.
+// A draft deploy, made if the profile or opts asks for one, is published at an address of its own, for preview,
+// rather than replacing the live site; Result.Url gives the address.
+// Files uploaded, and those no longer published, are reported; a dry run compares the files with those of the live site,
+// reporting the files that differ, without deploying anything.
+func Netlify(cfg config.DeployProfile, opts Options) (*Result, error) {
+	site := cfg.Site
+	if site == "" {
+		site = os.Getenv(netlifySiteVar)
+	}
+	if site == "" {
+		return nil, failure.Wrap(failure.Usage, fmt.Errorf("Deploy profile %s names no Netlify site; name one, or set %s.", opts.Profile, netlifySiteVar))
+	}
+	token := os.Getenv(netlifyTokenVar)
+	if token == "" {
+		return nil, failure.Wrap(failure.Usage, fmt.Errorf("No Netlify access token is set; set %s.", netlifyTokenVar))
+	}
+	endpoint := cfg.Endpoint
+	if endpoint == "" {
+		endpoint = netlifyEndpoint
+	}
+	c := &netlifyClient{strings.TrimSuffix(endpoint, "/"), token, &http.Client{Timeout: requestTimeout}, opts.context()}
+
+	digests := make(map[string]string, len(opts.Manifest.Files))
+	paths := make(map[string]string)
+	for _, e := range opts.Manifest.Files {
+		content, err := ioutil.ReadFile(filepath.Join(opts.OutputDir, filepath.FromSlash(e.Path)))
+		if err != nil {
+			return nil, err
+		}
+		sum := sha1.Sum(content)
+		digest := hex.EncodeToString(sum[:])
+		digests["/"+e.Path] = digest
+		paths[digest] = e.Path
+	}
+	var live []struct {
+		Path string
+		Sha  string
+	}
+	err := c.do("GET", "/sites/"+uriEncode(site, true)+"/files", nil, &live)
+	if err != nil {
+		return nil, failure.Wrap(failure.IO, fmt.Errorf("Cannot list the files of Netlify site %s: %v", site, err))
+	}
+	published := make(map[string]string, len(live))
+	for _, f := range live {
+		published[f.Path] = f.Sha
+	}
+
+	result := new(Result)
+	reasons := make(map[string]string)
+	for _, e := range opts.Manifest.Files {
+		digest, ok := published["/"+e.Path]
+		switch {
+		case !ok:
+			reasons[e.Path] = dryrun.New
+		case digest != digests["/"+e.Path]:
+			reasons[e.Path] = dryrun.Changed
+		default:
+			result.Unchanged++
+		}
+	}
+	var removed []string
+	for name := range published {
+		if _, ok := digests[name]; !ok {
+			removed = append(removed, strings.TrimPrefix(name, "/"))
+		}
+	}
+	sort.Strings(removed)
+	result.Deleted = len(removed)
+	if opts.DryRun {
+		for _, e := range opts.Manifest.Files {
+			switch reasons[e.Path] {
+			case dryrun.New:
+				dryrun.Report(dryrun.Create, "netlify:"+site+"/"+e.Path, dryrun.New)
+			case dryrun.Changed:
+				dryrun.Report(dryrun.Overwrite, "netlify:"+site+"/"+e.Path, dryrun.Changed)
+			}
+		}
+		for _, name := range removed {
+			dryrun.Report(dryrun.Remove, "netlify:"+site+"/"+name, dryrun.Orphaned)
+		}
+		result.Uploaded = len(reasons)
+		return result, nil
+	}
+
+	var deploy netlifyDeploy
+	request := map[string]interface{}{"files": digests, "draft": cfg.Draft || opts.Draft}
+	err = c.do("POST", "/sites/"+uriEncode(site, true)+"/deploys", request, &deploy)
+	if err != nil {
+		return nil, failure.Wrap(failure.IO, fmt.Errorf("Cannot create a deploy of Netlify site %s: %v", site, err))
+	}
+	for _, digest := range deploy.Required {
+		if err := c.ctx.Err(); err != nil {
+			return result, err
+		}
+		name, ok := paths[digest]
+		if !ok {
+			continue
+		}
+		err = c.upload(deploy.Id, name, filepath.Join(opts.OutputDir, filepath.FromSlash(name)))
+		if err != nil {
+			return result, failure.Wrap(failure.IO, fmt.Errorf("Cannot upload %s to Netlify: %v", name, err))
+		}
+		reason := reasons[name]
+		if reason == "" {
+			reason = dryrun.Changed
+		}
+		result.Uploaded++
+		report.Event("upload", fmt.Sprintf("uploaded %s (%s)", name, reason), report.Fields{"path": name, "reason": reason})
+	}
+	// Files whose content Netlify held already, under another name perhaps, weren't uploaded.
+	result.Unchanged = len(opts.Manifest.Files) - result.Uploaded
+	for _, name := range removed {
+		report.Event("delete", fmt.Sprintf("deleted %s (no longer built)", name), report.Fields{"path": name})
+	}
+
+	deploy, err = c.waitUntilReady(deploy)
+	if err != nil {
+		return result, failure.Wrap(failure.IO, fmt.Errorf("Netlify deploy %s failed: %v", deploy.Id, err))
+	}
+	result.Url = deploy.DeploySslUrl
+	if !cfg.Draft && !opts.Draft && deploy.SslUrl != "" {
+		result.Url = deploy.SslUrl
+	}
+	return result, nil
+}
+
+// upload uploads the named file's content as that of the published file name, within the given deploy.
+func (c *netlifyClient) upload(deployId, name, filename string) error {
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	return c.do("PUT", "/deploys/"+uriEncode(deployId, true)+"/files/"+uriEncode(name, false), content, nil)
+}
+
+// waitUntilReady waits for Netlify to finish processing a deploy, answering the deploy as it then stands.
+// A deploy Netlify takes too long over isn't an error, since it's published once Netlify's done with it, but it's warned of.
+func (c *netlifyClient) waitUntilReady(deploy netlifyDeploy) (netlifyDeploy, error) {
+	deadline := time.Now().Add(netlifyReadyTimeout)
+	for {
+		switch {
+		case deploy.State == "ready":
+			return deploy, nil
+		case deploy.State == "error":
+			return deploy, fmt.Errorf("%s", deploy.ErrorMessage)
+		case time.Now().After(deadline):
+			report.Warning("Netlify is still processing deploy %s (%s); it's published once it's ready", deploy.Id, deploy.State)
+			return deploy, nil
+		}
+		select {
+		case <-c.ctx.Done():
+			return deploy, c.ctx.Err()
+		case <-time.After(2 * time.Second):
+		}
+		err := c.do("GET", "/deploys/"+uriEncode(deploy.Id, true), nil, &deploy)
+		if err != nil {
+			return deploy, err
+		}
+	}
+}
+
+// do makes a request of Netlify's API, given the path of the resource within it, decoding the JSON answered into answer, unless it's nil.
+// A body given as bytes is sent as they are; anything else, encoded as JSON.
+// A response with a status other than success is answered as an error, described as Netlify describes it.
+func (c *netlifyClient) do(method, resource string, body interface{}, answer interface{}) error {
+	var content io.Reader
+	contentType := "application/json"
+	switch b := body.(type) {
+	case nil:
+	case []byte:
+		content, contentType = bytes.NewReader(b), "application/octet-stream"
+	default:
+		encoded, err := json.Marshal(b)
+		if err != nil {
+			return err
+		}
+		content = bytes.NewReader(encoded)
+	}
+	req, err := http.NewRequest(method, c.endpoint+resource, content)
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(c.ctx)
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	req.Header.Set("User-Agent", userAgent)
+	if content != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	raw, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode/100 != 2 {
+		var problem struct {
+			Message string
+		}
+		if json.Unmarshal(raw, &problem) == nil && problem.Message != "" {
+			return fmt.Errorf("%s (%s)", strings.TrimSuffix(problem.Message, "."), resp.Status)
+		}
+		return fmt.Errorf("the service answered %s", resp.Status)
+	}
+	if answer == nil {
+		return nil
+	}
+	return json.Unmarshal(raw, answer)
+}
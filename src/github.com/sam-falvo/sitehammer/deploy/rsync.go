@@ -0,0 +1,165 @@
+package deploy
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"github.com/sam-falvo/sitehammer/config"
+	"github.com/sam-falvo/sitehammer/dryrun"
+	"github.com/sam-falvo/sitehammer/failure"
+	"github.com/sam-falvo/sitehammer/report"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Rsync publishes the files the manifest lists to the profile's destination with rsync, reaching a remote host with the profile's ssh command, if any.
+// Rsync copies the files whose sizes or modification times differ from those at the destination, and only the parts of them that differ;
+// if the profile asks for it, it also deletes the files at the destination which the manifest doesn't list, unless they're excluded.
+// Each file copied or deleted is reported as rsync reports it, or as it would be, in a dry run.
+func Rsync(cfg config.DeployProfile, opts Options) (*Result, error) {
+	if cfg.Destination == "" {
+		return nil, failure.Wrap(failure.Usage, fmt.Errorf("Deploy profile %s names no destination.", opts.Profile))
+	}
+	filter, err := hideUnlisted(opts)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(filter)
+
+	args := []string{"--archive", "--compress", "--itemize-changes"}
+	if cfg.Ssh != "" {
+		args = append(args, "--rsh", cfg.Ssh)
+	}
+	if cfg.Delete {
+		args = append(args, "--delete")
+	}
+	if opts.DryRun {
+		args = append(args, "--dry-run")
+	}
+	for _, pattern := range cfg.Exclude {
+		args = append(args, "--exclude", pattern)
+	}
+	args = append(args, "--filter", "merge "+filter, strings.TrimSuffix(opts.OutputDir, "/")+"/", cfg.Destination)
+	cmd := exec.CommandContext(opts.context(), "rsync", args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	err = cmd.Start()
+	if err != nil {
+		return nil, failure.Wrap(failure.IO, fmt.Errorf("Cannot run rsync: %v", err))
+	}
+
+	result := new(Result)
+	lines := bufio.NewScanner(stdout)
+	for lines.Scan() {
+		reportChange(lines.Text(), cfg.Destination, opts.DryRun, result)
+	}
+	err = cmd.Wait()
+	if err != nil {
+		return result, failure.Wrap(failure.IO, fmt.Errorf("Cannot deploy to %s: rsync failed: %v %s", cfg.Destination, err, strings.TrimSpace(stderr.String())))
+	}
+	if unchanged := len(opts.Manifest.Files) - result.Uploaded; unchanged > 0 {
+		result.Unchanged = unchanged
+	}
+	return result, nil
+}
+
+// reportChange reports a file copied or deleted, as described by a line of rsync's itemized changes, counting it in result.
+// An itemized change begins with a field of flags: its first tells what happened, < or > for a file copied, c for a link created,
+// and * for a message, such as deleting; its second, the kind of file, f for a regular file, d for a directory, L for a link;
+// the rest, the attributes which changed, all plus signs for a new file.
+// Changes to directories, and the lines of anything else rsync says, aren't reported.
+func reportChange(line, destination string, dryRun bool, result *Result) {
+	fields := strings.SplitN(line, " ", 2)
+	if len(fields) != 2 || len(fields[0]) < 2 {
+		return
+	}
+	flags, name := fields[0], strings.TrimLeft(fields[1], " ")
+	if flags[1] == 'L' {
+		name = strings.SplitN(name, " -> ", 2)[0]
+	}
+	target := strings.TrimSuffix(destination, "/") + "/" + name
+	switch {
+	case flags == "*deleting":
+		if strings.HasSuffix(name, "/") {
+			return
+		}
+		result.Deleted++
+		if dryRun {
+			dryrun.Report(dryrun.Remove, target, dryrun.Orphaned)
+		} else {
+			report.Event("delete", fmt.Sprintf("deleted %s (no longer built)", name), report.Fields{"path": name})
+		}
+	case strings.IndexByte("<>c", flags[0]) >= 0 && (flags[1] == 'f' || flags[1] == 'L'):
+		verb, reason := dryrun.Overwrite, dryrun.Changed
+		if strings.Trim(flags[2:], "+") == "" {
+			verb, reason = dryrun.Create, dryrun.New
+		}
+		result.Uploaded++
+		if dryRun {
+			dryrun.Report(verb, target, reason)
+		} else {
+			report.Event("upload", fmt.Sprintf("uploaded %s (%s)", name, reason), report.Fields{"path": name, "reason": reason})
+		}
+	}
+}
+
+// hideUnlisted writes a file of rsync filter rules hiding the files in the output directory which the manifest doesn't list, answering its name.
+// Hiding them, rather than excluding them, leaves them unprotected at the destination, where they're deleted like any other file no longer built.
+func hideUnlisted(opts Options) (string, error) {
+	listed := make(map[string]bool, len(opts.Manifest.Files))
+	for _, e := range opts.Manifest.Files {
+		listed[e.Path] = true
+	}
+	var rules bytes.Buffer
+	err := filepath.Walk(opts.OutputDir, func(filename string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		name, err := filepath.Rel(opts.OutputDir, filename)
+		if err != nil {
+			return err
+		}
+		name = filepath.ToSlash(name)
+		if !listed[name] {
+			rules.WriteString("H /" + escapePattern(name) + "\n")
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	f, err := ioutil.TempFile("", "sitehammer-rsync-filter")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	_, err = f.Write(rules.Bytes())
+	if err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+// escapePattern answers an rsync pattern matching the given name alone.
+// Rsync takes backslashes for escapes only within patterns holding wildcards, so names without any are left alone.
+func escapePattern(name string) string {
+	if !strings.ContainsAny(name, "*?[") {
+		return name
+	}
+	var b strings.Builder
+	for _, r := range name {
+		if strings.ContainsRune(`*?[\`, r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
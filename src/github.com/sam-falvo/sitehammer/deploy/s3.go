@@ -0,0 +1,365 @@
+package deploy
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"github.com/sam-falvo/sitehammer/config"
+	"github.com/sam-falvo/sitehammer/directory"
+	"github.com/sam-falvo/sitehammer/dryrun"
+	"github.com/sam-falvo/sitehammer/failure"
+	"github.com/sam-falvo/sitehammer/report"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// userAgent identifies the deployer to the services it calls.
+const userAgent = "SiteHammer deployer"
+
+// defaultRegion names the AWS region of buckets whose region isn't configured.
+const defaultRegion = "us-east-1"
+
+// requestTimeout limits how long any one request may take, uploads included.
+const requestTimeout = 5 * time.Minute
+
+// cloudFrontEndpoint is the URL of CloudFront's API, which is global, signed as though it lived in us-east-1.
+const cloudFrontEndpoint = "https://cloudfront.amazonaws.com/2020-05-31"
+
+// maxInvalidationPaths limits the paths invalidated one by one; a deployment changing more invalidates everything beneath the site instead,
+// since CloudFront limits the paths being invalidated at once, and charges for each.
+const maxInvalidationPaths = 1000
+
+// awsClient makes signed requests of AWS.
+type awsClient struct {
+	credentials credentials
+	http        *http.Client
+	ctx         context.Context
+}
+
+// S3 publishes the files the manifest lists to the configured S3 bucket, under its prefix,
+// uploading those whose objects are missing, hold different content, or carry other Content-Type or Cache-Control headers than they'd be given now,
+// deleting the objects under the prefix which the manifest doesn't list, if the configuration asks for it,
+// and invalidating the paths of the objects changed or deleted in the configured CloudFront distribution, if any.
+// Each object uploaded, deleted, or invalidated is reported as it is, or as it would be, in a dry run.
+func S3(cfg config.DeployProfile, opts Options) (*Result, error) {
+	if cfg.Bucket == "" {
+		return nil, failure.Wrap(failure.Usage, fmt.Errorf("Deploy profile %s names no S3 bucket.", opts.Profile))
+	}
+	if cfg.Region == "" {
+		cfg.Region = defaultRegion
+	}
+	creds, err := envCredentials()
+	if err != nil {
+		return nil, err
+	}
+	c := &awsClient{creds, &http.Client{Timeout: requestTimeout}, opts.context()}
+	b := bucket{c, cfg}
+	prefix := strings.Trim(cfg.Prefix, "/")
+	if prefix != "" {
+		prefix += "/"
+	}
+
+	objects, err := b.list(prefix)
+	if err != nil {
+		return nil, failure.Wrap(failure.IO, fmt.Errorf("Cannot list the objects of s3://%s/%s: %v", cfg.Bucket, prefix, err))
+	}
+	result := new(Result)
+	var invalidate []string
+	for _, e := range opts.Manifest.Files {
+		if err := c.ctx.Err(); err != nil {
+			return result, err
+		}
+		key := prefix + e.Path
+		etag, exists := objects[key]
+		delete(objects, key)
+		content, err := ioutil.ReadFile(filepath.Join(opts.OutputDir, filepath.FromSlash(e.Path)))
+		if err != nil {
+			return result, err
+		}
+		sum := md5.Sum(content)
+		verb, reason := dryrun.Create, dryrun.New
+		if exists {
+			verb, reason = dryrun.Overwrite, dryrun.Changed
+		}
+		if etag == `"`+hex.EncodeToString(sum[:])+`"` {
+			// The listing doesn't give the headers, so a change to the cache_control rules shows only in the object's own.
+			differ, err := b.headersDiffer(key, e.Path)
+			if err != nil {
+				return result, failure.Wrap(failure.IO, fmt.Errorf("Cannot read the headers of s3://%s/%s: %v", cfg.Bucket, key, err))
+			}
+			if !differ {
+				result.Unchanged++
+				continue
+			}
+			reason = dryrun.Headers
+		}
+		if exists {
+			invalidate = append(invalidate, invalidationPaths(e.Path)...)
+		}
+		result.Uploaded++
+		if opts.DryRun {
+			dryrun.Report(verb, "s3://"+cfg.Bucket+"/"+key, reason)
+			continue
+		}
+		err = b.put(key, content, sum[:], e.Path)
+		if err != nil {
+			return result, failure.Wrap(failure.IO, fmt.Errorf("Cannot upload %s to s3://%s/%s: %v", e.Path, cfg.Bucket, key, err))
+		}
+		report.Event("upload", fmt.Sprintf("uploaded %s (%s)", e.Path, reason), report.Fields{"path": e.Path, "key": key, "reason": reason})
+	}
+
+	if cfg.Delete {
+		keys := make([]string, 0, len(objects))
+		for key := range objects {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if err := c.ctx.Err(); err != nil {
+				return result, err
+			}
+			result.Deleted++
+			invalidate = append(invalidate, invalidationPaths(strings.TrimPrefix(key, prefix))...)
+			if opts.DryRun {
+				dryrun.Report(dryrun.Remove, "s3://"+cfg.Bucket+"/"+key, dryrun.Orphaned)
+				continue
+			}
+			err = b.remove(key)
+			if err != nil {
+				return result, failure.Wrap(failure.IO, fmt.Errorf("Cannot delete s3://%s/%s: %v", cfg.Bucket, key, err))
+			}
+			report.Event("delete", fmt.Sprintf("deleted %s (no longer built)", key), report.Fields{"key": key})
+		}
+	}
+
+	if cfg.Distribution == "" || len(invalidate) == 0 {
+		return result, nil
+	}
+	if len(invalidate) > maxInvalidationPaths {
+		invalidate = []string{"/*"}
+	}
+	if opts.DryRun {
+		report.Event("dry-run", fmt.Sprintf("would invalidate %d path(s) in CloudFront distribution %s: %s", len(invalidate), cfg.Distribution, strings.Join(invalidate, " ")),
+			report.Fields{"verb": "invalidate", "distribution": cfg.Distribution, "paths": invalidate})
+		return result, nil
+	}
+	result.Invalidation, err = c.invalidate(cfg.Distribution, invalidate)
+	if err != nil {
+		return result, failure.Wrap(failure.IO, fmt.Errorf("Cannot invalidate the changed paths in CloudFront distribution %s: %v", cfg.Distribution, err))
+	}
+	report.Event("invalidate", fmt.Sprintf("invalidated %d path(s) in CloudFront distribution %s, as invalidation %s", len(invalidate), cfg.Distribution, result.Invalidation),
+		report.Fields{"distribution": cfg.Distribution, "invalidation": result.Invalidation, "paths": invalidate})
+	return result, nil
+}
+
+// invalidationPaths answers the paths by which readers reach the published file with the given slash-separated name:
+// its own, and, for an index page, its directory's.
+func invalidationPaths(name string) []string {
+	paths := []string{"/" + uriEncode(name, false)}
+	if path.Base(name) == "index.html" {
+		paths = append(paths, "/"+uriEncode(strings.TrimSuffix(name, "index.html"), false))
+	}
+	return paths
+}
+
+// bucket makes requests of an S3 bucket.
+type bucket struct {
+	client *awsClient
+	cfg    config.DeployProfile
+}
+
+// url answers the URL of the object with the given key, or, given an empty key, of the bucket itself.
+// Amazon's buckets are addressed by virtual host, unless their names hold dots, which TLS certificates can't match;
+// those of other services, by path.
+func (b bucket) url(key string) string {
+	switch {
+	case b.cfg.Endpoint != "":
+		return strings.TrimSuffix(b.cfg.Endpoint, "/") + "/" + b.cfg.Bucket + "/" + uriEncode(key, false)
+	case strings.Contains(b.cfg.Bucket, "."):
+		return "https://s3." + b.cfg.Region + ".amazonaws.com/" + b.cfg.Bucket + "/" + uriEncode(key, false)
+	}
+	return "https://" + b.cfg.Bucket + ".s3." + b.cfg.Region + ".amazonaws.com/" + uriEncode(key, false)
+}
+
+// listing is the part of an S3 ListObjectsV2 response of interest.
+type listing struct {
+	Contents []struct {
+		Key  string
+		ETag string
+	}
+	IsTruncated           bool
+	NextContinuationToken string
+}
+
+// list answers the ETags of the bucket's objects whose keys begin with prefix, by key.
+// Keys ending in a slash, which some tools create to stand for folders, are left out.
+func (b bucket) list(prefix string) (map[string]string, error) {
+	objects := make(map[string]string)
+	query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+	for {
+		body, err := b.client.do("GET", b.url("")+"?"+query.Encode(), nil, nil, b.cfg.Region, "s3")
+		if err != nil {
+			return nil, err
+		}
+		var page listing
+		err = xml.Unmarshal(body, &page)
+		if err != nil {
+			return nil, fmt.Errorf("Cannot understand the listing: %v", err)
+		}
+		for _, o := range page.Contents {
+			if !strings.HasSuffix(o.Key, "/") {
+				objects[o.Key] = o.ETag
+			}
+		}
+		if !page.IsTruncated || page.NextContinuationToken == "" {
+			return objects, nil
+		}
+		query.Set("continuation-token", page.NextContinuationToken)
+	}
+}
+
+// put uploads content, whose MD5 sum is given, as the object with the given key,
+// typed and given a Cache-Control header to suit the published file with the given slash-separated name.
+func (b bucket) put(key string, content, sum []byte, name string) error {
+	header := map[string]string{
+		"Content-Type": contentType(name),
+		"Content-MD5":  base64.StdEncoding.EncodeToString(sum),
+	}
+	if cc := b.cacheControl(name); cc != "" {
+		header["Cache-Control"] = cc
+	}
+	_, err := b.client.do("PUT", b.url(key), header, content, b.cfg.Region, "s3")
+	return err
+}
+
+// headersDiffer answers true if the object with the given key carries other Content-Type or Cache-Control headers than put would give it now,
+// as when the configuration's cache_control rules have changed since it was uploaded.
+func (b bucket) headersDiffer(key, name string) (bool, error) {
+	_, header, err := b.client.exchange("HEAD", b.url(key), nil, nil, b.cfg.Region, "s3")
+	if err != nil {
+		return false, err
+	}
+	return header.Get("Content-Type") != contentType(name) || header.Get("Cache-Control") != b.cacheControl(name), nil
+}
+
+// remove deletes the object with the given key.
+func (b bucket) remove(key string) error {
+	_, err := b.client.do("DELETE", b.url(key), nil, nil, b.cfg.Region, "s3")
+	return err
+}
+
+// cacheControl answers the Cache-Control header given by the first rule matching the published file with the given slash-separated name,
+// or an empty string if no rule matches.
+func (b bucket) cacheControl(name string) string {
+	for _, r := range b.cfg.CacheControl {
+		if ok, _ := directory.MatchGlob(r.Pattern, name); ok {
+			return r.Value
+		}
+	}
+	return ""
+}
+
+// contentType answers the media type of a published file, by its extension, or application/octet-stream if it's unknown.
+func contentType(name string) string {
+	if t := mime.TypeByExtension(path.Ext(name)); t != "" {
+		return t
+	}
+	return "application/octet-stream"
+}
+
+// invalidationBatch is the body of a CloudFront CreateInvalidation request.
+type invalidationBatch struct {
+	XMLName         xml.Name `xml:"http://cloudfront.amazonaws.com/doc/2020-05-31/ InvalidationBatch"`
+	Quantity        int      `xml:"Paths>Quantity"`
+	Items           []string `xml:"Paths>Items>Path"`
+	CallerReference string
+}
+
+// invalidate invalidates the given paths in a CloudFront distribution, answering the invalidation's ID.
+func (c *awsClient) invalidate(distribution string, paths []string) (string, error) {
+	body, err := xml.Marshal(invalidationBatch{
+		Quantity:        len(paths),
+		Items:           paths,
+		CallerReference: "sitehammer-" + time.Now().UTC().Format("20060102T150405.000000000Z"),
+	})
+	if err != nil {
+		return "", err
+	}
+	answer, err := c.do("POST", cloudFrontEndpoint+"/distribution/"+uriEncode(distribution, true)+"/invalidation",
+		map[string]string{"Content-Type": "text/xml"}, body, "us-east-1", "cloudfront")
+	if err != nil {
+		return "", err
+	}
+	var invalidation struct {
+		Id string
+	}
+	err = xml.Unmarshal(answer, &invalidation)
+	return invalidation.Id, err
+}
+
+// do makes a request of the given region and service of AWS, with the given headers and body, answering the response's body.
+// A response with a status other than success is answered as an error, described as AWS describes it.
+func (c *awsClient) do(method, rawUrl string, header map[string]string, body []byte, region, service string) ([]byte, error) {
+	answer, _, err := c.exchange(method, rawUrl, header, body, region, service)
+	return answer, err
+}
+
+// exchange works like do, but answers the response's headers along with its body.
+func (c *awsClient) exchange(method, rawUrl string, header map[string]string, body []byte, region, service string) ([]byte, http.Header, error) {
+	req, err := http.NewRequest(method, rawUrl, bytes.NewReader(body))
+	if err != nil {
+		return nil, nil, err
+	}
+	req = req.WithContext(c.ctx)
+	for name, value := range header {
+		req.Header.Set(name, value)
+	}
+	c.credentials.sign(req, body, region, service, time.Now())
+	req.Header.Set("User-Agent", userAgent)
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	answer, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		return nil, nil, awsError(resp.Status, answer)
+	}
+	return answer, resp.Header, nil
+}
+
+// awsError describes a failed request by the code and message of the error document answered, if any, and its status.
+// S3 answers an Error element; CloudFront wraps it in an ErrorResponse.
+func awsError(status string, answer []byte) error {
+	var doc struct {
+		Code    string
+		Message string
+		Error   struct {
+			Code    string
+			Message string
+		}
+	}
+	if xml.Unmarshal(answer, &doc) == nil {
+		if doc.Code == "" {
+			doc.Code, doc.Message = doc.Error.Code, doc.Error.Message
+		}
+		if doc.Code != "" {
+			return fmt.Errorf("%s: %s (%s)", doc.Code, strings.TrimSuffix(doc.Message, "."), status)
+		}
+	}
+	return fmt.Errorf("the service answered %s", status)
+}
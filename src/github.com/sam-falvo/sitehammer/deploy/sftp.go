@@ -0,0 +1,271 @@
+package deploy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/sam-falvo/sitehammer/config"
+	"github.com/sam-falvo/sitehammer/dryrun"
+	"github.com/sam-falvo/sitehammer/failure"
+	"github.com/sam-falvo/sitehammer/manifest"
+	"github.com/sam-falvo/sitehammer/report"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// deployedRecord names the file, kept at the destination of an SFTP or FTP deployment, recording the manifest last deployed there.
+// Comparing it with the build's manifest tells which files changed, since neither protocol can tell cheaply.
+const deployedRecord = ".sitehammer-deployed.json"
+
+// Operations a transferStep performs.
+const (
+	makeDirectory = "mkdir"
+	putFile       = "put"
+	removeFile    = "rm"
+)
+
+// transferStep is one operation of a file transfer: making the remote directory To, putting the local file From at To,
+// or removing the remote file To.
+type transferStep struct {
+	Op   string
+	From string
+	To   string
+}
+
+// fileTransfer reads, writes, and removes files at a remote host, as SFTP and FTP do.
+// Fetch answers the content of the named remote file, or nil if there's no such file;
+// apply performs the steps, in order, calling done as each is done, or as they all are, stopping at the first failure.
+// A directory which can't be made, usually because it exists, isn't a failure, nor is a file to be removed which doesn't exist.
+type fileTransfer interface {
+	fetch(name string) ([]byte, error)
+	apply(steps []transferStep, done func(transferStep)) error
+}
+
+// Sftp publishes the files the manifest lists to the profile's destination, an sftp URL, with the sftp command, which reaches the host over SSH.
+// Only the files which changed since the last deployment there are uploaded, as told by comparing the manifest with the record of it kept at the destination;
+// see transferChanges.
+// The sftp command runs in batch mode, so the host must accept a key or an agent's, rather than a password.
+func Sftp(cfg config.DeployProfile, opts Options) (*Result, error) {
+	u, err := destinationUrl(cfg, opts)
+	if err != nil {
+		return nil, err
+	}
+	base := u.Path
+	if base == "/~" || strings.HasPrefix(base, "/~/") {
+		// Relative to the login directory, as curl takes it.
+		base = strings.TrimPrefix(strings.TrimPrefix(base, "/~"), "/")
+	}
+	return transferChanges(&sftpTransfer{u, opts.context()}, base, cfg, opts)
+}
+
+// destinationUrl answers the profile's destination, which must be a URL naming a host.
+func destinationUrl(cfg config.DeployProfile, opts Options) (*url.URL, error) {
+	if cfg.Destination == "" {
+		return nil, failure.Wrap(failure.Usage, fmt.Errorf("Deploy profile %s names no destination.", opts.Profile))
+	}
+	u, err := url.Parse(cfg.Destination)
+	if err != nil || u.Host == "" {
+		return nil, failure.Wrap(failure.Usage, fmt.Errorf("Deploy profile %s names a malformed destination, %q.", opts.Profile, cfg.Destination))
+	}
+	return u, nil
+}
+
+// transferChanges publishes the files the manifest lists beneath the remote directory base, through t,
+// uploading only those which the record of the last deployment, kept at the destination, lacks, or lists with different content.
+// If the profile asks for it, the files the record lists but the manifest doesn't are deleted; only files deployed before are ever deleted.
+// The record is replaced once the files are uploaded, so a deployment failing part way is resumed where it stopped;
+// with no record, as at the first deployment, every file is uploaded.
+// Each file uploaded or deleted is reported, or, in a dry run, reported as it would be, without anything being changed.
+func transferChanges(t fileTransfer, base string, cfg config.DeployProfile, opts Options) (*Result, error) {
+	remote := func(name string) string { return path.Join(base, name) }
+	target := strings.TrimSuffix(cfg.Destination, "/") + "/"
+	raw, err := t.fetch(remote(deployedRecord))
+	if err != nil {
+		return nil, failure.Wrap(failure.IO, fmt.Errorf("Cannot read the record of the last deployment from %s: %v", cfg.Destination, err))
+	}
+	previous := &manifest.Manifest{Files: []manifest.Entry{}}
+	if raw != nil {
+		err = json.Unmarshal(raw, previous)
+		if err != nil {
+			report.Warning("The record of the last deployment, %s, is unreadable (%v); every file is uploaded", target+deployedRecord, err)
+			previous = &manifest.Manifest{Files: []manifest.Entry{}}
+		}
+	}
+	differences, unchanged := opts.Manifest.Diff(previous)
+	result := &Result{Unchanged: unchanged}
+
+	// Directories holding files deployed before exist already; the rest are made before the files they hold are put.
+	made := make(map[string]bool)
+	for _, e := range previous.Files {
+		for _, dir := range ancestry(path.Dir(e.Path)) {
+			made[dir] = true
+		}
+	}
+	var steps []transferStep
+	if raw == nil {
+		// The destination itself may not exist yet.
+		for _, dir := range ancestry(path.Clean(base)) {
+			steps = append(steps, transferStep{makeDirectory, "", dir})
+		}
+	}
+	reasons := make(map[string]string)
+	for _, d := range differences {
+		switch {
+		case d.Kind != manifest.Removed:
+			for _, dir := range ancestry(path.Dir(d.Path)) {
+				if !made[dir] {
+					steps = append(steps, transferStep{makeDirectory, "", remote(dir)})
+					made[dir] = true
+				}
+			}
+			steps = append(steps, transferStep{putFile, filepath.Join(opts.OutputDir, filepath.FromSlash(d.Path)), remote(d.Path)})
+			reasons[remote(d.Path)] = d.Kind
+			result.Uploaded++
+		case cfg.Delete:
+			steps = append(steps, transferStep{removeFile, "", remote(d.Path)})
+			result.Deleted++
+		}
+	}
+	if opts.DryRun {
+		for _, d := range differences {
+			switch {
+			case d.Kind == manifest.Added:
+				dryrun.Report(dryrun.Create, target+d.Path, dryrun.New)
+			case d.Kind == manifest.Changed:
+				dryrun.Report(dryrun.Overwrite, target+d.Path, dryrun.Changed)
+			case cfg.Delete:
+				dryrun.Report(dryrun.Remove, target+d.Path, dryrun.Orphaned)
+			}
+		}
+		return result, nil
+	}
+	if len(steps) == 0 && raw != nil {
+		return result, nil
+	}
+
+	record, err := ioutil.TempFile("", "sitehammer-deployed")
+	if err != nil {
+		return nil, err
+	}
+	record.Close()
+	defer os.Remove(record.Name())
+	err = opts.Manifest.Save(record.Name())
+	if err != nil {
+		return nil, err
+	}
+	steps = append(steps, transferStep{putFile, record.Name(), remote(deployedRecord)})
+	err = t.apply(steps, func(s transferStep) {
+		name := strings.TrimPrefix(strings.TrimPrefix(s.To, base), "/")
+		switch {
+		case s.Op == removeFile:
+			report.Event("delete", fmt.Sprintf("deleted %s (no longer built)", name), report.Fields{"path": name})
+		case s.Op == putFile && reasons[s.To] != "":
+			report.Event("upload", fmt.Sprintf("uploaded %s (%s)", name, reasons[s.To]), report.Fields{"path": name, "reason": reasons[s.To]})
+		}
+	})
+	if err != nil {
+		return result, failure.Wrap(failure.IO, fmt.Errorf("Cannot deploy to %s: %v", cfg.Destination, err))
+	}
+	return result, nil
+}
+
+// ancestry answers the directories leading to dir, outermost first, ending with dir itself, unless it's the current or root directory.
+func ancestry(dir string) []string {
+	var dirs []string
+	for ; dir != "." && dir != "/"; dir = path.Dir(dir) {
+		dirs = append([]string{dir}, dirs...)
+	}
+	return dirs
+}
+
+// sftpTransfer transfers files with the sftp command, to and from the host its URL names, as the user it names, if any.
+type sftpTransfer struct {
+	url *url.URL
+	ctx context.Context
+}
+
+// fetch fetches the named file into a scratch file, answering its content, or nil if there's no such file.
+func (s *sftpTransfer) fetch(name string) ([]byte, error) {
+	scratch, err := ioutil.TempDir("", "sitehammer-sftp")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(scratch)
+	local := filepath.Join(scratch, "fetched")
+	err = s.run("-get " + sftpQuote(name) + " " + sftpQuote(local) + "\n")
+	if err != nil {
+		return nil, err
+	}
+	content, err := ioutil.ReadFile(local)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return content, err
+}
+
+// apply performs the steps in a single sftp session, calling done for each once they're all done.
+func (s *sftpTransfer) apply(steps []transferStep, done func(transferStep)) error {
+	var batch strings.Builder
+	for _, step := range steps {
+		switch step.Op {
+		case makeDirectory:
+			batch.WriteString("-mkdir " + sftpQuote(step.To) + "\n")
+		case putFile:
+			batch.WriteString("put " + sftpQuote(step.From) + " " + sftpQuote(step.To) + "\n")
+		case removeFile:
+			batch.WriteString("-rm " + sftpQuote(step.To) + "\n")
+		}
+	}
+	err := s.run(batch.String())
+	if err != nil {
+		return err
+	}
+	for _, step := range steps {
+		done(step)
+	}
+	return nil
+}
+
+// run runs sftp with the given batch of commands; those beginning with a hyphen may fail without failing the batch.
+func (s *sftpTransfer) run(batch string) error {
+	args := []string{"-b", "-"}
+	if port := s.url.Port(); port != "" {
+		args = append(args, "-P", port)
+	}
+	host := s.url.Hostname()
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	if s.url.User != nil {
+		host = s.url.User.Username() + "@" + host
+	}
+	cmd := exec.CommandContext(s.ctx, "sftp", append(args, host)...)
+	cmd.Stdin = strings.NewReader(batch)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	if err != nil {
+		return fmt.Errorf("sftp failed: %v %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// sftpQuote quotes a name for an sftp command, so that spaces, quotes, and wildcards in it are taken literally.
+func sftpQuote(name string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range name {
+		if strings.ContainsRune(`"\*?[]`, r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	b.WriteByte('"')
+	return b.String()
+}
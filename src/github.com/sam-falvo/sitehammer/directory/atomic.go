@@ -0,0 +1,41 @@
+package directory
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// WriteFileAtomic writes data to the named file, which is created with the given permissions if it doesn't already exist.
+// The data is first written to a temporary file in the same directory and flushed to disk; the temporary file is then renamed over the original.
+// Since the rename is atomic, readers see either the old content or the new, never a partial file,
+// and a build that fails or is interrupted midway leaves the old file intact.
+// Because the file is replaced rather than rewritten, other hard links to the old file keep the old content.
+// The permissions of the new file are perm, whatever those of the file it replaces.
+func WriteFileAtomic(filename string, data []byte, perm os.FileMode) error {
+	dir, base := filepath.Split(filename);
+	if dir == "" {
+		dir = ".";
+	}
+	tmp, err := ioutil.TempFile(dir, "."+base+".inprogress");
+	if err != nil {
+		return err;
+	}
+	_, err = tmp.Write(data);
+	if err == nil {
+		err = tmp.Sync();
+	}
+	if err == nil {
+		err = tmp.Chmod(perm);
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr;
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), filename);
+	}
+	if err != nil {
+		os.Remove(tmp.Name());
+	}
+	return err;
+}
@@ -0,0 +1,84 @@
+package directory
+
+import (
+	"github.com/sam-falvo/sitehammer/errlist"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// EntryError records a handler's failure to process an entry, for ForEachEntryCollecting and WalkCollecting.
+type EntryError struct {
+	Path string;
+	Err  error;
+}
+
+// Error reports the entry's path followed by the error.
+func (e *EntryError) Error() string {
+	return e.Path + ": " + e.Err.Error();
+}
+
+// Unwrap answers the handler's original error.
+func (e *EntryError) Unwrap() error {
+	return e.Err;
+}
+
+// ForEachEntryCollecting works like ForEachEntry, except that a handler's error doesn't stop the enumeration.
+// Instead, each error is wrapped in an EntryError naming the entry, and every error is answered at the end, as an errlist.List.
+// If the directory can't be read at all, that error is answered as it is.
+func ForEachEntryCollecting(d string, f MemberHandler) error {
+	entries, err := readDir(d, Order{});
+	if err != nil {
+		return err;
+	}
+
+	var failed errlist.List;
+	for _, entry := range entries {
+		if err := f(entry); err != nil {
+			failed.Add(&EntryError{entry.Name(), err});
+		}
+	}
+	return failed.Err();
+}
+
+// WalkCollecting works like Walk, except that errors don't stop the walk.
+// A handler's error is wrapped in an EntryError naming the entry;
+// the failure to read a subdirectory, which names the directory already, is recorded as it is, and its contents skipped.
+// SkipDir prunes the walk as it does for Walk, and isn't recorded.
+// Every error is answered at the end, as an errlist.List.
+// If the root itself can't be read, that error is answered as it is.
+func WalkCollecting(root string, f WalkHandler) error {
+	entries, err := readDir(root, Order{});
+	if err != nil {
+		return err;
+	}
+
+	var failed errlist.List;
+	walkCollecting(root, "", entries, f, &failed);
+	return failed.Err();
+}
+
+// walkCollecting visits the given entries of the directory named rel, relative to root, for WalkCollecting.
+func walkCollecting(root, rel string, entries []os.FileInfo, f WalkHandler, failed *errlist.List) {
+	for _, entry := range entries {
+		name := path.Join(rel, entry.Name());
+		err := f(name, entry);
+		if err == SkipDir {
+			if entry.IsDir() {
+				continue;
+			}
+			return;
+		}
+		if err != nil {
+			failed.Add(&EntryError{name, err});
+		}
+		if entry.IsDir() {
+			children, err := readDir(filepath.Join(root, filepath.FromSlash(name)), Order{});
+			if err != nil {
+				failed.Add(err);
+				continue;
+			}
+			walkCollecting(root, name, children, f, failed);
+		}
+	}
+}
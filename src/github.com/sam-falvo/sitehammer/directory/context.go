@@ -0,0 +1,24 @@
+package directory
+
+import (
+	"context"
+	"os"
+)
+
+// contextErr answers the error of ctx if it's done, or nil if it isn't, or if there's no context at all.
+func contextErr(ctx context.Context) error {
+	if ctx == nil {
+		return nil;
+	}
+	return ctx.Err();
+}
+
+// ForEachEntryContext works like ForEachEntry, but stops once ctx is done, answering its error.
+func ForEachEntryContext(ctx context.Context, d string, f MemberHandler) error {
+	return ForEachEntry(d, func(fi os.FileInfo) error {
+		if err := ctx.Err(); err != nil {
+			return err;
+		}
+		return f(fi);
+	});
+}
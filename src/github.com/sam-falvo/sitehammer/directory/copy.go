@@ -0,0 +1,157 @@
+package directory
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// Overwrite decides what CopyTree does when a file it would copy already exists at the destination.
+type Overwrite int;
+
+const (
+	// OverwriteAlways replaces existing files.
+	OverwriteAlways Overwrite = iota;
+	// OverwriteNever leaves existing files alone.
+	OverwriteNever;
+	// OverwriteIfNewer replaces existing files only if the source was modified more recently.
+	OverwriteIfNewer;
+	// OverwriteError treats an existing file as an error, stopping the copy.
+	OverwriteError;
+);
+
+// CopyOptions controls CopyTree.
+// Filter, if not nil, selects the entries to copy, given their paths relative to the source directory;
+// a directory it rejects is skipped along with everything beneath it.
+type CopyOptions struct {
+	Overwrite Overwrite;
+	Filter    Predicate;
+}
+
+// CopyTree recursively copies the directory src to dst, creating dst if it doesn't already exist,
+// and merging into it if it does.
+// Permissions and modification times are preserved.
+// Symbolic links are copied as links, not followed; special files, such as sockets and devices, are skipped.
+func CopyTree(src, dst string, opts CopyOptions) error {
+	fi, err := os.Stat(src);
+	if err != nil {
+		return err;
+	}
+	if !fi.IsDir() {
+		return fmt.Errorf("Cannot copy %s: not a directory.", src);
+	}
+	err = ensureCopyDir(dst, fi);
+	if err != nil {
+		return err;
+	}
+	return copyTree(src, dst, "", opts);
+}
+
+// copyTree copies the directory named rel, relative to src, into the same place relative to dst, for CopyTree.
+func copyTree(src, dst, rel string, opts CopyOptions) error {
+	entries, err := readDir(filepath.Join(src, filepath.FromSlash(rel)), Order{});
+	if err != nil {
+		return err;
+	}
+
+	for _, e := range entries {
+		name := path.Join(rel, e.Name());
+		if opts.Filter != nil && !opts.Filter(name, e) {
+			continue;
+		}
+		from := filepath.Join(src, filepath.FromSlash(name));
+		to := filepath.Join(dst, filepath.FromSlash(name));
+		mode := e.Mode();
+		switch {
+		case mode.IsDir():
+			err = ensureCopyDir(to, e);
+			if err == nil {
+				err = copyTree(src, dst, name, opts);
+			}
+		case mode&os.ModeSymlink != 0:
+			err = copyEntry(to, e, opts.Overwrite, func() error {
+				target, err := os.Readlink(from);
+				if err != nil {
+					return err;
+				}
+				return os.Symlink(target, to);
+			});
+		case mode.IsRegular():
+			err = copyEntry(to, e, opts.Overwrite, func() error {
+				return copyFile(from, to, e);
+			});
+		}
+		if err != nil {
+			return err;
+		}
+	}
+
+	return nil;
+}
+
+// ensureCopyDir creates the directory dir, with the permissions of the source directory fi, unless it already exists.
+func ensureCopyDir(dir string, fi os.FileInfo) error {
+	existing, err := os.Lstat(dir);
+	if os.IsNotExist(err) {
+		err = os.Mkdir(dir, fi.Mode().Perm());
+		if err != nil {
+			return err;
+		}
+		return os.Chmod(dir, fi.Mode().Perm());
+	}
+	if err != nil {
+		return err;
+	}
+	if !existing.IsDir() {
+		return fmt.Errorf("Cannot copy directory onto %s: not a directory.", dir);
+	}
+	return nil;
+}
+
+// copyEntry applies the overwrite policy to the destination to, then calls copy if the source entry fi is to be copied.
+// An existing destination is removed first, so a file hard-linked elsewhere is replaced rather than changed in place.
+func copyEntry(to string, fi os.FileInfo, policy Overwrite, copy func() error) error {
+	existing, err := os.Lstat(to);
+	if err != nil && !os.IsNotExist(err) {
+		return err;
+	}
+	if err == nil {
+		if existing.IsDir() {
+			return fmt.Errorf("Cannot copy onto %s: it's a directory.", to);
+		}
+		switch policy {
+		case OverwriteNever:
+			return nil;
+		case OverwriteIfNewer:
+			if !fi.ModTime().After(existing.ModTime()) {
+				return nil;
+			}
+		case OverwriteError:
+			return fmt.Errorf("Cannot copy onto %s: it already exists.", to);
+		}
+		err = os.Remove(to);
+		if err != nil {
+			return err;
+		}
+	}
+	return copy();
+}
+
+// copyFile copies a regular file, preserving its permissions and modification time.
+func copyFile(from, to string, fi os.FileInfo) error {
+	data, err := ioutil.ReadFile(from);
+	if err != nil {
+		return err;
+	}
+	err = ioutil.WriteFile(to, data, fi.Mode().Perm());
+	if err != nil {
+		return err;
+	}
+	err = os.Chmod(to, fi.Mode().Perm());
+	if err != nil {
+		return err;
+	}
+	return os.Chtimes(to, fi.ModTime(), fi.ModTime());
+}
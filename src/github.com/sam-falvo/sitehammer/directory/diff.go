@@ -0,0 +1,92 @@
+package directory
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// Diff compares the trees beneath directories a and b, answering the files added in b, removed from a,
+// and present in both but changed, named by slash-separated paths relative to the directories.
+// Unlike SyncTree, Diff lists every file individually; directories themselves are never listed.
+// Regular files are compared by size, then, if their sizes agree, by the SHA-256 hashes of their contents;
+// modification times are ignored, so rebuilding a file identically doesn't count as a change.
+// Symbolic links are compared by target.  Special files are ignored.
+// A directory which doesn't exist is treated as empty.
+func Diff(a, b string) (*Changes, error) {
+	before, err := filesBeneath(a);
+	if err != nil {
+		return nil, err;
+	}
+	after, err := filesBeneath(b);
+	if err != nil {
+		return nil, err;
+	}
+
+	changes := &Changes{};
+	for name, fi := range after {
+		old, ok := before[name];
+		if !ok {
+			changes.Added = append(changes.Added, name);
+			continue;
+		}
+		same, err := sameFiles(filepath.Join(a, filepath.FromSlash(name)), filepath.Join(b, filepath.FromSlash(name)), old, fi);
+		if err != nil {
+			return nil, err;
+		}
+		if !same {
+			changes.Updated = append(changes.Updated, name);
+		}
+	}
+	for name := range before {
+		if _, ok := after[name]; !ok {
+			changes.Removed = append(changes.Removed, name);
+		}
+	}
+	sort.Strings(changes.Added);
+	sort.Strings(changes.Updated);
+	sort.Strings(changes.Removed);
+	return changes, nil;
+}
+
+// filesBeneath answers the regular files and symbolic links beneath the directory root, by relative path.
+func filesBeneath(root string) (map[string]os.FileInfo, error) {
+	files := make(map[string]os.FileInfo);
+	err := Walk(root, func(name string, fi os.FileInfo) error {
+		if fi.Mode().IsRegular() || fi.Mode()&os.ModeSymlink != 0 {
+			files[name] = fi;
+		}
+		return nil;
+	});
+	if os.IsNotExist(err) {
+		return files, nil;
+	}
+	return files, err;
+}
+
+// sameFiles answers true if the files x and y, described by xi and yi, are equivalent.
+func sameFiles(x, y string, xi, yi os.FileInfo) (bool, error) {
+	if !sameKind(xi, yi) {
+		return false, nil;
+	}
+	if xi.Mode()&os.ModeSymlink != 0 {
+		xt, err := os.Readlink(x);
+		if err != nil {
+			return false, err;
+		}
+		yt, err := os.Readlink(y);
+		return xt == yt, err;
+	}
+	if xi.Size() != yi.Size() {
+		return false, nil;
+	}
+	xh, err := HashFile(x);
+	if err != nil {
+		return false, err;
+	}
+	yh, err := HashFile(y);
+	if err != nil {
+		return false, err;
+	}
+	return xh == yh, nil;
+}
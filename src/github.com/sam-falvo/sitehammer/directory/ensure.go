@@ -0,0 +1,59 @@
+package directory
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// EnsureDir makes sure the named directory exists, creating it with the given permissions if need be.
+// Its parent must already exist.
+// An error is answered if the name is taken by something other than a directory.
+func EnsureDir(dir string, perm os.FileMode) error {
+	fi, err := os.Stat(dir);
+	if os.IsNotExist(err) {
+		return os.Mkdir(dir, perm);
+	}
+	if err != nil {
+		return err;
+	}
+	if !fi.IsDir() {
+		return notADirectory(dir);
+	}
+	return nil;
+}
+
+// EnsureDirAll works like EnsureDir, but creates any missing parents as well, with the same permissions, as os.MkdirAll does.
+// If the name, or that of any parent, is taken by something other than a directory, the error names it.
+func EnsureDirAll(dir string, perm os.FileMode) error {
+	fi, err := os.Stat(dir);
+	if err == nil {
+		if !fi.IsDir() {
+			return notADirectory(dir);
+		}
+		return nil;
+	}
+	if !os.IsNotExist(err) {
+		return err;
+	}
+	err = os.MkdirAll(dir, perm);
+	if err == nil {
+		return nil;
+	}
+	// Find the obstruction, if that's what the problem is.
+	for parent := filepath.Dir(dir); parent != filepath.Dir(parent); parent = filepath.Dir(parent) {
+		fi, statErr := os.Stat(parent);
+		if statErr == nil {
+			if !fi.IsDir() {
+				return notADirectory(parent);
+			}
+			break;
+		}
+	}
+	return err;
+}
+
+// notADirectory reports a name taken by something other than a directory.
+func notADirectory(name string) error {
+	return fmt.Errorf("Path %s exists, but isn't a directory.", name);
+}
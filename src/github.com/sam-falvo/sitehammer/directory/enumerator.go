@@ -4,7 +4,6 @@ The directory package contains useful utilities relating to directories, at leas
 package directory
 
 import (
-	"io/ioutil"
 	"os"
 )
 
@@ -15,20 +14,10 @@ type MemberHandler func(os.FileInfo) error;
 
 // ForEachEntry enumerates every directory entry found by ioutil.ReadDir, calling a function
 // f for each one.  See the type MemberHandler for the signature and semantics of f.
+// Entries are enumerated in ascending order by name, comparing bytes, whatever the platform;
+// use ForEachEntryOrdered for other orders.
 func ForEachEntry(d string, f MemberHandler) error {
-	entries, err := ioutil.ReadDir(d);
-	if err != nil {
-		return err;
-	}
-
-	for _, entry := range entries {
-		err := f(entry);
-		if err != nil {
-			return err;
-		}
-	}
-
-	return nil;
+	return ForEachEntryOrdered(d, Order{}, f);
 }
 
 // OnlyFiles should be used with ForEachEntry to filter out only files.
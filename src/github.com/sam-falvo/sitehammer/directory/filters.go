@@ -0,0 +1,129 @@
+package directory
+
+import (
+	"os"
+	"regexp"
+	"strings"
+)
+
+// OnlyDirs should be used with ForEachEntry to filter out only directories.
+func OnlyDirs(inp os.FileInfo, f MemberHandler) error {
+	if !inp.IsDir() { return nil; }
+	return f(inp);
+}
+
+// Predicate functions decide which directory entries are of interest.
+// A Predicate takes the entry's path, relative to the directory being enumerated and separated by slashes,
+// along with its os.FileInfo, and answers true if the entry should be processed.
+// When enumerating a single directory with ForEachEntry, the path is simply the entry's name.
+type Predicate func(string, os.FileInfo) bool;
+
+// IsDir answers true for directories.
+func IsDir(p string, fi os.FileInfo) bool {
+	return fi.IsDir();
+}
+
+// IsFile answers true for anything other than a directory, as OnlyFiles does.
+func IsFile(p string, fi os.FileInfo) bool {
+	return !fi.IsDir();
+}
+
+// IsRegular answers true for regular files, excluding directories, symbolic links, and special files.
+func IsRegular(p string, fi os.FileInfo) bool {
+	return fi.Mode().IsRegular();
+}
+
+// IsSymlink answers true for symbolic links.
+func IsSymlink(p string, fi os.FileInfo) bool {
+	return fi.Mode()&os.ModeSymlink != 0;
+}
+
+// IsHidden answers true for entries whose names begin with a dot, which Unix tools conventionally hide.
+func IsHidden(p string, fi os.FileInfo) bool {
+	return strings.HasPrefix(fi.Name(), ".");
+}
+
+// IsUnderscored answers true for entries whose names begin with an underscore, which SiteHammer never publishes.
+func IsUnderscored(p string, fi os.FileInfo) bool {
+	return strings.HasPrefix(fi.Name(), "_");
+}
+
+// HasExtension answers a Predicate which is true for entries whose names end in any of the given extensions, ignoring case.
+// Extensions include the leading dot.
+func HasExtension(extensions ...string) Predicate {
+	return func(p string, fi os.FileInfo) bool {
+		name := strings.ToLower(fi.Name());
+		for _, ext := range extensions {
+			if strings.HasSuffix(name, strings.ToLower(ext)) {
+				return true;
+			}
+		}
+		return false;
+	};
+}
+
+// Matching answers a Predicate which is true for entries whose paths contain a match for re.
+// Anchor the expression with ^ and $ to match whole paths; for example, `^articles/[0-9]+$` selects numbered article directories,
+// and `(^|/)[0-9]{4}-[0-9]{2}-[0-9]{2}-[^/]*\.md$` selects date-prefixed Markdown files at any depth.
+func Matching(re *regexp.Regexp) Predicate {
+	return func(p string, fi os.FileInfo) bool {
+		return re.MatchString(p);
+	};
+}
+
+// And answers a Predicate which is true only when every one of ps is true.
+// With no predicates at all, it's always true.
+func And(ps ...Predicate) Predicate {
+	return func(p string, fi os.FileInfo) bool {
+		for _, pred := range ps {
+			if !pred(p, fi) {
+				return false;
+			}
+		}
+		return true;
+	};
+}
+
+// Or answers a Predicate which is true when any of ps is true.
+// With no predicates at all, it's always false.
+func Or(ps ...Predicate) Predicate {
+	return func(p string, fi os.FileInfo) bool {
+		for _, pred := range ps {
+			if pred(p, fi) {
+				return true;
+			}
+		}
+		return false;
+	};
+}
+
+// Not answers a Predicate which is true when pred is false, and vice versa.
+func Not(pred Predicate) Predicate {
+	return func(p string, fi os.FileInfo) bool {
+		return !pred(p, fi);
+	};
+}
+
+// Only answers a MemberHandler, for use with ForEachEntry, which calls f only for entries satisfying pred.
+// For example, to process only regular files that are neither hidden nor underscored:
+//
+//	directory.ForEachEntry(d, directory.Only(directory.And(
+//		directory.IsRegular,
+//		directory.Not(directory.IsHidden),
+//		directory.Not(directory.IsUnderscored),
+//	), f))
+func Only(pred Predicate, f MemberHandler) MemberHandler {
+	return func(fi os.FileInfo) error {
+		if !pred(fi.Name(), fi) { return nil; }
+		return f(fi);
+	};
+}
+
+// OnlyWalked answers a WalkHandler, for use with Walk, which calls f only for entries satisfying pred.
+// Entries not satisfying pred are merely skipped; directories among them are still descended into.
+func OnlyWalked(pred Predicate, f WalkHandler) WalkHandler {
+	return func(p string, fi os.FileInfo) error {
+		if !pred(p, fi) { return nil; }
+		return f(p, fi);
+	};
+}
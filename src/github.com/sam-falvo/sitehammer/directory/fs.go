@@ -0,0 +1,81 @@
+package directory
+
+import (
+	"io/fs"
+	"os"
+	"path"
+)
+
+// readDirFS reads the entries of directory d within fsys, in the given order.
+func readDirFS(fsys fs.FS, d string, order Order) ([]os.FileInfo, error) {
+	dirents, err := fs.ReadDir(fsys, d);
+	if err != nil {
+		return nil, err;
+	}
+	entries := make([]os.FileInfo, 0, len(dirents));
+	for _, dirent := range dirents {
+		fi, err := dirent.Info();
+		if err != nil {
+			return nil, err;
+		}
+		entries = append(entries, fi);
+	}
+	Sort(entries, order);
+	return entries, nil;
+}
+
+// ForEachEntryFS works like ForEachEntry, but enumerates the directory d within fsys rather than the real filesystem,
+// so content embedded in the program, held in a zip archive, or kept in memory can be processed the same way.
+// Following the conventions of fs.FS, d is slash-separated and unrooted; "." names the root of fsys.
+func ForEachEntryFS(fsys fs.FS, d string, f MemberHandler) error {
+	entries, err := readDirFS(fsys, d, Order{});
+	if err != nil {
+		return err;
+	}
+
+	for _, entry := range entries {
+		err := f(entry);
+		if err != nil {
+			return err;
+		}
+	}
+
+	return nil;
+}
+
+// WalkFS works like Walk, but walks the directory root within fsys rather than the real filesystem.
+// As with Walk, paths passed to f are relative to root, and f may return SkipDir to prune the walk.
+func WalkFS(fsys fs.FS, root string, f WalkHandler) error {
+	err := walkFS(fsys, root, "", f);
+	if err == SkipDir {
+		return nil;
+	}
+	return err;
+}
+
+// walkFS enumerates the directory named rel, relative to root, within fsys, for WalkFS.
+func walkFS(fsys fs.FS, root, rel string, f WalkHandler) error {
+	entries, err := readDirFS(fsys, path.Join(root, rel), Order{});
+	if err != nil {
+		return err;
+	}
+
+	for _, entry := range entries {
+		name := path.Join(rel, entry.Name());
+		err = f(name, entry);
+		if err == SkipDir && entry.IsDir() {
+			continue;
+		}
+		if err != nil {
+			return err;
+		}
+		if entry.IsDir() {
+			err = walkFS(fsys, root, name, f);
+			if err != nil && err != SkipDir {
+				return err;
+			}
+		}
+	}
+
+	return nil;
+}
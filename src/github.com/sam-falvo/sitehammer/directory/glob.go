@@ -0,0 +1,71 @@
+package directory
+
+import (
+	"os"
+	"path"
+	"strings"
+)
+
+// MatchGlob answers true if the slash-separated path name matches the glob pattern.
+//
+// Patterns use the syntax of path.Match, with one addition: a path component of ** matches any number of directories, including none.
+// Thus, images/**/*.png matches images/a.png and images/x/y/b.png alike.
+// A pattern without any slashes matches an entry's name at any depth, so *.md matches both a.md and docs/b.md.
+// The only possible error is path.ErrBadPattern.
+func MatchGlob(pattern, name string) (bool, error) {
+	if !strings.Contains(pattern, "/") {
+		return path.Match(pattern, path.Base(name));
+	}
+	parts := strings.Split(strings.TrimPrefix(pattern, "/"), "/");
+	for _, part := range parts {
+		// Check every component up front, since matching stops at the first mismatch.
+		if _, err := path.Match(part, ""); err != nil {
+			return false, err;
+		}
+	}
+	return matchParts(parts, strings.Split(name, "/"));
+}
+
+// matchParts matches a path, split into components, against a pattern, likewise split.
+func matchParts(pattern, parts []string) (bool, error) {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			// Try consuming no components, then one, then two, and so on.
+			for i := 0; i <= len(parts); i++ {
+				matched, err := matchParts(pattern[1:], parts[i:]);
+				if matched || err != nil {
+					return matched, err;
+				}
+			}
+			return false, nil;
+		}
+		if len(parts) == 0 {
+			return false, nil;
+		}
+		matched, err := path.Match(pattern[0], parts[0]);
+		if !matched || err != nil {
+			return false, err;
+		}
+		pattern, parts = pattern[1:], parts[1:];
+	}
+	return len(parts) == 0, nil;
+}
+
+// Glob answers a Predicate which is true for entries whose paths match any of the given glob patterns;
+// see MatchGlob for their syntax.
+// An error is answered if any pattern is malformed.
+func Glob(patterns ...string) (Predicate, error) {
+	for _, pattern := range patterns {
+		if _, err := MatchGlob(pattern, ""); err != nil {
+			return nil, err;
+		}
+	}
+	return func(p string, fi os.FileInfo) bool {
+		for _, pattern := range patterns {
+			if matched, _ := MatchGlob(pattern, p); matched {
+				return true;
+			}
+		}
+		return false;
+	}, nil;
+}
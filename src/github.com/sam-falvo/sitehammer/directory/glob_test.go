@@ -0,0 +1,95 @@
+package directory
+
+import (
+	"path"
+	"testing"
+)
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		pattern, name string;
+		want          bool;
+	}{
+		// Patterns without slashes match names at any depth.
+		{"*.md", "a.md", true},
+		{"*.md", "docs/deep/b.md", true},
+		{"*.md", "a.md.bak", false},
+		{"a.md", "docs/a.md", true},
+		{"docs", "docs", true},
+
+		// Patterns with slashes match whole paths.
+		{"docs/*.md", "docs/a.md", true},
+		{"docs/*.md", "docs/sub/a.md", false},
+		{"docs/*.md", "other/docs/a.md", false},
+		{"/docs/*.md", "docs/a.md", true},
+		{"*/a.md", "docs/a.md", true},
+		{"*/a.md", "a.md", false},
+
+		// ** matches any number of directories, including none.
+		{"images/**/*.png", "images/a.png", true},
+		{"images/**/*.png", "images/x/a.png", true},
+		{"images/**/*.png", "images/x/y/z/a.png", true},
+		{"images/**/*.png", "images/x/a.jpg", false},
+		{"images/**/*.png", "other/images/a.png", false},
+		{"**/*.png", "a.png", true},
+		{"**/*.png", "x/y/a.png", true},
+		{"docs/**", "docs", true},
+		{"docs/**", "docs/a/b.md", true},
+		{"docs/**", "docsx/a.md", false},
+		{"a/**/b/**/c", "a/b/c", true},
+		{"a/**/b/**/c", "a/x/b/y/z/c", true},
+		{"a/**/b/**/c", "a/x/y/c", false},
+
+		// Character classes and single-character wildcards, which never match a slash.
+		{"[abc].txt", "b.txt", true},
+		{"[abc].txt", "d.txt", false},
+		{"[a-c]*.txt", "cat.txt", true},
+		{"[^a-c]*.txt", "cat.txt", false},
+		{"[^a-c]*.txt", "dog.txt", true},
+		{"img/?.png", "img/a.png", true},
+		{"img/?.png", "img/ab.png", false},
+		{"img?a.png", "img/a.png", false},
+		{`\*.txt`, "*.txt", true},
+		{`\*.txt`, "a.txt", false},
+	}
+	for _, tc := range tests {
+		got, err := MatchGlob(tc.pattern, tc.name);
+		if err != nil || got != tc.want {
+			t.Errorf("MatchGlob(%q, %q) = %v, %v; want %v", tc.pattern, tc.name, got, err, tc.want);
+		}
+	}
+}
+
+func TestMatchGlobMalformed(t *testing.T) {
+	tests := []struct {
+		pattern, name string;
+	}{
+		{"[", "a"},
+		{"[a-", "a"},
+		{`a\`, "a"},
+		{"docs/[/*.md", "docs/a.md"},
+		// Malformed components are reported though an earlier one already fails to match.
+		{"other/[/*.md", "docs/a.md"},
+		{"**/[", "a"},
+	}
+	for _, tc := range tests {
+		if _, err := MatchGlob(tc.pattern, tc.name); err != path.ErrBadPattern {
+			t.Errorf("MatchGlob(%q, %q) error = %v, want %v", tc.pattern, tc.name, err, path.ErrBadPattern);
+		}
+	}
+}
+
+func TestGlob(t *testing.T) {
+	if _, err := Glob("*.md", "docs/["); err != path.ErrBadPattern {
+		t.Errorf("Glob with a malformed pattern answered error %v, want %v", err, path.ErrBadPattern);
+	}
+	matches, err := Glob("*.md", "images/**/*.png");
+	if err != nil {
+		t.Fatal(err);
+	}
+	for name, want := range map[string]bool{"a.md": true, "images/x/a.png": true, "a.png": false, "images/a.jpg": false} {
+		if got := matches(name, nil); got != want {
+			t.Errorf("Glob predicate on %q = %v, want %v", name, got, want);
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package directory
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// HashFile answers the hexadecimal SHA-256 hash of the named file's contents, reading it a piece at a time.
+func HashFile(name string) (string, error) {
+	f, err := os.Open(name);
+	if err != nil {
+		return "", err;
+	}
+	defer f.Close();
+	h := sha256.New();
+	_, err = io.Copy(h, f);
+	if err != nil {
+		return "", err;
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil;
+}
+
+// racyInterval covers the coarsest modification time granularity of common filesystems.
+// A file modified this recently could change again without its modification time changing, so its hash isn't remembered.
+const racyInterval = 2 * time.Second;
+
+// HashEntry records the hash of a file's contents, along with the size and modification time it had when hashed.
+type HashEntry struct {
+	Size    int64     `json:"size"`;
+	ModTime time.Time `json:"mtime"`;
+	Hash    string    `json:"sha256"`;
+}
+
+// HashCache remembers the content hashes of files between runs, so unchanged files needn't be read again to learn their hashes.
+// A file is taken to be unchanged as long as its size and modification time are.
+// Files are named by slash-separated paths relative to Root, which defaults to the current directory;
+// since Root isn't saved with the cache, files hashed in one place (say, a staging area) are recognized in another.
+// A HashCache is safe to use from several goroutines.  A nil *HashCache remembers nothing, and simply hashes files.
+type HashCache struct {
+	Root     string;
+	filename string;
+	mu       sync.Mutex;
+	entries  map[string]HashEntry;
+	dirty    bool;
+}
+
+// OpenHashCache loads the named hash cache file.
+// As with the build cache, a missing or unreadable file isn't an error; an empty cache results.
+func OpenHashCache(filename string) *HashCache {
+	c := &HashCache{Root: ".", filename: filename, entries: make(map[string]HashEntry)};
+	raw, err := ioutil.ReadFile(filename);
+	if err != nil {
+		return c;
+	}
+	if json.Unmarshal(raw, &c.entries) != nil || c.entries == nil {
+		c.entries = make(map[string]HashEntry);
+	}
+	return c;
+}
+
+// Hash answers the hexadecimal SHA-256 hash of the named file's contents,
+// reading the file only if its size or modification time differ from those recorded.
+func (c *HashCache) Hash(name string) (string, error) {
+	if c == nil {
+		return HashFile(name);
+	}
+	filename := filepath.Join(c.Root, filepath.FromSlash(name));
+	fi, err := os.Stat(filename);
+	if err != nil {
+		return "", err;
+	}
+
+	c.mu.Lock();
+	e, ok := c.entries[name];
+	c.mu.Unlock();
+	if ok && e.Size == fi.Size() && e.ModTime.Equal(fi.ModTime()) {
+		return e.Hash, nil;
+	}
+
+	hash, err := HashFile(filename);
+	if err != nil {
+		return "", err;
+	}
+	c.mu.Lock();
+	if time.Since(fi.ModTime()) >= racyInterval {
+		c.entries[name] = HashEntry{fi.Size(), fi.ModTime(), hash};
+	} else {
+		delete(c.entries, name);
+	}
+	c.dirty = true;
+	c.mu.Unlock();
+	return hash, nil;
+}
+
+// Forget discards what the cache knows of the named file.
+func (c *HashCache) Forget(name string) {
+	if c == nil {
+		return;
+	}
+	c.mu.Lock();
+	defer c.mu.Unlock();
+	if _, ok := c.entries[name]; ok {
+		delete(c.entries, name);
+		c.dirty = true;
+	}
+}
+
+// Save writes the cache back to the file it was opened from, creating the file's directory if necessary.
+// Nothing is written if nothing has changed.
+func (c *HashCache) Save() error {
+	if c == nil {
+		return nil;
+	}
+	c.mu.Lock();
+	defer c.mu.Unlock();
+	if !c.dirty {
+		return nil;
+	}
+	raw, err := json.MarshalIndent(c.entries, "", " ");
+	if err != nil {
+		return err;
+	}
+	err = EnsureDirAll(filepath.Dir(c.filename), 0755);
+	if err != nil {
+		return err;
+	}
+	err = WriteFileAtomic(c.filename, raw, 0644);
+	if err == nil {
+		c.dirty = false;
+	}
+	return err;
+}
@@ -0,0 +1,171 @@
+package directory
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Names of the files from which LoadIgnoreRules reads ignore rules, and which WalkOptions and WatchOptions may name as IgnoreFiles.
+const (
+	GitIgnoreFilename    = ".gitignore";
+	HammerIgnoreFilename = ".hammerignore";
+);
+
+// ignoreRule is one line of an ignore file.
+type ignoreRule struct {
+	pattern string;
+	negated bool;
+	dirOnly bool;
+}
+
+// IgnoreRules decide which entries beneath a directory are to be left alone, following the conventions of .gitignore files:
+//
+//	# A comment; blank lines are ignored too.
+//	*.log            Any file or directory ending in .log, at any depth.
+//	node_modules/    Any directory named node_modules, and everything beneath it.
+//	/build           Only build at the root.
+//	docs/**/draft-*  Drafts anywhere beneath docs.
+//	!keep.log        Don't ignore keep.log after all.
+//
+// A pattern containing a slash, other than a trailing one, is matched against the whole path from the root;
+// one without is matched against entries' names at any depth.  See MatchGlob for the patterns' syntax.
+// Later rules take precedence over earlier ones.
+// As with Git, an entry beneath an ignored directory stays ignored, whatever later rules say,
+// since the directory is never descended into.
+//
+// Rules may also be read from ignore files in subdirectories, as with nested .gitignore files; see Nest.
+// Their patterns are matched against paths relative to the directory holding the file,
+// and they take precedence over the rules of the directories above it.
+//
+// Walk honors rules given in WalkOptions; with ForEachEntry, filter entries with Only(Not(rules.Ignores), f).
+// A nil *IgnoreRules ignores nothing.
+type IgnoreRules struct {
+	rules []ignoreRule;
+	// dir is the slash-separated path, relative to the root, of the directory the rules were read from, or "" for the root itself.
+	dir string;
+	// parent holds the rules in effect above dir, which these take precedence over.
+	parent *IgnoreRules;
+}
+
+// ParseIgnoreRules parses ignore rules, one per line.
+// An error is answered if any pattern is malformed.
+func ParseIgnoreRules(lines []string) (*IgnoreRules, error) {
+	r := &IgnoreRules{};
+	for _, line := range lines {
+		line = strings.TrimRight(line, " \t\r");
+		if line == "" || line[0] == '#' {
+			continue;
+		}
+		var rule ignoreRule;
+		if line[0] == '!' {
+			rule.negated = true;
+			line = line[1:];
+		} else if strings.HasPrefix(line, `\`) {
+			// Escapes a leading # or !.
+			line = line[1:];
+		}
+		if strings.HasSuffix(line, "/") {
+			rule.dirOnly = true;
+			line = strings.TrimRight(line, "/");
+		}
+		if line == "" {
+			continue;
+		}
+		if _, err := MatchGlob(line, ""); err != nil {
+			return nil, err;
+		}
+		rule.pattern = line;
+		r.rules = append(r.rules, rule);
+	}
+	return r, nil;
+}
+
+// LoadIgnoreRules reads the ignore rules in the .gitignore and .hammerignore files of directory root, if either exists,
+// the latter's rules taking precedence.
+// Missing files aren't an error; if neither exists, the rules ignore nothing.
+func LoadIgnoreRules(root string) (*IgnoreRules, error) {
+	return loadIgnoreFiles(root, []string{GitIgnoreFilename, HammerIgnoreFilename});
+}
+
+// loadIgnoreFiles reads the ignore rules in the named files of directory dir, those of later files taking precedence.
+// Missing files aren't an error.
+func loadIgnoreFiles(dir string, names []string) (*IgnoreRules, error) {
+	var lines []string;
+	for _, name := range names {
+		more, err := readLines(filepath.Join(dir, name));
+		if err != nil {
+			return nil, err;
+		}
+		lines = append(lines, more...);
+	}
+	return ParseIgnoreRules(lines);
+}
+
+// LoadIgnoreFile reads the ignore rules in the named file.
+// A missing file isn't an error; the rules then ignore nothing.
+func LoadIgnoreFile(filename string) (*IgnoreRules, error) {
+	lines, err := readLines(filename);
+	if err != nil {
+		return nil, err;
+	}
+	return ParseIgnoreRules(lines);
+}
+
+// readLines answers the lines of a text file, or none at all if it doesn't exist.
+func readLines(filename string) ([]string, error) {
+	f, err := os.Open(filename);
+	if os.IsNotExist(err) {
+		return nil, nil;
+	}
+	if err != nil {
+		return nil, err;
+	}
+	defer f.Close();
+	var lines []string;
+	scanner := bufio.NewScanner(f);
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text());
+	}
+	return lines, scanner.Err();
+}
+
+// Nest answers rules which add nested's to r's, for the entries beneath the directory at path dir,
+// slash-separated and relative to the root r applies to; "" or "." names the root itself.
+// The nested rules are those of an ignore file found in that directory, freshly parsed or loaded:
+// their patterns are matched against paths relative to it, and they take precedence over r's.
+// If nested holds no rules, r itself is answered.
+func (r *IgnoreRules) Nest(dir string, nested *IgnoreRules) *IgnoreRules {
+	if nested == nil || len(nested.rules) == 0 {
+		return r;
+	}
+	if dir == "." {
+		dir = "";
+	}
+	return &IgnoreRules{rules: nested.rules, dir: dir, parent: r};
+}
+
+// Ignores answers true if the entry at path p, slash-separated and relative to the root the rules apply to, is to be ignored.
+// Its signature matches Predicate, so rules.Ignores may be used with the package's filters.
+func (r *IgnoreRules) Ignores(p string, fi os.FileInfo) bool {
+	if r == nil {
+		return false;
+	}
+	ignored := r.parent.Ignores(p, fi);
+	if r.dir != "" {
+		if !strings.HasPrefix(p, r.dir+"/") {
+			return ignored;
+		}
+		p = p[len(r.dir)+1:];
+	}
+	for _, rule := range r.rules {
+		if rule.negated != ignored || (rule.dirOnly && !fi.IsDir()) {
+			continue;
+		}
+		if matched, _ := MatchGlob(rule.pattern, p); matched {
+			ignored = !rule.negated;
+		}
+	}
+	return ignored;
+}
@@ -0,0 +1,197 @@
+package directory
+
+import (
+	"os"
+	"path"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// entryInfo is the os.FileInfo of an imaginary file or directory, for the rules to judge.
+type entryInfo struct {
+	name string;
+	dir  bool;
+}
+
+func (fi entryInfo) Name() string       { return path.Base(fi.name); }
+func (fi entryInfo) Size() int64        { return 0; }
+func (fi entryInfo) ModTime() time.Time { return time.Time{}; }
+func (fi entryInfo) IsDir() bool        { return fi.dir; }
+func (fi entryInfo) Sys() interface{}   { return nil; }
+
+func (fi entryInfo) Mode() os.FileMode {
+	if fi.dir {
+		return os.ModeDir | 0755;
+	}
+	return 0644;
+}
+
+func TestIgnores(t *testing.T) {
+	tests := []struct {
+		lines []string;
+		p     string;
+		dir   bool;
+		want  bool;
+	}{
+		{[]string{"*.log"}, "a.log", false, true},
+		{[]string{"*.log"}, "x/y/a.log", false, true},
+		{[]string{"*.log"}, "a.txt", false, false},
+		{[]string{"*.log  ", "\t"}, "a.log", false, true},
+
+		// Negation, the later rule taking precedence.
+		{[]string{"*.log", "!keep.log"}, "keep.log", false, false},
+		{[]string{"*.log", "!keep.log"}, "x/keep.log", false, false},
+		{[]string{"*.log", "!keep.log"}, "other.log", false, true},
+		{[]string{"!keep.log", "*.log"}, "keep.log", false, true},
+		{[]string{"!keep.log"}, "keep.log", false, false},
+
+		// Directory-only patterns.
+		{[]string{"node_modules/"}, "node_modules", true, true},
+		{[]string{"node_modules/"}, "a/node_modules", true, true},
+		{[]string{"node_modules/"}, "node_modules", false, false},
+		{[]string{"build//"}, "build", true, true},
+		{[]string{"tmp/", "!tmp/"}, "tmp", true, false},
+		{[]string{"tmp/", "!tmp"}, "tmp", true, false},
+		{[]string{"tmp", "!tmp/"}, "tmp", false, true},
+
+		// Patterns with slashes match from the root.
+		{[]string{"/build"}, "build", true, true},
+		{[]string{"/build"}, "src/build", true, false},
+		{[]string{"docs/**/draft-*"}, "docs/draft-1.md", false, true},
+		{[]string{"docs/**/draft-*"}, "docs/a/b/draft-1.md", false, true},
+		{[]string{"docs/**/draft-*"}, "other/draft-1.md", false, false},
+
+		// Comments, blank lines, and escapes.
+		{[]string{"# a.log", "", "/"}, "# a.log", false, false},
+		{[]string{`\#a.log`}, "#a.log", false, true},
+		{[]string{`\!a.log`}, "!a.log", false, true},
+	}
+	for _, tc := range tests {
+		rules, err := ParseIgnoreRules(tc.lines);
+		if err != nil {
+			t.Errorf("ParseIgnoreRules(%q) answered error %v", tc.lines, err);
+			continue;
+		}
+		if got := rules.Ignores(tc.p, entryInfo{tc.p, tc.dir}); got != tc.want {
+			t.Errorf("rules %q: Ignores(%q, directory %v) = %v, want %v", tc.lines, tc.p, tc.dir, got, tc.want);
+		}
+	}
+}
+
+func TestParseIgnoreRulesMalformed(t *testing.T) {
+	for _, lines := range [][]string{{"["}, {"*.log", "!docs/[/x"}, {"a/[b/"}} {
+		if _, err := ParseIgnoreRules(lines); err != path.ErrBadPattern {
+			t.Errorf("ParseIgnoreRules(%q) answered error %v, want %v", lines, err, path.ErrBadPattern);
+		}
+	}
+}
+
+func TestNest(t *testing.T) {
+	parse := func(lines ...string) *IgnoreRules {
+		t.Helper();
+		rules, err := ParseIgnoreRules(lines);
+		if err != nil {
+			t.Fatal(err);
+		}
+		return rules;
+	};
+	var none *IgnoreRules;
+	outer := parse("*.tmp", "/local.html");
+	rules := outer.Nest("docs", parse("!keep.tmp", "/draft.html", "*.bak"));
+	if empty := parse("# nothing"); outer.Nest("docs", empty) != outer || outer.Nest("docs", nil) != outer {
+		t.Errorf("nesting no rules answered new rules");
+	}
+	tests := []struct {
+		rules *IgnoreRules;
+		p     string;
+		want  bool;
+	}{
+		{none, "a.tmp", false},
+		{none.Nest(".", parse("*.tmp")), "a.tmp", true},
+		{none.Nest("", parse("/a.tmp")), "a.tmp", true},
+		{rules, "a.tmp", true},
+		{rules, "docs/a.tmp", true},
+		{rules, "docs/keep.tmp", false},
+		{rules, "docs/sub/keep.tmp", false},
+		{rules, "keep.tmp", true},
+		{rules, "other/keep.tmp", true},
+		{rules, "local.html", true},
+		{rules, "docs/local.html", false},
+		{rules, "docs/draft.html", true},
+		{rules, "docs/sub/draft.html", false},
+		{rules, "draft.html", false},
+		{rules, "docs/a.bak", true},
+		{rules, "docsx/a.bak", false},
+		{rules, "a.bak", false},
+	}
+	for _, tc := range tests {
+		if got := tc.rules.Ignores(tc.p, entryInfo{tc.p, false}); got != tc.want {
+			t.Errorf("Ignores(%q) = %v, want %v", tc.p, got, tc.want);
+		}
+	}
+}
+
+func TestLoadIgnoreRules(t *testing.T) {
+	root := t.TempDir();
+	rules, err := LoadIgnoreRules(root);
+	if err != nil || rules.Ignores("a.html", entryInfo{"a.html", false}) {
+		t.Fatalf("without ignore files, LoadIgnoreRules answered error %v, or rules which ignore a.html", err);
+	}
+	write(t, root, GitIgnoreFilename, "*.html\n");
+	write(t, root, HammerIgnoreFilename, "!index.html\n");
+	rules, err = LoadIgnoreRules(root);
+	if err != nil {
+		t.Fatal(err);
+	}
+	for p, want := range map[string]bool{"a.html": true, "index.html": false, "a.css": false} {
+		if got := rules.Ignores(p, entryInfo{p, false}); got != want {
+			t.Errorf("Ignores(%q) = %v, want %v", p, got, want);
+		}
+	}
+	write(t, root, HammerIgnoreFilename, "[\n");
+	if _, err := LoadIgnoreRules(root); err != path.ErrBadPattern {
+		t.Errorf("with a malformed pattern, LoadIgnoreRules answered error %v, want %v", err, path.ErrBadPattern);
+	}
+}
+
+// TestWalkIgnoreFiles checks that a walk heeds the ignore files it finds along the way,
+// and never reads those of ignored directories.
+func TestWalkIgnoreFiles(t *testing.T) {
+	root := t.TempDir();
+	write(t, root, HammerIgnoreFilename, "*.tmp\nbuild/\ndrafts/\n");
+	write(t, root, "a.tmp", "");
+	write(t, root, "a.html", "");
+	write(t, root, "build/a.html", "");
+	write(t, root, "docs/"+HammerIgnoreFilename, "!keep.tmp\n/local.html\n");
+	write(t, root, "docs/keep.tmp", "");
+	write(t, root, "docs/other.tmp", "");
+	write(t, root, "docs/local.html", "");
+	write(t, root, "docs/sub/local.html", "");
+	write(t, root, "docs/sub/keep.tmp", "");
+	write(t, root, "drafts/"+HammerIgnoreFilename, "!*\n");
+	write(t, root, "drafts/a.html", "");
+
+	var got []string;
+	err := WalkWith(root, WalkOptions{IgnoreFiles: []string{HammerIgnoreFilename}}, func(p string, fi os.FileInfo) error {
+		got = append(got, p);
+		return nil;
+	});
+	if err != nil {
+		t.Fatal(err);
+	}
+	want := []string{
+		HammerIgnoreFilename, "a.html",
+		"docs", "docs/" + HammerIgnoreFilename, "docs/keep.tmp",
+		"docs/sub", "docs/sub/keep.tmp", "docs/sub/local.html",
+	};
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("walk visited %q, want %q", got, want);
+	}
+
+	write(t, root, "docs/sub/"+HammerIgnoreFilename, "a/[b\n");
+	err = WalkWith(root, WalkOptions{IgnoreFiles: []string{HammerIgnoreFilename}}, func(string, os.FileInfo) error { return nil; });
+	if err != path.ErrBadPattern {
+		t.Errorf("with a malformed nested pattern, the walk answered error %v, want %v", err, path.ErrBadPattern);
+	}
+}
@@ -0,0 +1,39 @@
+package directory
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+// leftover matches the names WriteFileAtomic gives its temporary files.
+var leftover = regexp.MustCompile(`^\..+\.inprogress\d+$`)
+
+// RemoveLeftovers removes, from the directory tree rooted at root, the temporary files of WriteFileAtomic
+// left behind by a build killed outright, before it could clean up after itself,
+// along with any directory, such as a half-written article's, which held nothing else.
+// It answers the names of the files removed.
+// A root which doesn't exist holds no leftovers.
+func RemoveLeftovers(root string) ([]string, error) {
+	var removed []string
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == root {
+				return nil
+			}
+			return err
+		}
+		if info.Mode().IsRegular() && leftover.MatchString(info.Name()) {
+			err = os.Remove(path)
+			if err != nil {
+				return err
+			}
+			removed = append(removed, path)
+			if dir := filepath.Dir(path); dir != root && os.Remove(dir) == nil {
+				removed = append(removed, dir)
+			}
+		}
+		return nil
+	})
+	return removed, err
+}
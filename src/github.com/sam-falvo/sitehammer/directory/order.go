@@ -0,0 +1,106 @@
+package directory
+
+import (
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Key names the property by which entries are ordered during enumeration.
+type Key int;
+
+const (
+	// ByName orders entries by name, comparing bytes, so the order is the same on every platform.
+	ByName Key = iota;
+	// ByModTime orders entries by modification time.
+	ByModTime;
+	// BySize orders entries by size in bytes.
+	BySize;
+);
+
+// Order describes the order in which entries are enumerated.
+// Entries which tie on the chosen key are ordered by name, ascending, whatever the direction,
+// so enumeration is deterministic even when many files share a timestamp.
+// The zero Order is ascending by name, the order used by ForEachEntry and Walk.
+type Order struct {
+	By         Key;
+	Descending bool;
+}
+
+// Sort sorts entries into the given order.
+func Sort(entries []os.FileInfo, order Order) {
+	sort.SliceStable(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j];
+		var c int;
+		switch order.By {
+		case ByName:
+			c = strings.Compare(a.Name(), b.Name());
+		case ByModTime:
+			c = compareTimes(a, b);
+		case BySize:
+			c = compareSizes(a, b);
+		}
+		if c == 0 {
+			return a.Name() < b.Name();
+		}
+		if order.Descending {
+			return c > 0;
+		}
+		return c < 0;
+	});
+}
+
+// compareTimes answers -1, 0, or 1 as a was modified before, at the same time as, or after b.
+func compareTimes(a, b os.FileInfo) int {
+	switch {
+	case a.ModTime().Before(b.ModTime()):
+		return -1;
+	case a.ModTime().After(b.ModTime()):
+		return 1;
+	}
+	return 0;
+}
+
+// compareSizes answers -1, 0, or 1 as a is smaller than, the same size as, or larger than b.
+func compareSizes(a, b os.FileInfo) int {
+	switch {
+	case a.Size() < b.Size():
+		return -1;
+	case a.Size() > b.Size():
+		return 1;
+	}
+	return 0;
+}
+
+// readDir reads the entries of directory d, in the given order.
+func readDir(d string, order Order) ([]os.FileInfo, error) {
+	entries, err := ioutil.ReadDir(d);
+	if err != nil {
+		return nil, err;
+	}
+	Sort(entries, order);
+	return entries, nil;
+}
+
+// ForEachEntryOrdered works like ForEachEntry, but enumerates entries in the given order.
+func ForEachEntryOrdered(d string, order Order, f MemberHandler) error {
+	entries, err := readDir(d, order);
+	if err != nil {
+		return err;
+	}
+
+	for _, entry := range entries {
+		err := f(entry);
+		if err != nil {
+			return err;
+		}
+	}
+
+	return nil;
+}
+
+// WalkOrdered works like Walk, but visits the entries of each directory in the given order.
+func WalkOrdered(root string, order Order, f WalkHandler) error {
+	return walk(root, "", WalkOptions{Order: order}, f);
+}
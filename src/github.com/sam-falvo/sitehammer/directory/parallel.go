@@ -0,0 +1,131 @@
+package directory
+
+import (
+	"context"
+	"errors"
+	"os"
+	"runtime"
+	"sync"
+)
+
+// errStopped stops enumeration once a handler has failed; it's never returned to callers.
+var errStopped = errors.New("enumeration stopped");
+
+// ForEachEntryParallel works like ForEachEntry, but calls f from as many as workers goroutines at once;
+// if workers is less than one, it uses one per CPU.
+// Since handlers run concurrently, they may finish in any order, and f must be safe to call from several goroutines.
+// As with ForEachEntry, an error stops the enumeration: no handler starts after one has failed,
+// though those already running are allowed to finish.  The first error is returned.
+func ForEachEntryParallel(d string, workers int, f MemberHandler) error {
+	return parallel(nil, workers, func(submit func(func() error) bool) error {
+		entries, err := readDir(d, Order{});
+		if err != nil {
+			return err;
+		}
+		for _, entry := range entries {
+			entry := entry;
+			if !submit(func() error { return f(entry); }) {
+				break;
+			}
+		}
+		return nil;
+	});
+}
+
+// WalkParallel works like Walk, but calls f from as many as workers goroutines at once, as ForEachEntryParallel does.
+// Entries are still discovered in Walk's order, but a directory's handler may run after, or alongside, those of its contents.
+// For the same reason, SkipDir can't prune the walk; a handler returning it is taken to have succeeded.
+func WalkParallel(root string, workers int, f WalkHandler) error {
+	return WalkParallelWith(root, workers, WalkOptions{}, f);
+}
+
+// WalkParallelWith works like WalkParallel, as modified by the given options, as WalkWith is.
+// When the options' context is done, no further handlers start, and the walk answers the context's error.
+func WalkParallelWith(root string, workers int, opts WalkOptions, f WalkHandler) error {
+	return parallel(opts.Context, workers, func(submit func(func() error) bool) error {
+		return walk(root, "", opts, func(p string, fi os.FileInfo) error {
+			job := func() error {
+				err := f(p, fi);
+				if err == SkipDir {
+					return nil;
+				}
+				return err;
+			};
+			if !submit(job) {
+				return errStopped;
+			}
+			return nil;
+		});
+	});
+}
+
+// parallel runs the jobs submitted by produce on a pool of workers, answering the first error either produces.
+// Submit answers false once a job has failed, or ctx, if not nil, is done, whereupon produce should stop submitting.
+func parallel(ctx context.Context, workers int, produce func(submit func(func() error) bool) error) error {
+	if workers < 1 {
+		workers = runtime.NumCPU();
+	}
+	if ctx == nil {
+		ctx = context.Background();
+	}
+
+	jobs := make(chan func() error);
+	done := make(chan struct{});
+	var once sync.Once;
+	var first error;
+	fail := func(err error) {
+		once.Do(func() {
+			first = err;
+			close(done);
+		});
+	};
+
+	var wg sync.WaitGroup;
+	for i := 0; i < workers; i++ {
+		wg.Add(1);
+		go func() {
+			defer wg.Done();
+			for job := range jobs {
+				select {
+				case <-done:
+					continue;
+				case <-ctx.Done():
+					// A job handed over just as ctx was done.
+					fail(ctx.Err());
+					continue;
+				default:
+				}
+				if err := job(); err != nil {
+					fail(err);
+				}
+			}
+		}();
+	}
+
+	submit := func(job func() error) bool {
+		select {
+		case <-done:
+			return false;
+		case <-ctx.Done():
+			fail(ctx.Err());
+			return false;
+		default:
+		}
+		select {
+		case jobs <- job:
+			return true;
+		case <-done:
+			return false;
+		case <-ctx.Done():
+			fail(ctx.Err());
+			return false;
+		}
+	};
+	err := produce(submit);
+	close(jobs);
+	wg.Wait();
+	if err != nil && err != errStopped {
+		fail(err);
+	}
+	return first;
+}
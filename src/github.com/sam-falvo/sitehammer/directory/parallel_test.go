@@ -0,0 +1,194 @@
+package directory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recorder records the entries handlers are called for, safely from several goroutines,
+// along with the most handlers that have run at once.
+type recorder struct {
+	mu      sync.Mutex;
+	names   []string;
+	running int;
+	most    int;
+}
+
+// visit records a call for the named entry, lasting a moment so that handlers overlap.
+func (r *recorder) visit(name string) {
+	r.mu.Lock();
+	r.names = append(r.names, name);
+	r.running++;
+	if r.running > r.most {
+		r.most = r.running;
+	}
+	r.mu.Unlock();
+	time.Sleep(time.Millisecond);
+	r.mu.Lock();
+	r.running--;
+	r.mu.Unlock();
+}
+
+// sorted answers the names recorded, in ascending order.
+func (r *recorder) sorted() []string {
+	r.mu.Lock();
+	defer r.mu.Unlock();
+	names := append([]string(nil), r.names...);
+	sort.Strings(names);
+	return names;
+}
+
+// numbered plants n files, named f00, f01, and so on, in a new directory, answering the directory and the names.
+func numbered(t *testing.T, n int) (string, []string) {
+	t.Helper();
+	root := t.TempDir();
+	entries := make(map[string]string);
+	var names []string;
+	for i := 0; i < n; i++ {
+		name := fmt.Sprintf("f%02d", i);
+		entries[name] = name;
+		names = append(names, name);
+	}
+	plant(t, root, entries);
+	return root, names;
+}
+
+func TestForEachEntryParallel(t *testing.T) {
+	root, names := numbered(t, 20);
+	for _, workers := range []int{-1, 0, 1, 3, 50} {
+		r := &recorder{};
+		err := ForEachEntryParallel(root, workers, func(fi os.FileInfo) error {
+			r.visit(fi.Name());
+			return nil;
+		});
+		if err != nil {
+			t.Errorf("with %d workers, ForEachEntryParallel answered error %v", workers, err);
+		}
+		if got := r.sorted(); !reflect.DeepEqual(got, names) {
+			t.Errorf("with %d workers, handlers were called for %q, want %q", workers, got, names);
+		}
+		if workers > 0 && r.most > workers {
+			t.Errorf("with %d workers, %d handlers ran at once", workers, r.most);
+		}
+	}
+}
+
+func TestForEachEntryParallelErrors(t *testing.T) {
+	root, _ := numbered(t, 20);
+	failure := errors.New("handler failed");
+	tests := []struct {
+		workers int;
+		// fails names the entry whose handler fails.
+		fails string;
+		// most, if positive, is how many handlers may be called at most: with one worker, none starts after the one which fails.
+		most int;
+	}{
+		{1, "f00", 1},
+		{1, "f02", 3},
+		{1, "f19", 20},
+		{4, "f02", 0},
+		{4, "f19", 0},
+	}
+	for _, tc := range tests {
+		r := &recorder{};
+		err := ForEachEntryParallel(root, tc.workers, func(fi os.FileInfo) error {
+			r.visit(fi.Name());
+			if fi.Name() == tc.fails {
+				return failure;
+			}
+			return nil;
+		});
+		if err != failure {
+			t.Errorf("with %d workers, failing at %s: ForEachEntryParallel answered error %v, want %v", tc.workers, tc.fails, err, failure);
+		}
+		if got := len(r.sorted()); tc.most > 0 && got > tc.most {
+			t.Errorf("with %d workers, failing at %s: %d handlers were called, want no more than %d", tc.workers, tc.fails, got, tc.most);
+		}
+	}
+
+	if err := ForEachEntryParallel(filepath.Join(root, "missing"), 2, func(os.FileInfo) error { return nil; }); !os.IsNotExist(err) {
+		t.Errorf("enumerating a missing directory answered error %v, want a missing-file error", err);
+	}
+}
+
+func TestWalkParallel(t *testing.T) {
+	root := t.TempDir();
+	plant(t, root, map[string]string{"a.html": "", "docs/b.html": "", "docs/sub/c.html": "", "empty/": "", "skipped/d.html": ""});
+	var want []string;
+	err := Walk(root, func(p string, fi os.FileInfo) error {
+		want = append(want, p);
+		return nil;
+	});
+	if err != nil {
+		t.Fatal(err);
+	}
+	for _, workers := range []int{1, 4} {
+		r := &recorder{};
+		err := WalkParallel(root, workers, func(p string, fi os.FileInfo) error {
+			r.visit(p);
+			if p == "skipped" {
+				// Taken as success, though it can't prune the walk.
+				return SkipDir;
+			}
+			return nil;
+		});
+		if err != nil {
+			t.Errorf("with %d workers, WalkParallel answered error %v", workers, err);
+		}
+		if got := r.sorted(); !reflect.DeepEqual(got, want) {
+			t.Errorf("with %d workers, WalkParallel visited %q, want %q", workers, got, want);
+		}
+	}
+
+	failure := errors.New("handler failed");
+	err = WalkParallel(root, 1, func(p string, fi os.FileInfo) error {
+		if p == "docs/b.html" {
+			return failure;
+		}
+		return nil;
+	});
+	if err != failure {
+		t.Errorf("WalkParallel answered error %v, want %v", err, failure);
+	}
+}
+
+func TestWalkParallelCancellation(t *testing.T) {
+	root, _ := numbered(t, 20);
+
+	ctx, cancel := context.WithCancel(context.Background());
+	cancel();
+	r := &recorder{};
+	err := WalkParallelWith(root, 2, WalkOptions{Context: ctx}, func(p string, fi os.FileInfo) error {
+		r.visit(p);
+		return nil;
+	});
+	if err != context.Canceled || len(r.sorted()) != 0 {
+		t.Errorf("walking with a context done already answered error %v, having called handlers for %q; want %v, and none", err, r.sorted(), context.Canceled);
+	}
+
+	// Once the context is done, no further handlers start, though those running already finish.
+	for _, workers := range []int{1, 4} {
+		ctx, cancel := context.WithCancel(context.Background());
+		r := &recorder{};
+		err := WalkParallelWith(root, workers, WalkOptions{Context: ctx}, func(p string, fi os.FileInfo) error {
+			cancel();
+			r.visit(p);
+			return nil;
+		});
+		cancel();
+		if err != context.Canceled {
+			t.Errorf("with %d workers, a walk cancelled by its first handler answered error %v, want %v", workers, err, context.Canceled);
+		}
+		if got := len(r.sorted()); got > workers {
+			t.Errorf("with %d workers, a walk cancelled by its first handler called %d handlers", workers, got);
+		}
+	}
+}
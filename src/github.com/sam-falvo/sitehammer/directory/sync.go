@@ -0,0 +1,179 @@
+package directory
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// SyncOptions controls SyncTree.
+// Filter, if not nil, selects the entries to mirror, given their paths relative to either directory;
+// entries it rejects are neither copied from the source nor removed from the destination.
+// DryRun reports the changes SyncTree would make, without making them.
+type SyncOptions struct {
+	Filter Predicate;
+	DryRun bool;
+}
+
+// Changes reports what SyncTree changed, or what Diff found to differ, naming entries by slash-separated paths.
+// SyncTree lists a directory it added or removed once, without the entries beneath it.
+type Changes struct {
+	Added   []string;
+	Updated []string;
+	Removed []string;
+}
+
+// Empty answers true if nothing changed.
+func (c *Changes) Empty() bool {
+	return len(c.Added) == 0 && len(c.Updated) == 0 && len(c.Removed) == 0;
+}
+
+// SyncTree makes the directory dst a mirror of the directory src, creating dst if need be.
+// Entries missing from dst are copied from src, entries differing from those of src are replaced,
+// and entries of dst which don't exist in src are removed.
+// A regular file differs if its size, modification time, or permissions differ; a symbolic link, if its target differs.
+// As with CopyTree, permissions and modification times are preserved, symbolic links are copied as links,
+// and special files are skipped.
+func SyncTree(src, dst string, opts SyncOptions) (*Changes, error) {
+	changes := &Changes{};
+	fi, err := os.Stat(src);
+	if err != nil {
+		return changes, err;
+	}
+	if !opts.DryRun {
+		err = ensureCopyDir(dst, fi);
+		if err != nil {
+			return changes, err;
+		}
+	}
+	err = syncTree(src, dst, "", opts, changes);
+	return changes, err;
+}
+
+// syncTree mirrors the directory named rel, relative to src, into the same place relative to dst, for SyncTree.
+func syncTree(src, dst, rel string, opts SyncOptions, changes *Changes) error {
+	from := filepath.Join(src, filepath.FromSlash(rel));
+	to := filepath.Join(dst, filepath.FromSlash(rel));
+	sources, err := readDir(from, Order{});
+	if err != nil {
+		return err;
+	}
+	targets, err := readDir(to, Order{});
+	if err != nil && !os.IsNotExist(err) {
+		return err;
+	}
+	existing := make(map[string]os.FileInfo, len(targets));
+	for _, t := range targets {
+		existing[t.Name()] = t;
+	}
+
+	wanted := make(map[string]bool, len(sources));
+	for _, s := range sources {
+		name := path.Join(rel, s.Name());
+		if opts.Filter != nil && !opts.Filter(name, s) {
+			continue;
+		}
+		if !s.IsDir() && !s.Mode().IsRegular() && s.Mode()&os.ModeSymlink == 0 {
+			continue;
+		}
+		wanted[s.Name()] = true;
+		err = syncEntry(src, dst, name, s, existing[s.Name()], opts, changes);
+		if err != nil {
+			return err;
+		}
+	}
+
+	for _, t := range targets {
+		name := path.Join(rel, t.Name());
+		if wanted[t.Name()] || (opts.Filter != nil && !opts.Filter(name, t)) {
+			continue;
+		}
+		changes.Removed = append(changes.Removed, name);
+		if !opts.DryRun {
+			err = os.RemoveAll(filepath.Join(dst, filepath.FromSlash(name)));
+			if err != nil {
+				return err;
+			}
+		}
+	}
+
+	return nil;
+}
+
+// syncEntry mirrors the source entry s, named by name, onto the destination entry d, which is nil if it doesn't exist.
+func syncEntry(src, dst, name string, s, d os.FileInfo, opts SyncOptions, changes *Changes) error {
+	from := filepath.Join(src, filepath.FromSlash(name));
+	to := filepath.Join(dst, filepath.FromSlash(name));
+
+	if d != nil && !sameKind(s, d) {
+		changes.Removed = append(changes.Removed, name);
+		if !opts.DryRun {
+			err := os.RemoveAll(to);
+			if err != nil {
+				return err;
+			}
+		}
+		d = nil;
+	}
+
+	if s.IsDir() {
+		if d == nil {
+			changes.Added = append(changes.Added, name);
+			if opts.DryRun {
+				return nil;
+			}
+			err := ensureCopyDir(to, s);
+			if err != nil {
+				return err;
+			}
+			// Everything beneath a new directory is new too; the directory alone is reported.
+			return syncTree(src, dst, name, opts, &Changes{});
+		}
+		return syncTree(src, dst, name, opts, changes);
+	}
+
+	if d != nil {
+		differs, err := entriesDiffer(from, to, s, d);
+		if err != nil || !differs {
+			return err;
+		}
+		changes.Updated = append(changes.Updated, name);
+	} else {
+		changes.Added = append(changes.Added, name);
+	}
+	if opts.DryRun {
+		return nil;
+	}
+	if d != nil {
+		err := os.Remove(to);
+		if err != nil {
+			return err;
+		}
+	}
+	if s.Mode()&os.ModeSymlink != 0 {
+		target, err := os.Readlink(from);
+		if err != nil {
+			return err;
+		}
+		return os.Symlink(target, to);
+	}
+	return copyFile(from, to, s);
+}
+
+// sameKind answers true if a and b are both directories, both symbolic links, or both regular files.
+func sameKind(a, b os.FileInfo) bool {
+	return a.Mode().Type() == b.Mode().Type();
+}
+
+// entriesDiffer answers true if the destination entry d, found at to, must be replaced by the source entry s, found at from.
+func entriesDiffer(from, to string, s, d os.FileInfo) (bool, error) {
+	if s.Mode()&os.ModeSymlink != 0 {
+		a, err := os.Readlink(from);
+		if err != nil {
+			return false, err;
+		}
+		b, err := os.Readlink(to);
+		return a != b, err;
+	}
+	return s.Size() != d.Size() || !s.ModTime().Equal(d.ModTime()) || s.Mode().Perm() != d.Mode().Perm(), nil;
+}
@@ -0,0 +1,203 @@
+package directory
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// planted is the modification time plant gives the files it writes, so that identical files planted apart look alike.
+var planted = time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC);
+
+// plant creates the given entries beneath root, by slash-separated path.
+// Content beginning with "-> " makes a symbolic link to the rest; a path ending in a slash, an empty directory.
+// Files are given the modification time planted.
+func plant(t *testing.T, root string, entries map[string]string) {
+	t.Helper();
+	if err := os.MkdirAll(root, 0755); err != nil {
+		t.Fatal(err);
+	}
+	for p, content := range entries {
+		filename := filepath.Join(root, filepath.FromSlash(p));
+		if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+			t.Fatal(err);
+		}
+		var err error;
+		switch {
+		case strings.HasSuffix(p, "/"):
+			err = os.MkdirAll(filename, 0755);
+		case strings.HasPrefix(content, "-> "):
+			err = os.Symlink(strings.TrimPrefix(content, "-> "), filename);
+		default:
+			err = ioutil.WriteFile(filename, []byte(content), 0644);
+			if err == nil {
+				err = os.Chtimes(filename, planted, planted);
+			}
+		}
+		if err != nil {
+			t.Fatal(err);
+		}
+	}
+}
+
+// treeOf answers what lies beneath root, in the form plant takes, save that every directory is listed.
+func treeOf(t *testing.T, root string) map[string]string {
+	t.Helper();
+	tree := make(map[string]string);
+	err := Walk(root, func(p string, fi os.FileInfo) error {
+		filename := filepath.Join(root, filepath.FromSlash(p));
+		switch {
+		case fi.IsDir():
+			tree[p+"/"] = "";
+		case fi.Mode()&os.ModeSymlink != 0:
+			target, err := os.Readlink(filename);
+			tree[p] = "-> " + target;
+			return err;
+		default:
+			content, err := ioutil.ReadFile(filename);
+			tree[p] = string(content);
+			return err;
+		}
+		return nil;
+	});
+	if err != nil {
+		t.Fatal(err);
+	}
+	return tree;
+}
+
+func TestSyncTree(t *testing.T) {
+	notKept := func(p string, fi os.FileInfo) bool { return !strings.HasSuffix(p, ".keep"); };
+	tests := []struct {
+		name     string;
+		src, dst map[string]string;
+		filter   Predicate;
+		want     Changes;
+		// after is what dst is to hold afterwards, if not exactly what src holds.
+		after map[string]string;
+	}{
+		{
+			name: "into an empty directory",
+			src:  map[string]string{"a.html": "a", "docs/b.html": "b", "docs/sub/c.html": "c", "link": "-> a.html"},
+			dst:  map[string]string{},
+			want: Changes{Added: []string{"a.html", "docs", "link"}},
+		},
+		{
+			name: "unchanged",
+			src:  map[string]string{"a.html": "a", "docs/b.html": "b", "empty/": "", "link": "-> a.html"},
+			dst:  map[string]string{"a.html": "a", "docs/b.html": "b", "empty/": "", "link": "-> a.html"},
+		},
+		{
+			name: "deletions",
+			src:  map[string]string{"a.html": "a", "docs/b.html": "b"},
+			dst:  map[string]string{"a.html": "a", "docs/b.html": "b", "docs/old.html": "old", "gone/x.html": "x", "stale": "-> a.html", "z.html": "z"},
+			want: Changes{Removed: []string{"docs/old.html", "gone", "stale", "z.html"}},
+		},
+		{
+			name: "updates",
+			src:  map[string]string{"size.html": "longer", "same.html": "same", "link": "-> b.html"},
+			dst:  map[string]string{"size.html": "short", "same.html": "same", "link": "-> a.html"},
+			want: Changes{Updated: []string{"link", "size.html"}},
+		},
+		{
+			name: "entries changing kind",
+			src:  map[string]string{"x/y.html": "y", "f": "file"},
+			dst:  map[string]string{"x": "file", "f/g.html": "g"},
+			want: Changes{Added: []string{"f", "x"}, Removed: []string{"f", "x"}},
+		},
+		{
+			name:   "filtered",
+			src:    map[string]string{"a.html": "a", "b.keep": "b"},
+			dst:    map[string]string{"old.keep": "old"},
+			filter: notKept,
+			want:   Changes{Added: []string{"a.html"}},
+			after:  map[string]string{"a.html": "a", "old.keep": "old"},
+		},
+	}
+	for _, tc := range tests {
+		root := t.TempDir();
+		src, dst := filepath.Join(root, "src"), filepath.Join(root, "dst");
+		plant(t, src, tc.src);
+		plant(t, dst, tc.dst);
+		before := treeOf(t, dst);
+
+		changes, err := SyncTree(src, dst, SyncOptions{Filter: tc.filter, DryRun: true});
+		if err != nil {
+			t.Errorf("%s: dry run answered error %v", tc.name, err);
+			continue;
+		}
+		if !reflect.DeepEqual(*changes, tc.want) {
+			t.Errorf("%s: dry run reported %+v, want %+v", tc.name, *changes, tc.want);
+		}
+		if got := treeOf(t, dst); !reflect.DeepEqual(got, before) {
+			t.Errorf("%s: dry run changed the destination to %q", tc.name, got);
+		}
+
+		changes, err = SyncTree(src, dst, SyncOptions{Filter: tc.filter});
+		if err != nil {
+			t.Errorf("%s: SyncTree answered error %v", tc.name, err);
+			continue;
+		}
+		if !reflect.DeepEqual(*changes, tc.want) {
+			t.Errorf("%s: SyncTree reported %+v, want %+v", tc.name, *changes, tc.want);
+		}
+		after := treeOf(t, src);
+		if tc.after != nil {
+			plant(t, filepath.Join(root, "after"), tc.after);
+			after = treeOf(t, filepath.Join(root, "after"));
+		}
+		if got := treeOf(t, dst); !reflect.DeepEqual(got, after) {
+			t.Errorf("%s: destination holds %q, want %q", tc.name, got, after);
+		}
+
+		// The mirror, its modification times preserved, is now up to date.
+		changes, err = SyncTree(src, dst, SyncOptions{Filter: tc.filter});
+		if err != nil || !changes.Empty() {
+			t.Errorf("%s: syncing again reported %+v, %v; want no changes", tc.name, *changes, err);
+		}
+	}
+}
+
+// TestSyncTreeModTime checks that a file differing only in its modification time is replaced.
+func TestSyncTreeModTime(t *testing.T) {
+	root := t.TempDir();
+	src, dst := filepath.Join(root, "src"), filepath.Join(root, "dst");
+	plant(t, src, map[string]string{"a.html": "new"});
+	plant(t, dst, map[string]string{"a.html": "old"});
+	later := planted.Add(time.Hour);
+	if err := os.Chtimes(filepath.Join(src, "a.html"), later, later); err != nil {
+		t.Fatal(err);
+	}
+	changes, err := SyncTree(src, dst, SyncOptions{});
+	if err != nil {
+		t.Fatal(err);
+	}
+	if want := []string{"a.html"}; !reflect.DeepEqual(changes.Updated, want) {
+		t.Errorf("SyncTree updated %q, want %q", changes.Updated, want);
+	}
+	fi, err := os.Stat(filepath.Join(dst, "a.html"));
+	if err != nil {
+		t.Fatal(err);
+	}
+	if !fi.ModTime().Equal(later) {
+		t.Errorf("the mirrored file's modification time = %v, want %v", fi.ModTime(), later);
+	}
+	if got := treeOf(t, dst)["a.html"]; got != "new" {
+		t.Errorf("the mirrored file holds %q, want %q", got, "new");
+	}
+}
+
+func TestSyncTreeMissingSource(t *testing.T) {
+	root := t.TempDir();
+	changes, err := SyncTree(filepath.Join(root, "missing"), filepath.Join(root, "dst"), SyncOptions{});
+	if !os.IsNotExist(err) || !changes.Empty() {
+		t.Errorf("SyncTree from a missing directory answered %+v, %v; want no changes and a missing-file error", *changes, err);
+	}
+	if _, err := os.Stat(filepath.Join(root, "dst")); !os.IsNotExist(err) {
+		t.Errorf("SyncTree from a missing directory created the destination");
+	}
+}
@@ -0,0 +1,147 @@
+package directory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// WalkHandler functions are used by Walk to process what's found beneath a directory.
+// A WalkHandler takes the entry's path, relative to the root of the walk and separated by slashes,
+// along with its os.FileInfo, and returns either nil if processing is successful, or some error otherwise.
+// Returning an error terminates the walk, except for SkipDir.
+type WalkHandler func(string, os.FileInfo) error;
+
+// SkipDir may be returned by a WalkHandler to prune the walk, as with filepath.WalkDir.
+// Returned for a directory, the directory's contents are skipped; returned for anything else,
+// the remaining entries of the directory containing it are skipped.  Either way, the walk carries on otherwise,
+// and SkipDir is never returned by the walk itself.
+var SkipDir = errors.New("skip this directory");
+
+// Symlinks decides how Walk treats symbolic links.
+type Symlinks int;
+
+const (
+	// ReportSymlinks passes symbolic links to the handler as links, without following them.
+	ReportSymlinks Symlinks = iota;
+	// FollowSymlinks passes the files and directories that symbolic links refer to, under the links' names,
+	// descending into linked directories.
+	// A link which can't be followed, because its target doesn't exist, is reported as a link.
+	// A link leading back into a directory the walk is already inside of would make the walk endless, so it stops the walk with an error.
+	FollowSymlinks;
+	// SkipSymlinks ignores symbolic links altogether.
+	SkipSymlinks;
+);
+
+// WalkOptions controls WalkWith.
+// Order gives the order in which each directory's entries are visited.
+// Ignore, if not nil, names entries to skip; an ignored directory isn't descended into at all,
+// which spares the walk trees like node_modules.
+// IgnoreFiles names ignore files, such as HammerIgnoreFilename, to read from every directory the walk enters, the root included;
+// the rules of each apply beneath the directory holding it, on top of Ignore and those of the directories above, as IgnoreRules.Nest describes.
+// Symlinks decides the treatment of symbolic links.
+// MaxDepth, if positive, limits how deeply the walk descends: with a MaxDepth of 1, only the root's own entries are visited;
+// with 2, those of its subdirectories as well, and so on.
+// Context, if not nil, cancels the walk: once it's done, no further entries are visited, and the walk answers the context's error.
+type WalkOptions struct {
+	Order    Order;
+	Ignore      *IgnoreRules;
+	IgnoreFiles []string;
+	Symlinks    Symlinks;
+	MaxDepth    int;
+	Context     context.Context;
+}
+
+// Walk enumerates every entry beneath the directory root, descending into subdirectories, and calls f for each one.
+// The root itself isn't passed to f.
+// A directory is passed to f before its contents; the entries of each directory are visited in ascending order by name,
+// comparing bytes, whatever the platform.  Use WalkOrdered for other orders.
+// Symbolic links are reported as links, and never followed; use WalkWith to follow or skip them.
+func Walk(root string, f WalkHandler) error {
+	return walk(root, "", WalkOptions{}, f);
+}
+
+// WalkWith works like Walk, as modified by the given options.
+func WalkWith(root string, opts WalkOptions, f WalkHandler) error {
+	return walk(root, "", opts, f);
+}
+
+// walk enumerates the directory named rel, relative to root, for Walk and its variants.
+func walk(root, rel string, opts WalkOptions, f WalkHandler) error {
+	var ancestors []os.FileInfo;
+	if opts.Symlinks == FollowSymlinks {
+		fi, err := os.Stat(filepath.Join(root, filepath.FromSlash(rel)));
+		if err != nil {
+			return err;
+		}
+		ancestors = []os.FileInfo{fi};
+	}
+	err := walkDir(root, rel, opts, f, ancestors, 1);
+	if err == SkipDir {
+		return nil;
+	}
+	return err;
+}
+
+// walkDir enumerates the directory named rel, relative to root, whose entries lie at the given depth,
+// inside of the given ancestors when following symbolic links.
+func walkDir(root, rel string, opts WalkOptions, f WalkHandler, ancestors []os.FileInfo, depth int) error {
+	dirname := filepath.Join(root, filepath.FromSlash(rel));
+	entries, err := readDir(dirname, opts.Order);
+	if err != nil {
+		return err;
+	}
+	if len(opts.IgnoreFiles) > 0 {
+		// The directory's own rules apply to what lies beneath it, opts being walkDir's own copy.
+		nested, err := loadIgnoreFiles(dirname, opts.IgnoreFiles);
+		if err != nil {
+			return err;
+		}
+		opts.Ignore = opts.Ignore.Nest(rel, nested);
+	}
+
+	for _, entry := range entries {
+		if err := contextErr(opts.Context); err != nil {
+			return err;
+		}
+		name := path.Join(rel, entry.Name());
+		if entry.Mode()&os.ModeSymlink != 0 {
+			switch opts.Symlinks {
+			case SkipSymlinks:
+				continue;
+			case FollowSymlinks:
+				if target, err := os.Stat(filepath.Join(root, filepath.FromSlash(name))); err == nil {
+					entry = target;
+				}
+			}
+		}
+		if opts.Ignore.Ignores(name, entry) {
+			continue;
+		}
+		err = f(name, entry);
+		if err == SkipDir && entry.IsDir() {
+			continue;
+		}
+		if err != nil {
+			return err;
+		}
+		if entry.IsDir() && (opts.MaxDepth <= 0 || depth < opts.MaxDepth) {
+			if opts.Symlinks == FollowSymlinks {
+				for _, a := range ancestors {
+					if os.SameFile(a, entry) {
+						return fmt.Errorf("Cannot walk %s: it leads back into a directory containing it.", name);
+					}
+				}
+			}
+			err = walkDir(root, name, opts, f, append(ancestors[:len(ancestors):len(ancestors)], entry), depth+1);
+			if err != nil && err != SkipDir {
+				return err;
+			}
+		}
+	}
+
+	return nil;
+}
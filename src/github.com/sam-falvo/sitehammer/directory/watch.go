@@ -0,0 +1,370 @@
+package directory
+
+import (
+	"github.com/fsnotify/fsnotify"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Op describes how a watched file changed.
+type Op int;
+
+const (
+	Created Op = iota + 1;
+	Modified;
+	Removed;
+);
+
+// String names the operation.
+func (op Op) String() string {
+	switch op {
+	case Created:
+		return "created";
+	case Modified:
+		return "modified";
+	case Removed:
+		return "removed";
+	}
+	return "unknown";
+}
+
+// Event reports a change to a file beneath a watched directory, named by its slash-separated path relative to the directory.
+type Event struct {
+	Path string;
+	Op   Op;
+}
+
+// Debounce gives how long a Watcher waits, after the latest change it's told of, before delivering the changes it holds.
+var Debounce = 100 * time.Millisecond;
+
+// WatchOptions controls WatchWith.
+// Patterns, if any are given, limit the files reported to those whose paths match one of them; see MatchGlob for their syntax.
+// Ignore, if not nil, names entries to leave unwatched, as it does for WalkWith; an ignored directory isn't watched, nor anything beneath it.
+// IgnoreFiles names ignore files to read from every directory watched, as it does for WalkWith.
+// They're read as their directories come to be watched; to heed later changes to them, watch anew.
+// Skip lists directories not to watch either, by their slash-separated paths relative to the root,
+// such as the output directory and caches, which every build rewrites.
+type WatchOptions struct {
+	Patterns    []string;
+	Ignore      *IgnoreRules;
+	IgnoreFiles []string;
+	Skip        []string;
+}
+
+// Watcher reports changes to the files beneath a directory; see Watch.
+type Watcher struct {
+	// Events delivers changes in batches, each sorted by path.  It's closed once the Watcher is closed.
+	Events <-chan []Event;
+	// Errors delivers problems encountered while watching, such as the operating system's queue of changes overflowing;
+	// the Watcher carries on regardless.
+	// Errors aren't queued; one which nobody is waiting to receive is dropped.
+	Errors <-chan error;
+
+	root    string;
+	opts    WatchOptions;
+	watcher *fsnotify.Watcher;
+	events  chan []Event;
+	errors  chan error;
+	stop    chan struct{};
+	done    chan struct{};
+
+	// files and dirs hold the paths of the files reported and the directories watched, so that removing or renaming a directory
+	// reports the files within it, and stops watching the directories within it.
+	files map[string]bool;
+	dirs  map[string]bool;
+	// ignores holds the ignore rules in effect within each directory watched, when the options name ignore files.
+	ignores map[string]*IgnoreRules;
+}
+
+// Watch begins watching the files beneath the directory root whose paths match any of the given glob patterns,
+// or every file if no patterns are given; see MatchGlob for the patterns' syntax.  Directories themselves aren't reported.
+// It's WatchWith, given only the patterns.
+func Watch(root string, patterns ...string) (*Watcher, error) {
+	return WatchWith(root, WatchOptions{Patterns: patterns});
+}
+
+// WatchWith begins watching the files beneath the directory root, as modified by the given options.
+//
+// Changes are learned of from the operating system, through fsnotify, rather than by scanning the tree again and again:
+// every directory beneath the root is watched, save those the options leave out, and directories created later are watched as they appear,
+// their files reported as created.  Some operating systems limit how many directories may be watched, as Linux does with
+// fs.inotify.max_user_watches; leaving out trees nobody edits, like node_modules, keeps within them.
+// Changes are debounced: they're held until none has arrived for Debounce, then delivered together,
+// so saving a dozen files at once, or an editor's write-then-rename, provokes one batch rather than many.
+// A file created and removed again before delivery isn't reported at all.
+//
+// Close the Watcher to stop watching.
+func WatchWith(root string, opts WatchOptions) (*Watcher, error) {
+	for _, pattern := range opts.Patterns {
+		if _, err := MatchGlob(pattern, ""); err != nil {
+			return nil, err;
+		}
+	}
+	watcher, err := fsnotify.NewWatcher();
+	if err != nil {
+		return nil, err;
+	}
+	events := make(chan []Event);
+	errors := make(chan error);
+	w := &Watcher{
+		Events:  events,
+		Errors:  errors,
+		root:    root,
+		opts:    opts,
+		watcher: watcher,
+		events:  events,
+		errors:  errors,
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+		files:   make(map[string]bool),
+		dirs:    make(map[string]bool),
+		ignores: make(map[string]*IgnoreRules),
+	};
+	err = w.add("", nil);
+	if err != nil {
+		watcher.Close();
+		return nil, err;
+	}
+	go w.run();
+	return w, nil;
+}
+
+// Close stops watching, discarding any changes not yet delivered.
+func (w *Watcher) Close() error {
+	close(w.stop);
+	<-w.done;
+	return nil;
+}
+
+// run gathers changes, delivering them once they settle, until the Watcher is closed.
+func (w *Watcher) run() {
+	defer close(w.done);
+	defer close(w.events);
+	defer w.watcher.Close();
+	settled := time.NewTimer(Debounce);
+	settled.Stop();
+	defer settled.Stop();
+
+	pending := make(map[string]Op);
+	for {
+		select {
+		case <-w.stop:
+			return;
+		case e, ok := <-w.watcher.Events:
+			if !ok {
+				return;
+			}
+			w.handle(e, pending);
+			settled.Reset(Debounce);
+			continue;
+		case err, ok := <-w.watcher.Errors:
+			if ok {
+				w.report(err);
+			}
+			continue;
+		case <-settled.C:
+		}
+		if len(pending) == 0 {
+			continue;
+		}
+
+		batch := make([]Event, 0, len(pending));
+		for p, op := range pending {
+			batch = append(batch, Event{p, op});
+		}
+		sort.Slice(batch, func(i, j int) bool { return batch[i].Path < batch[j].Path; });
+		select {
+		case w.events <- batch:
+			pending = make(map[string]Op);
+		case <-w.stop:
+			return;
+		}
+	}
+}
+
+// report passes err on to whoever is receiving from Errors, if anyone is.
+func (w *Watcher) report(err error) {
+	select {
+	case w.errors <- err:
+	default:
+	}
+}
+
+// handle records the change fsnotify tells of in pending.
+func (w *Watcher) handle(e fsnotify.Event, pending map[string]Op) {
+	rel, err := filepath.Rel(w.root, e.Name);
+	if err != nil {
+		return;
+	}
+	p := filepath.ToSlash(rel);
+	switch {
+	case e.Has(fsnotify.Remove) || e.Has(fsnotify.Rename):
+		w.forget(p, pending);
+	case e.Has(fsnotify.Create) || e.Has(fsnotify.Write):
+		fi, err := os.Lstat(e.Name);
+		if err != nil || w.skips(p, fi) {
+			// Gone again already, in which case its removal follows.
+			return;
+		}
+		if fi.IsDir() {
+			if !w.dirs[p] {
+				err = w.add(p, pending);
+				if err != nil {
+					w.report(err);
+				}
+			}
+			return;
+		}
+		if !w.watches(p) {
+			return;
+		}
+		if w.files[p] {
+			note(pending, p, Modified);
+			return;
+		}
+		w.files[p] = true;
+		note(pending, p, Created);
+	}
+}
+
+// add watches the directory at path rel, relative to the root, and every directory beneath it, save those the options leave out,
+// noting the files within them as created in pending, if it isn't nil.
+func (w *Watcher) add(rel string, pending map[string]Op) error {
+	err := w.watchDir(rel);
+	if err != nil {
+		return err;
+	}
+	return Walk(filepath.Join(w.root, filepath.FromSlash(rel)), func(p string, fi os.FileInfo) error {
+		p = path.Join(rel, p);
+		switch {
+		case w.skips(p, fi):
+			if fi.IsDir() {
+				return SkipDir;
+			}
+		case fi.IsDir():
+			err := w.watchDir(p);
+			if err != nil {
+				return err;
+			}
+		case w.watches(p) && !w.files[p]:
+			w.files[p] = true;
+			if pending != nil {
+				note(pending, p, Created);
+			}
+		}
+		return nil;
+	});
+}
+
+// watchDir watches the directory at path rel, relative to the root, reading its ignore files, if the options name any.
+func (w *Watcher) watchDir(rel string) error {
+	dirname := filepath.Join(w.root, filepath.FromSlash(rel));
+	err := w.watcher.Add(dirname);
+	if err != nil {
+		return err;
+	}
+	w.dirs[rel] = true;
+	if len(w.opts.IgnoreFiles) > 0 {
+		nested, err := loadIgnoreFiles(dirname, w.opts.IgnoreFiles);
+		if err != nil {
+			return err;
+		}
+		parent := w.opts.Ignore;
+		if rel != "" {
+			parent = w.rulesWithin(parentOf(rel));
+		}
+		w.ignores[rel] = parent.Nest(rel, nested);
+	}
+	return nil;
+}
+
+// rulesWithin answers the ignore rules in effect for the entries of the watched directory at path dir.
+func (w *Watcher) rulesWithin(dir string) *IgnoreRules {
+	if rules, ok := w.ignores[dir]; ok {
+		return rules;
+	}
+	return w.opts.Ignore;
+}
+
+// parentOf answers the path of the directory containing the entry at path p, relative to the root, or "" for the root itself.
+func parentOf(p string) string {
+	dir := path.Dir(p);
+	if dir == "." {
+		return "";
+	}
+	return dir;
+}
+
+// forget notes the removal, in pending, of the file at path p, or of every file beneath it, if it was a directory,
+// and stops watching the directories beneath it.
+func (w *Watcher) forget(p string, pending map[string]Op) {
+	if w.files[p] {
+		delete(w.files, p);
+		note(pending, p, Removed);
+	}
+	if !w.dirs[p] {
+		return;
+	}
+	prefix := p + "/";
+	for f := range w.files {
+		if strings.HasPrefix(f, prefix) {
+			delete(w.files, f);
+			note(pending, f, Removed);
+		}
+	}
+	for d := range w.dirs {
+		if d == p || strings.HasPrefix(d, prefix) {
+			delete(w.dirs, d);
+			delete(w.ignores, d);
+			// A directory removed outright is no longer watched anyway; one renamed away still is.
+			w.watcher.Remove(filepath.Join(w.root, filepath.FromSlash(d)));
+		}
+	}
+}
+
+// skips answers true if the entry at path p is left unwatched: if the options ignore it, or it's a directory they skip.
+func (w *Watcher) skips(p string, fi os.FileInfo) bool {
+	if w.rulesWithin(parentOf(p)).Ignores(p, fi) {
+		return true;
+	}
+	if !fi.IsDir() {
+		return false;
+	}
+	for _, dir := range w.opts.Skip {
+		if p == path.Clean(dir) {
+			return true;
+		}
+	}
+	return false;
+}
+
+// watches answers true if the file at path p matches the Watcher's patterns.
+func (w *Watcher) watches(p string) bool {
+	if len(w.opts.Patterns) == 0 {
+		return true;
+	}
+	for _, pattern := range w.opts.Patterns {
+		if matched, _ := MatchGlob(pattern, p); matched {
+			return true;
+		}
+	}
+	return false;
+}
+
+// note records a change to the file at path p in pending, merging it with any change already pending.
+func note(pending map[string]Op, p string, op Op) {
+	switch prior := pending[p]; {
+	case prior == Created && op == Removed:
+		delete(pending, p);
+	case prior == Created:
+		// Still new, as far as the recipient knows.
+	case prior == Removed && op == Created:
+		pending[p] = Modified;
+	default:
+		pending[p] = op;
+	}
+}
@@ -0,0 +1,102 @@
+package directory
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// nextBatch answers the next batch of changes w delivers, failing the test if none arrives in time.
+func nextBatch(t *testing.T, w *Watcher) []Event {
+	t.Helper();
+	select {
+	case batch := <-w.Events:
+		return batch;
+	case <-time.After(5 * time.Second):
+		t.Fatal("no changes delivered");
+	}
+	return nil;
+}
+
+// write writes content to the file at the slash-separated path p beneath root, creating its directory if need be.
+func write(t *testing.T, root, p, content string) {
+	t.Helper();
+	filename := filepath.Join(root, filepath.FromSlash(p));
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		t.Fatal(err);
+	}
+	if err := ioutil.WriteFile(filename, []byte(content), 0644); err != nil {
+		t.Fatal(err);
+	}
+}
+
+func TestWatch(t *testing.T) {
+	root := t.TempDir();
+	write(t, root, "old.html", "old");
+	write(t, root, "_site/index.html", "built");
+	write(t, root, "drafts/a.html", "draft");
+	ignore, err := ParseIgnoreRules([]string{"drafts/"});
+	if err != nil {
+		t.Fatal(err);
+	}
+	w, err := WatchWith(root, WatchOptions{Ignore: ignore, Skip: []string{"_site"}});
+	if err != nil {
+		t.Fatal(err);
+	}
+	defer w.Close();
+
+	// Changes to skipped and ignored directories go unreported, so only the first batch's changes arrive.
+	write(t, root, "_site/index.html", "rebuilt");
+	write(t, root, "drafts/b.html", "draft");
+	write(t, root, "new.html", "new");
+	write(t, root, "old.html", "changed");
+	write(t, root, "docs/sub/page.html", "page");
+	want := []Event{{"docs/sub/page.html", Created}, {"new.html", Created}, {"old.html", Modified}};
+	if got := nextBatch(t, w); !reflect.DeepEqual(got, want) {
+		t.Fatalf("first batch = %v, want %v", got, want);
+	}
+
+	if err := os.Rename(filepath.Join(root, "docs"), filepath.Join(t.TempDir(), "docs")); err != nil {
+		t.Fatal(err);
+	}
+	want = []Event{{"docs/sub/page.html", Removed}};
+	if got := nextBatch(t, w); !reflect.DeepEqual(got, want) {
+		t.Fatalf("batch after moving docs away = %v, want %v", got, want);
+	}
+
+	// A file created and removed again before delivery isn't reported.
+	write(t, root, "brief.html", "brief");
+	os.Remove(filepath.Join(root, "brief.html"));
+	os.Remove(filepath.Join(root, "old.html"));
+	want = []Event{{"old.html", Removed}};
+	if got := nextBatch(t, w); !reflect.DeepEqual(got, want) {
+		t.Fatalf("batch after removals = %v, want %v", got, want);
+	}
+}
+
+// TestWatchIgnoreFiles checks that a Watcher heeds the ignore files of the directories it watches.
+func TestWatchIgnoreFiles(t *testing.T) {
+	root := t.TempDir();
+	write(t, root, HammerIgnoreFilename, "*.tmp\n");
+	write(t, root, "docs/"+HammerIgnoreFilename, "!keep.tmp\nsub/\n");
+	write(t, root, "docs/sub/a.html", "");
+	w, err := WatchWith(root, WatchOptions{IgnoreFiles: []string{HammerIgnoreFilename}});
+	if err != nil {
+		t.Fatal(err);
+	}
+	defer w.Close();
+
+	write(t, root, "a.tmp", "");
+	write(t, root, "keep.tmp", "");
+	write(t, root, "docs/a.tmp", "");
+	write(t, root, "docs/keep.tmp", "");
+	write(t, root, "docs/sub/b.html", "");
+	write(t, root, "docs/a.html", "");
+	want := []Event{{"docs/a.html", Created}, {"docs/keep.tmp", Created}};
+	if got := nextBatch(t, w); !reflect.DeepEqual(got, want) {
+		t.Fatalf("batch = %v, want %v", got, want);
+	}
+}
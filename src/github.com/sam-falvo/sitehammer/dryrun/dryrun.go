@@ -0,0 +1,83 @@
+/*
+The dryrun package reports what a command would have done, had it not been asked merely to pretend.
+
+Each report appears on standard output as a single line, such as:
+
+	would create _site/about.html (new)
+	would overwrite articles/1234/index.html (changed)
+	would remove _site/old.html (orphaned)
+	would skip _site/articles/1234/index.html (unchanged)
+
+When reports are written as JSON, each is a dry-run event instead; see the report package.
+*/
+package dryrun
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/sam-falvo/sitehammer/report"
+	"io/ioutil"
+	"os"
+)
+
+// Verbs describing actions a dry run reports.
+const (
+	Create    = "create"
+	Overwrite = "overwrite"
+	Remove    = "remove"
+	Skip      = "skip"
+	Render    = "render"
+)
+
+// Reasons explaining why an action would be taken.
+const (
+	New       = "new"
+	Changed   = "changed"
+	Headers   = "headers changed"
+	Orphaned  = "orphaned"
+	Generated = "generated"
+	Empty     = "empty"
+	Unchanged = "unchanged"
+	Invalid   = "invalid"
+	Failed    = "failed"
+	NotChosen = "not selected"
+	Resumed   = "rendered before"
+)
+
+// Report prints a line describing an action the dry run would have taken upon the named path, and why.
+func Report(verb, path, reason string) {
+	report.Event("dry-run", fmt.Sprintf("would %s %s (%s)", verb, path, reason), report.Fields{"verb": verb, "path": path, "reason": reason})
+}
+
+// ReportWrite reports that the named file would have been written.
+// Whether the write creates a new file or overwrites a changed one depends on whether the file exists already.
+func ReportWrite(path string) {
+	if _, err := os.Lstat(path); err == nil {
+		Report(Overwrite, path, Changed)
+	} else {
+		Report(Create, path, New)
+	}
+}
+
+// ReportWriteIfChanged reports that the named file would have been written with the given content,
+// unless the file already holds exactly that content, in which case nothing is reported.
+func ReportWriteIfChanged(path string, content []byte) {
+	existing, err := ioutil.ReadFile(path)
+	if err == nil && bytes.Equal(existing, content) {
+		return
+	}
+	ReportWrite(path)
+}
+
+// ReportRender reports that the named page would have been rendered with the given content:
+// created, overwritten, or, if the page already holds exactly that content, skipped as unchanged.
+// It answers true if the page would have been written.
+func ReportRender(path string, content []byte) bool {
+	existing, err := ioutil.ReadFile(path)
+	if err == nil && bytes.Equal(existing, content) {
+		Report(Skip, path, Unchanged)
+		return false
+	}
+	ReportWrite(path)
+	return true
+}
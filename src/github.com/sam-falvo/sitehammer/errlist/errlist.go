@@ -0,0 +1,59 @@
+/*
+The errlist package collects errors, so a build can carry on past a problem and report every problem at the end,
+rather than stopping at the first.
+*/
+package errlist
+
+import (
+	"fmt"
+	"strings"
+)
+
+// List collects errors in the order they happened.
+// The zero value is an empty list, ready to use.
+type List struct {
+	errors []error
+}
+
+// Add appends err to the list, unless err is nil.
+// If err is itself a List, its errors are appended one by one.
+func (l *List) Add(err error) {
+	switch e := err.(type) {
+	case nil:
+	case *List:
+		l.errors = append(l.errors, e.errors...)
+	default:
+		l.errors = append(l.errors, err)
+	}
+}
+
+// Errors answers the errors collected.
+func (l *List) Errors() []error {
+	return l.errors
+}
+
+// Len answers how many errors have been collected.
+func (l *List) Len() int {
+	return len(l.errors)
+}
+
+// Err answers nil if the list is empty, or the list itself otherwise.
+// A single error is answered as it is.
+func (l *List) Err() error {
+	switch len(l.errors) {
+	case 0:
+		return nil
+	case 1:
+		return l.errors[0]
+	}
+	return l
+}
+
+// Error reports every error collected, one per line, after a count.
+func (l *List) Error() string {
+	lines := []string{fmt.Sprintf("%d errors:", len(l.errors))}
+	for _, err := range l.errors {
+		lines = append(lines, "  "+strings.Replace(err.Error(), "\n", "\n  ", -1))
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,163 @@
+/*
+The failure package sorts the errors stopping a command into classes, each with an exit status of its own,
+so that scripts wrapping SiteHammer can tell one kind of failure from another:
+retrying after an I/O failure, say, or opening an editor after a validation failure.
+
+	Status  Class
+	1       General: any failure not otherwise classified
+	2       Usage: bad flags or arguments, or a mistake in the configuration
+	3       Validation: invalid article descriptors, or HTML problems found by -validate or -a11y in strict mode
+	4       Content: source content which can't be published as it stands, such as an article with no abstract
+	5       Template: a template which doesn't parse, or fails while rendering
+	6       I/O: a file which can't be read or written
+	130     Interrupted: stopped by an interrupt (SIGINT) or termination request (SIGTERM), having cleaned up
+
+Errors are classified where they arise, with Wrap, or by implementing Classified;
+errors of the os package are I/O failures, those of the template packages' own types are template failures,
+and context.Canceled is an interruption, wherever they arise.
+*/
+package failure
+
+import (
+	"context"
+	"errors"
+	htmltemplate "html/template"
+	"os"
+	"syscall"
+	texttemplate "text/template"
+)
+
+// Class identifies a kind of failure.
+type Class int
+
+// Classes of failure; see the package documentation.
+const (
+	General Class = iota
+	Usage
+	Validation
+	Content
+	Template
+	IO
+	Interrupted
+)
+
+// names gives the name of each class, as String answers it.
+var names = []string{"general", "usage", "validation", "content", "template", "io", "interrupted"}
+
+// String answers the class's name.
+func (c Class) String() string {
+	if c < 0 || int(c) >= len(names) {
+		return names[General]
+	}
+	return names[c]
+}
+
+// ExitStatus answers the status with which a command stopped by a failure of this class exits.
+// Interruptions exit with 130, as shells report a command killed by SIGINT.
+func (c Class) ExitStatus() int {
+	switch {
+	case c == Interrupted:
+		return 130
+	case c < 0 || int(c) >= len(names):
+		return 1
+	}
+	return int(c) + 1
+}
+
+// Classified errors know their own class.
+type Classified interface {
+	error
+	Class() Class
+}
+
+// classified attaches a class to an error which doesn't know its own.
+type classified struct {
+	class Class
+	err   error
+}
+
+// Error answers the error's message, unchanged.
+func (e *classified) Error() string {
+	return e.err.Error()
+}
+
+// Class answers the class attached to the error.
+func (e *classified) Class() Class {
+	return e.class
+}
+
+// Unwrap answers the error to which the class is attached.
+func (e *classified) Unwrap() error {
+	return e.err
+}
+
+// Wrap answers err as a failure of class c, with the same message, or nil if err is nil.
+// Lists of errors (see the errlist package) are answered as they are, since their class follows from those of their members.
+func Wrap(c Class, err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := err.(interface{ Errors() []error }); ok {
+		return err
+	}
+	return &classified{c, err}
+}
+
+// Default answers err as a failure of class c, like Wrap, unless err already belongs to a class other than General.
+func Default(c Class, err error) error {
+	if err == nil || Classify(err) != General {
+		return err
+	}
+	return Wrap(c, err)
+}
+
+// Classify answers the class of err, looking through any errors it wraps.
+// A list of errors belongs to the class its members share, or to General if they're of several classes.
+func Classify(err error) Class {
+	if err == nil {
+		return General
+	}
+	if list, ok := err.(interface{ Errors() []error }); ok {
+		return classifyAll(list.Errors())
+	}
+
+	var c Classified
+	if errors.As(err, &c) {
+		return c.Class()
+	}
+	if errors.Is(err, context.Canceled) {
+		return Interrupted
+	}
+	var execError texttemplate.ExecError
+	var escapeError *htmltemplate.Error
+	if errors.As(err, &execError) || errors.As(err, &escapeError) {
+		return Template
+	}
+	var pathError *os.PathError
+	var linkError *os.LinkError
+	var syscallError *os.SyscallError
+	var errno syscall.Errno
+	if errors.As(err, &pathError) || errors.As(err, &linkError) || errors.As(err, &syscallError) || errors.As(err, &errno) {
+		return IO
+	}
+	return General
+}
+
+// classifyAll answers the class shared by every error in errs, or General if they don't share one.
+func classifyAll(errs []error) Class {
+	if len(errs) == 0 {
+		return General
+	}
+	c := Classify(errs[0])
+	for _, err := range errs[1:] {
+		if Classify(err) != c {
+			return General
+		}
+	}
+	return c
+}
+
+// ExitStatus answers the status with which a command stopped by err exits, according to its class.
+func ExitStatus(err error) int {
+	return Classify(err).ExitStatus()
+}
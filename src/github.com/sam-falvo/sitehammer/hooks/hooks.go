@@ -0,0 +1,101 @@
+/*
+The hooks package runs the external commands a site configures to run before and after its build,
+such as "npm run css" to compile stylesheets beforehand, or an rsync deployment afterward.
+
+Each hook is a command line run by the shell (sh -c, or cmd /C on Windows), in the site's source directory.
+Hooks run one at a time, in the order configured; the first to fail stops the build.
+Their output, both standard output and standard error, is passed along line by line, each line labeled with the hook's stage,
+so it's clear which lines came from hooks and which from SiteHammer itself.
+The environment variable SITEHAMMER_OUTPUT names the output directory.
+*/
+package hooks
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// Stages at which hooks run.
+const (
+	Pre  = "pre-build"
+	Post = "post-build"
+)
+
+// Options controls how hooks run.
+// Output receives the hooks' labeled output; OutputDir names the output directory, for the hooks' environment.
+// DryRun reports the hooks that would run, without running them.
+// Context, if not nil, cancels the hooks: once it's done, a running hook is killed, and no further hooks run.
+type Options struct {
+	Output    io.Writer
+	OutputDir string
+	DryRun    bool
+	Context   context.Context
+}
+
+// Run runs each of the given hooks, answering an error if any fails.
+func Run(opts Options, stage string, commands []string) error {
+	for _, command := range commands {
+		if strings.TrimSpace(command) == "" {
+			continue
+		}
+		if opts.DryRun {
+			fmt.Fprintf(opts.Output, "[%s] would run: %s\n", stage, command)
+			continue
+		}
+		fmt.Fprintf(opts.Output, "[%s] %s\n", stage, command)
+		err := run(opts, stage, command)
+		if err != nil {
+			return fmt.Errorf("The %s hook %q failed: %s", stage, command, err.Error())
+		}
+	}
+	return nil
+}
+
+// run runs a single hook through the shell, relaying its output.
+func run(opts Options, stage, command string) error {
+	ctx := opts.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	}
+	cmd.Env = append(os.Environ(), "SITEHAMMER_OUTPUT="+opts.OutputDir)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return err
+	}
+	err = cmd.Start()
+	if err != nil {
+		return err
+	}
+
+	var lock sync.Mutex
+	var wg sync.WaitGroup
+	relay := func(r io.Reader) {
+		defer wg.Done()
+		scanner := bufio.NewScanner(r)
+		for scanner.Scan() {
+			lock.Lock()
+			fmt.Fprintf(opts.Output, "[%s] %s\n", stage, scanner.Text())
+			lock.Unlock()
+		}
+	}
+	wg.Add(2)
+	go relay(stdout)
+	go relay(stderr)
+	wg.Wait()
+	return cmd.Wait()
+}
@@ -0,0 +1,194 @@
+package hosting
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/sam-falvo/sitehammer/config"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ApacheConfig names the file Apache reads the configuration of a directory from, relative to the output directory.
+const ApacheConfig = ".htaccess"
+
+// compressedTypes lists the media types of text worth compressing as it's served.
+var compressedTypes = []string{
+	"text/html", "text/css", "text/plain", "text/xml", "application/javascript", "application/json",
+	"application/xml", "application/rss+xml", "application/atom+xml", "image/svg+xml",
+}
+
+// defaultCacheRules give the Cache-Control headers of files when the configuration gives no rules of its own:
+// pages must be revalidated, so readers see changes at once, while fingerprinted assets, whose names change with their content, never need be.
+var defaultCacheRules = []struct {
+	pattern string
+	value   string
+}{
+	{`[^/]*\.html`, "no-cache"},
+	{`[^/]*\.[0-9a-f]{10}\.[^./]+`, "public, max-age=31536000, immutable"},
+}
+
+// apacheConfig answers the content of an .htaccess file redirecting as the redirect tables say, serving the configured error pages,
+// compressing text, or serving precompressed variants, and giving the Cache-Control headers of the configured rules,
+// along with the headers of the header tables.
+// Site paths are taken relative to the path of the base URL, where the site is served.
+func apacheConfig(cfg *config.Config) []byte {
+	base := ""
+	if u, err := url.Parse(cfg.Blog.BaseUrl); err == nil {
+		base = strings.TrimSuffix(u.Path, "/")
+	}
+	local := func(target string) string {
+		if strings.HasPrefix(target, "/") {
+			return base + target
+		}
+		return target
+	}
+
+	var b bytes.Buffer
+	b.WriteString("# Generated by SiteHammer from its configuration; do not edit.\n")
+	if len(cfg.Hosting.Errors) > 0 {
+		b.WriteString("\n")
+		statuses := make([]string, 0, len(cfg.Hosting.Errors))
+		for status := range cfg.Hosting.Errors {
+			statuses = append(statuses, status)
+		}
+		sort.Strings(statuses)
+		for _, status := range statuses {
+			fmt.Fprintf(&b, "ErrorDocument %s %s\n", status, local(cfg.Hosting.Errors[status]))
+		}
+	}
+	if len(cfg.Redirects) > 0 {
+		b.WriteString("\n<IfModule mod_alias.c>\n")
+		for _, r := range cfg.Redirects {
+			fmt.Fprintf(&b, "Redirect %d %s %s\n", r.StatusCode(), apacheQuote(local(r.From)), apacheQuote(local(r.To)))
+		}
+		b.WriteString("</IfModule>\n")
+	}
+
+	precompressed := ""
+	if cfg.Compress.Gzip || cfg.Compress.Brotli {
+		// Variants take their media types from the extensions before theirs, and are served in place of the originals if readers accept them.
+		precompressed = `(\.(gz|br))?`
+		b.WriteString("\n<IfModule mod_mime.c>\nRemoveType .gz .br\nAddEncoding gzip .gz\nAddEncoding br .br\n</IfModule>\n")
+		b.WriteString("\n<IfModule mod_rewrite.c>\nRewriteEngine On\n")
+		for _, variant := range []struct {
+			enabled  bool
+			encoding string
+			ext      string
+		}{{cfg.Compress.Brotli, "br", ".br"}, {cfg.Compress.Gzip, "gzip", ".gz"}} {
+			if variant.enabled {
+				fmt.Fprintf(&b, "RewriteCond %%{HTTP:Accept-Encoding} \\b%s\\b\n", variant.encoding)
+				fmt.Fprintf(&b, "RewriteCond %%{REQUEST_FILENAME}%s -f\n", variant.ext)
+				fmt.Fprintf(&b, "RewriteRule ^(.*)$ $1%s [L,E=no-gzip:1]\n", variant.ext)
+			}
+		}
+		b.WriteString("</IfModule>\n")
+	}
+	fmt.Fprintf(&b, "\n<IfModule mod_deflate.c>\nAddOutputFilterByType DEFLATE %s\n</IfModule>\n", strings.Join(compressedTypes, " "))
+
+	var rules []struct{ pattern, value string }
+	for _, r := range cfg.Hosting.CacheControl {
+		rules = append(rules, struct{ pattern, value string }{globRegexp(r.Pattern), r.Value})
+	}
+	if len(rules) == 0 {
+		for _, r := range defaultCacheRules {
+			if r.value != "no-cache" && !cfg.Assets.Fingerprint {
+				continue
+			}
+			rules = append(rules, struct{ pattern, value string }{`(.*/)?` + r.pattern, r.value})
+		}
+	}
+	b.WriteString("\n<IfModule mod_headers.c>\n")
+	if precompressed != "" {
+		b.WriteString("Header append Vary Accept-Encoding\n")
+	}
+	for i, r := range rules {
+		directive := "If"
+		if i > 0 {
+			directive = "ElseIf"
+		}
+		pattern := "^" + regexp.QuoteMeta(base) + "/" + r.pattern + precompressed + "$"
+		fmt.Fprintf(&b, "<%s %s>\nHeader set Cache-Control %s\n</%s>\n", directive, uriMatches(pattern), apacheQuote(r.value), directive)
+	}
+	for _, r := range cfg.Hosting.Headers {
+		parts := strings.Split(r.Path, "*")
+		for i := range parts {
+			parts[i] = regexp.QuoteMeta(parts[i])
+		}
+		fmt.Fprintf(&b, "<If %s>\n", uriMatches("^"+regexp.QuoteMeta(base)+strings.Join(parts, ".*")+"$"))
+		for _, h := range r.Headers {
+			name, value, _ := config.ParseHeader(h)
+			fmt.Fprintf(&b, "Header set %s %s\n", name, apacheQuote(value))
+		}
+		b.WriteString("</If>\n")
+	}
+	b.WriteString("</IfModule>\n")
+	return b.Bytes()
+}
+
+// uriMatches answers the quoted condition of an If directive, true if the request's URI matches the regular expression.
+func uriMatches(pattern string) string {
+	return apacheQuote("%{REQUEST_URI} =~ m#" + strings.Replace(pattern, "#", `\#`, -1) + "#")
+}
+
+// apacheQuote quotes an argument of an Apache directive; Apache takes a backslash for an escape only before a quote.
+func apacheQuote(s string) string {
+	return `"` + strings.Replace(s, `"`, `\"`, -1) + `"`
+}
+
+// globRegexp answers a regular expression matching the paths, without their leading slashes, which the glob pattern matches,
+// as directory.MatchGlob matches them: a pattern without slashes matches names at any depth, and a ** component, any number of directories.
+func globRegexp(pattern string) string {
+	if !strings.Contains(pattern, "/") {
+		return `(.*/)?` + componentRegexp(pattern)
+	}
+	var b strings.Builder
+	parts := strings.Split(strings.TrimPrefix(pattern, "/"), "/")
+	for i, part := range parts {
+		switch {
+		case part == "**" && i == len(parts)-1:
+			b.WriteString(`.*`)
+		case part == "**":
+			b.WriteString(`(.*/)?`)
+		case i == len(parts)-1:
+			b.WriteString(componentRegexp(part))
+		default:
+			b.WriteString(componentRegexp(part) + "/")
+		}
+	}
+	return b.String()
+}
+
+// componentRegexp answers a regular expression matching the path components the path.Match pattern does.
+func componentRegexp(pattern string) string {
+	var b strings.Builder
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; c {
+		case '*':
+			b.WriteString(`[^/]*`)
+		case '?':
+			b.WriteString(`[^/]`)
+		case '\\':
+			if i+1 < len(pattern) {
+				i++
+				b.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+			}
+		case '[':
+			end := strings.IndexByte(pattern[i+1:], ']')
+			if end < 0 {
+				b.WriteString(`\[`)
+				continue
+			}
+			class := pattern[i+1 : i+1+end]
+			if strings.HasPrefix(class, "^") {
+				class = "^/" + class[1:]
+			}
+			b.WriteString("[" + class + "]")
+			i += end + 1
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return b.String()
+}
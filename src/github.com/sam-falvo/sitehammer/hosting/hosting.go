@@ -0,0 +1,235 @@
+/*
+The hosting package generates the configuration files static hosts read, so that the site's redirects are served as real redirects,
+and, for Apache, so that the site is served as its configuration says.
+
+Each redirect table of the configuration redirects requests for one site path elsewhere.
+Netlify, and Cloudflare Pages, read a file called _redirects, at the root of the site, listing a redirect on each line:
+
+	/2012/hello.html /articles/1234/ 301
+
+Vercel reads a file called vercel.json, whose redirects list holds the same:
+
+	{
+	  "redirects": [
+	    {
+	      "source": "/2012/hello.html",
+	      "destination": "/articles/1234/",
+	      "statusCode": 301
+	    }
+	  ]
+	}
+
+Apache reads a file called .htaccess, which redirects likewise, with Redirect directives,
+but also names the pages served for errors, such as 404.html, with ErrorDocument directives.
+It compresses text as it's served, with mod_deflate, or, if the build precompresses files,
+serves a file's brotli or gzip variant in its place, with mod_rewrite, to readers accepting it.
+Finally, it gives each file the Cache-Control header of the first of the hosting table's cache_control rules matching its path, with mod_headers;
+without rules, pages are given no-cache, so readers revalidate them, and, if assets are fingerprinted,
+fingerprinted assets are cached for a year, since their names change whenever their content does.
+Paths are taken to lie beneath the path of the site's base URL, so a site served from a subdirectory is configured rightly.
+Each part of the file needing a module is wrapped in an IfModule section, so a host lacking a module ignores that part, rather than failing.
+
+Each header table of the hosting table lists headers sent with the files whose paths match its own.
+Netlify reads them from a file called _headers, listing each path, followed by its headers, indented:
+
+	/theme/*
+	  Cache-Control: public, max-age=31536000, immutable
+
+Vercel reads them from vercel.json's headers list, and Apache, from Header directives in .htaccess.
+
+Only the files of the hosts the hosting table names are generated.
+If it names none, each redirect is served instead by a page at its path, such as 2012/hello.html,
+which refreshes to the destination at once; it's not a real redirect, but any host serves it.
+*/
+package hosting
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/sam-falvo/sitehammer/config"
+	"github.com/sam-falvo/sitehammer/directory"
+	"github.com/sam-falvo/sitehammer/dryrun"
+	"github.com/sam-falvo/sitehammer/failure"
+	"github.com/sam-falvo/sitehammer/report"
+	"html"
+	"path/filepath"
+	"strings"
+)
+
+// Names of the files hosts read, relative to the output directory.
+const (
+	NetlifyRedirects = "_redirects"
+	NetlifyHeaders   = "_headers"
+	VercelConfig     = "vercel.json"
+)
+
+// Options controls generation of the hosts' files.
+//
+// OutputDir names the directory holding the built site, which may be a staging area;
+// DisplayDir names the same directory as the user knows it, for dry-run reports.
+type Options struct {
+	Config     *config.Config
+	OutputDir  string
+	DisplayDir string
+	DryRun     bool
+}
+
+// generated is a file to be written, relative to the output directory.
+type generated struct {
+	name    string
+	content []byte
+}
+
+// Generate writes the files of the hosts the configuration names into the output directory,
+// or, if it names none, the pages standing in for the redirects,
+// refusing to replace any of the named outputs the build already produced, or to redirect away from one.
+// It answers the slash-separated names of the files it's responsible for.
+func Generate(opts Options, outputs map[string]bool) ([]string, error) {
+	cfg := opts.Config
+	for _, r := range cfg.Redirects {
+		// Hosts serve a file at a redirect's path in preference to redirecting, as do browsers the page standing in for it.
+		if outputs[r.Page()] {
+			return nil, failure.Wrap(failure.Content, fmt.Errorf("The redirect from %s leads away from %s, which the site has; remove one or the other.", r.From, r.Page()))
+		}
+	}
+	var files []generated
+	redirects, headers := len(cfg.Redirects) > 0, len(cfg.Hosting.Headers) > 0
+	for _, host := range cfg.Hosting.Hosts {
+		switch host {
+		case config.HostNetlify:
+			if redirects {
+				files = append(files, generated{NetlifyRedirects, netlifyRedirects(cfg.Redirects)})
+			}
+			if headers {
+				files = append(files, generated{NetlifyHeaders, netlifyHeaders(cfg.Hosting.Headers)})
+			}
+		case config.HostVercel:
+			if redirects || headers {
+				content, err := vercelConfig(cfg.Redirects, cfg.Hosting.Headers)
+				if err != nil {
+					return nil, err
+				}
+				files = append(files, generated{VercelConfig, content})
+			}
+		case config.HostApache:
+			for status, page := range cfg.Hosting.Errors {
+				if !outputs[strings.TrimPrefix(page, "/")] {
+					report.Warning("the page for error %s, %s, isn't one the site has", status, page)
+				}
+			}
+			files = append(files, generated{ApacheConfig, apacheConfig(cfg)})
+		}
+	}
+	if len(cfg.Hosting.Hosts) == 0 {
+		for _, r := range cfg.Redirects {
+			files = append(files, generated{r.Page(), redirectPage(r.To)})
+		}
+	}
+
+	var produced []string
+	for _, f := range files {
+		if outputs[f.name] {
+			return nil, failure.Wrap(failure.Content, fmt.Errorf("The site already has %s, which the hosting table of the configuration would replace; remove one or the other.", f.name))
+		}
+		produced = append(produced, f.name)
+		if opts.DryRun {
+			dryrun.ReportWriteIfChanged(filepath.Join(opts.DisplayDir, filepath.FromSlash(f.name)), f.content)
+			continue
+		}
+		filename := filepath.Join(opts.OutputDir, filepath.FromSlash(f.name))
+		err := directory.EnsureDirAll(filepath.Dir(filename), cfg.Output.DirPerm())
+		if err == nil {
+			err = directory.WriteFileAtomic(filename, f.content, cfg.Output.FilePerm(0644))
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return produced, nil
+}
+
+// netlifyRedirects answers the content of a _redirects file listing the redirects.
+func netlifyRedirects(redirects []config.Redirect) []byte {
+	var b bytes.Buffer
+	b.WriteString("# Generated by SiteHammer from the redirect tables of its configuration; do not edit.\n")
+	for _, r := range redirects {
+		fmt.Fprintf(&b, "%s %s %d\n", r.From, r.To, r.StatusCode())
+	}
+	return b.Bytes()
+}
+
+// netlifyHeaders answers the content of a _headers file listing the headers sent with files, by path.
+func netlifyHeaders(rules []config.HeaderRule) []byte {
+	var b bytes.Buffer
+	b.WriteString("# Generated by SiteHammer from the header tables of its configuration; do not edit.\n")
+	for _, r := range rules {
+		b.WriteString(r.Path + "\n")
+		for _, h := range r.Headers {
+			name, value, _ := config.ParseHeader(h)
+			fmt.Fprintf(&b, "  %s: %s\n", name, value)
+		}
+	}
+	return b.Bytes()
+}
+
+// vercelConfig answers the content of a vercel.json file listing the redirects, and the headers sent with files, by path.
+// Vercel writes the parts of paths matching anything as (.*).
+func vercelConfig(redirects []config.Redirect, headers []config.HeaderRule) ([]byte, error) {
+	type redirect struct {
+		Source      string `json:"source"`
+		Destination string `json:"destination"`
+		StatusCode  int    `json:"statusCode"`
+	}
+	type header struct {
+		Key   string `json:"key"`
+		Value string `json:"value"`
+	}
+	type rule struct {
+		Source  string   `json:"source"`
+		Headers []header `json:"headers"`
+	}
+	var file struct {
+		Redirects []redirect `json:"redirects,omitempty"`
+		Headers   []rule     `json:"headers,omitempty"`
+	}
+	for _, r := range redirects {
+		file.Redirects = append(file.Redirects, redirect{r.From, r.To, r.StatusCode()})
+	}
+	for _, r := range headers {
+		source := rule{Source: strings.Replace(r.Path, "*", "(.*)", -1)}
+		for _, h := range r.Headers {
+			name, value, _ := config.ParseHeader(h)
+			source.Headers = append(source.Headers, header{name, value})
+		}
+		file.Headers = append(file.Headers, source)
+	}
+	var b bytes.Buffer
+	encoder := json.NewEncoder(&b)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	err := encoder.Encode(file)
+	return b.Bytes(), err
+}
+
+// redirectPage answers a page refreshing to the given destination at once, for search engines as well as readers.
+func redirectPage(to string) []byte {
+	to = html.EscapeString(to)
+	return []byte(fmt.Sprintf(redirectPageSource, to, to, to, to))
+}
+
+// redirectPageSource is the source of a page standing in for a redirect, awaiting its destination four times.
+const redirectPageSource = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Redirecting</title>
+<link rel="canonical" href="%s">
+<meta name="robots" content="noindex">
+<meta http-equiv="refresh" content="0; url=%s">
+</head>
+<body>
+<p>This page has moved to <a href="%s">%s</a>.</p>
+</body>
+</html>
+`
@@ -0,0 +1,82 @@
+package htmlcheck
+
+import (
+	"fmt"
+	"html"
+	"strings"
+)
+
+// Accessibility checks an HTML page for common barriers to readers using assistive technology:
+// images without alternative text, links with no text to announce, headings that skip levels,
+// and documents that don't declare their language.
+// An empty alt attribute is fine; it marks an image as decorative.
+func Accessibility(content []byte) []Problem {
+	var problems []Problem
+	report := func(line int, format string, args ...interface{}) {
+		problems = append(problems, Problem{line, fmt.Sprintf(format, args...)})
+	}
+
+	lastHeading := 0
+	var link *token
+	linkText := ""
+	for _, t := range tokenize(content) {
+		switch t.kind {
+		case startTagToken:
+			switch t.name {
+			case "html":
+				if lang, _ := t.attr("lang"); strings.TrimSpace(lang) == "" {
+					report(t.line, "<html> has no lang attribute")
+				}
+			case "img", "area":
+				if _, ok := t.attr("alt"); !ok {
+					report(t.line, "<%s> has no alt attribute", t.name)
+				}
+			case "input":
+				if kind, _ := t.attr("type"); strings.EqualFold(kind, "image") {
+					if _, ok := t.attr("alt"); !ok {
+						report(t.line, "<input type=image> has no alt attribute")
+					}
+				}
+			case "h1", "h2", "h3", "h4", "h5", "h6":
+				level := int(t.name[1] - '0')
+				if lastHeading > 0 && level > lastHeading+1 {
+					report(t.line, "<%s> skips a heading level, following <h%d>", t.name, lastHeading)
+				}
+				lastHeading = level
+			case "a":
+				if _, ok := t.attr("href"); ok && link == nil {
+					tag := t
+					link, linkText = &tag, labelOf(t)
+				}
+			}
+			if link != nil && t.name == "img" {
+				alt, _ := t.attr("alt")
+				linkText += alt
+			}
+
+		case textToken:
+			if link != nil {
+				linkText += html.UnescapeString(t.text)
+			}
+
+		case endTagToken:
+			if t.name == "a" && link != nil {
+				if strings.TrimSpace(linkText) == "" {
+					href, _ := link.attr("href")
+					report(link.line, "link to %s has no text", href)
+				}
+				link = nil
+			}
+		}
+	}
+	return problems
+}
+
+// labelOf answers the accessible label a tag gives itself through aria-label or title, if any.
+func labelOf(t token) string {
+	if label, ok := t.attr("aria-label"); ok {
+		return label
+	}
+	title, _ := t.attr("title")
+	return title
+}
@@ -0,0 +1,245 @@
+/*
+The htmlcheck package checks generated HTML pages for problems a browser would silently paper over.
+
+Validate looks for structural problems:
+elements left unclosed, end tags closing nothing, elements nested where HTML forbids them, and duplicate ids.
+Elements whose end tags HTML makes optional, such as p and li, may be left unclosed.
+
+Accessibility looks for common accessibility problems:
+images without alt text, links without text, headings that skip levels, and pages lacking a lang attribute.
+
+Problems are reported one per line, naming the page and line at fault:
+
+	_site/about.html:3: <div> is never closed before </body> on line 12
+*/
+package htmlcheck
+
+import (
+	"fmt"
+	"github.com/sam-falvo/sitehammer/failure"
+	"github.com/sam-falvo/sitehammer/report"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Modes in which checks may run.
+// Off skips checking; Warn reports problems; Strict reports problems and fails the build if there are any.
+const (
+	Off    = "off"
+	Warn   = "warn"
+	Strict = "strict"
+)
+
+// Problem describes one problem found in a page.
+type Problem struct {
+	Line    int
+	Message string
+}
+
+// A Checker examines a page's content for problems.
+type Checker func(content []byte) []Problem
+
+// ValidateMode answers an error unless mode names one of the check modes.
+func ValidateMode(mode string) error {
+	switch mode {
+	case Off, Warn, Strict:
+		return nil
+	}
+	return failure.Wrap(failure.Usage, fmt.Errorf("Check mode must be %s, %s, or %s, not %q.", Off, Warn, Strict, mode))
+}
+
+// CheckOutputs runs check over each of the named outputs, slash-separated and relative to root, that is an HTML page.
+// Problems are printed to standard error, naming pages relative to displayRoot.
+// Outputs which don't exist, as in a dry run, are passed over.
+// The number of problems found is returned.
+func CheckOutputs(root, displayRoot string, outputs map[string]bool, check Checker) (int, error) {
+	var names []string
+	for name := range outputs {
+		ext := strings.ToLower(path.Ext(name))
+		if ext == ".html" || ext == ".htm" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	count := 0
+	for _, name := range names {
+		content, err := ioutil.ReadFile(filepath.Join(root, filepath.FromSlash(name)))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return count, err
+		}
+		for _, p := range check(content) {
+			report.Problem(displayRoot+"/"+name, p.Line, p.Message)
+			count++
+		}
+	}
+	return count, nil
+}
+
+// Enforce answers an error if, in strict mode, any problems were found.
+func Enforce(mode string, problems int, what string) error {
+	if mode == Strict && problems > 0 {
+		return failure.Wrap(failure.Validation, fmt.Errorf("%d %s problem(s) found.", problems, what))
+	}
+	return nil
+}
+
+// voidElements never have content, nor end tags.
+var voidElements = setOf("area", "base", "br", "col", "embed", "hr", "img", "input", "keygen", "link", "meta", "param", "source", "track", "wbr")
+
+// optionalEndTags lists elements whose end tags may be omitted.
+var optionalEndTags = setOf("html", "head", "body", "p", "li", "dt", "dd", "option", "optgroup", "rb", "rt", "rtc", "rp",
+	"colgroup", "caption", "thead", "tbody", "tfoot", "tr", "td", "th")
+
+// closesParagraph lists elements whose start tags implicitly close an open p element.
+var closesParagraph = setOf("address", "article", "aside", "blockquote", "details", "div", "dl", "fieldset", "figcaption", "figure",
+	"footer", "form", "h1", "h2", "h3", "h4", "h5", "h6", "header", "hgroup", "hr", "main", "menu", "nav", "ol", "p", "pre",
+	"section", "table", "ul")
+
+// blockElements may not appear inside phrasingElements.
+var blockElements = setOf("address", "article", "aside", "blockquote", "dd", "div", "dl", "dt", "fieldset", "figure", "footer",
+	"form", "h1", "h2", "h3", "h4", "h5", "h6", "header", "hr", "li", "main", "nav", "ol", "p", "pre", "section", "table", "ul")
+
+// phrasingElements may contain only phrasing content: text and inline elements.
+var phrasingElements = setOf("abbr", "b", "bdi", "bdo", "cite", "code", "data", "dfn", "em", "h1", "h2", "h3", "h4", "h5", "h6",
+	"i", "kbd", "label", "mark", "p", "q", "s", "samp", "small", "span", "strong", "sub", "sup", "time", "u", "var")
+
+// interactiveElements may not contain one another.
+var interactiveElements = setOf("a", "button", "details", "embed", "iframe", "label", "select", "textarea")
+
+// requiredParents maps elements to the parents they must have.
+var requiredParents = map[string]map[string]bool{
+	"li":       setOf("ul", "ol", "menu"),
+	"dt":       setOf("dl", "div"),
+	"dd":       setOf("dl", "div"),
+	"tr":       setOf("table", "thead", "tbody", "tfoot"),
+	"td":       setOf("tr"),
+	"th":       setOf("tr"),
+	"option":   setOf("select", "datalist", "optgroup"),
+	"optgroup": setOf("select"),
+}
+
+func setOf(names ...string) map[string]bool {
+	set := make(map[string]bool)
+	for _, n := range names {
+		set[n] = true
+	}
+	return set
+}
+
+// openElement records an element awaiting its end tag.
+type openElement struct {
+	name string
+	line int
+}
+
+// implicitlyClosed answers true if opening an element named next closes the open element named open, as HTML's parsing rules dictate.
+func implicitlyClosed(open, next string) bool {
+	switch open {
+	case "p":
+		return closesParagraph[next]
+	case "li":
+		return next == "li"
+	case "dt", "dd":
+		return next == "dt" || next == "dd"
+	case "td", "th":
+		return next == "td" || next == "th" || next == "tr"
+	case "tr":
+		return next == "tr"
+	case "option":
+		return next == "option" || next == "optgroup"
+	case "thead", "tbody":
+		return next == "tbody" || next == "tfoot"
+	}
+	return false
+}
+
+// Validate checks an HTML page's structure.
+func Validate(content []byte) []Problem {
+	var problems []Problem
+	report := func(line int, format string, args ...interface{}) {
+		problems = append(problems, Problem{line, fmt.Sprintf(format, args...)})
+	}
+	var stack []openElement
+	ids := make(map[string]int)
+
+	for _, t := range tokenize(content) {
+		switch t.kind {
+		case startTagToken:
+			for len(stack) > 0 && implicitlyClosed(stack[len(stack)-1].name, t.name) {
+				stack = stack[:len(stack)-1]
+			}
+			if id, ok := t.attr("id"); ok {
+				if first, seen := ids[id]; seen {
+					report(t.line, "duplicate id %q (first used on line %d)", id, first)
+				} else {
+					ids[id] = t.line
+				}
+			}
+			if len(stack) > 0 {
+				parent := stack[len(stack)-1].name
+				if blockElements[t.name] && phrasingElements[parent] {
+					report(t.line, "<%s> may not appear inside <%s>", t.name, parent)
+				}
+				if parents, ok := requiredParents[t.name]; ok && !parents[parent] {
+					report(t.line, "<%s> must appear inside %s, not <%s>", t.name, nameList(parents), parent)
+				}
+			}
+			if interactiveElements[t.name] {
+				for _, e := range stack {
+					if interactiveElements[e.name] {
+						report(t.line, "<%s> may not appear inside <%s> (opened on line %d)", t.name, e.name, e.line)
+						break
+					}
+				}
+			}
+			if !voidElements[t.name] && !t.selfClosing {
+				stack = append(stack, openElement{t.name, t.line})
+			}
+
+		case endTagToken:
+			if voidElements[t.name] {
+				report(t.line, "</%s> closes a void element, which has no end tag", t.name)
+				continue
+			}
+			i := len(stack) - 1
+			for i >= 0 && stack[i].name != t.name {
+				i--
+			}
+			if i < 0 {
+				report(t.line, "</%s> closes nothing; no <%s> is open", t.name, t.name)
+				continue
+			}
+			for _, e := range stack[i+1:] {
+				if !optionalEndTags[e.name] {
+					report(e.line, "<%s> is never closed before </%s> on line %d", e.name, t.name, t.line)
+				}
+			}
+			stack = stack[:i]
+		}
+	}
+	for _, e := range stack {
+		if !optionalEndTags[e.name] {
+			report(e.line, "<%s> is never closed", e.name)
+		}
+	}
+	sort.SliceStable(problems, func(i, j int) bool { return problems[i].Line < problems[j].Line })
+	return problems
+}
+
+// nameList describes a set of element names for a message, as in "<ol> or <ul>".
+func nameList(set map[string]bool) string {
+	var names []string
+	for n := range set {
+		names = append(names, "<"+n+">")
+	}
+	sort.Strings(names)
+	return strings.Join(names, " or ")
+}
@@ -0,0 +1,60 @@
+package htmlcheck
+
+import (
+	"html"
+	"strings"
+)
+
+// Link describes a reference from a page to another resource.
+type Link struct {
+	Url  string
+	Line int
+}
+
+// linkAttributes maps elements to the attributes through which they refer to other resources.
+var linkAttributes = map[string]string{
+	"a":      "href",
+	"area":   "href",
+	"link":   "href",
+	"img":    "src",
+	"script": "src",
+	"iframe": "src",
+	"source": "src",
+	"video":  "src",
+	"audio":  "src",
+	"embed":  "src",
+}
+
+// Links answers every reference an HTML page makes to other resources, in document order.
+// Data URIs, which embed their resources rather than referring to them, and may run to megabytes, aren't references.
+func Links(content []byte) []Link {
+	var links []Link
+	for _, t := range tokenize(content) {
+		if t.kind != startTagToken {
+			continue
+		}
+		if attr, ok := linkAttributes[t.name]; ok {
+			if url, ok := t.attr(attr); ok && url != "" && !isDataUri(url) {
+				links = append(links, Link{Url: html.UnescapeString(url), Line: t.line})
+			}
+		}
+	}
+	return links
+}
+
+// isDataUri answers true if the value of an attribute, as written, is a data URI.
+func isDataUri(value string) bool {
+	value = strings.TrimSpace(value)
+	return len(value) >= 5 && strings.EqualFold(value[:5], "data:")
+}
+
+// Title answers the text of a page's title element, with its whitespace collapsed, or an empty string if it has none.
+func Title(content []byte) string {
+	tokens := tokenize(content)
+	for i, t := range tokens {
+		if t.kind == startTagToken && t.name == "title" && i+1 < len(tokens) && tokens[i+1].kind == textToken {
+			return strings.Join(strings.Fields(html.UnescapeString(tokens[i+1].text)), " ")
+		}
+	}
+	return ""
+}
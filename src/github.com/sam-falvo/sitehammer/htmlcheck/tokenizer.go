@@ -0,0 +1,197 @@
+package htmlcheck
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Kinds of token the tokenizer produces.
+const (
+	textToken = iota
+	startTagToken
+	endTagToken
+	commentToken
+	doctypeToken
+)
+
+// token describes one piece of an HTML document.
+// For tags, name holds the tag name in lower case, and attrs its attributes, names in lower case, in document order.
+// The selfClosing flag is set for tags written like <br/>.
+type token struct {
+	kind        int
+	name        string
+	attrs       []attribute
+	selfClosing bool
+	text        string
+	line        int
+}
+
+// attribute holds one attribute of a start tag.
+// The hasValue flag distinguishes <input disabled> from <input disabled="">.
+type attribute struct {
+	name     string
+	value    string
+	hasValue bool
+}
+
+// attr answers the value of the named attribute, and whether the tag has it at all.
+func (t token) attr(name string) (string, bool) {
+	for _, a := range t.attrs {
+		if a.name == name {
+			return a.value, true
+		}
+	}
+	return "", false
+}
+
+// rawTextElements hold text which isn't parsed for tags, up to their end tags.
+var rawTextElements = map[string]bool{"script": true, "style": true, "textarea": true, "title": true}
+
+var (
+	tagName  = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9:-]*`)
+	attrName = regexp.MustCompile(`^[^\s"'>/=]+`)
+)
+
+// tokenize splits an HTML document into tokens.
+// It's forgiving, as browsers are: anything that doesn't parse as markup is treated as text.
+func tokenize(doc []byte) []token {
+	var tokens []token
+	s := string(doc)
+	line := 1
+	emit := func(t token, consumed string) {
+		t.line = line
+		tokens = append(tokens, t)
+		line += strings.Count(consumed, "\n")
+	}
+	for len(s) > 0 {
+		lt := strings.IndexByte(s, '<')
+		if lt != 0 {
+			if lt < 0 {
+				lt = len(s)
+			}
+			emit(token{kind: textToken, text: s[:lt]}, s[:lt])
+			s = s[lt:]
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(s, "<!--"):
+			end := strings.Index(s[4:], "-->")
+			n := len(s)
+			if end >= 0 {
+				n = 4 + end + 3
+			}
+			emit(token{kind: commentToken, text: s[:n]}, s[:n])
+			s = s[n:]
+
+		case strings.HasPrefix(s, "<!") || strings.HasPrefix(s, "<?"):
+			n := strings.IndexByte(s, '>') + 1
+			if n == 0 {
+				n = len(s)
+			}
+			emit(token{kind: doctypeToken, text: s[:n]}, s[:n])
+			s = s[n:]
+
+		case strings.HasPrefix(s, "</") && tagName.MatchString(s[2:]):
+			n := strings.IndexByte(s, '>') + 1
+			if n == 0 {
+				n = len(s)
+			}
+			emit(token{kind: endTagToken, name: strings.ToLower(tagName.FindString(s[2:]))}, s[:n])
+			s = s[n:]
+
+		case tagName.MatchString(s[1:]):
+			t, n := startTag(s)
+			emit(t, s[:n])
+			s = s[n:]
+			if rawTextElements[t.name] && !t.selfClosing {
+				end := indexFold(s, "</"+t.name)
+				if end < 0 {
+					end = len(s)
+				}
+				emit(token{kind: textToken, text: s[:end]}, s[:end])
+				s = s[end:]
+			}
+
+		default:
+			emit(token{kind: textToken, text: "<"}, "<")
+			s = s[1:]
+		}
+	}
+	return tokens
+}
+
+// startTag parses the start tag at the beginning of s, answering the token and the number of bytes it occupies.
+func startTag(s string) (token, int) {
+	name := tagName.FindString(s[1:])
+	t := token{kind: startTagToken, name: strings.ToLower(name)}
+	i := 1 + len(name)
+	for i < len(s) {
+		rest := s[i:]
+		trimmed := strings.TrimLeft(rest, " \t\r\n\f")
+		i += len(rest) - len(trimmed)
+		if trimmed == "" {
+			break
+		}
+		if trimmed[0] == '>' {
+			return t, i + 1
+		}
+		if strings.HasPrefix(trimmed, "/>") {
+			t.selfClosing = true
+			return t, i + 2
+		}
+		if trimmed[0] == '/' {
+			i++
+			continue
+		}
+		n := attrName.FindString(trimmed)
+		if n == "" {
+			i++
+			continue
+		}
+		a := attribute{name: strings.ToLower(n)}
+		i += len(n)
+		rest = s[i:]
+		trimmed = strings.TrimLeft(rest, " \t\r\n\f")
+		if strings.HasPrefix(trimmed, "=") {
+			i += len(rest) - len(trimmed) + 1
+			rest = s[i:]
+			trimmed = strings.TrimLeft(rest, " \t\r\n\f")
+			i += len(rest) - len(trimmed)
+			v := attributeValue(trimmed)
+			i += len(v)
+			if len(v) >= 2 && (v[0] == '"' || v[0] == '\'') {
+				v = v[1 : len(v)-1]
+			}
+			a.value, a.hasValue = v, true
+		}
+		t.attrs = append(t.attrs, a)
+	}
+	return t, len(s)
+}
+
+// attributeValue answers the value of the attribute at the beginning of s, as written, quotes and all, if it's quoted.
+// An unquoted value, or one whose quote is never closed, runs to the next space or the end of the tag.
+// It's scanned for directly, rather than matched by a regular expression, since values such as data URIs may run to megabytes.
+func attributeValue(s string) string {
+	if s != "" && (s[0] == '"' || s[0] == '\'') {
+		if end := strings.IndexByte(s[1:], s[0]); end >= 0 {
+			return s[:end+2]
+		}
+	}
+	end := strings.IndexAny(s, " \t\n\f\r>")
+	if end < 0 {
+		return s
+	}
+	return s[:end]
+}
+
+// indexFold finds substr in s, ignoring ASCII case.
+func indexFold(s, substr string) int {
+	for i := 0; i+len(substr) <= len(s); i++ {
+		if strings.EqualFold(s[i:i+len(substr)], substr) {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,193 @@
+/*
+The imageopt package shrinks images without changing how they look.
+
+PNG images are recompressed at the highest compression level, dropping ancillary chunks such as text and timestamps.
+JPEG images lose their comments and metadata segments (XMP, IPTC, and EXIF),
+though EXIF data is kept whenever it rotates the image, since dropping it would change the image's orientation;
+the compressed image data itself is left alone.
+SVG images lose comments, metadata elements, and the private annotations of editors like Inkscape.
+
+An external optimizer may be configured for any extension, in which case it's used instead.
+It must read the image on standard input and write the optimized image on standard output,
+as "jpegtran -copy none -optimize" does.
+
+An optimized image is used only if it's smaller than the original.
+*/
+package imageopt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image/png"
+	"os/exec"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Optimize answers a smaller, but otherwise equivalent, version of the named image, or the image itself if it can't be shrunk.
+// Commands maps extensions, with leading dots, to the command lines of external optimizers.
+func Optimize(name string, content []byte, commands map[string]string) ([]byte, error) {
+	ext := strings.ToLower(path.Ext(name))
+	var optimized []byte
+	var err error
+	if command, ok := commands[ext]; ok && command != "" {
+		optimized, err = external(command, content)
+	} else {
+		switch ext {
+		case ".png":
+			optimized, err = optimizePng(content)
+		case ".jpg", ".jpeg":
+			optimized, err = optimizeJpeg(content)
+		case ".svg":
+			optimized, err = optimizeSvg(content)
+		default:
+			return content, nil
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Cannot optimize %s: %s", name, err.Error())
+	}
+	if len(optimized) == 0 || len(optimized) >= len(content) {
+		return content, nil
+	}
+	return optimized, nil
+}
+
+// external runs an external optimizer over content.
+func external(command string, content []byte) ([]byte, error) {
+	args := strings.Fields(command)
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin = bytes.NewReader(content)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("%s %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
+
+// optimizePng decodes and re-encodes a PNG image at the best compression level.
+// Decoding and encoding preserve every pixel exactly; only ancillary chunks are lost.
+func optimizePng(content []byte) ([]byte, error) {
+	img, err := png.Decode(bytes.NewReader(content))
+	if err != nil {
+		return nil, err
+	}
+	var out bytes.Buffer
+	encoder := png.Encoder{CompressionLevel: png.BestCompression}
+	err = encoder.Encode(&out, img)
+	return out.Bytes(), err
+}
+
+// JPEG markers of interest.
+const (
+	markerSOI  = 0xd8
+	markerSOS  = 0xda
+	markerEOI  = 0xd9
+	markerAPP1 = 0xe1
+	markerAPPD = 0xed
+	markerCOM  = 0xfe
+)
+
+// optimizeJpeg removes comments and metadata segments from a JPEG image, copying everything else verbatim.
+func optimizeJpeg(content []byte) ([]byte, error) {
+	if len(content) < 4 || content[0] != 0xff || content[1] != markerSOI {
+		return nil, fmt.Errorf("not a JPEG image")
+	}
+	out := bytes.NewBuffer(content[:2:2])
+	i := 2
+	for i+4 <= len(content) {
+		if content[i] != 0xff {
+			return nil, fmt.Errorf("bad JPEG marker at offset %d", i)
+		}
+		marker := content[i+1]
+		if marker == 0xff {
+			// Fill byte.
+			i++
+			continue
+		}
+		if marker == markerSOS || marker == markerEOI {
+			// Compressed image data follows; copy the rest of the file.
+			out.Write(content[i:])
+			return out.Bytes(), nil
+		}
+		length := int(binary.BigEndian.Uint16(content[i+2:]))
+		end := i + 2 + length
+		if length < 2 || end > len(content) {
+			return nil, fmt.Errorf("truncated JPEG segment at offset %d", i)
+		}
+		segment := content[i:end]
+		if !isDisposable(marker, segment[4:]) {
+			out.Write(segment)
+		}
+		i = end
+	}
+	return nil, fmt.Errorf("JPEG image ends prematurely")
+}
+
+// isDisposable answers true for JPEG segments which don't affect the image's appearance.
+func isDisposable(marker byte, payload []byte) bool {
+	switch marker {
+	case markerCOM, markerAPPD:
+		return true
+	case markerAPP1:
+		if bytes.HasPrefix(payload, []byte("Exif\x00\x00")) {
+			return exifOrientation(payload[6:]) <= 1
+		}
+		return true
+	}
+	return false
+}
+
+// exifOrientation answers the orientation recorded in EXIF (TIFF) data, or zero if none is found.
+func exifOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 0
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 0
+	}
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd+2 > len(tiff) {
+		return 0
+	}
+	entries := int(order.Uint16(tiff[ifd:]))
+	for e := 0; e < entries; e++ {
+		at := ifd + 2 + e*12
+		if at+12 > len(tiff) {
+			return 0
+		}
+		if order.Uint16(tiff[at:]) == 0x0112 {
+			return int(order.Uint16(tiff[at+8:]))
+		}
+	}
+	return 0
+}
+
+var (
+	svgComment    = regexp.MustCompile(`(?s)<!--.*?-->`)
+	svgMetadata   = regexp.MustCompile(`(?s)<metadata\b.*?</metadata\s*>|<metadata\b[^>]*/>`)
+	editorElement = regexp.MustCompile(`(?s)<(sodipodi|inkscape):[A-Za-z-]+\b[^>]*/>|<(sodipodi|inkscape):([A-Za-z-]+)\b.*?</(sodipodi|inkscape):[A-Za-z-]+\s*>`)
+	editorAttr    = regexp.MustCompile(`\s+(?:sodipodi|inkscape):[A-Za-z-]+\s*=\s*(?:"[^"]*"|'[^']*')`)
+	blankLines    = regexp.MustCompile(`>\s*\n\s*<`)
+)
+
+// optimizeSvg removes comments, metadata, and editor annotations from an SVG image.
+// Whitespace spanning lines between tags shrinks to a single line break; since SVG collapses whitespace anyway, text is unaffected.
+func optimizeSvg(content []byte) ([]byte, error) {
+	content = svgComment.ReplaceAll(content, nil)
+	content = svgMetadata.ReplaceAll(content, nil)
+	content = editorElement.ReplaceAll(content, nil)
+	content = editorAttr.ReplaceAll(content, nil)
+	content = blankLines.ReplaceAll(content, []byte(">\n<"))
+	return content, nil
+}
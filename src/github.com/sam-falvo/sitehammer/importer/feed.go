@@ -0,0 +1,223 @@
+package importer
+
+import (
+	"encoding/xml"
+	"fmt"
+	"github.com/sam-falvo/sitehammer/failure"
+	"github.com/sam-falvo/sitehammer/scaffold"
+	"github.com/sam-falvo/sitehammer/weblog"
+	"html"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// feedDocument is an RSS or Atom feed.
+// Elements are matched by their local names alone, so RSS 2.0, RSS 1.0, whose items lie outside its channel, and Atom are all read alike.
+type feedDocument struct {
+	XMLName      xml.Name
+	ChannelItems []feedEntry  `xml:"channel>item"`
+	Items        []feedEntry  `xml:"item"`
+	Entries      []feedEntry  `xml:"entry"`
+	Authors      []feedAuthor `xml:"author"`
+}
+
+// feedEntry is an RSS item or Atom entry.
+type feedEntry struct {
+	Title       feedText     `xml:"title"`
+	PubDate     string       `xml:"pubDate"`
+	Date        string       `xml:"date"`
+	Published   string       `xml:"published"`
+	Updated     string       `xml:"updated"`
+	Description string       `xml:"description"`
+	Encoded     string       `xml:"encoded"`
+	Summary     feedText     `xml:"summary"`
+	Content     feedText     `xml:"content"`
+	Creator     string       `xml:"creator"`
+	Authors     []feedAuthor `xml:"author"`
+	Categories  []feedTerm   `xml:"category"`
+	Subjects    []string     `xml:"subject"`
+}
+
+// feedText is an Atom text construct, whose type says whether it's plain text, escaped HTML, or inline XHTML.
+// RSS elements read the same way, as untyped text.
+type feedText struct {
+	Type  string `xml:"type,attr"`
+	Text  string `xml:",chardata"`
+	Inner string `xml:",innerxml"`
+}
+
+// feedAuthor is an Atom author, with a name and email address, or an RSS author, given as text like "jo@example.com (Jo)".
+type feedAuthor struct {
+	Name  string `xml:"name"`
+	Email string `xml:"email"`
+	Text  string `xml:",chardata"`
+}
+
+// feedTerm is an Atom category, named by its term, or an RSS category, named by its text.
+type feedTerm struct {
+	Term string `xml:"term,attr"`
+	Text string `xml:",chardata"`
+}
+
+// rssAuthor matches an RSS author written as an email address followed by a name in parentheses.
+var rssAuthor = regexp.MustCompile(`^\s*(\S+@\S+)\s*\((.*)\)\s*$`)
+
+// feedTimeLayouts lists the layouts in which feeds are found to give times: RFC 822 and its variants for RSS, RFC 3339 for Atom and Dublin Core.
+var feedTimeLayouts = []string{
+	time.RFC1123Z, time.RFC1123, "Mon, 2 Jan 2006 15:04:05 -0700", "Mon, 2 Jan 2006 15:04:05 MST",
+	"2 Jan 2006 15:04:05 -0700", "2 Jan 2006 15:04:05 MST", time.RFC822Z, time.RFC822,
+	time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02",
+}
+
+// Feed reads an RSS or Atom feed, answering its entries as articles, oldest first.
+// It's meant for blogs whose platform offers no proper export, but does publish a feed with the posts' full content.
+// Each entry's body is its full content, if the feed gives it, or else its summary;
+// its abstract is its summary, as plain text, where that differs from its content.
+// Entries' categories become their articles' tags, and their authors, their articles' authors, or the feed's author, if they name none.
+// Entries whose dates can't be understood are skipped.
+// A feed only holds what's published, so opts.Drafts is of no effect, and the media the entries refer to is reported, not copied.
+func Feed(r io.Reader, opts Options) (*Result, error) {
+	var doc feedDocument
+	decoder := xml.NewDecoder(r)
+	decoder.Strict = false
+	decoder.Entity = xml.HTMLEntity
+	err := decoder.Decode(&doc)
+	if err != nil {
+		return nil, failure.Wrap(failure.Content, fmt.Errorf("Cannot read the feed: %v", err))
+	}
+	entries := append(append(doc.ChannelItems, doc.Items...), doc.Entries...)
+	if len(entries) == 0 {
+		return nil, failure.Wrap(failure.Content, fmt.Errorf("The feed holds no entries; is it an RSS or Atom feed?"))
+	}
+	fallback := Options{Author: opts.Author, Email: opts.Email}
+	if len(doc.Authors) > 0 {
+		if name, email := doc.Authors[0].parse(); name != "" {
+			fallback.Author, fallback.Email = name, email
+		}
+	}
+
+	result := &Result{}
+	media := make(map[string]bool)
+	var articles []dated
+	for i, e := range entries {
+		title := strings.TrimSpace(e.Title.html())
+		if title != "" {
+			title = html.UnescapeString(markup.ReplaceAllString(title, ""))
+		}
+		name := fmt.Sprintf("entry %d", i+1)
+		if title != "" {
+			name = fmt.Sprintf("entry %q", title)
+		}
+		published, err := e.published()
+		if err != nil {
+			result.Skipped = append(result.Skipped, Skipped{name, err.Error()})
+			continue
+		}
+		summary := e.Description
+		if e.Summary.Inner != "" {
+			summary = e.Summary.html()
+		}
+		body := e.Encoded
+		if e.Content.Inner != "" {
+			body = e.Content.html()
+		}
+		excerpt := ""
+		if body == "" {
+			body = summary
+		} else if strings.TrimSpace(summary) != strings.TrimSpace(body) {
+			excerpt = strings.Join(strings.Fields(html.UnescapeString(markup.ReplaceAllString(summary, " "))), " ")
+		}
+		body = strings.TrimSpace(body)
+		if body == "" {
+			result.Skipped = append(result.Skipped, Skipped{name, "it has no content"})
+			continue
+		}
+		if !strings.HasPrefix(body, "<") {
+			body = "<p>" + body + "</p>"
+		}
+		body += "\n"
+		mediaOf(body, media)
+		if title == "" {
+			title = titleFrom(markup.ReplaceAllString(body, " "), "untitled")
+		}
+
+		d := weblog.Descriptor{
+			Title:     title,
+			Author:    fallback.Author,
+			Email:     fallback.Email,
+			Published: published.Format(scaffold.DateLayout),
+		}
+		if e.Creator != "" {
+			d.Author, d.Email = strings.TrimSpace(e.Creator), ""
+		}
+		if len(e.Authors) > 0 {
+			if name, email := e.Authors[0].parse(); name != "" {
+				d.Author, d.Email = name, email
+			}
+		}
+		for _, c := range e.Categories {
+			if tag := strings.TrimSpace(c.Term + c.Text); tag != "" {
+				d.Tags = append(d.Tags, tag)
+			}
+		}
+		for _, s := range e.Subjects {
+			if tag := strings.TrimSpace(s); tag != "" {
+				d.Tags = append(d.Tags, tag)
+			}
+		}
+		articles = append(articles, dated{scaffold.Article{Descriptor: d, Abstract: abstractOf(excerpt, body, title), Body: body}, published})
+	}
+	result.Articles = sortByDate(articles)
+	result.Media = sortedKeys(media)
+	return result, nil
+}
+
+// html answers a text construct as HTML: escaped, if it's plain text; as is, if it's HTML;
+// or, if it's XHTML, the content of the div wrapping it.
+func (t feedText) html() string {
+	switch t.Type {
+	case "xhtml":
+		inner := strings.TrimSpace(t.Inner)
+		if strings.HasPrefix(inner, "<div") && strings.HasSuffix(inner, "</div>") {
+			inner = inner[strings.Index(inner, ">")+1 : len(inner)-len("</div>")]
+		}
+		return strings.TrimSpace(inner)
+	case "text":
+		return html.EscapeString(t.Text)
+	}
+	return t.Text
+}
+
+// published answers when an entry was published, or failing that, last updated.
+func (e feedEntry) published() (time.Time, error) {
+	for _, s := range []string{e.Published, e.PubDate, e.Date, e.Updated} {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		for _, layout := range feedTimeLayouts {
+			if t, err := time.Parse(layout, s); err == nil {
+				return t, nil
+			}
+		}
+		return time.Time{}, fmt.Errorf("its date %q can't be understood", s)
+	}
+	return time.Time{}, fmt.Errorf("it has no date")
+}
+
+// parse answers an author's name and email address.
+func (a feedAuthor) parse() (name, email string) {
+	if a.Name != "" {
+		return strings.TrimSpace(a.Name), strings.TrimSpace(a.Email)
+	}
+	text := strings.TrimSpace(a.Text)
+	if m := rssAuthor.FindStringSubmatch(text); m != nil {
+		return strings.TrimSpace(m[2]), m[1]
+	}
+	if strings.Contains(text, "@") {
+		return "", text
+	}
+	return text, ""
+}
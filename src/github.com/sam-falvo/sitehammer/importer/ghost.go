@@ -0,0 +1,260 @@
+package importer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/sam-falvo/sitehammer/failure"
+	"github.com/sam-falvo/sitehammer/markdown"
+	"github.com/sam-falvo/sitehammer/scaffold"
+	"github.com/sam-falvo/sitehammer/weblog"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ghostExport is the JSON export of a Ghost blog, as written by Ghost 1.0 and later,
+// which wrap the data in a db array, and by earlier versions, which don't.
+type ghostExport struct {
+	Db   []ghostDb `json:"db"`
+	Data ghostData `json:"data"`
+}
+
+type ghostDb struct {
+	Data ghostData `json:"data"`
+}
+
+// ghostData holds the tables of a Ghost blog bearing on its posts.
+type ghostData struct {
+	Posts        []ghostPost `json:"posts"`
+	Tags         []ghostTag  `json:"tags"`
+	Users        []ghostUser `json:"users"`
+	PostsTags    []ghostLink `json:"posts_tags"`
+	PostsAuthors []ghostLink `json:"posts_authors"`
+}
+
+type ghostPost struct {
+	Id            ghostId   `json:"id"`
+	Title         string    `json:"title"`
+	Slug          string    `json:"slug"`
+	Html          *string   `json:"html"`
+	Mobiledoc     *string   `json:"mobiledoc"`
+	Lexical       *string   `json:"lexical"`
+	Markdown      *string   `json:"markdown"`
+	CustomExcerpt *string   `json:"custom_excerpt"`
+	Status        string    `json:"status"`
+	Type          string    `json:"type"`
+	Page          ghostBool `json:"page"`
+	PublishedAt   ghostTime `json:"published_at"`
+	UpdatedAt     ghostTime `json:"updated_at"`
+	CreatedAt     ghostTime `json:"created_at"`
+	AuthorId      ghostId   `json:"author_id"`
+}
+
+type ghostTag struct {
+	Id         ghostId `json:"id"`
+	Name       string  `json:"name"`
+	Visibility string  `json:"visibility"`
+}
+
+type ghostUser struct {
+	Id    ghostId `json:"id"`
+	Name  string  `json:"name"`
+	Email string  `json:"email"`
+}
+
+// ghostLink relates a post to one of its tags or authors.
+type ghostLink struct {
+	PostId    ghostId `json:"post_id"`
+	TagId     ghostId `json:"tag_id"`
+	AuthorId  ghostId `json:"author_id"`
+	SortOrder int     `json:"sort_order"`
+}
+
+// ghostId is the ID of a row of a Ghost table, a number in early exports and a string in later ones.
+type ghostId string
+
+func (id *ghostId) UnmarshalJSON(raw []byte) error {
+	if bytes.Equal(raw, []byte("null")) {
+		*id = ""
+		return nil
+	}
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		*id = ghostId(s)
+		return nil
+	}
+	var n json.Number
+	err := json.Unmarshal(raw, &n)
+	*id = ghostId(n)
+	return err
+}
+
+// ghostBool is a flag, a boolean in later exports and 0 or 1 in early ones.
+type ghostBool bool
+
+func (b *ghostBool) UnmarshalJSON(raw []byte) error {
+	s := string(raw)
+	*b = ghostBool(s == "true" || s == "1")
+	return nil
+}
+
+// ghostTime is a moment in a Ghost table: a timestamp string in later exports, milliseconds since 1970 in early ones,
+// or null, if it never happened.
+type ghostTime struct {
+	time.Time
+}
+
+func (t *ghostTime) UnmarshalJSON(raw []byte) error {
+	if bytes.Equal(raw, []byte("null")) {
+		return nil
+	}
+	var s string
+	if json.Unmarshal(raw, &s) != nil {
+		ms, err := strconv.ParseInt(string(raw), 10, 64)
+		if err != nil {
+			return fmt.Errorf("Cannot understand the time %s.", raw)
+		}
+		t.Time = time.Unix(0, ms*int64(time.Millisecond)).UTC()
+		return nil
+	}
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02 15:04:05"} {
+		if parsed, err := time.Parse(layout, s); err == nil {
+			t.Time = parsed
+			return nil
+		}
+	}
+	return fmt.Errorf("Cannot understand the time %q.", s)
+}
+
+// ghostUrl stands for the blog's own URL in the HTML of posts exported by Ghost 4.0 and later.
+const ghostUrl = "__GHOST_URL__"
+
+// Ghost reads a Ghost JSON export, as made by Ghost's Labs settings, answering its posts as articles, oldest first.
+// Each post's tags become its article's tags, except for Ghost's internal tags, whose names begin with #,
+// and its primary author becomes its article's author.
+// A post's body is its HTML, if the export has it; otherwise, it's converted from the post's Mobiledoc or Markdown source.
+// Pages, as opposed to posts, are skipped, as are drafts and scheduled posts, unless opts.Drafts is set.
+// References to the blog's own URL, which Ghost writes as __GHOST_URL__, become references relative to the site's root,
+// such as /content/images/photo.jpg, so that media copied into the site's content directory is found.
+func Ghost(r io.Reader, opts Options) (*Result, error) {
+	raw, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var export ghostExport
+	err = json.Unmarshal(raw, &export)
+	if err != nil {
+		return nil, failure.Wrap(failure.Content, fmt.Errorf("Cannot read the Ghost export: %v", err))
+	}
+	data := export.Data
+	if len(export.Db) > 0 {
+		data = export.Db[0].Data
+	}
+
+	tags := make(map[ghostId]ghostTag)
+	for _, t := range data.Tags {
+		tags[t.Id] = t
+	}
+	users := make(map[ghostId]ghostUser)
+	for _, u := range data.Users {
+		users[u.Id] = u
+	}
+	postTags := linksByPost(data.PostsTags)
+	postAuthors := linksByPost(data.PostsAuthors)
+
+	result := &Result{}
+	media := make(map[string]bool)
+	var articles []dated
+	for _, p := range data.Posts {
+		name := fmt.Sprintf("post %q", p.Slug)
+		if p.Type == "page" || bool(p.Page) {
+			result.Skipped = append(result.Skipped, Skipped{name, "it's a page, not a post"})
+			continue
+		}
+		published := p.PublishedAt.Time
+		if p.Status != "published" {
+			if !opts.Drafts {
+				result.Skipped = append(result.Skipped, Skipped{name, fmt.Sprintf("it's %s, not published", p.Status)})
+				continue
+			}
+			published = latest(p.UpdatedAt.Time, p.CreatedAt.Time)
+		}
+		body, err := ghostBody(p, opts.Markdown)
+		if err != nil {
+			result.Skipped = append(result.Skipped, Skipped{name, err.Error()})
+			continue
+		}
+		body = strings.Replace(body, ghostUrl, "", -1)
+		if body != "" && !strings.HasSuffix(body, "\n") {
+			body += "\n"
+		}
+		mediaOf(body, media)
+
+		d := weblog.Descriptor{
+			Title:     p.Title,
+			Author:    opts.Author,
+			Email:     opts.Email,
+			Published: published.Format(scaffold.DateLayout),
+		}
+		authorId := p.AuthorId
+		if links := postAuthors[p.Id]; len(links) > 0 {
+			authorId = links[0].AuthorId
+		}
+		if u, ok := users[authorId]; ok && u.Name != "" {
+			d.Author, d.Email = u.Name, u.Email
+		}
+		for _, link := range postTags[p.Id] {
+			t, ok := tags[link.TagId]
+			if ok && t.Visibility != "internal" && !strings.HasPrefix(t.Name, "#") {
+				d.Tags = append(d.Tags, t.Name)
+			}
+		}
+		excerpt := ""
+		if p.CustomExcerpt != nil {
+			excerpt = *p.CustomExcerpt
+		}
+		articles = append(articles, dated{scaffold.Article{Descriptor: d, Abstract: abstractOf(excerpt, body, p.Title), Body: body}, published})
+	}
+	result.Articles = sortByDate(articles)
+	result.Media = sortedKeys(media)
+	return result, nil
+}
+
+// linksByPost groups the links relating posts to their tags or authors by post, each post's in their sort order.
+func linksByPost(links []ghostLink) map[ghostId][]ghostLink {
+	byPost := make(map[ghostId][]ghostLink)
+	for _, link := range links {
+		byPost[link.PostId] = append(byPost[link.PostId], link)
+	}
+	for _, links := range byPost {
+		sort.SliceStable(links, func(i, j int) bool { return links[i].SortOrder < links[j].SortOrder })
+	}
+	return byPost
+}
+
+// ghostBody answers a post's body as HTML, converting Markdown with the given extensions.
+func ghostBody(p ghostPost, ext markdown.Extensions) (string, error) {
+	switch {
+	case p.Html != nil && *p.Html != "":
+		return *p.Html, nil
+	case p.Mobiledoc != nil && *p.Mobiledoc != "":
+		return mobiledocToHtml(*p.Mobiledoc, ext)
+	case p.Markdown != nil && *p.Markdown != "":
+		return string(markdown.ToHtmlWith([]byte(*p.Markdown), ext)), nil
+	case p.Lexical != nil && *p.Lexical != "":
+		return "", fmt.Errorf("its content is only in Lexical form, which can't be converted; export it again from a Ghost version which includes HTML")
+	}
+	return "", nil
+}
+
+// latest answers the later of two times.
+func latest(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
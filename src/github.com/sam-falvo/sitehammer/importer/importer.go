@@ -0,0 +1,105 @@
+/*
+The importer package converts the exports of other blogging systems into SiteHammer articles,
+so a blog can move to SiteHammer without retyping it.
+
+Each importer reads an export, answering its posts as scaffold.Article values, oldest first,
+ready for scaffold.AddArticles to number and add to the blog.
+Bodies become HTML fragments, as SiteHammer expects; each article's abstract is its excerpt, where the export has one,
+or else the first paragraph of its body.
+Media the export holds is answered along with the articles, to be copied into the site;
+media it doesn't hold isn't, and the importers report where it's expected to be found.
+
+Ghost reads the JSON export of the Ghost blogging platform.
+Tumblr reads a Tumblr backup, either Tumblr's own export or the posts' JSON as saved by backup tools.
+Feed reads an RSS or Atom feed, for platforms with no proper export but a feed giving the posts' full content.
+*/
+package importer
+
+import (
+	"github.com/sam-falvo/sitehammer/markdown"
+	"github.com/sam-falvo/sitehammer/scaffold"
+	"html"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Options controls an import.
+// Drafts imports posts that were never published, which are otherwise skipped, dated as of their last update.
+// Author and Email name the author of posts for which the export names none.
+// MediaUrl is the URL, relative to the site's root, beneath which the articles refer to the media files the export holds.
+// Markdown gives the extensions to the Markdown dialect with which Markdown in the export is converted.
+type Options struct {
+	Drafts   bool
+	Author   string
+	Email    string
+	MediaUrl string
+	Markdown markdown.Extensions
+}
+
+// Skipped describes an entry of an export which wasn't imported: what it was, named as the export names it, and why not.
+type Skipped struct {
+	Name   string
+	Reason string
+}
+
+// Result holds what an import found: the Articles to add, oldest first, and the entries Skipped.
+// Media lists, sorted, the URLs of the media the articles refer to which the export doesn't hold, which must be copied into the site by hand.
+// Files holds the media files the export does hold, by name, to be copied into the site beneath the Options' MediaUrl.
+type Result struct {
+	Articles []scaffold.Article
+	Skipped  []Skipped
+	Media    []string
+	Files    map[string][]byte
+}
+
+// dated is an article along with its publication time, for sorting.
+type dated struct {
+	article   scaffold.Article
+	published time.Time
+}
+
+// sortByDate answers the articles oldest first, articles published together keeping their order.
+func sortByDate(articles []dated) []scaffold.Article {
+	sort.SliceStable(articles, func(i, j int) bool { return articles[i].published.Before(articles[j].published) })
+	sorted := make([]scaffold.Article, len(articles))
+	for i, a := range articles {
+		sorted[i] = a.article
+	}
+	return sorted
+}
+
+var (
+	firstParagraph = regexp.MustCompile(`(?is)<p[\s>].*?</p>`)
+	mediaSource    = regexp.MustCompile(`(?i)\s(?:src|href)="([^"]+\.(?:png|jpe?g|gif|webp|svg|mp4|webm|mp3|ogg|pdf))"`)
+)
+
+// abstractOf answers an article's abstract: its excerpt, as an escaped paragraph, if it has one;
+// otherwise, the first paragraph of its body, or failing that, its title.
+func abstractOf(excerpt, body, title string) string {
+	if excerpt = strings.TrimSpace(excerpt); excerpt != "" {
+		return "<p>" + html.EscapeString(excerpt) + "</p>\n"
+	}
+	if p := firstParagraph.FindString(body); p != "" {
+		return p + "\n"
+	}
+	return "<p>" + html.EscapeString(title) + "</p>\n"
+}
+
+// mediaOf adds to media the URLs of the media a body refers to.
+func mediaOf(body string, media map[string]bool) {
+	for _, m := range mediaSource.FindAllStringSubmatch(body, -1) {
+		media[m[1]] = true
+	}
+}
+
+// sortedKeys answers the keys of a set, sorted.
+func sortedKeys(set map[string]bool) []string {
+	keys := []string{}
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
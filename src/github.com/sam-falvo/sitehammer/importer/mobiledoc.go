@@ -0,0 +1,212 @@
+package importer
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/sam-falvo/sitehammer/markdown"
+	"html"
+	"strings"
+)
+
+// mobiledoc is a document in Mobiledoc form, the JSON document format of Ghost 1.0 to 4.0.
+// Sections, markers, markups, atoms, and cards are arrays of mixed types, so they're decoded piecemeal.
+type mobiledoc struct {
+	Atoms    [][]json.RawMessage `json:"atoms"`
+	Cards    [][]json.RawMessage `json:"cards"`
+	Markups  [][]json.RawMessage `json:"markups"`
+	Sections [][]json.RawMessage `json:"sections"`
+	ext      markdown.Extensions
+}
+
+// Kinds of Mobiledoc section.
+const (
+	markupSection = 1
+	imageSection  = 2
+	listSection   = 3
+	cardSection   = 10
+)
+
+// mobiledocToHtml renders a Mobiledoc document as HTML.
+// Cards are rendered as Ghost renders them, as far as plain HTML allows; those of unknown kinds become comments naming them.
+// Markdown cards are converted with the given extensions.
+func mobiledocToHtml(source string, ext markdown.Extensions) (string, error) {
+	doc := mobiledoc{ext: ext}
+	err := json.Unmarshal([]byte(source), &doc)
+	if err != nil {
+		return "", fmt.Errorf("its Mobiledoc content is malformed: %v", err)
+	}
+	var b strings.Builder
+	for _, section := range doc.Sections {
+		err = doc.renderSection(&b, section)
+		if err != nil {
+			return "", fmt.Errorf("its Mobiledoc content is malformed: %v", err)
+		}
+	}
+	return b.String(), nil
+}
+
+// renderSection renders one section of the document.
+func (doc *mobiledoc) renderSection(b *strings.Builder, section []json.RawMessage) error {
+	var kind int
+	if len(section) < 2 || json.Unmarshal(section[0], &kind) != nil {
+		return fmt.Errorf("a section lacks its kind")
+	}
+	switch kind {
+	case markupSection:
+		var tag string
+		var markers [][]json.RawMessage
+		if len(section) < 3 || json.Unmarshal(section[1], &tag) != nil || json.Unmarshal(section[2], &markers) != nil {
+			return fmt.Errorf("a markup section is incomplete")
+		}
+		tag = strings.ToLower(tag)
+		if tag == "pull-quote" {
+			tag = "blockquote"
+		}
+		fmt.Fprintf(b, "<%s>", tag)
+		err := doc.renderMarkers(b, markers)
+		fmt.Fprintf(b, "</%s>\n", tag)
+		return err
+	case imageSection:
+		var src string
+		if json.Unmarshal(section[1], &src) != nil {
+			return fmt.Errorf("an image section lacks its source")
+		}
+		fmt.Fprintf(b, "<img src=\"%s\">\n", html.EscapeString(src))
+	case listSection:
+		var tag string
+		var items [][][]json.RawMessage
+		if len(section) < 3 || json.Unmarshal(section[1], &tag) != nil || json.Unmarshal(section[2], &items) != nil {
+			return fmt.Errorf("a list section is incomplete")
+		}
+		fmt.Fprintf(b, "<%s>\n", tag)
+		for _, item := range items {
+			b.WriteString("<li>")
+			err := doc.renderMarkers(b, item)
+			if err != nil {
+				return err
+			}
+			b.WriteString("</li>\n")
+		}
+		fmt.Fprintf(b, "</%s>\n", tag)
+	case cardSection:
+		var index int
+		if json.Unmarshal(section[1], &index) != nil || index < 0 || index >= len(doc.Cards) || len(doc.Cards[index]) < 2 {
+			return fmt.Errorf("a card section refers to no card")
+		}
+		var name string
+		json.Unmarshal(doc.Cards[index][0], &name)
+		var payload map[string]interface{}
+		json.Unmarshal(doc.Cards[index][1], &payload)
+		b.WriteString(renderCard(name, payload, doc.ext))
+	}
+	return nil
+}
+
+// renderMarkers renders the markers of a markup section or a list item: runs of text, and atoms, each opening and closing markups.
+func (doc *mobiledoc) renderMarkers(b *strings.Builder, markers [][]json.RawMessage) error {
+	var open []string
+	for _, marker := range markers {
+		var kind, closed int
+		var opened []int
+		if len(marker) < 4 || json.Unmarshal(marker[0], &kind) != nil || json.Unmarshal(marker[1], &opened) != nil || json.Unmarshal(marker[2], &closed) != nil {
+			return fmt.Errorf("a marker is incomplete")
+		}
+		for _, i := range opened {
+			if i < 0 || i >= len(doc.Markups) || len(doc.Markups[i]) == 0 {
+				return fmt.Errorf("a marker refers to no markup")
+			}
+			var tag string
+			var attrs []string
+			json.Unmarshal(doc.Markups[i][0], &tag)
+			if len(doc.Markups[i]) > 1 {
+				json.Unmarshal(doc.Markups[i][1], &attrs)
+			}
+			b.WriteString("<" + tag)
+			for j := 0; j+1 < len(attrs); j += 2 {
+				fmt.Fprintf(b, " %s=\"%s\"", attrs[j], html.EscapeString(attrs[j+1]))
+			}
+			b.WriteString(">")
+			open = append(open, tag)
+		}
+		switch kind {
+		case 0:
+			var text string
+			json.Unmarshal(marker[3], &text)
+			b.WriteString(html.EscapeString(text))
+		case 1:
+			var index int
+			if json.Unmarshal(marker[3], &index) != nil || index < 0 || index >= len(doc.Atoms) || len(doc.Atoms[index]) < 2 {
+				return fmt.Errorf("a marker refers to no atom")
+			}
+			var name, text string
+			json.Unmarshal(doc.Atoms[index][0], &name)
+			json.Unmarshal(doc.Atoms[index][1], &text)
+			if name == "soft-return" {
+				b.WriteString("<br>")
+			} else {
+				b.WriteString(html.EscapeString(text))
+			}
+		}
+		for ; closed > 0 && len(open) > 0; closed-- {
+			b.WriteString("</" + open[len(open)-1] + ">")
+			open = open[:len(open)-1]
+		}
+	}
+	for len(open) > 0 {
+		b.WriteString("</" + open[len(open)-1] + ">")
+		open = open[:len(open)-1]
+	}
+	return nil
+}
+
+// renderCard renders a card, given its name and payload, converting Markdown with the given extensions.
+func renderCard(name string, payload map[string]interface{}, ext markdown.Extensions) string {
+	str := func(key string) string {
+		s, _ := payload[key].(string)
+		return s
+	}
+	figure := func(content, caption string) string {
+		if caption == "" {
+			return "<figure>" + content + "</figure>\n"
+		}
+		return "<figure>" + content + "<figcaption>" + caption + "</figcaption></figure>\n"
+	}
+	switch name {
+	case "markdown", "card-markdown":
+		return string(markdown.ToHtmlWith([]byte(str("markdown")), ext))
+	case "html", "embed":
+		return str("html") + "\n"
+	case "image":
+		return figure(fmt.Sprintf("<img src=\"%s\" alt=\"%s\">", html.EscapeString(str("src")), html.EscapeString(str("alt"))), str("caption"))
+	case "gallery":
+		images, _ := payload["images"].([]interface{})
+		var content strings.Builder
+		for _, i := range images {
+			image, _ := i.(map[string]interface{})
+			src, _ := image["src"].(string)
+			alt, _ := image["alt"].(string)
+			fmt.Fprintf(&content, "<img src=\"%s\" alt=\"%s\">", html.EscapeString(src), html.EscapeString(alt))
+		}
+		return figure(content.String(), str("caption"))
+	case "code":
+		class := ""
+		if str("language") != "" {
+			class = fmt.Sprintf(" class=\"language-%s\"", html.EscapeString(str("language")))
+		}
+		return fmt.Sprintf("<pre><code%s>%s</code></pre>\n", class, html.EscapeString(str("code")))
+	case "hr":
+		return "<hr>\n"
+	case "bookmark":
+		metadata, _ := payload["metadata"].(map[string]interface{})
+		title, _ := metadata["title"].(string)
+		if title == "" {
+			title = str("url")
+		}
+		return fmt.Sprintf("<p><a href=\"%s\">%s</a></p>\n", html.EscapeString(str("url")), html.EscapeString(title))
+	case "video", "audio":
+		return fmt.Sprintf("<%s src=\"%s\" controls></%s>\n", name, html.EscapeString(str("src")), name)
+	case "file":
+		return fmt.Sprintf("<p><a href=\"%s\">%s</a></p>\n", html.EscapeString(str("src")), html.EscapeString(str("fileName")))
+	}
+	return fmt.Sprintf("<!-- Ghost %s card not imported -->\n", html.EscapeString(name))
+}
@@ -0,0 +1,326 @@
+package importer
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"github.com/sam-falvo/sitehammer/failure"
+	"github.com/sam-falvo/sitehammer/scaffold"
+	"github.com/sam-falvo/sitehammer/weblog"
+	"html"
+	"io/fs"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// tumblrPost is a post in the form the Tumblr API answers it, as backup tools save it, in its legacy format.
+type tumblrPost struct {
+	Id        json.Number   `json:"id"`
+	Type      string        `json:"type"`
+	Timestamp int64         `json:"timestamp"`
+	Slug      string        `json:"slug"`
+	State     string        `json:"state"`
+	Tags      []string      `json:"tags"`
+	Summary   string        `json:"summary"`
+	Title     string        `json:"title"`
+	Body      string        `json:"body"`
+	Caption   string        `json:"caption"`
+	Photos    []tumblrPhoto `json:"photos"`
+	Text      string        `json:"text"`
+	Source    string        `json:"source"`
+	Url       string        `json:"url"`
+	Desc      string        `json:"description"`
+	Dialogue  []struct {
+		Label  string `json:"label"`
+		Phrase string `json:"phrase"`
+	} `json:"dialogue"`
+	VideoUrl string `json:"video_url"`
+	AudioUrl string `json:"audio_url"`
+}
+
+type tumblrPhoto struct {
+	Caption      string `json:"caption"`
+	OriginalSize struct {
+		Url string `json:"url"`
+	} `json:"original_size"`
+}
+
+// Tumblr reads a Tumblr backup, a directory or a zip archive, answering its posts as articles, oldest first,
+// along with the media files the backup holds for them.
+// Two kinds of backup are understood:
+// the export Tumblr makes from a blog's settings, which holds a page of HTML for each post beneath posts/html, and the media beneath media;
+// and backups holding posts as the Tumblr API answers them, as JSON, one post, array of posts, or API response per file, such as backup tools save.
+// Text, photo, quote, link, chat, video, and audio posts are all imported, each converted to HTML:
+// photos become figures captioned with their captions, and quotes, block quotes citing their sources.
+// A media file the backup holds is answered in Result.Files, and referred to beneath opts.MediaUrl;
+// media it doesn't hold is referred to where it stands, and listed in Result.Media.
+// Each post's tags become its article's tags; posts not yet published are skipped, unless opts.Drafts is set.
+func Tumblr(backup string, opts Options) (*Result, error) {
+	fsys, closer, err := openBackup(backup)
+	if err != nil {
+		return nil, err
+	}
+	defer closer()
+
+	t := &tumblrImport{fsys: fsys, opts: opts, result: &Result{Files: make(map[string][]byte)}, media: make(map[string]bool)}
+	err = fs.WalkDir(fsys, ".", func(name string, e fs.DirEntry, err error) error {
+		if err != nil || e.IsDir() {
+			return err
+		}
+		switch {
+		case strings.HasSuffix(name, ".json"):
+			return t.readJson(name)
+		case strings.HasSuffix(name, ".html") && path.Base(path.Dir(name)) == "html":
+			return t.readPage(name)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(t.articles) == 0 && len(t.result.Skipped) == 0 {
+		return nil, failure.Wrap(failure.Content, fmt.Errorf("%s holds no Tumblr posts; give the directory or zip archive holding posts/html or the posts' JSON.", backup))
+	}
+	t.result.Articles = sortByDate(t.articles)
+	t.result.Media = sortedKeys(t.media)
+	return t.result, nil
+}
+
+// openBackup opens a backup, a directory or a zip archive, answering its files along with a function to close it.
+func openBackup(name string) (fs.FS, func(), error) {
+	info, err := os.Stat(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	if info.IsDir() {
+		return os.DirFS(name), func() {}, nil
+	}
+	r, err := zip.OpenReader(name)
+	if err != nil {
+		return nil, nil, failure.Wrap(failure.Content, fmt.Errorf("Cannot read the Tumblr backup %s: %v", name, err))
+	}
+	return r, func() { r.Close() }, nil
+}
+
+// tumblrImport holds the state of an import from a Tumblr backup in progress.
+type tumblrImport struct {
+	fsys     fs.FS
+	opts     Options
+	result   *Result
+	articles []dated
+	media    map[string]bool
+}
+
+// readJson imports the posts held by a JSON file of the backup, which may be a post, an array of posts, or an API response.
+// JSON files holding none of these are passed over.
+func (t *tumblrImport) readJson(name string) error {
+	raw, err := fs.ReadFile(t.fsys, name)
+	if err != nil {
+		return err
+	}
+	var posts []tumblrPost
+	var response struct {
+		Response struct {
+			Posts []tumblrPost `json:"posts"`
+		} `json:"response"`
+	}
+	var post tumblrPost
+	switch {
+	case json.Unmarshal(raw, &posts) == nil:
+	case json.Unmarshal(raw, &response) == nil && len(response.Response.Posts) > 0:
+		posts = response.Response.Posts
+	case json.Unmarshal(raw, &post) == nil && post.Type != "":
+		posts = []tumblrPost{post}
+	}
+	for _, p := range posts {
+		if p.Type != "" {
+			t.importPost(p)
+		}
+	}
+	return nil
+}
+
+// importPost converts a post of the API's form into an article.
+func (t *tumblrImport) importPost(p tumblrPost) {
+	name := fmt.Sprintf("%s post %s", p.Type, p.Id)
+	if p.State != "" && p.State != "published" && !t.opts.Drafts {
+		t.result.Skipped = append(t.result.Skipped, Skipped{name, fmt.Sprintf("it's %s, not published", p.State)})
+		return
+	}
+	var body strings.Builder
+	title := p.Title
+	switch p.Type {
+	case "text":
+		body.WriteString(p.Body)
+	case "photo":
+		for _, photo := range p.Photos {
+			body.WriteString(figure(t.mediaUrl(photo.OriginalSize.Url), photo.Caption))
+		}
+		body.WriteString(p.Caption)
+	case "quote":
+		body.WriteString(quote(p.Text, p.Source))
+	case "link":
+		if title == "" {
+			title = p.Url
+		}
+		fmt.Fprintf(&body, "<p><a href=\"%s\">%s</a></p>\n%s", html.EscapeString(p.Url), html.EscapeString(title), p.Desc)
+	case "chat":
+		body.WriteString("<dl>\n")
+		for _, line := range p.Dialogue {
+			fmt.Fprintf(&body, "<dt>%s</dt><dd>%s</dd>\n", html.EscapeString(line.Label), html.EscapeString(line.Phrase))
+		}
+		body.WriteString("</dl>\n")
+	case "video", "audio":
+		src := p.VideoUrl
+		if p.Type == "audio" {
+			src = p.AudioUrl
+		}
+		if src != "" {
+			fmt.Fprintf(&body, "<%s src=\"%s\" controls></%s>\n", p.Type, html.EscapeString(t.mediaUrl(src)), p.Type)
+		}
+		body.WriteString(p.Caption)
+	default:
+		t.result.Skipped = append(t.result.Skipped, Skipped{name, fmt.Sprintf("%s posts can't be imported", p.Type)})
+		return
+	}
+	if title == "" {
+		title = titleFrom(p.Summary, p.Type)
+	}
+	t.add(title, body.String(), time.Unix(p.Timestamp, 0).UTC(), p.Tags)
+}
+
+// Patterns picking apart a page of Tumblr's own export.
+var (
+	pageBody      = regexp.MustCompile(`(?is)<body[^>]*>(.*)</body>`)
+	pageTitle     = regexp.MustCompile(`(?is)^\s*<h1[^>]*>(.*?)</h1>`)
+	pageFooter    = regexp.MustCompile(`(?is)<div id="footer">.*$`)
+	pageTimestamp = regexp.MustCompile(`(?is)<span id="timestamp">(.*?)</span>`)
+	pageTag       = regexp.MustCompile(`(?is)<span class="tag">(.*?)</span>`)
+	pageMedia     = regexp.MustCompile(`(?i)(\s(?:src|href)=")([^"]+)"`)
+	ordinal       = regexp.MustCompile(`(\d)(?:st|nd|rd|th)\b`)
+	markup        = regexp.MustCompile(`<[^>]*>`)
+)
+
+// readPage imports the post a page of Tumblr's own export describes.
+// The page holds the post's content, led by its title, if it has one, followed by a footer giving its timestamp and tags.
+func (t *tumblrImport) readPage(name string) error {
+	raw, err := fs.ReadFile(t.fsys, name)
+	if err != nil {
+		return err
+	}
+	content := string(raw)
+	if m := pageBody.FindStringSubmatch(content); m != nil {
+		content = m[1]
+	}
+	footer := pageFooter.FindString(content)
+	content = strings.TrimSpace(pageFooter.ReplaceAllString(content, ""))
+
+	var published time.Time
+	if m := pageTimestamp.FindStringSubmatch(footer); m != nil {
+		stamp := ordinal.ReplaceAllString(strings.TrimSpace(html.UnescapeString(m[1])), "$1")
+		published, err = time.Parse("January 2, 2006 3:04pm", stamp)
+		if err != nil {
+			t.result.Skipped = append(t.result.Skipped, Skipped{name, fmt.Sprintf("its timestamp %q can't be understood", stamp)})
+			return nil
+		}
+	}
+	var tags []string
+	for _, m := range pageTag.FindAllStringSubmatch(footer, -1) {
+		tags = append(tags, html.UnescapeString(strings.TrimSpace(m[1])))
+	}
+	title := ""
+	if m := pageTitle.FindStringSubmatch(content); m != nil {
+		title = html.UnescapeString(markup.ReplaceAllString(m[1], ""))
+		content = strings.TrimSpace(content[len(m[0]):])
+	}
+	content = pageMedia.ReplaceAllStringFunc(content, func(attr string) string {
+		m := pageMedia.FindStringSubmatch(attr)
+		ref := html.UnescapeString(m[2])
+		if strings.Contains(ref, ":") || strings.HasPrefix(ref, "/") || strings.HasPrefix(ref, "#") {
+			return attr
+		}
+		return m[1] + html.EscapeString(t.mediaUrl(path.Join(path.Dir(name), ref))) + `"`
+	})
+	if title == "" {
+		title = titleFrom(markup.ReplaceAllString(content, " "), "post")
+	}
+	t.add(title, content, published, tags)
+	return nil
+}
+
+// mediaUrl answers the URL by which an article refers to a media file:
+// one beneath opts.MediaUrl if the backup holds the file, noting it to be copied, or else the reference as it stands.
+// A file is looked for by its name, relative to the backup's root, or by its base name in the backup's media directory.
+func (t *tumblrImport) mediaUrl(ref string) string {
+	names := []string{path.Join("media", path.Base(ref))}
+	if !strings.Contains(ref, "://") {
+		names = append([]string{path.Clean(ref)}, names...)
+	}
+	for _, name := range names {
+		content, err := fs.ReadFile(t.fsys, name)
+		if err == nil {
+			t.result.Files[path.Base(name)] = content
+			return t.mediaPrefix() + path.Base(name)
+		}
+	}
+	return ref
+}
+
+// mediaPrefix answers the prefix of the URLs of the media files copied from the backup.
+func (t *tumblrImport) mediaPrefix() string {
+	return strings.TrimSuffix(t.opts.MediaUrl, "/") + "/"
+}
+
+// add adds an article with the given title, body, publication time, and tags.
+func (t *tumblrImport) add(title, body string, published time.Time, tags []string) {
+	if body != "" && !strings.HasSuffix(body, "\n") {
+		body += "\n"
+	}
+	media := make(map[string]bool)
+	mediaOf(body, media)
+	for url := range media {
+		if !strings.HasPrefix(url, t.mediaPrefix()) {
+			t.media[url] = true
+		}
+	}
+	d := weblog.Descriptor{
+		Title:     title,
+		Author:    t.opts.Author,
+		Email:     t.opts.Email,
+		Published: published.Format(scaffold.DateLayout),
+		Tags:      tags,
+	}
+	t.articles = append(t.articles, dated{scaffold.Article{Descriptor: d, Abstract: abstractOf("", body, title), Body: body}, published})
+}
+
+// figure answers the HTML showing an image, with its caption, if it has one.
+func figure(src, caption string) string {
+	img := fmt.Sprintf("<img src=\"%s\" alt=\"\">", html.EscapeString(src))
+	if caption == "" {
+		return "<figure>" + img + "</figure>\n"
+	}
+	return "<figure>" + img + "<figcaption>" + caption + "</figcaption></figure>\n"
+}
+
+// quote answers the HTML quoting text, an HTML fragment, citing its source, if it has one.
+func quote(text, source string) string {
+	if source == "" {
+		return "<blockquote>" + text + "</blockquote>\n"
+	}
+	return "<blockquote>" + text + "<footer>" + source + "</footer></blockquote>\n"
+}
+
+// titleFrom answers a title for a post which has none: its summary's first few words, or failing that, the kind of post.
+func titleFrom(summary, kind string) string {
+	words := strings.Fields(html.UnescapeString(summary))
+	if len(words) == 0 {
+		return strings.Title(kind)
+	}
+	if len(words) > 8 {
+		words = append(words[:8], "…")
+	}
+	return strings.Join(words, " ")
+}
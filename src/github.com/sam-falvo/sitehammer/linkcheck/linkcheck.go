@@ -0,0 +1,194 @@
+/*
+The linkcheck package checks whether the external links in a built site still lead anywhere.
+
+Each URL is checked with a HEAD request, falling back to GET for servers that don't support HEAD.
+A URL answering with a status of 400 or above, or not answering at all within the timeout, is dead.
+
+Results are cached in .sitehammer-cache/links.json.
+A URL checked more recently than the cache's time-to-live isn't checked again,
+so repeated runs don't hammer other people's servers.
+*/
+package linkcheck
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/sam-falvo/sitehammer/directory"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// CacheFilename names the link cache, relative to the cache directory.
+const CacheFilename = "links.json"
+
+// userAgent identifies the checker to the servers it visits.
+const userAgent = "SiteHammer link checker"
+
+// Result records the outcome of checking one URL.
+// Status holds the HTTP status the URL answered with, or zero if the request failed, in which case Error says why.
+type Result struct {
+	Status  int
+	Error   string `json:",omitempty"`
+	Checked time.Time
+}
+
+// Dead answers true if the URL couldn't be retrieved.
+func (r Result) Dead() bool {
+	return r.Status == 0 || r.Status >= 400
+}
+
+// String describes the result briefly, e.g., "404 Not Found" or "timeout".
+func (r Result) String() string {
+	if r.Status == 0 {
+		return r.Error
+	}
+	return fmt.Sprintf("%d %s", r.Status, http.StatusText(r.Status))
+}
+
+// Checker checks URLs, consulting and updating a cache of earlier results.
+//
+// TTL says how long a result remains trustworthy; Timeout, how long to wait for a server to answer.
+// Workers limits how many URLs are checked at once.
+type Checker struct {
+	TTL     time.Duration
+	Timeout time.Duration
+	Workers int
+
+	filename string
+	results  map[string]Result
+	client   *http.Client
+}
+
+// Open answers a checker whose cache lives in the named file.
+// A missing or unreadable cache isn't an error; every URL will simply be checked afresh.
+func Open(filename string, ttl, timeout time.Duration, workers int) *Checker {
+	c := &Checker{
+		TTL:      ttl,
+		Timeout:  timeout,
+		Workers:  workers,
+		filename: filename,
+		results:  make(map[string]Result),
+	}
+	if raw, err := ioutil.ReadFile(filename); err == nil {
+		if json.Unmarshal(raw, &c.results) != nil || c.results == nil {
+			c.results = make(map[string]Result)
+		}
+	}
+	c.client = &http.Client{Timeout: timeout}
+	if c.Workers < 1 {
+		c.Workers = 1
+	}
+	return c
+}
+
+// Check checks every URL given, answering each one's result.
+// Cached results younger than the time-to-live are used as they are.
+func (c *Checker) Check(urls []string) map[string]Result {
+	results := make(map[string]Result)
+	var pending []string
+	for _, link := range uniq(urls) {
+		if r, ok := c.results[link]; ok && time.Since(r.Checked) < c.TTL {
+			results[link] = r
+		} else {
+			pending = append(pending, link)
+		}
+	}
+
+	var lock sync.Mutex
+	var wg sync.WaitGroup
+	work := make(chan string)
+	for i := 0; i < c.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for link := range work {
+				r := c.check(link)
+				lock.Lock()
+				results[link] = r
+				c.results[link] = r
+				lock.Unlock()
+			}
+		}()
+	}
+	for _, link := range pending {
+		work <- link
+	}
+	close(work)
+	wg.Wait()
+	return results
+}
+
+// check retrieves a single URL.
+func (c *Checker) check(link string) Result {
+	r := Result{Checked: time.Now().UTC()}
+	status, err := c.request("HEAD", link)
+	if err == nil && (status == http.StatusMethodNotAllowed || status == http.StatusNotImplemented || status == http.StatusForbidden) {
+		// Some servers refuse HEAD requests but answer GET requests perfectly well.
+		status, err = c.request("GET", link)
+	}
+	if err != nil {
+		if e, ok := err.(*url.Error); ok {
+			// The URL is reported alongside the result; don't repeat it.
+			err = e.Err
+		}
+		r.Error = err.Error()
+		if e, ok := err.(interface{ Timeout() bool }); ok && e.Timeout() {
+			r.Error = "timeout"
+		}
+		return r
+	}
+	r.Status = status
+	return r
+}
+
+func (c *Checker) request(method, link string) (int, error) {
+	req, err := http.NewRequest(method, link, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("User-Agent", userAgent)
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	return resp.StatusCode, nil
+}
+
+// Save writes the cache back to its file, creating the cache directory if necessary.
+// Results older than the time-to-live are dropped, so the cache doesn't grow without bound.
+func (c *Checker) Save() error {
+	for link, r := range c.results {
+		if time.Since(r.Checked) >= c.TTL {
+			delete(c.results, link)
+		}
+	}
+	raw, err := json.MarshalIndent(c.results, "", " ")
+	if err != nil {
+		return err
+	}
+	err = directory.EnsureDirAll(filepath.Dir(c.filename), 0755)
+	if err != nil {
+		return err
+	}
+	return directory.WriteFileAtomic(c.filename, raw, 0644)
+}
+
+// uniq answers the distinct strings given, sorted.
+func uniq(items []string) []string {
+	seen := make(map[string]bool)
+	var out []string
+	for _, s := range items {
+		if !seen[s] {
+			seen[s] = true
+			out = append(out, s)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
@@ -0,0 +1,49 @@
+package manifest
+
+import (
+	"sort"
+)
+
+// Kinds of difference Diff finds between two manifests.
+const (
+	Added   = "new"
+	Changed = "changed"
+	Removed = "removed"
+)
+
+// Difference describes a file whose content differs between two manifests:
+// its Path, the Kind of difference, and the Sources it was generated from, as the newer manifest lists them,
+// or as the older one does for a removed file.
+type Difference struct {
+	Path    string
+	Kind    string
+	Sources []string
+}
+
+// Diff answers the files whose content differs between the previous manifest and m, in order of their paths:
+// those m lists but the previous manifest doesn't, those both list with different hashes,
+// and those the previous manifest lists but m doesn't.
+// It also answers how many files both list unchanged.
+func (m *Manifest) Diff(previous *Manifest) (differences []Difference, unchanged int) {
+	before := make(map[string]Entry)
+	for _, e := range previous.Files {
+		before[e.Path] = e
+	}
+	for _, e := range m.Files {
+		old, ok := before[e.Path]
+		delete(before, e.Path)
+		switch {
+		case !ok:
+			differences = append(differences, Difference{e.Path, Added, e.Sources})
+		case old.Sha256 != e.Sha256:
+			differences = append(differences, Difference{e.Path, Changed, e.Sources})
+		default:
+			unchanged++
+		}
+	}
+	for _, e := range before {
+		differences = append(differences, Difference{e.Path, Removed, e.Sources})
+	}
+	sort.Slice(differences, func(i, j int) bool { return differences[i].Path < differences[j].Path })
+	return
+}
@@ -0,0 +1,123 @@
+/*
+The manifest package describes the outcome of a build in a machine-readable form,
+so deployment scripts and cache-invalidation tools can act on exactly what changed.
+
+A manifest is a JSON file like this:
+
+	{
+	  "output_dir": "_site",
+	  "built": "2012-01-01T12:00:00Z",
+	  "files": [
+	    {
+	      "path": "about.html",
+	      "sources": ["about.md"],
+	      "size": 1234,
+	      "sha256": "0123...",
+	      "changed": true
+	    }
+	  ]
+	}
+
+Paths are slash-separated and relative to the output directory; sources are relative to the site's source directory.
+A file is marked changed if the previous manifest didn't list it, or listed it with different content.
+Symbolic links published as links carry a link field naming their targets, and their hash is that of the target's name.
+*/
+package manifest
+
+import (
+	"encoding/json"
+	"github.com/sam-falvo/sitehammer/buildcache"
+	"github.com/sam-falvo/sitehammer/directory"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// Entry describes one generated file.
+type Entry struct {
+	Path    string   `json:"path"`
+	Sources []string `json:"sources"`
+	Size    int64    `json:"size"`
+	Sha256  string   `json:"sha256"`
+	Link    string   `json:"link,omitempty"`
+	Changed bool     `json:"changed"`
+}
+
+// Manifest describes every file a build generated.
+type Manifest struct {
+	OutputDir string    `json:"output_dir"`
+	Built     time.Time `json:"built"`
+	Files     []Entry   `json:"files"`
+}
+
+// Build describes the files found in root, an output directory known to the user as outputDir.
+// Sources maps each generated file's path to the names of the source files it was generated from.
+// Files are listed in order of their paths.
+func Build(root, outputDir string, sources map[string][]string) (*Manifest, error) {
+	m := &Manifest{OutputDir: outputDir, Built: time.Now().UTC(), Files: []Entry{}}
+	for name, from := range sources {
+		e := Entry{Path: name, Sources: from}
+		if e.Sources == nil {
+			e.Sources = []string{}
+		}
+		filename := filepath.Join(root, filepath.FromSlash(name))
+		fi, err := os.Lstat(filename)
+		if err != nil {
+			return nil, err
+		}
+		if fi.Mode()&os.ModeSymlink != 0 {
+			e.Link, err = os.Readlink(filename)
+			if err != nil {
+				return nil, err
+			}
+			e.Sha256 = buildcache.Hash([]byte(e.Link))
+		} else {
+			content, err := ioutil.ReadFile(filename)
+			if err != nil {
+				return nil, err
+			}
+			e.Size = int64(len(content))
+			e.Sha256 = buildcache.Hash(content)
+		}
+		m.Files = append(m.Files, e)
+	}
+	sort.Slice(m.Files, func(i, j int) bool { return m.Files[i].Path < m.Files[j].Path })
+	return m, nil
+}
+
+// Load reads a manifest written by Save.
+// A missing manifest isn't an error; an empty manifest results.
+func Load(filename string) (*Manifest, error) {
+	m := &Manifest{Files: []Entry{}}
+	raw, err := ioutil.ReadFile(filename)
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+		}
+		return m, err
+	}
+	return m, json.Unmarshal(raw, m)
+}
+
+// MarkChanges marks each file which the previous manifest lacks, or lists with a different hash, as changed.
+func (m *Manifest) MarkChanges(previous *Manifest) {
+	hashes := make(map[string]string)
+	for _, e := range previous.Files {
+		hashes[e.Path] = e.Sha256
+	}
+	for i, e := range m.Files {
+		hash, ok := hashes[e.Path]
+		m.Files[i].Changed = !ok || hash != e.Sha256
+	}
+}
+
+// Save writes the manifest to the named file in JSON format.
+func (m *Manifest) Save(filename string) error {
+	raw, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return directory.WriteFileAtomic(filename, raw, 0644)
+}
@@ -0,0 +1,300 @@
+package markdown
+
+import (
+	"bytes"
+	"html"
+	"regexp"
+	"strings"
+)
+
+// Extensions selects the extensions to the Markdown dialect ToHtmlWith understands.
+//
+// Tables are GitHub's: a header row of cells separated by pipes, a delimiter row of dashes, colons marking each column's alignment,
+// and the body's rows, up to the first blank line.
+// Strikethrough renders text between one or two tildes, like ~~this~~, as deleted.
+// TaskLists renders list items beginning with [ ] or [x] as unchecked or checked boxes.
+// Autolinks makes links of bare URLs beginning with http://, https://, or www., and of bare email addresses,
+// leaving off trailing punctuation, as GitHub does.
+// DefinitionLists are PHP Markdown Extra's: a line or more of terms, each followed by definitions beginning with a colon.
+// SmartPunctuation renders straight quotes as curly ones, -- and --- as en and em dashes, and ... as an ellipsis, as SmartyPants does.
+type Extensions struct {
+	Tables           bool
+	Strikethrough    bool
+	TaskLists        bool
+	Autolinks        bool
+	DefinitionLists  bool
+	SmartPunctuation bool
+}
+
+var (
+	tableDelimiter  = regexp.MustCompile(`^ {0,3}\|?[ \t]*:?-+:?[ \t]*(?:\|[ \t]*:?-+:?[ \t]*)*\|?[ \t]*$`)
+	taskItem        = regexp.MustCompile(`^\[([ xX])\](?: +|$)`)
+	definitionStart = regexp.MustCompile(`^ {0,3}:(?: +|$)`)
+	bareUrl         = regexp.MustCompile(`^(?:https?://|www\.)[A-Za-z0-9-]+(?:\.[A-Za-z0-9-]+)*[^\s<]*`)
+	bareEmail       = regexp.MustCompile(`^[A-Za-z0-9.+_-]+@[A-Za-z0-9_-]+(?:\.[A-Za-z0-9_-]+)+`)
+	smartCharacters = "\"'.-"
+)
+
+// isTable answers true if a table begins at lines[i]: a header row followed by a delimiter row with as many cells.
+func isTable(lines []string, i int) bool {
+	return i+1 < len(lines) && strings.Contains(lines[i], "|") && tableDelimiter.MatchString(lines[i+1]) &&
+		len(tableCells(lines[i])) == len(tableCells(lines[i+1]))
+}
+
+// tableCells splits a table row into its cells' text, trimmed.
+// Pipes escaped with a backslash, or within code spans, belong to their cells.
+func tableCells(row string) []string {
+	row = strings.TrimSpace(row)
+	row = strings.TrimPrefix(row, "|")
+	if strings.HasSuffix(row, "|") && !strings.HasSuffix(row, `\|`) {
+		row = row[:len(row)-1]
+	}
+	var cells []string
+	var cell strings.Builder
+	inCode := false
+	for i := 0; i < len(row); i++ {
+		switch {
+		case row[i] == '\\' && i+1 < len(row) && row[i+1] == '|':
+			cell.WriteByte('|')
+			i++
+		case row[i] == '`':
+			inCode = !inCode
+			cell.WriteByte('`')
+		case row[i] == '|' && !inCode:
+			cells = append(cells, strings.TrimSpace(cell.String()))
+			cell.Reset()
+		default:
+			cell.WriteByte(row[i])
+		}
+	}
+	return append(cells, strings.TrimSpace(cell.String()))
+}
+
+// table renders the table beginning at lines[i], answering the index of the line following it.
+// Rows with too few cells are padded with empty ones, and those with too many, cut short.
+func (r *renderer) table(lines []string, i int, out *bytes.Buffer) int {
+	header := tableCells(lines[i])
+	var aligns []string
+	for _, d := range tableCells(lines[i+1]) {
+		switch {
+		case strings.HasPrefix(d, ":") && strings.HasSuffix(d, ":"):
+			aligns = append(aligns, ` style="text-align: center"`)
+		case strings.HasPrefix(d, ":"):
+			aligns = append(aligns, ` style="text-align: left"`)
+		case strings.HasSuffix(d, ":"):
+			aligns = append(aligns, ` style="text-align: right"`)
+		default:
+			aligns = append(aligns, "")
+		}
+	}
+	row := func(cells []string, tag string) {
+		out.WriteString("<tr>\n")
+		for c := range header {
+			out.WriteString("<" + tag + aligns[c] + ">")
+			if c < len(cells) {
+				r.renderInline(cells[c], out)
+			}
+			out.WriteString("</" + tag + ">\n")
+		}
+		out.WriteString("</tr>\n")
+	}
+
+	out.WriteString("<table>\n<thead>\n")
+	row(header, "th")
+	out.WriteString("</thead>\n")
+	i += 2
+	if i < len(lines) && !isBlank(lines[i]) && !interruptsParagraph(lines[i]) {
+		out.WriteString("<tbody>\n")
+		for ; i < len(lines) && !isBlank(lines[i]) && !interruptsParagraph(lines[i]); i++ {
+			row(tableCells(lines[i]), "td")
+		}
+		out.WriteString("</tbody>\n")
+	}
+	out.WriteString("</table>\n")
+	return i
+}
+
+// taskMarker answers the checkbox a task list item's first line begins with, if any, and the rest of the line.
+func taskMarker(line string) (checkbox, rest string) {
+	m := taskItem.FindStringSubmatch(line)
+	if m == nil {
+		return "", line
+	}
+	if m[1] == " " {
+		return `<input type="checkbox" disabled="" /> `, line[len(m[0]):]
+	}
+	return `<input type="checkbox" checked="" disabled="" /> `, line[len(m[0]):]
+}
+
+// withCheckbox places a checkbox at the start of a rendered list item, within its first paragraph, if it has one.
+func withCheckbox(rendered, checkbox string) string {
+	if strings.HasPrefix(rendered, "<p>") {
+		return "<p>" + checkbox + rendered[len("<p>"):]
+	}
+	return checkbox + rendered
+}
+
+// isDefinitionList answers true if a definition list begins at lines[i]: lines of terms followed by a definition.
+func isDefinitionList(lines []string, i int) bool {
+	if definitionStart.MatchString(lines[i]) {
+		return false
+	}
+	for j := i; j < len(lines) && !isBlank(lines[j]); j++ {
+		if definitionStart.MatchString(lines[j]) {
+			return true
+		}
+		if j > i && interruptsParagraph(lines[j]) {
+			return false
+		}
+	}
+	return false
+}
+
+// definitionList renders the definition list beginning at lines[i], answering the index of the line following it.
+// A definition continues on the lines following its own, up to a blank line, or the next definition;
+// after a blank line, lines indented by four spaces continue it too, as further paragraphs.
+// A definition separated from the previous one by a blank line, or holding several paragraphs, is loose: its paragraphs keep their tags.
+func (r *renderer) definitionList(lines []string, i int, out *bytes.Buffer) int {
+	out.WriteString("<dl>\n")
+	for i < len(lines) && isDefinitionList(lines, i) {
+		for ; !definitionStart.MatchString(lines[i]); i++ {
+			out.WriteString("<dt>")
+			r.renderInline(strings.TrimSpace(lines[i]), out)
+			out.WriteString("</dt>\n")
+		}
+		separated := false
+		for i < len(lines) && definitionStart.MatchString(lines[i]) {
+			loose := separated
+			loc := definitionStart.FindStringIndex(lines[i])
+			definition := []string{lines[i][loc[1]:]}
+			i++
+			for i < len(lines) {
+				if !isBlank(lines[i]) && !definitionStart.MatchString(lines[i]) {
+					definition = append(definition, strings.TrimPrefix(lines[i], indentedCode))
+					i++
+					continue
+				}
+				j := i
+				for j < len(lines) && isBlank(lines[j]) {
+					j++
+				}
+				if j > i && j < len(lines) && strings.HasPrefix(lines[j], indentedCode) {
+					for ; i < j; i++ {
+						definition = append(definition, "")
+					}
+					loose = true
+					continue
+				}
+				if j > i && j < len(lines) && definitionStart.MatchString(lines[j]) {
+					separated = true
+					i = j
+				}
+				break
+			}
+			var content bytes.Buffer
+			r.renderBlocks(definition, &content)
+			rendered := content.String()
+			if !loose {
+				rendered = tighten(rendered)
+			}
+			out.WriteString("<dd>" + strings.TrimSuffix(rendered, "\n") + "</dd>\n")
+		}
+		j := i
+		for j < len(lines) && isBlank(lines[j]) {
+			j++
+		}
+		if j == len(lines) || !isDefinitionList(lines, j) {
+			break
+		}
+		i = j
+	}
+	out.WriteString("</dl>\n")
+	return i
+}
+
+// strikethrough renders deleted text between the runs of one or two tildes beginning at s[i].
+func (r *renderer) strikethrough(s string, i int, out *bytes.Buffer) int {
+	run := 0
+	for i+run < len(s) && s[i+run] == '~' {
+		run++
+	}
+	after := i + run
+	if run > 2 || after == len(s) || isSpace(s[after]) {
+		out.WriteString(s[i:after])
+		return after
+	}
+	end := closingDelimiter(s, after, s[i:after])
+	if end < 0 {
+		out.WriteString(s[i:after])
+		return after
+	}
+	out.WriteString("<del>")
+	r.renderInline(s[after:end], out)
+	out.WriteString("</del>")
+	return end + run
+}
+
+// bareLink answers the bare URL or email address beginning at s[i], if one does and it begins a word,
+// leaving off trailing punctuation, and closing parentheses not opened within it.
+func bareLink(s string, i int) string {
+	if i > 0 && !isSpace(s[i-1]) && strings.IndexByte("(*_~", s[i-1]) < 0 {
+		return ""
+	}
+	if m := bareEmail.FindString(s[i:]); m != "" {
+		return strings.TrimRight(m, ".-_")
+	}
+	m := bareUrl.FindString(s[i:])
+	for m != "" {
+		trimmed := strings.TrimRight(m, "?!.,:*_~'\"")
+		if strings.HasSuffix(trimmed, ")") && strings.Count(trimmed, "(") < strings.Count(trimmed, ")") {
+			trimmed = trimmed[:len(trimmed)-1]
+		}
+		if trimmed == m {
+			break
+		}
+		m = trimmed
+	}
+	return m
+}
+
+// bareLink renders the bare URL or email address beginning at s[i] as a link.
+func (r *renderer) bareLink(s string, i int, out *bytes.Buffer) int {
+	text := bareLink(s, i)
+	href := text
+	switch {
+	case strings.HasPrefix(text, "www."):
+		href = "http://" + text
+	case !strings.Contains(text, "://"):
+		href = "mailto:" + text
+	}
+	out.WriteString(`<a href="` + html.EscapeString(href) + `">` + html.EscapeString(text) + "</a>")
+	return i + len(text)
+}
+
+// smartPunctuation renders the quote, dash, or dots at s[i] as typographers would,
+// opening quotes at the start of the text or after spaces and opening brackets, and closing them elsewhere.
+func smartPunctuation(s string, i int, out *bytes.Buffer) int {
+	opens := i == 0 || isSpace(s[i-1]) || strings.IndexByte("([{-", s[i-1]) >= 0
+	switch {
+	case strings.HasPrefix(s[i:], "..."):
+		out.WriteString("&hellip;")
+		return i + 3
+	case strings.HasPrefix(s[i:], "---"):
+		out.WriteString("&mdash;")
+		return i + 3
+	case strings.HasPrefix(s[i:], "--"):
+		out.WriteString("&ndash;")
+		return i + 2
+	case s[i] == '"' && opens:
+		out.WriteString("&ldquo;")
+	case s[i] == '"':
+		out.WriteString("&rdquo;")
+	case s[i] == '\'' && opens:
+		out.WriteString("&lsquo;")
+	case s[i] == '\'':
+		out.WriteString("&rsquo;")
+	default:
+		out.WriteString(html.EscapeString(s[i : i+1]))
+	}
+	return i + 1
+}
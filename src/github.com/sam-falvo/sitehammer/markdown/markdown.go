@@ -0,0 +1,608 @@
+/*
+The markdown package converts Markdown text into HTML.
+
+The dialect understood is that of the original Markdown, give or take CommonMark's clarifications:
+ATX and setext headings, paragraphs, block quotes, ordered and unordered (and nested) lists,
+indented and fenced code blocks, horizontal rules, and raw HTML blocks;
+within text, emphasis, strong emphasis, code spans, links, images, autolinks, inline HTML,
+backslash escapes, and hard line breaks.
+
+ToHtmlWith adds extensions to the dialect, as other Markdown implementations do, since content written for one of them relies on its extensions:
+GitHub's tables, strikethrough, task lists, and autolinks of bare URLs and email addresses,
+PHP Markdown Extra's definition lists, and SmartyPants' smart punctuation.
+See Extensions.
+*/
+package markdown
+
+import (
+	"bytes"
+	"html"
+	"regexp"
+	"strings"
+)
+
+// ToHtml converts Markdown source into an HTML fragment, with no extensions.
+func ToHtml(src []byte) []byte {
+	return ToHtmlWith(src, Extensions{})
+}
+
+// ToHtmlWith converts Markdown source into an HTML fragment, understanding the given extensions.
+func ToHtmlWith(src []byte, ext Extensions) []byte {
+	var out bytes.Buffer
+	text := strings.Replace(string(src), "\r\n", "\n", -1)
+	text = strings.Replace(text, "\t", "    ", -1)
+	r := &renderer{ext: ext}
+	r.renderBlocks(strings.Split(text, "\n"), &out)
+	return out.Bytes()
+}
+
+// renderer renders one Markdown document.
+// Links counts the links whose text is being rendered, within which bare URLs aren't made links of their own.
+type renderer struct {
+	ext   Extensions
+	links int
+}
+
+var (
+	atxHeading   = regexp.MustCompile(`^ {0,3}(#{1,6})(?:[ \t]+(.*?))?(?:[ \t]+#+)?[ \t]*$`)
+	setextLine   = regexp.MustCompile(`^ {0,3}(=+|-+)[ \t]*$`)
+	horizontal   = regexp.MustCompile(`^ {0,3}(?:(?:-[ \t]*){3,}|(?:\*[ \t]*){3,}|(?:_[ \t]*){3,})$`)
+	fenceOpen    = regexp.MustCompile("^( {0,3})(`{3,}|~{3,})[ \t]*([^`]*?)[ \t]*$")
+	bulletItem   = regexp.MustCompile(`^( {0,3})([-*+])( +|$)`)
+	orderedItem  = regexp.MustCompile(`^( {0,3})(\d{1,9})([.)])( +|$)`)
+	htmlBlock    = regexp.MustCompile(`^ {0,3}<(?:/?[A-Za-z][A-Za-z0-9-]*(?:[ \t>/]|$)|!--)`)
+	quoteMarker  = regexp.MustCompile(`^ {0,3}> ?`)
+	indentedCode = "    "
+)
+
+func isBlank(line string) bool {
+	return strings.TrimSpace(line) == ""
+}
+
+// renderBlocks renders a sequence of lines as block-level elements.
+func (r *renderer) renderBlocks(lines []string, out *bytes.Buffer) {
+	i := 0
+	for i < len(lines) {
+		line := lines[i]
+		switch {
+		case isBlank(line):
+			i++
+		case fenceOpen.MatchString(line):
+			i = fencedCode(lines, i, out)
+		case atxHeading.MatchString(line):
+			m := atxHeading.FindStringSubmatch(line)
+			r.heading(len(m[1]), m[2], out)
+			i++
+		case horizontal.MatchString(line):
+			out.WriteString("<hr />\n")
+			i++
+		case quoteMarker.MatchString(line):
+			i = r.blockQuote(lines, i, out)
+		case bulletItem.MatchString(line) || orderedItem.MatchString(line):
+			i = r.list(lines, i, out)
+		case strings.HasPrefix(line, indentedCode):
+			i = indentedCodeBlock(lines, i, out)
+		case htmlBlock.MatchString(line):
+			i = rawHtml(lines, i, out)
+		case r.ext.Tables && isTable(lines, i):
+			i = r.table(lines, i, out)
+		case r.ext.DefinitionLists && isDefinitionList(lines, i):
+			i = r.definitionList(lines, i, out)
+		default:
+			i = r.paragraph(lines, i, out)
+		}
+	}
+}
+
+func (r *renderer) heading(level int, text string, out *bytes.Buffer) {
+	tag := string('0' + byte(level))
+	out.WriteString("<h" + tag + ">")
+	r.renderInline(strings.TrimSpace(text), out)
+	out.WriteString("</h" + tag + ">\n")
+}
+
+// interruptsParagraph answers true if the line begins a block which may cut a paragraph short.
+func interruptsParagraph(line string) bool {
+	return fenceOpen.MatchString(line) || atxHeading.MatchString(line) || horizontal.MatchString(line) ||
+		quoteMarker.MatchString(line) || bulletItem.MatchString(line) || htmlBlock.MatchString(line) ||
+		orderedItem.MatchString(line) && orderedItem.FindStringSubmatch(line)[2] == "1"
+}
+
+func (r *renderer) paragraph(lines []string, i int, out *bytes.Buffer) int {
+	var text []string
+	for i < len(lines) && !isBlank(lines[i]) {
+		if len(text) > 0 && setextLine.MatchString(lines[i]) {
+			level := 1
+			if strings.TrimSpace(lines[i])[0] == '-' {
+				level = 2
+			}
+			r.heading(level, strings.Join(text, "\n"), out)
+			return i + 1
+		}
+		if len(text) > 0 && interruptsParagraph(lines[i]) {
+			break
+		}
+		text = append(text, strings.TrimLeft(lines[i], " "))
+		i++
+	}
+	out.WriteString("<p>")
+	r.renderInline(strings.TrimRight(strings.Join(text, "\n"), " "), out)
+	out.WriteString("</p>\n")
+	return i
+}
+
+func fencedCode(lines []string, i int, out *bytes.Buffer) int {
+	m := fenceOpen.FindStringSubmatch(lines[i])
+	indent, fence, info := len(m[1]), m[2], m[3]
+	i++
+	var code []string
+	for ; i < len(lines); i++ {
+		trimmed := strings.TrimSpace(lines[i])
+		if strings.HasPrefix(trimmed, fence) && strings.Trim(trimmed, fence[:1]) == "" {
+			i++
+			break
+		}
+		line := lines[i]
+		for n := 0; n < indent && strings.HasPrefix(line, " "); n++ {
+			line = line[1:]
+		}
+		code = append(code, line)
+	}
+	language := strings.Fields(info + " ")
+	if len(language) > 0 {
+		out.WriteString(`<pre><code class="language-` + html.EscapeString(language[0]) + `">`)
+	} else {
+		out.WriteString("<pre><code>")
+	}
+	for _, line := range code {
+		out.WriteString(html.EscapeString(line) + "\n")
+	}
+	out.WriteString("</code></pre>\n")
+	return i
+}
+
+func indentedCodeBlock(lines []string, i int, out *bytes.Buffer) int {
+	var code []string
+	for ; i < len(lines); i++ {
+		if isBlank(lines[i]) {
+			code = append(code, "")
+			continue
+		}
+		if !strings.HasPrefix(lines[i], indentedCode) {
+			break
+		}
+		code = append(code, lines[i][len(indentedCode):])
+	}
+	for len(code) > 0 && code[len(code)-1] == "" {
+		code = code[:len(code)-1]
+	}
+	out.WriteString("<pre><code>")
+	for _, line := range code {
+		out.WriteString(html.EscapeString(line) + "\n")
+	}
+	out.WriteString("</code></pre>\n")
+	return i
+}
+
+func rawHtml(lines []string, i int, out *bytes.Buffer) int {
+	for ; i < len(lines) && !isBlank(lines[i]); i++ {
+		out.WriteString(lines[i] + "\n")
+	}
+	return i
+}
+
+func (r *renderer) blockQuote(lines []string, i int, out *bytes.Buffer) int {
+	var inner []string
+	for i < len(lines) && !isBlank(lines[i]) {
+		if loc := quoteMarker.FindStringIndex(lines[i]); loc != nil {
+			inner = append(inner, lines[i][loc[1]:])
+		} else {
+			// A lazy continuation line, belonging to the quote's last paragraph.
+			inner = append(inner, lines[i])
+		}
+		i++
+	}
+	out.WriteString("<blockquote>\n")
+	r.renderBlocks(inner, out)
+	out.WriteString("</blockquote>\n")
+	return i
+}
+
+// listMarker answers the type of list a line's marker begins ("ul" or "ol"), the ordered list's starting number,
+// the marker character (for telling one list from the next), and the indentation of the item's content.
+func listMarker(line string) (kind, start, marker string, contentIndent int, ok bool) {
+	if m := bulletItem.FindStringSubmatch(line); m != nil {
+		contentIndent = len(m[1]) + 1 + spacesAfterMarker(m[3])
+		return "ul", "", m[2], contentIndent, true
+	}
+	if m := orderedItem.FindStringSubmatch(line); m != nil {
+		contentIndent = len(m[1]) + len(m[2]) + 1 + spacesAfterMarker(m[4])
+		return "ol", m[2], m[3], contentIndent, true
+	}
+	return "", "", "", 0, false
+}
+
+// spacesAfterMarker counts the spaces separating a list marker from the item's content.
+// More than four spaces means the content is an indented code block, so only one counts.
+func spacesAfterMarker(spaces string) int {
+	if len(spaces) == 0 || len(spaces) > 4 {
+		return 1
+	}
+	return len(spaces)
+}
+
+func (r *renderer) list(lines []string, i int, out *bytes.Buffer) int {
+	kind, start, marker, _, _ := listMarker(lines[i])
+	var items [][]string
+	loose := false
+	for i < len(lines) {
+		k, _, m, indent, ok := listMarker(lines[i])
+		if !ok || k != kind || m != marker {
+			break
+		}
+		item := []string{strings.TrimLeft(lines[i][min(indent, len(lines[i])):], " ")}
+		i++
+		for i < len(lines) {
+			line := lines[i]
+			if isBlank(line) {
+				// A blank line continues the item only if indented content follows.
+				j := i
+				for j < len(lines) && isBlank(lines[j]) {
+					j++
+				}
+				if j < len(lines) && leadingSpaces(lines[j]) >= indent {
+					for ; i < j; i++ {
+						item = append(item, "")
+					}
+					continue
+				}
+				break
+			}
+			if leadingSpaces(line) >= indent {
+				item = append(item, line[indent:])
+			} else if _, _, _, _, isItem := listMarker(line); !isItem && !interruptsParagraph(line) && !isBlank(item[len(item)-1]) {
+				item = append(item, strings.TrimLeft(line, " "))
+			} else {
+				break
+			}
+			i++
+		}
+		for _, l := range item {
+			if isBlank(l) {
+				loose = true
+			}
+		}
+		items = append(items, item)
+
+		// Blank lines between items make the list loose.
+		j := i
+		for j < len(lines) && isBlank(lines[j]) {
+			j++
+		}
+		if j > i && j < len(lines) {
+			if k, _, m, _, ok := listMarker(lines[j]); ok && k == kind && m == marker {
+				loose = true
+				i = j
+			}
+		}
+	}
+
+	if kind == "ol" && start != "1" {
+		out.WriteString(`<ol start="` + strings.TrimLeft(start, "0") + `">` + "\n")
+	} else {
+		out.WriteString("<" + kind + ">\n")
+	}
+	for _, item := range items {
+		checkbox := ""
+		if r.ext.TaskLists {
+			checkbox, item[0] = taskMarker(item[0])
+		}
+		var content bytes.Buffer
+		r.renderBlocks(item, &content)
+		rendered := content.String()
+		if !loose {
+			rendered = tighten(rendered)
+		}
+		if checkbox != "" {
+			rendered = withCheckbox(rendered, checkbox)
+		}
+		out.WriteString("<li>" + strings.TrimSuffix(rendered, "\n") + "</li>\n")
+	}
+	out.WriteString("</" + kind + ">\n")
+	return i
+}
+
+// tighten removes the paragraph tags from items of a tight list.
+func tighten(rendered string) string {
+	rendered = strings.Replace(rendered, "<p>", "", -1)
+	return strings.Replace(rendered, "</p>\n", "\n", -1)
+}
+
+func leadingSpaces(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " "))
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+var (
+	autolink   = regexp.MustCompile(`^<([A-Za-z][A-Za-z0-9+.-]{1,31}:[^<>\s]*)>`)
+	emailLink  = regexp.MustCompile(`^<([A-Za-z0-9.!#$%&'*+/=?^_{|}~-]+@[A-Za-z0-9](?:[A-Za-z0-9-]*[A-Za-z0-9])?(?:\.[A-Za-z0-9](?:[A-Za-z0-9-]*[A-Za-z0-9])?)*)>`)
+	inlineTag  = regexp.MustCompile(`^(?:<[A-Za-z][A-Za-z0-9-]*(?:\s+[A-Za-z_:][A-Za-z0-9_.:-]*(?:\s*=\s*(?:"[^"]*"|'[^']*'|[^\s"'=<>` + "`" + `]+))?)*\s*/?>|</[A-Za-z][A-Za-z0-9-]*\s*>|<!--[\s\S]*?-->)`)
+	entity     = regexp.MustCompile(`^&(?:[A-Za-z][A-Za-z0-9]{1,31}|#[0-9]{1,7}|#[xX][0-9A-Fa-f]{1,6});`)
+	linkTarget = regexp.MustCompile(`^\(\s*(<[^>]*>|[^\s()]*(?:\([^\s()]*\)[^\s()]*)*)(?:\s+("[^"]*"|'[^']*'|\([^)]*\)))?\s*\)`)
+	escapable  = "\\`*_{}[]()#+-.!<>\"'~|:&"
+)
+
+// renderInline renders the text within a block, interpreting inline markup.
+func (r *renderer) renderInline(s string, out *bytes.Buffer) {
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == '\\' && i+1 < len(s) && strings.IndexByte(escapable, s[i+1]) >= 0:
+			out.WriteString(html.EscapeString(s[i+1 : i+2]))
+			i += 2
+
+		case c == '\\' && i+1 < len(s) && s[i+1] == '\n':
+			out.WriteString("<br />\n")
+			i += 2
+
+		case c == '`':
+			i = codeSpan(s, i, out)
+
+		case c == '!' && i+1 < len(s) && s[i+1] == '[':
+			if n, ok := r.link(s, i+1, true, out); ok {
+				i = n
+			} else {
+				out.WriteByte('!')
+				i++
+			}
+
+		case c == '[':
+			if n, ok := r.link(s, i, false, out); ok {
+				i = n
+			} else {
+				out.WriteByte('[')
+				i++
+			}
+
+		case c == '<':
+			i = angleBracket(s, i, out)
+
+		case c == '*' || c == '_':
+			i = r.emphasis(s, i, out)
+
+		case c == '~' && r.ext.Strikethrough:
+			i = r.strikethrough(s, i, out)
+
+		case r.ext.Autolinks && r.links == 0 && bareLink(s, i) != "":
+			i = r.bareLink(s, i, out)
+
+		case r.ext.SmartPunctuation && strings.IndexByte(smartCharacters, c) >= 0:
+			i = smartPunctuation(s, i, out)
+
+		case c == '&':
+			if m := entity.FindString(s[i:]); m != "" {
+				out.WriteString(m)
+				i += len(m)
+			} else {
+				out.WriteString("&amp;")
+				i++
+			}
+
+		case c == '\n':
+			if strings.HasSuffix(out.String(), "  ") {
+				trimTrailingSpaces(out)
+				out.WriteString("<br />\n")
+			} else {
+				trimTrailingSpaces(out)
+				out.WriteByte('\n')
+			}
+			i++
+
+		default:
+			out.WriteString(html.EscapeString(s[i : i+1]))
+			i++
+		}
+	}
+}
+
+func trimTrailingSpaces(out *bytes.Buffer) {
+	b := out.Bytes()
+	n := len(bytes.TrimRight(b, " "))
+	out.Truncate(n)
+}
+
+func codeSpan(s string, i int, out *bytes.Buffer) int {
+	run := 0
+	for i+run < len(s) && s[i+run] == '`' {
+		run++
+	}
+	fence := s[i : i+run]
+	for j := i + run; j < len(s); {
+		k := strings.Index(s[j:], fence)
+		if k < 0 {
+			break
+		}
+		k += j
+		end := k + run
+		if end < len(s) && s[end] == '`' {
+			for end < len(s) && s[end] == '`' {
+				end++
+			}
+			j = end
+			continue
+		}
+		code := strings.Replace(s[i+run:k], "\n", " ", -1)
+		if len(code) > 2 && code[0] == ' ' && code[len(code)-1] == ' ' && strings.TrimSpace(code) != "" {
+			code = code[1 : len(code)-1]
+		}
+		out.WriteString("<code>" + html.EscapeString(code) + "</code>")
+		return end
+	}
+	out.WriteString(fence)
+	return i + run
+}
+
+// link renders a link or image whose text begins with the bracket at s[i].
+// It answers the index just past the link, and whether a link was found at all.
+func (r *renderer) link(s string, i int, image bool, out *bytes.Buffer) (int, bool) {
+	depth := 0
+	closing := -1
+	for j := i; j < len(s) && closing < 0; j++ {
+		switch s[j] {
+		case '\\':
+			j++
+		case '`':
+			if k := strings.IndexByte(s[j+1:], '`'); k >= 0 {
+				j += k + 1
+			}
+		case '[':
+			depth++
+		case ']':
+			depth--
+			if depth == 0 {
+				closing = j
+			}
+		}
+	}
+	if closing < 0 {
+		return i, false
+	}
+	m := linkTarget.FindStringSubmatch(s[closing+1:])
+	if m == nil {
+		return i, false
+	}
+	text := s[i+1 : closing]
+	url := strings.TrimSuffix(strings.TrimPrefix(m[1], "<"), ">")
+	title := ""
+	if len(m[2]) >= 2 {
+		title = m[2][1 : len(m[2])-1]
+	}
+	titleAttr := ""
+	if title != "" {
+		titleAttr = ` title="` + html.EscapeString(title) + `"`
+	}
+	if image {
+		out.WriteString(`<img src="` + html.EscapeString(url) + `" alt="` + html.EscapeString(r.plainText(text)) + `"` + titleAttr + ` />`)
+	} else {
+		out.WriteString(`<a href="` + html.EscapeString(url) + `"` + titleAttr + `>`)
+		r.links++
+		r.renderInline(text, out)
+		r.links--
+		out.WriteString("</a>")
+	}
+	return closing + 1 + len(m[0]), true
+}
+
+// plainText strips inline markup from text, for use in attributes such as alt.
+func (r *renderer) plainText(text string) string {
+	var out bytes.Buffer
+	r.renderInline(text, &out)
+	return html.UnescapeString(regexp.MustCompile(`<[^>]*>`).ReplaceAllString(out.String(), ""))
+}
+
+func angleBracket(s string, i int, out *bytes.Buffer) int {
+	if m := autolink.FindStringSubmatch(s[i:]); m != nil {
+		out.WriteString(`<a href="` + html.EscapeString(m[1]) + `">` + html.EscapeString(m[1]) + "</a>")
+		return i + len(m[0])
+	}
+	if m := emailLink.FindStringSubmatch(s[i:]); m != nil {
+		out.WriteString(`<a href="mailto:` + html.EscapeString(m[1]) + `">` + html.EscapeString(m[1]) + "</a>")
+		return i + len(m[0])
+	}
+	if m := inlineTag.FindString(s[i:]); m != "" {
+		out.WriteString(m)
+		return i + len(m)
+	}
+	out.WriteString("&lt;")
+	return i + 1
+}
+
+// emphasis renders emphasis or strong emphasis beginning with the delimiter run at s[i].
+// Underscores only count at word boundaries, so snake_case_names stay intact.
+func (r *renderer) emphasis(s string, i int, out *bytes.Buffer) int {
+	c := s[i]
+	run := 0
+	for i+run < len(s) && s[i+run] == c {
+		run++
+	}
+	after := i + run
+	leftFlanking := after < len(s) && !isSpace(s[after])
+	if c == '_' && i > 0 && isWordChar(s[i-1]) {
+		leftFlanking = false
+	}
+	if !leftFlanking || run > 3 {
+		out.WriteString(s[i:after])
+		return after
+	}
+
+	for n := run; n >= 1; n-- {
+		delimiter := strings.Repeat(string(c), n)
+		if end := closingDelimiter(s, i+n, delimiter); end >= 0 {
+			inner := s[i+n : end]
+			if n == 3 {
+				out.WriteString("<em><strong>")
+				r.renderInline(inner, out)
+				out.WriteString("</strong></em>")
+			} else {
+				tag := "em"
+				if n == 2 {
+					tag = "strong"
+				}
+				out.WriteString(s[i : i+run-n])
+				out.WriteString("<" + tag + ">")
+				r.renderInline(inner, out)
+				out.WriteString("</" + tag + ">")
+			}
+			return end + n
+		}
+	}
+	out.WriteString(s[i:after])
+	return after
+}
+
+// closingDelimiter finds the delimiter closing an emphasis span whose content starts at s[from].
+func closingDelimiter(s string, from int, delimiter string) int {
+	c := delimiter[0]
+	for j := from; j < len(s); j++ {
+		switch s[j] {
+		case '\\':
+			j++
+			continue
+		case '`':
+			if k := strings.IndexByte(s[j+1:], '`'); k >= 0 {
+				j += k + 1
+			}
+			continue
+		}
+		if !strings.HasPrefix(s[j:], delimiter) || j == from || isSpace(s[j-1]) {
+			continue
+		}
+		run := 0
+		for j+run < len(s) && s[j+run] == c {
+			run++
+		}
+		if run != len(delimiter) && !(run > len(delimiter) && len(delimiter) == 1) {
+			j += run - 1
+			continue
+		}
+		if c == '_' && j+len(delimiter) < len(s) && isWordChar(s[j+len(delimiter)]) {
+			continue
+		}
+		return j
+	}
+	return -1
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n'
+}
+
+func isWordChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c >= 0x80
+}
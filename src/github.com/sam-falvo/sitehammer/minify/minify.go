@@ -0,0 +1,235 @@
+/*
+The minify package shrinks stylesheets and scripts without changing their meaning.
+
+The minifiers here are deliberately conservative.
+They remove comments and redundant whitespace, but never rename identifiers or rewrite expressions.
+The JavaScript minifier also preserves line breaks, so automatic semicolon insertion behaves exactly as it did before minification.
+*/
+package minify
+
+import (
+	"bytes"
+	"path"
+	"strings"
+)
+
+// ForName minifies content according to the extension of the named file.
+// Content of any kind other than CSS or JavaScript passes through unchanged.
+func ForName(name string, content []byte) []byte {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".css":
+		return CSS(content)
+	case ".js":
+		return JS(content)
+	}
+	return content
+}
+
+// cssTight lists the characters around which CSS never needs whitespace.
+const cssTight = "{};,>"
+
+// CSS minifies a stylesheet.
+func CSS(src []byte) []byte {
+	var out bytes.Buffer
+	pendingSpace := false
+	for i := 0; i < len(src); i++ {
+		c := src[i]
+		switch {
+		case c == '/' && i+1 < len(src) && src[i+1] == '*':
+			end := bytes.Index(src[i+2:], []byte("*/"))
+			if end < 0 {
+				i = len(src)
+			} else {
+				i += end + 3
+			}
+			// A comment separates tokens without being whitespace, so it becomes a space only where its neighbors would otherwise run together:
+			// .a/**/.b is one compound selector, which .a .b isn't.
+			if i+1 < len(src) && isCSSNameChar(lastByte(&out)) && isCSSNameChar(src[i+1]) {
+				pendingSpace = true
+			}
+
+		case isSpace(c):
+			pendingSpace = true
+
+		case c == '"' || c == '\'':
+			end := quotedEnd(src, i)
+			emitPendingSpace(&out, pendingSpace, c)
+			out.Write(src[i:end])
+			i = end - 1
+			pendingSpace = false
+
+		default:
+			if c == '}' && lastByte(&out) == ';' {
+				out.Truncate(out.Len() - 1)
+			}
+			emitPendingSpace(&out, pendingSpace, c)
+			out.WriteByte(c)
+			pendingSpace = false
+		}
+	}
+	return out.Bytes()
+}
+
+// emitPendingSpace writes a single space for a run of whitespace or comments, unless neither neighbor needs it.
+func emitPendingSpace(out *bytes.Buffer, pending bool, next byte) {
+	if !pending || out.Len() == 0 {
+		return
+	}
+	prev := lastByte(out)
+	if strings.IndexByte(cssTight, prev) >= 0 || strings.IndexByte(cssTight, next) >= 0 || prev == ':' {
+		return
+	}
+	out.WriteByte(' ')
+}
+
+// JS minifies a script.
+// Comments disappear, each line loses its leading and trailing whitespace, and blank lines go away.
+func JS(src []byte) []byte {
+	var out bytes.Buffer
+	lineStart := true
+	pendingSpace := false
+	significant := byte(0) // the last non-whitespace character written, used to tell division from regular expressions
+
+	emit := func(bs []byte) {
+		if pendingSpace && !lineStart {
+			out.WriteByte(' ')
+		}
+		out.Write(bs)
+		significant = bs[len(bs)-1]
+		lineStart = false
+		pendingSpace = false
+	}
+	newline := func() {
+		if !lineStart {
+			out.WriteByte('\n')
+		}
+		lineStart = true
+		pendingSpace = false
+	}
+
+	for i := 0; i < len(src); i++ {
+		c := src[i]
+		switch {
+		case c == '\n':
+			newline()
+
+		case isSpace(c):
+			pendingSpace = true
+
+		case c == '/' && i+1 < len(src) && src[i+1] == '/':
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+			i--
+			pendingSpace = true
+
+		case c == '/' && i+1 < len(src) && src[i+1] == '*':
+			end := bytes.Index(src[i+2:], []byte("*/"))
+			if end < 0 {
+				end = len(src) - i - 2
+			}
+			comment := src[i : i+end+2]
+			i += end + 3
+			if bytes.IndexByte(comment, '\n') >= 0 {
+				newline()
+			} else {
+				pendingSpace = true
+			}
+
+		case c == '"' || c == '\'' || c == '`':
+			end := quotedEnd(src, i)
+			emit(src[i:end])
+			i = end - 1
+
+		case c == '/' && regexpMayFollow(&out, significant):
+			end := regexpEnd(src, i)
+			emit(src[i:end])
+			i = end - 1
+
+		default:
+			emit(src[i : i+1])
+		}
+	}
+	return bytes.TrimRight(out.Bytes(), "\n")
+}
+
+// regexpMayFollow decides whether a slash starts a regular expression literal rather than a division.
+func regexpMayFollow(out *bytes.Buffer, significant byte) bool {
+	written := bytes.TrimRight(out.Bytes(), "\n")
+	if n := len(written); n >= 2 && (significant == '+' || significant == '-') && written[n-2] == significant {
+		// A postfix increment or decrement, as in i++ / 2.
+		return false
+	}
+	if significant == 0 || strings.IndexByte("(,=:[!&|?{};+-*%<>~^", significant) >= 0 {
+		return true
+	}
+	for _, keyword := range []string{"return", "typeof", "case", "do", "else", "in", "instanceof", "new", "void", "delete", "throw"} {
+		if bytes.HasSuffix(written, []byte(keyword)) {
+			n := len(written) - len(keyword)
+			if n == 0 || !isIdentChar(written[n-1]) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// regexpEnd answers the index just past the regular expression literal (including flags) starting at src[start].
+func regexpEnd(src []byte, start int) int {
+	inClass := false
+	i := start + 1
+	for ; i < len(src) && src[i] != '\n'; i++ {
+		switch src[i] {
+		case '\\':
+			i++
+		case '[':
+			inClass = true
+		case ']':
+			inClass = false
+		case '/':
+			if !inClass {
+				i++
+				for i < len(src) && isIdentChar(src[i]) {
+					i++
+				}
+				return i
+			}
+		}
+	}
+	return i
+}
+
+// quotedEnd answers the index just past the quoted string starting at src[start].
+// Backslash escapes are honored.
+func quotedEnd(src []byte, start int) int {
+	quote := src[start]
+	for i := start + 1; i < len(src); i++ {
+		switch src[i] {
+		case '\\':
+			i++
+		case quote:
+			return i + 1
+		}
+	}
+	return len(src)
+}
+
+func lastByte(b *bytes.Buffer) byte {
+	if b.Len() == 0 {
+		return 0
+	}
+	return b.Bytes()[b.Len()-1]
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
+
+// isCSSNameChar answers true if c may continue a CSS identifier, number, or unit; bytes of multi-byte characters count.
+func isCSSNameChar(c byte) bool {
+	return isIdentChar(c) && c != '$' || c == '-' || c == '%' || c == '\\' || c >= 0x80
+}
+
+func isIdentChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '$'
+}
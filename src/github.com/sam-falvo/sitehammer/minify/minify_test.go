@@ -0,0 +1,104 @@
+package minify
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata with the minifiers' output")
+
+// golden minifies the named file in testdata, comparing the result with the file's golden counterpart, named by golden.
+func golden(t *testing.T, name, golden string, minify func([]byte) []byte) {
+	t.Helper()
+	src, err := ioutil.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := minify(src)
+	filename := filepath.Join("testdata", golden)
+	if *update {
+		if err := ioutil.WriteFile(filename, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("minifying %s answered\n%s\nwant\n%s", name, got, want)
+	}
+}
+
+func TestCSS(t *testing.T) {
+	golden(t, "style.css", "style.min.css", CSS)
+}
+
+func TestJS(t *testing.T) {
+	golden(t, "script.js", "script.min.js", JS)
+}
+
+// TestJSRegexpOrDivision checks the guesses JS makes, from the last significant character written, about whether a slash divides or starts a regular expression.
+// A wrong guess shows when the line goes on to hold a comment, which is kept if the guess took it for part of a regular expression.
+func TestJSRegexpOrDivision(t *testing.T) {
+	tests := []struct {
+		src, want string
+	}{
+		{"a = b / c; // x", "a = b / c;"},
+		{"a = b/c/d; // x", "a = b/c/d;"},
+		{"a = f(x) / 2; // x", "a = f(x) / 2;"},
+		{"a = v[0] / 2; // x", "a = v[0] / 2;"},
+		{"a = 10 / 2; // x", "a = 10 / 2;"},
+		{"a = i++ / 2; // x", "a = i++ / 2;"},
+		{"a = i-- / 2; // x", "a = i-- / 2;"},
+		{"a = i++\n/ 2; // x", "a = i++\n/ 2;"},
+		{"a = 'q' / 2; // x", "a = 'q' / 2;"},
+		{"a = done / 2; // x", "a = done / 2;"},
+		{"a = main / 2; // x", "a = main / 2;"},
+		{"a = return_ / 2; // x", "a = return_ / 2;"},
+		{"/a // b/.test(s)", "/a // b/.test(s)"},
+		{"a = /a // b/", "a = /a // b/"},
+		{"f(/a // b/)", "f(/a // b/)"},
+		{"f(x, /a // b/)", "f(x, /a // b/)"},
+		{"a = [/a // b/]", "a = [/a // b/]"},
+		{"a = !/a // b/.test(s)", "a = !/a // b/.test(s)"},
+		{"a = x && /a // b/", "a = x && /a // b/"},
+		{"a = x ? /a // b/ : /c // d/", "a = x ? /a // b/ : /c // d/"},
+		{"a = { k: /a // b/ }", "a = { k: /a // b/ }"},
+		{"a = x + /a // b/.source", "a = x + /a // b/.source"},
+		{"f = x => /a // b/", "f = x => /a // b/"},
+		{"return /a // b/", "return /a // b/"},
+		{"a = typeof /a // b/", "a = typeof /a // b/"},
+		{"throw /a // b/", "throw /a // b/"},
+		{"case /a // b/:", "case /a // b/:"},
+		{"a = /[/]/ // x", "a = /[/]/"},
+		{`a = /\// // x`, `a = /\//`},
+		{"a = /x/gi / 2 // x", "a = /x/gi / 2"},
+	}
+	for _, tc := range tests {
+		if got := string(JS([]byte(tc.src))); got != tc.want {
+			t.Errorf("JS(%q) = %q, want %q", tc.src, got, tc.want)
+		}
+	}
+}
+
+func TestCSSComments(t *testing.T) {
+	tests := []struct {
+		src, want string
+	}{
+		{".a/**/.b{}", ".a.b{}"},
+		{".a /**/ .b{}", ".a .b{}"},
+		{"a{margin:1px/**/2px}", "a{margin:1px 2px}"},
+		{"a{color:red/**/}", "a{color:red}"},
+		{"a{}/* unterminated", "a{}"},
+		{`a{content:"/**/"}`, `a{content:"/**/"}`},
+	}
+	for _, tc := range tests {
+		if got := string(CSS([]byte(tc.src))); got != tc.want {
+			t.Errorf("CSS(%q) = %q, want %q", tc.src, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+// Site scripts.
+/* A block comment
+   spanning lines. */
+"use strict";
+
+function halve(values) {
+	var out = [];
+	for (var i = 0; i < values.length; i++) {
+		out.push(values[i] / 2); // division after ]
+	}
+	var n = values.length / 2 / 1;   // division after an identifier, twice
+	var m = (n + 1) / 2;             // division after )
+	var k = 0;
+	k++ / 2;                         // division after a postfix ++
+	k-- / 2;                         // division after a postfix --
+	return out;
+}
+
+function matches(s) {
+	if (/^\/\/[a-z]+/i.test(s)) {    // a regular expression after (
+		return /[/*]+"'/.test(s);    // after return, holding a class with / and *
+	}
+	var re = /a\/b/g, other = s ? /x/ : /y/;
+	var kind = typeof /z/;
+	var quoted = "// not a comment", single = 'also /* not */ one';
+	var tpl = `a // b ${s} /* c */`;
+	var f = x => /=>/.test(x);
+	return !/q/.test(s) && re.test(s) || other.test(kind + quoted + single + tpl + f(s));
+}
+
+var total = 1
+/ 2;
+
+function finish() {
+	return /* same line */ 1;
+}
+
+function early() {
+	return /* a comment
+	spanning lines ends the statement */ 1;
+}
@@ -0,0 +1,33 @@
+"use strict";
+function halve(values) {
+var out = [];
+for (var i = 0; i < values.length; i++) {
+out.push(values[i] / 2);
+}
+var n = values.length / 2 / 1;
+var m = (n + 1) / 2;
+var k = 0;
+k++ / 2;
+k-- / 2;
+return out;
+}
+function matches(s) {
+if (/^\/\/[a-z]+/i.test(s)) {
+return /[/*]+"'/.test(s);
+}
+var re = /a\/b/g, other = s ? /x/ : /y/;
+var kind = typeof /z/;
+var quoted = "// not a comment", single = 'also /* not */ one';
+var tpl = `a // b ${s} /* c */`;
+var f = x => /=>/.test(x);
+return !/q/.test(s) && re.test(s) || other.test(kind + quoted + single + tpl + f(s));
+}
+var total = 1
+/ 2;
+function finish() {
+return 1;
+}
+function early() {
+return
+1;
+}
\ No newline at end of file
@@ -0,0 +1,200 @@
+/*
+The offline package generates a service worker which makes a built site readable offline,
+along with an asset manifest listing every file the service worker precaches.
+
+The asset manifest is a JSON file mapping each precached URL to a hash of its content:
+
+	{
+	  "/index.html": "0123456789",
+	  "/theme/site.0123456789.css": "0123456789"
+	}
+
+The service worker embeds the same list.
+Its cache name carries a hash of the whole list, so whenever any page or asset changes,
+browsers install a fresh worker, fetch the new files, and discard the old cache.
+Requests for a directory, such as /articles/1234 or /articles/1234/, are answered with the directory's index.html.
+
+Pages must register the service worker themselves, for instance with:
+
+	<script>
+	if ("serviceWorker" in navigator) { navigator.serviceWorker.register("/sw.js"); }
+	</script>
+*/
+package offline
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/sam-falvo/sitehammer/buildcache"
+	"github.com/sam-falvo/sitehammer/config"
+	"github.com/sam-falvo/sitehammer/directory"
+	"github.com/sam-falvo/sitehammer/dryrun"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// revisionLength gives the number of hexadecimal digits of content hash kept for each file's revision.
+const revisionLength = 10
+
+// Options controls generation of the service worker.
+//
+// SourceDir names the site's source directory, where the cache of content hashes lives.
+// OutputDir names the directory holding the built site, which may be a staging area;
+// DisplayDir names the same directory as the user knows it, for dry-run reports.
+type Options struct {
+	Config     *config.Config
+	SourceDir  string
+	OutputDir  string
+	DisplayDir string
+	DryRun     bool
+}
+
+// Generate writes the service worker and asset manifest into the output directory,
+// precaching each of the named outputs whose extension the configuration lists.
+// It answers the slash-separated names of the two files it's responsible for.
+func Generate(opts Options, outputs map[string]bool) ([]string, error) {
+	cfg := opts.Config.Offline
+	hashes := directory.OpenHashCache(filepath.Join(buildcache.DirIn(opts.SourceDir), buildcache.HashesFilename))
+	hashes.Root = opts.OutputDir
+	revisions := make(map[string]string)
+	for name := range outputs {
+		if name == cfg.ServiceWorker || name == cfg.AssetManifest || !hasExtension(name, cfg.Extensions) {
+			continue
+		}
+		hash, err := hashes.Hash(name)
+		if opts.DryRun && os.IsNotExist(err) {
+			hash, err = buildcache.Hash(nil), nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		revisions["/"+name] = hash[:revisionLength]
+	}
+	if !opts.DryRun {
+		err := hashes.Save()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	manifest, err := json.MarshalIndent(revisions, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	worker, err := serviceWorker(revisions, buildcache.Hash(manifest)[:revisionLength])
+	if err != nil {
+		return nil, err
+	}
+
+	files := []struct {
+		name    string
+		content []byte
+	}{
+		{cfg.AssetManifest, append(manifest, '\n')},
+		{cfg.ServiceWorker, worker},
+	}
+	var produced []string
+	for _, f := range files {
+		produced = append(produced, f.name)
+		if opts.DryRun {
+			dryrun.ReportWriteIfChanged(filepath.Join(opts.DisplayDir, filepath.FromSlash(f.name)), f.content)
+			continue
+		}
+		err = writeFile(opts.Config, filepath.Join(opts.OutputDir, filepath.FromSlash(f.name)), f.content)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return produced, nil
+}
+
+// hasExtension answers true if the name ends with one of the given extensions.
+func hasExtension(name string, extensions []string) bool {
+	ext := strings.ToLower(path.Ext(name))
+	for _, e := range extensions {
+		if ext == strings.ToLower(e) {
+			return true
+		}
+	}
+	return false
+}
+
+// writeFile writes a generated file with the configured permissions, creating directories as needed.
+func writeFile(cfg *config.Config, filename string, content []byte) error {
+	err := directory.EnsureDirAll(filepath.Dir(filename), cfg.Output.DirPerm())
+	if err != nil {
+		return err
+	}
+	perm := cfg.Output.FilePerm(0644)
+	return directory.WriteFileAtomic(filename, content, perm)
+}
+
+// serviceWorker answers the source of a service worker precaching the given URLs, under a cache named for version.
+func serviceWorker(revisions map[string]string, version string) ([]byte, error) {
+	var urls []string
+	for url := range revisions {
+		urls = append(urls, url)
+	}
+	sort.Strings(urls)
+	list, err := json.MarshalIndent(urls, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return []byte(fmt.Sprintf(serviceWorkerSource, version, list)), nil
+}
+
+// serviceWorkerSource is the service worker's source, awaiting its cache version and list of precached URLs.
+const serviceWorkerSource = `// Generated by SiteHammer; do not edit.
+var CACHE = "sitehammer-%s";
+var PRECACHE = %s;
+
+self.addEventListener("install", function (event) {
+  event.waitUntil(caches.open(CACHE).then(function (cache) {
+    return cache.addAll(PRECACHE);
+  }).then(function () {
+    return self.skipWaiting();
+  }));
+});
+
+self.addEventListener("activate", function (event) {
+  event.waitUntil(caches.keys().then(function (names) {
+    return Promise.all(names.filter(function (name) {
+      return name.indexOf("sitehammer-") === 0 && name !== CACHE;
+    }).map(function (name) {
+      return caches.delete(name);
+    }));
+  }).then(function () {
+    return self.clients.claim();
+  }));
+});
+
+// canonical maps a request's path onto the name of the file answering it.
+function canonical(path) {
+  if (path.charAt(path.length - 1) === "/") {
+    return path + "index.html";
+  }
+  if (path.lastIndexOf(".") < path.lastIndexOf("/")) {
+    return path + "/index.html";
+  }
+  return path;
+}
+
+self.addEventListener("fetch", function (event) {
+  var url = new URL(event.request.url);
+  if (event.request.method !== "GET" || url.origin !== self.location.origin) {
+    return;
+  }
+  var path = canonical(url.pathname);
+  if (PRECACHE.indexOf(path) < 0) {
+    return;
+  }
+  event.respondWith(caches.open(CACHE).then(function (cache) {
+    return cache.match(path).then(function (response) {
+      return response || fetch(event.request);
+    });
+  }));
+});
+`
@@ -0,0 +1,457 @@
+/*
+The orgmode package converts Org-mode text, as Emacs writes it, into HTML.
+
+The dialect understood is the part of Org's markup that makes sense in a published article:
+headlines, rendered a level below the article's title, so that * Intro becomes an h2,
+without their TODO keywords, priorities, or tags;
+paragraphs; ordered, unordered, and description lists, nested by indentation;
+source blocks (#+BEGIN_SRC go), example blocks, and fixed-width lines beginning with a colon, all rendered as preformatted code;
+quote and center blocks; export blocks for HTML, passed through as they are;
+tables, whose rows before the first rule become the header; and horizontal rules of five dashes or more.
+Within text, it understands links, [[target][description]] or [[target]], the latter showing an image if its target is one;
+*bold*, /italic/, _underlined_, +struck+, =verbatim=, and ~code~ text; and line breaks (\\ at the end of a line).
+Keywords (#+TITLE:), comments, and drawers, such as :PROPERTIES:, are left out, as are headlines tagged :noexport:.
+*/
+package orgmode
+
+import (
+	"bytes"
+	"html"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// ToHtml converts Org-mode source into an HTML fragment.
+func ToHtml(src []byte) []byte {
+	var out bytes.Buffer
+	text := strings.Replace(string(src), "\r\n", "\n", -1)
+	text = strings.Replace(text, "\t", "        ", -1)
+	renderBlocks(strings.Split(text, "\n"), &out)
+	return out.Bytes()
+}
+
+var (
+	headline   = regexp.MustCompile(`^(\*+)\s+(?:(?:TODO|DONE|NEXT|WAITING|CANCELLED|CANCELED)\s+)?(?:\[#[A-Za-z0-9]\]\s+)?(.*?)(?:\s+(:[\w@#%:]+:))?\s*$`)
+	blockBegin = regexp.MustCompile(`(?i)^\s*#\+begin_(\w+)(?:\s+(.*?))?\s*$`)
+	keyword    = regexp.MustCompile(`^\s*#\+\w+:`)
+	comment    = regexp.MustCompile(`^\s*#(?:\s|$)`)
+	drawer     = regexp.MustCompile(`^\s*:[\w-]+:\s*$`)
+	fixedWidth = regexp.MustCompile(`^\s*:(?:\s|$)`)
+	rule       = regexp.MustCompile(`^\s*-{5,}\s*$`)
+	tableRow   = regexp.MustCompile(`^\s*\|`)
+	listItem   = regexp.MustCompile(`^(\s*)([-+]|\d+[.)])(\s+|$)`)
+	descItem   = regexp.MustCompile(`^(.*?)\s+::(?:\s+|$)`)
+)
+
+func isBlank(line string) bool {
+	return strings.TrimSpace(line) == ""
+}
+
+// startsBlock answers true if the line begins something other than a paragraph, and so ends one.
+func startsBlock(line string) bool {
+	return headline.MatchString(line) || blockBegin.MatchString(line) || keyword.MatchString(line) || comment.MatchString(line) ||
+		drawer.MatchString(line) || fixedWidth.MatchString(line) || rule.MatchString(line) || tableRow.MatchString(line) ||
+		listItem.MatchString(line)
+}
+
+// renderBlocks renders a sequence of lines as block-level elements.
+func renderBlocks(lines []string, out *bytes.Buffer) {
+	i := 0
+	for i < len(lines) {
+		line := lines[i]
+		switch {
+		case isBlank(line):
+			i++
+		case headline.MatchString(line):
+			i = heading(lines, i, out)
+		case blockBegin.MatchString(line):
+			i = block(lines, i, out)
+		case keyword.MatchString(line) || comment.MatchString(line):
+			i++
+		case drawer.MatchString(line):
+			i = skipDrawer(lines, i, out)
+		case fixedWidth.MatchString(line):
+			i = fixedWidthBlock(lines, i, out)
+		case rule.MatchString(line):
+			out.WriteString("<hr />\n")
+			i++
+		case tableRow.MatchString(line):
+			i = table(lines, i, out)
+		case listItem.MatchString(line):
+			i = list(lines, i, out)
+		default:
+			i = paragraph(lines, i, out)
+		}
+	}
+}
+
+// heading renders the headline at lines[i], unless it's tagged :noexport:, in which case it's skipped along with its subtree.
+func heading(lines []string, i int, out *bytes.Buffer) int {
+	m := headline.FindStringSubmatch(lines[i])
+	level := len(m[1])
+	if strings.Contains(m[3], ":noexport:") {
+		for i++; i < len(lines); i++ {
+			if n := headline.FindStringSubmatch(lines[i]); n != nil && len(n[1]) <= level {
+				break
+			}
+		}
+		return i
+	}
+	tag := string('0' + byte(min(level+1, 6)))
+	out.WriteString("<h" + tag + ">")
+	renderInline(m[2], out)
+	out.WriteString("</h" + tag + ">\n")
+	return i + 1
+}
+
+// block renders the #+BEGIN_ block beginning at lines[i], up to its #+END_ line.
+func block(lines []string, i int, out *bytes.Buffer) int {
+	m := blockBegin.FindStringSubmatch(lines[i])
+	kind, params := strings.ToLower(m[1]), strings.Fields(m[2])
+	end := "#+end_" + kind
+	var inner []string
+	for i++; i < len(lines) && strings.ToLower(strings.TrimSpace(lines[i])) != end; i++ {
+		inner = append(inner, lines[i])
+	}
+	if i < len(lines) {
+		i++
+	}
+	switch kind {
+	case "src":
+		if len(params) > 0 {
+			out.WriteString(`<pre><code class="language-` + html.EscapeString(params[0]) + `">`)
+		} else {
+			out.WriteString("<pre><code>")
+		}
+		writeCode(unindent(inner), out)
+	case "example":
+		out.WriteString("<pre><code>")
+		writeCode(unindent(inner), out)
+	case "quote":
+		out.WriteString("<blockquote>\n")
+		renderBlocks(inner, out)
+		out.WriteString("</blockquote>\n")
+	case "center":
+		out.WriteString(`<div style="text-align: center">` + "\n")
+		renderBlocks(inner, out)
+		out.WriteString("</div>\n")
+	case "export":
+		if len(params) > 0 && strings.ToLower(params[0]) == "html" {
+			out.WriteString(strings.Join(inner, "\n") + "\n")
+		}
+	case "comment":
+	default:
+		renderBlocks(inner, out)
+	}
+	return i
+}
+
+// writeCode writes lines of code, escaped, closing the pre and code elements a caller opened.
+// Org escapes lines beginning with * or #+ within blocks with a leading comma, which is removed.
+func writeCode(lines []string, out *bytes.Buffer) {
+	for _, line := range lines {
+		if strings.HasPrefix(line, ",*") || strings.HasPrefix(line, ",#+") {
+			line = line[1:]
+		}
+		out.WriteString(html.EscapeString(line) + "\n")
+	}
+	out.WriteString("</code></pre>\n")
+}
+
+// unindent removes the indentation common to every nonblank line.
+func unindent(lines []string) []string {
+	common := -1
+	for _, line := range lines {
+		if !isBlank(line) && (common < 0 || leadingSpaces(line) < common) {
+			common = leadingSpaces(line)
+		}
+	}
+	out := make([]string, len(lines))
+	for i, line := range lines {
+		if len(line) >= common && common > 0 {
+			line = line[common:]
+		}
+		out[i] = strings.TrimRight(line, " ")
+	}
+	return out
+}
+
+// skipDrawer skips the drawer beginning at lines[i], up to its :END: line.
+// A line that merely looks like a drawer's start, with no :END: following, is taken as a paragraph instead.
+func skipDrawer(lines []string, i int, out *bytes.Buffer) int {
+	for j := i + 1; j < len(lines); j++ {
+		if strings.ToUpper(strings.TrimSpace(lines[j])) == ":END:" {
+			return j + 1
+		}
+		if headline.MatchString(lines[j]) {
+			break
+		}
+	}
+	return paragraph(lines, i, out)
+}
+
+func fixedWidthBlock(lines []string, i int, out *bytes.Buffer) int {
+	var code []string
+	for ; i < len(lines) && fixedWidth.MatchString(lines[i]); i++ {
+		line := strings.TrimLeft(lines[i], " ")[1:]
+		code = append(code, strings.TrimPrefix(line, " "))
+	}
+	out.WriteString("<pre><code>")
+	writeCode(code, out)
+	return i
+}
+
+func paragraph(lines []string, i int, out *bytes.Buffer) int {
+	var text []string
+	for i < len(lines) && !isBlank(lines[i]) && (len(text) == 0 || !startsBlock(lines[i])) {
+		text = append(text, strings.TrimSpace(lines[i]))
+		i++
+	}
+	out.WriteString("<p>")
+	renderInline(strings.Join(text, "\n"), out)
+	out.WriteString("</p>\n")
+	return i
+}
+
+// table renders the table beginning at lines[i].
+// Rules (|---+---|) divide its rows into groups; if there's more than one, the first is the table's header.
+func table(lines []string, i int, out *bytes.Buffer) int {
+	var groups [][][]string
+	var group [][]string
+	for ; i < len(lines) && tableRow.MatchString(lines[i]); i++ {
+		row := strings.TrimSpace(lines[i])
+		if strings.HasPrefix(row, "|-") {
+			if len(group) > 0 {
+				groups = append(groups, group)
+				group = nil
+			}
+			continue
+		}
+		row = strings.TrimSuffix(strings.TrimPrefix(row, "|"), "|")
+		cells := strings.Split(row, "|")
+		for c := range cells {
+			cells[c] = strings.TrimSpace(cells[c])
+		}
+		group = append(group, cells)
+	}
+	if len(group) > 0 {
+		groups = append(groups, group)
+	}
+
+	out.WriteString("<table>\n")
+	for g, rows := range groups {
+		section, tag := "tbody", "td"
+		if g == 0 && len(groups) > 1 {
+			section, tag = "thead", "th"
+		}
+		out.WriteString("<" + section + ">\n")
+		for _, cells := range rows {
+			out.WriteString("<tr>\n")
+			for _, cell := range cells {
+				out.WriteString("<" + tag + ">")
+				renderInline(cell, out)
+				out.WriteString("</" + tag + ">\n")
+			}
+			out.WriteString("</tr>\n")
+		}
+		out.WriteString("</" + section + ">\n")
+	}
+	out.WriteString("</table>\n")
+	return i
+}
+
+// list renders the list beginning at lines[i]: its items, each running until the next line indented no further than its bullet.
+// A list whose first item has a term followed by :: is a description list.
+func list(lines []string, i int, out *bytes.Buffer) int {
+	m := listItem.FindStringSubmatch(lines[i])
+	indent := len(m[1])
+	ordered := isOrdered(m[2])
+	first := lines[i][len(m[0]):]
+	description := !ordered && descItem.MatchString(first)
+	kind := "ul"
+	switch {
+	case ordered:
+		kind = "ol"
+	case description:
+		kind = "dl"
+	}
+	out.WriteString("<" + kind + ">\n")
+	for i < len(lines) {
+		m := listItem.FindStringSubmatch(lines[i])
+		if m == nil || len(m[1]) != indent || isOrdered(m[2]) != ordered {
+			break
+		}
+		item := []string{lines[i][len(m[0]):]}
+		contentIndent := len(m[0])
+		i++
+		for i < len(lines) {
+			if isBlank(lines[i]) {
+				j := i
+				for j < len(lines) && isBlank(lines[j]) {
+					j++
+				}
+				if j-i > 1 || j == len(lines) || leadingSpaces(lines[j]) <= indent {
+					break
+				}
+				item = append(item, "")
+				i++
+				continue
+			}
+			if leadingSpaces(lines[i]) <= indent {
+				break
+			}
+			line := lines[i]
+			if leadingSpaces(line) >= contentIndent {
+				line = line[contentIndent:]
+			} else {
+				line = strings.TrimLeft(line, " ")
+			}
+			item = append(item, line)
+			i++
+		}
+		if description {
+			term := ""
+			if d := descItem.FindStringSubmatch(item[0]); d != nil {
+				term = d[1]
+				item[0] = item[0][len(d[0]):]
+			}
+			out.WriteString("<dt>")
+			renderInline(term, out)
+			out.WriteString("</dt>\n<dd>" + renderItem(item) + "</dd>\n")
+		} else {
+			out.WriteString("<li>" + renderItem(item) + "</li>\n")
+		}
+		// A single blank line between items keeps the list going.
+		if i+1 < len(lines) && isBlank(lines[i]) && !isBlank(lines[i+1]) {
+			if m := listItem.FindStringSubmatch(lines[i+1]); m != nil && len(m[1]) == indent && isOrdered(m[2]) == ordered {
+				i++
+			}
+		}
+	}
+	out.WriteString("</" + kind + ">\n")
+	return i
+}
+
+// isOrdered answers true if a list item's bullet is a number, making its list ordered.
+func isOrdered(bullet string) bool {
+	return bullet != "-" && bullet != "+"
+}
+
+// renderItem renders the content of a list item; a lone paragraph, perhaps followed by a nested list, loses its tags.
+func renderItem(item []string) string {
+	var content bytes.Buffer
+	renderBlocks(item, &content)
+	rendered := strings.TrimSuffix(content.String(), "\n")
+	if strings.HasPrefix(rendered, "<p>") && strings.Count(rendered, "<p>") == 1 {
+		rendered = strings.Replace(rendered[len("<p>"):], "</p>", "", 1)
+	}
+	return rendered
+}
+
+func leadingSpaces(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " "))
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+var (
+	link        = regexp.MustCompile(`^\[\[((?:[^\]\\]|\\.)+)\](?:\[(.+?)\])?\]`)
+	imageTarget = regexp.MustCompile(`(?i)\.(?:png|jpe?g|gif|svg|webp)$`)
+	markers     = map[byte]string{'*': "strong", '/': "em", '_': "u", '+': "del", '=': "code", '~': "code"}
+)
+
+// renderInline renders the text within a block, interpreting inline markup.
+func renderInline(s string, out *bytes.Buffer) {
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == '[' && link.MatchString(s[i:]):
+			m := link.FindStringSubmatch(s[i:])
+			renderLink(m[1], m[2], out)
+			i += len(m[0])
+
+		case c == '\\' && strings.HasPrefix(s[i:], "\\\\") && (i+2 == len(s) || s[i+2] == '\n'):
+			out.WriteString("<br />")
+			i += 2
+
+		case markers[c] != "" && opensEmphasis(s, i):
+			if end := closingMarker(s, i); end > 0 {
+				tag := markers[c]
+				out.WriteString("<" + tag + ">")
+				if tag == "code" {
+					out.WriteString(html.EscapeString(s[i+1 : end]))
+				} else {
+					renderInline(s[i+1:end], out)
+				}
+				out.WriteString("</" + tag + ">")
+				i = end + 1
+			} else {
+				out.WriteString(html.EscapeString(s[i : i+1]))
+				i++
+			}
+
+		default:
+			out.WriteString(html.EscapeString(s[i : i+1]))
+			i++
+		}
+	}
+}
+
+// renderLink renders a link to target, showing description, or if it has none, the target itself,
+// or the image the target names.
+func renderLink(target, description string, out *bytes.Buffer) {
+	target = strings.Replace(target, `\]`, "]", -1)
+	href := strings.TrimPrefix(target, "file:")
+	if strings.HasSuffix(href, ".org") && !strings.Contains(href, "://") {
+		href = strings.TrimSuffix(href, ".org") + ".html"
+	}
+	if description == "" && imageTarget.MatchString(href) {
+		out.WriteString(`<img src="` + html.EscapeString(href) + `" alt="` + html.EscapeString(path.Base(href)) + `" />`)
+		return
+	}
+	out.WriteString(`<a href="` + html.EscapeString(href) + `">`)
+	if description == "" {
+		out.WriteString(html.EscapeString(target))
+	} else if imageTarget.MatchString(description) && !strings.ContainsAny(description, " \n") {
+		out.WriteString(`<img src="` + html.EscapeString(strings.TrimPrefix(description, "file:")) + `" alt="" />`)
+	} else {
+		renderInline(description, out)
+	}
+	out.WriteString("</a>")
+}
+
+// opensEmphasis answers true if the marker at s[i] may open emphasis:
+// it follows the start of the text, whitespace, or opening punctuation, and precedes something other than whitespace.
+func opensEmphasis(s string, i int) bool {
+	if i > 0 && !strings.ContainsRune(" \t\n-({'\"", rune(s[i-1])) {
+		return false
+	}
+	return i+1 < len(s) && !strings.ContainsRune(" \t\n", rune(s[i+1]))
+}
+
+// closingMarker finds the marker closing the emphasis opened at s[i], within three lines:
+// one preceded by something other than whitespace, and followed by the end of the text, whitespace, or closing punctuation.
+func closingMarker(s string, i int) int {
+	c := s[i]
+	newlines := 0
+	for j := i + 2; j < len(s); j++ {
+		if s[j] == '\n' {
+			if newlines++; newlines > 2 {
+				return -1
+			}
+		}
+		if s[j] != c || strings.ContainsRune(" \t\n", rune(s[j-1])) {
+			continue
+		}
+		if j+1 == len(s) || strings.ContainsRune(" \t\n-.,;:!?'\")}[", rune(s[j+1])) {
+			return j
+		}
+	}
+	return -1
+}
@@ -0,0 +1,217 @@
+/*
+The precompress package writes compressed variants of a build's outputs alongside the originals,
+so web servers configured for static precompression (nginx's gzip_static and brotli_static, for instance)
+can serve them without compressing anything on the fly.
+
+For each compressible output, such as about.html, a gzip variant (about.html.gz) and a brotli variant (about.html.br) may be written.
+Gzip compression happens in-process; brotli compression runs the configured brotli command.
+A variant is written only if it's actually smaller than the original; otherwise, any stale variant is removed.
+Variants are remembered in .sitehammer-cache/precompress.json, so an output which hasn't changed isn't compressed again;
+the outputs' content hashes are remembered in .sitehammer-cache/hashes.json, so an unchanged output isn't even read.
+*/
+package precompress
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"github.com/sam-falvo/sitehammer/buildcache"
+	"github.com/sam-falvo/sitehammer/config"
+	"github.com/sam-falvo/sitehammer/directory"
+	"github.com/sam-falvo/sitehammer/dryrun"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// cacheFilename names the precompression cache, relative to the cache directory.
+const cacheFilename = "precompress.json"
+
+// Extensions of the variants, each naming the encoding used to produce it.
+const (
+	GzipExt   = ".gz"
+	BrotliExt = ".br"
+)
+
+// Options controls precompression.
+//
+// Config supplies the compress table's settings, along with the output permissions and mtime policy.
+// SourceDir names the site's source directory, where the cache lives.
+// OutputDir names the directory holding the outputs, which may be a staging area;
+// DisplayDir names the same directory as the user knows it, for dry-run reports.
+type Options struct {
+	Config     *config.Config
+	SourceDir  string
+	OutputDir  string
+	DisplayDir string
+	DryRun     bool
+}
+
+// compressor produces one kind of variant.
+type compressor struct {
+	ext      string
+	compress func(data []byte) ([]byte, error)
+}
+
+// Variants compresses each of the named outputs, slash-separated and relative to the output directory,
+// whose extension is configured as compressible.
+// It answers the names of the variants the build is responsible for, whether or not they were rewritten,
+// each mapped to the name of the output it's a variant of.
+func Variants(opts Options, outputs map[string]bool) (map[string]string, error) {
+	cfg := opts.Config.Compress
+	var compressors []compressor
+	if cfg.Gzip {
+		compressors = append(compressors, compressor{GzipExt, gzipBytes})
+	}
+	if cfg.Brotli {
+		compressors = append(compressors, compressor{BrotliExt, func(data []byte) ([]byte, error) {
+			return brotliBytes(cfg.BrotliCommand, data)
+		}})
+	}
+	variants := make(map[string]string)
+	if len(compressors) == 0 {
+		return variants, nil
+	}
+
+	cache := buildcache.Open(filepath.Join(buildcache.DirIn(opts.SourceDir), cacheFilename))
+	cache.Root = opts.OutputDir
+	hashes := directory.OpenHashCache(filepath.Join(buildcache.DirIn(opts.SourceDir), buildcache.HashesFilename))
+	hashes.Root = opts.OutputDir
+	var names []string
+	for name := range outputs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if !isCompressible(name, cfg.Extensions) {
+			continue
+		}
+		filename := filepath.Join(opts.OutputDir, filepath.FromSlash(name))
+		fi, err := os.Lstat(filename)
+		if opts.DryRun && os.IsNotExist(err) {
+			// The output would have been created; so would its variants.
+			for _, c := range compressors {
+				variants[name+c.ext] = name
+				dryrun.Report(dryrun.Create, filepath.Join(opts.DisplayDir, filepath.FromSlash(name+c.ext)), dryrun.New)
+			}
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !fi.Mode().IsRegular() {
+			continue
+		}
+		hash, err := hashes.Hash(name)
+		if err != nil {
+			return nil, err
+		}
+		var data []byte
+		for _, c := range compressors {
+			variant := name + c.ext
+			signature := buildcache.Signature(hash, c.ext)
+			if cache.Fresh(variant, signature) {
+				variants[variant] = name
+				continue
+			}
+			if data == nil {
+				data, err = ioutil.ReadFile(filename)
+				if err != nil {
+					return nil, err
+				}
+			}
+			compressed, err := c.compress(data)
+			if err != nil {
+				return nil, fmt.Errorf("Cannot compress %s: %s", name, err.Error())
+			}
+			if len(compressed) >= len(data) {
+				// A variant left over from an earlier build would now be stale.
+				cache.Forget(variant)
+				if !opts.DryRun {
+					err = os.Remove(filename + c.ext)
+					if err != nil && !os.IsNotExist(err) {
+						return nil, err
+					}
+				}
+				continue
+			}
+			variants[variant] = name
+			if opts.DryRun {
+				dryrun.ReportWrite(filepath.Join(opts.DisplayDir, filepath.FromSlash(variant)))
+				continue
+			}
+			err = writeVariant(opts.Config, filename+c.ext, compressed, fi)
+			if err != nil {
+				cache.Forget(variant)
+				return nil, err
+			}
+			cache.Record(variant, signature)
+		}
+	}
+	if opts.DryRun {
+		return variants, nil
+	}
+	err := hashes.Save()
+	if err != nil {
+		return nil, err
+	}
+	return variants, cache.Save()
+}
+
+// isCompressible answers true if the name ends with one of the given extensions.
+func isCompressible(name string, extensions []string) bool {
+	ext := strings.ToLower(path.Ext(name))
+	for _, e := range extensions {
+		if ext == strings.ToLower(e) {
+			return true
+		}
+	}
+	return false
+}
+
+// writeVariant writes a compressed variant with the same permissions as the original output,
+// and, if so configured, the same modification time.
+func writeVariant(cfg *config.Config, filename string, data []byte, original os.FileInfo) error {
+	perm := original.Mode().Perm()
+	err := directory.WriteFileAtomic(filename, data, perm)
+	if err == nil && cfg.Output.PreserveMtimes {
+		err = os.Chtimes(filename, time.Now(), original.ModTime())
+	}
+	return err
+}
+
+// gzipBytes compresses data with gzip at the best compression level, since it's done once and served many times.
+func gzipBytes(data []byte) ([]byte, error) {
+	var out bytes.Buffer
+	w, err := gzip.NewWriterLevel(&out, gzip.BestCompression)
+	if err != nil {
+		return nil, err
+	}
+	_, err = w.Write(data)
+	if err == nil {
+		err = w.Close()
+	}
+	return out.Bytes(), err
+}
+
+// brotliBytes compresses data by running the given brotli command, which must read standard input and write standard output.
+func brotliBytes(command string, data []byte) ([]byte, error) {
+	args := strings.Fields(command)
+	if len(args) == 0 {
+		return nil, fmt.Errorf("No brotli command is configured.")
+	}
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin = bytes.NewReader(data)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("%s %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
@@ -0,0 +1,91 @@
+package precompress
+
+import (
+	"bytes"
+	"compress/gzip"
+	"github.com/sam-falvo/sitehammer/buildcache"
+	"github.com/sam-falvo/sitehammer/config"
+	"github.com/sam-falvo/sitehammer/static"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// gunzip answers the decompressed content of the named gzip file.
+func gunzip(t *testing.T, filename string) string {
+	t.Helper()
+	raw, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := gzip.NewReader(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(content)
+}
+
+// TestVariantsFollowRewrittenOutputs checks that an output rewritten with content of the same size,
+// and given back its source's modification time, as preserve_mtimes does, is compressed again rather than taken as unchanged.
+// Here, editing the layout of a Markdown page rewrites the page, which keeps the modification time of its Markdown.
+func TestVariantsFollowRewrittenOutputs(t *testing.T) {
+	dir := t.TempDir()
+	defer func(saved string) { buildcache.Dir = saved }(buildcache.Dir)
+	buildcache.Dir = filepath.Join(dir, "cache")
+	cfg := config.Default()
+	cfg.Output.PreserveMtimes = true
+	cfg.Compress.Gzip = true
+	cfg.Markdown.Layout = "_layout.html"
+	sourceDir, outputDir := filepath.Join(dir, "src"), filepath.Join(dir, "_site")
+	if err := os.MkdirAll(sourceDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	page := filepath.Join(sourceDir, "page.md")
+	if err := ioutil.WriteFile(page, []byte(strings.Repeat("Some text worth compressing.\n\n", 20)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// Old enough that the hash cache remembers the output, which takes the page's modification time.
+	mtime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	if err := os.Chtimes(page, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+	output := filepath.Join(outputDir, "page.html")
+
+	for _, class := range []string{"aaaaaaaa", "bbbbbbbb"} {
+		layout := `<div class="` + class + `">{{.Content}}</div>`
+		if err := ioutil.WriteFile(filepath.Join(sourceDir, cfg.Markdown.Layout), []byte(layout), 0644); err != nil {
+			t.Fatal(err)
+		}
+		result, err := static.Build(static.Options{Config: cfg, SourceDir: sourceDir, OutputDir: outputDir, Symlinks: config.SymlinksFollow})
+		if err != nil {
+			t.Fatalf("static.Build: %v", err)
+		}
+		if err := result.Save(nil); err != nil {
+			t.Fatal(err)
+		}
+		content, err := ioutil.ReadFile(output)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(content), class) {
+			t.Fatalf("with class %s, the page holds\n%s", class, content)
+		}
+		if fi, err := os.Stat(output); err != nil || !fi.ModTime().Equal(mtime) {
+			t.Fatalf("the page's modification time = %v, %v; want %v", fi.ModTime(), err, mtime)
+		}
+		_, err = Variants(Options{Config: cfg, SourceDir: sourceDir, OutputDir: outputDir}, result.Produced)
+		if err != nil {
+			t.Fatalf("Variants: %v", err)
+		}
+		if got := gunzip(t, output+GzipExt); got != string(content) {
+			t.Fatalf("with class %s, the gzip variant holds\n%s\nwant\n%s", class, got, content)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+/*
+The proof command proofreads the blog's articles, reporting misspellings and violations of the site's style rules.
+
+USAGE: proof [descs.json]
+
+Proof reads the abstract and body of every article the descriptor file describes
+(the configured descriptor file, unless one is named on the command line),
+strips them of markup, and checks what remains; see the proofread package.
+Each problem is reported with the article's ID and the line of the offending file:
+
+	article 1234 (src/1234/body:12): misspelled "recieve"
+	article 1234 (src/1234/body:40): "very unique": unique admits no degrees
+
+The spellchecker, custom dictionary, and style rules come from the proofread table of sitehammer.toml;
+see the config package.
+Proof exits with status 1 if it found any problems.
+*/
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/sam-falvo/sitehammer/config"
+	"github.com/sam-falvo/sitehammer/proofread"
+	"github.com/sam-falvo/sitehammer/weblog"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+// abend abnormally ends the program, usually as a result of some blocking error.
+// The specified diagnostic is printed before terminating the program.
+// The program stops with shell result code 1.
+func abend(reason error) {
+	if reason != nil {
+		fmt.Println(reason)
+		os.Exit(1)
+	}
+}
+
+// proofreaderFor configures a proofreader according to the site's configuration.
+func proofreaderFor(cfg *config.Config) (*proofread.Proofreader, error) {
+	dictionary, err := proofread.LoadDictionary(cfg.Proofread.Dictionary)
+	if err != nil {
+		return nil, err
+	}
+	p := &proofread.Proofreader{Command: cfg.Proofread.Spellchecker, Dictionary: dictionary}
+	for _, r := range cfg.Proofread.Rules {
+		p.Rules = append(p.Rules, proofread.Rule{Pattern: regexp.MustCompile(r.Pattern), Message: r.Message})
+	}
+	return p, nil
+}
+
+func main() {
+	cfg, err := config.Load(config.Find())
+	abend(err)
+	flag.Parse()
+	descriptorFile := cfg.Blog.Descriptors
+	if flag.NArg() > 0 {
+		descriptorFile = flag.Arg(0)
+	}
+
+	descriptors, err := weblog.LoadDescriptors(descriptorFile)
+	abend(err)
+	p, err := proofreaderFor(cfg)
+	abend(err)
+
+	count := 0
+	for _, d := range descriptors {
+		for _, kind := range append([]string{"abstract"}, weblog.BodyFilenames(cfg)...) {
+			filename := filepath.Join(filepath.FromSlash(cfg.Blog.Sources), fmt.Sprint(d.Id), kind)
+			content, err := ioutil.ReadFile(filename)
+			if os.IsNotExist(err) && kind != "abstract" {
+				continue
+			}
+			abend(err)
+			problems, err := p.Check(proofread.StripMarkup(content))
+			abend(err)
+			for _, problem := range problems {
+				fmt.Printf("article %d (%s:%d): %s\n", d.Id, filename, problem.Line, problem.Message)
+				count++
+			}
+		}
+	}
+	if count > 0 {
+		abend(fmt.Errorf("%d problem(s) found.", count))
+	}
+}
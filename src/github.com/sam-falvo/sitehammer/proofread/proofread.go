@@ -0,0 +1,144 @@
+/*
+The proofread package looks for typos and stylistic slips in article text.
+
+Text is first stripped of markup, leaving line numbers intact.
+Code, preformatted text, scripts, and stylesheets are dropped entirely, since they aren't prose.
+Spelling is checked by an external spellchecker, such as aspell or hunspell,
+which must read text on standard input and list the words it doesn't recognize on standard output,
+as "aspell list" and "hunspell -l" do.
+Words in the site's custom dictionary are never reported.
+
+Style rules are regular expressions, each paired with a message explaining what's wrong with the text it matches.
+Doubled words, as in "the the", are always reported.
+*/
+package proofread
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"html"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+)
+
+// Problem describes one problem found in a text.
+type Problem struct {
+	Line    int
+	Message string
+}
+
+// Rule describes a style rule: text matching Pattern gets Message.
+type Rule struct {
+	Pattern *regexp.Regexp
+	Message string
+}
+
+// Proofreader checks texts for misspellings and violations of its style rules.
+// Command holds the spellchecker's command line; if empty, spelling isn't checked.
+type Proofreader struct {
+	Command    string
+	Dictionary map[string]bool
+	Rules      []Rule
+}
+
+var (
+	nonProse = regexp.MustCompile(`(?is)<(script|style|pre|code)\b.*?</(script|style|pre|code)\s*>`)
+	markup   = regexp.MustCompile(`(?s)<!--.*?-->|<[^>]*>`)
+	word     = regexp.MustCompile(`[\p{L}']+`)
+)
+
+// StripMarkup removes HTML markup from content, along with code, scripts, and stylesheets,
+// replacing what's removed with spaces so the lines of the text remaining correspond to those of the original.
+// Entities are decoded.
+func StripMarkup(content []byte) string {
+	blank := func(s []byte) []byte {
+		return bytes.Map(func(r rune) rune {
+			if r == '\n' {
+				return r
+			}
+			return ' '
+		}, s)
+	}
+	content = nonProse.ReplaceAllFunc(content, blank)
+	content = markup.ReplaceAllFunc(content, blank)
+	return html.UnescapeString(string(content))
+}
+
+// LoadDictionary reads a custom dictionary: a file listing one word per line.
+// Blank lines and lines beginning with # are ignored.
+// A missing dictionary isn't an error; it's simply empty.
+func LoadDictionary(filename string) (map[string]bool, error) {
+	words := make(map[string]bool)
+	raw, err := ioutil.ReadFile(filename)
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+		}
+		return words, err
+	}
+	for _, line := range strings.Split(string(raw), "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") {
+			words[strings.ToLower(line)] = true
+		}
+	}
+	return words, nil
+}
+
+// Check proofreads text, answering the problems found in order of line.
+func (p *Proofreader) Check(text string) ([]Problem, error) {
+	var problems []Problem
+	misspelled, err := p.misspellings(text)
+	if err != nil {
+		return nil, err
+	}
+	for i, line := range strings.Split(text, "\n") {
+		n := i + 1
+		previous := ""
+		previousEnd := 0
+		for _, loc := range word.FindAllStringIndex(line, -1) {
+			w := strings.Trim(line[loc[0]:loc[1]], "'")
+			if misspelled[w] && !p.Dictionary[strings.ToLower(w)] {
+				problems = append(problems, Problem{n, fmt.Sprintf("misspelled %q", w)})
+			}
+			if strings.EqualFold(w, previous) && strings.TrimSpace(line[previousEnd:loc[0]]) == "" {
+				problems = append(problems, Problem{n, fmt.Sprintf("doubled word %q", w)})
+			}
+			previous, previousEnd = w, loc[1]
+		}
+		for _, r := range p.Rules {
+			for _, m := range r.Pattern.FindAllString(line, -1) {
+				problems = append(problems, Problem{n, fmt.Sprintf("%q: %s", m, r.Message)})
+			}
+		}
+	}
+	return problems, nil
+}
+
+// misspellings runs the spellchecker over text, answering the set of words it doesn't recognize.
+func (p *Proofreader) misspellings(text string) (map[string]bool, error) {
+	words := make(map[string]bool)
+	args := strings.Fields(p.Command)
+	if len(args) == 0 {
+		return words, nil
+	}
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin = strings.NewReader(text)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("Cannot run spellchecker %s: %s %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		if w := strings.TrimSpace(scanner.Text()); w != "" {
+			words[w] = true
+		}
+	}
+	return words, scanner.Err()
+}
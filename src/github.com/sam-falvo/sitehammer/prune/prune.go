@@ -0,0 +1,123 @@
+/*
+The prune package removes orphaned outputs:
+files left behind in an output directory by earlier builds, which no current source produces any longer.
+*/
+package prune
+
+import (
+	"fmt"
+	"github.com/sam-falvo/sitehammer/dryrun"
+	"github.com/sam-falvo/sitehammer/report"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// Orphans removes every file beneath root whose slash-separated path, relative to root, isn't a key in keep.
+// Directories left empty as a result are removed as well.
+// If listOnly is true, nothing is removed; the orphans, and the directories they'd leave empty, are merely reported, dry-run style.
+//
+// Messages name orphans relative to displayRoot rather than root,
+// so that a build happening in a staging area reports the paths the user knows.
+// The slash-separated relative paths of the orphans are returned.
+func Orphans(root, displayRoot string, keep map[string]bool, listOnly bool) ([]string, error) {
+	var orphans []string
+	_, err := orphansIn(root, displayRoot, "", keep, listOnly, &orphans)
+	return orphans, err
+}
+
+// orphansIn removes the orphans of the directory named by prefix, relative to root, and those of its subdirectories,
+// answering true if anything is left in the directory afterwards (or would be, if listOnly is true).
+func orphansIn(root, displayRoot, prefix string, keep map[string]bool, listOnly bool, orphans *[]string) (kept bool, err error) {
+	entries, err := ioutil.ReadDir(filepath.Join(root, filepath.FromSlash(prefix)))
+	if err != nil {
+		return false, err
+	}
+	for _, e := range entries {
+		name := prefix + e.Name()
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if e.IsDir() {
+			subKept, err := orphansIn(root, displayRoot, name+"/", keep, listOnly, orphans)
+			switch {
+			case err != nil:
+				return false, err
+			case subKept:
+				kept = true
+			case listOnly:
+				dryrun.Report(dryrun.Remove, filepath.Join(displayRoot, filepath.FromSlash(name)), dryrun.Empty)
+			default:
+				err = removeIfEmpty(path)
+				if err != nil {
+					return false, err
+				}
+			}
+			continue
+		}
+		if keep[name] {
+			kept = true
+			continue
+		}
+		*orphans = append(*orphans, name)
+		displayName := filepath.Join(displayRoot, filepath.FromSlash(name))
+		if listOnly {
+			dryrun.Report(dryrun.Remove, displayName, dryrun.Orphaned)
+			continue
+		}
+		report.Event("remove", fmt.Sprintf("removing %s (orphaned)", displayName), report.Fields{"path": displayName, "reason": dryrun.Orphaned})
+		err = os.Remove(path)
+		if err != nil {
+			return false, err
+		}
+	}
+	return kept, nil
+}
+
+// Outputs removes the files beneath root named, by their slash-separated paths relative to root, in outputs;
+// these are typically the outputs of an earlier build, as recorded in its metadata.
+// Directories left empty as a result are removed as well, up to but not including root.
+// Files which no longer exist are passed over silently.
+// If listOnly is true, nothing is removed; the outputs are merely reported, dry-run style.
+// The slash-separated relative paths of the files removed are returned, in order.
+func Outputs(root, displayRoot string, outputs map[string]bool, listOnly bool) ([]string, error) {
+	var names []string
+	for name := range outputs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var removed []string
+	for _, name := range names {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if _, err := os.Lstat(path); os.IsNotExist(err) {
+			continue
+		}
+		removed = append(removed, name)
+		displayName := filepath.Join(displayRoot, filepath.FromSlash(name))
+		if listOnly {
+			dryrun.Report(dryrun.Remove, displayName, dryrun.Generated)
+			continue
+		}
+		report.Event("remove", fmt.Sprintf("removing %s (generated)", displayName), report.Fields{"path": displayName, "reason": dryrun.Generated})
+		err := os.Remove(path)
+		if err != nil {
+			return removed, err
+		}
+		for dir := filepath.Dir(path); dir != filepath.Clean(root) && dir != "." && dir != string(filepath.Separator); dir = filepath.Dir(dir) {
+			err = removeIfEmpty(dir)
+			if err != nil {
+				return removed, err
+			}
+		}
+	}
+	return removed, nil
+}
+
+// removeIfEmpty removes the named directory, provided it has nothing in it.
+func removeIfEmpty(dir string) error {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil || len(entries) > 0 {
+		return err
+	}
+	return os.Remove(dir)
+}
@@ -0,0 +1,96 @@
+package report
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Details describes an error in the particulars a readable diagnostic shows:
+// the Subject it concerns, such as "article 1234"; the Field at fault, if any;
+// the Problem with it; and a Suggestion for putting it right, if there is one.
+type Details struct {
+	Subject    string
+	Field      string
+	Problem    string
+	Suggestion string
+}
+
+// Detailed errors can describe themselves in Details.
+type Detailed interface {
+	error
+	Details() Details
+}
+
+// ANSI escape sequences used to color text reports.
+const (
+	red    = "\x1b[1;31m"
+	yellow = "\x1b[1;33m"
+	bold   = "\x1b[1m"
+	cyan   = "\x1b[36m"
+	reset  = "\x1b[0m"
+)
+
+// IsTerminal answers true if f is a terminal, rather than, say, a file or a pipe.
+func IsTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// colorful answers true if text reports written to f should be colored:
+// if f is a terminal, and the user hasn't asked for plain text by setting NO_COLOR or TERM=dumb.
+func colorful(f *os.File) bool {
+	if _, set := os.LookupEnv("NO_COLOR"); set || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	return IsTerminal(f)
+}
+
+// paint answers s in the given color, if color is wanted on f, or plain otherwise.
+func paint(f *os.File, color, s string) string {
+	if !colorful(f) {
+		return s
+	}
+	return color + s + reset
+}
+
+// renderErrors describes the errors stopping a command for a person reading f:
+// each error follows a red "error:" label, except that Detailed errors concerning the same subject
+// are gathered into one block, listing the field, problem, and suggestion of each.
+// Errors with no details are shown as they are.
+func renderErrors(f *os.File, errors []error) string {
+	var b strings.Builder
+	if len(errors) > 1 {
+		fmt.Fprintf(&b, "%s %d errors\n", paint(f, red, "error:"), len(errors))
+	}
+
+	var subjects []string
+	bySubject := make(map[string][]Details)
+	for _, err := range errors {
+		d, ok := err.(Detailed)
+		if !ok {
+			fmt.Fprintf(&b, "%s %s\n", paint(f, red, "error:"), err.Error())
+			continue
+		}
+		details := d.Details()
+		if _, seen := bySubject[details.Subject]; !seen {
+			subjects = append(subjects, details.Subject)
+		}
+		bySubject[details.Subject] = append(bySubject[details.Subject], details)
+	}
+
+	for _, subject := range subjects {
+		fmt.Fprintf(&b, "%s %s\n", paint(f, red, "error:"), paint(f, bold, subject))
+		for _, d := range bySubject[subject] {
+			if d.Field != "" {
+				fmt.Fprintf(&b, "  %s: %s\n", paint(f, bold, d.Field), d.Problem)
+			} else {
+				fmt.Fprintf(&b, "  %s\n", d.Problem)
+			}
+			if d.Suggestion != "" {
+				fmt.Fprintf(&b, "    %s\n", paint(f, cyan, d.Suggestion))
+			}
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,124 @@
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"github.com/sam-falvo/sitehammer/failure"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strconv"
+	"sync"
+)
+
+// Severities of the entries of a problem file.
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+// Entry describes one problem in a problem file: the Path of the file at fault and the Line within it, where they're known;
+// its Severity, SeverityError for a problem that stopped the command, or SeverityWarning for one that didn't;
+// a Code classifying it; and the Message describing it, as reported.
+// Errors are coded by their class of failure (see the failure package), problems found in generated HTML as "html",
+// and other warnings as "warning".
+type Entry struct {
+	Path     string `json:"path,omitempty"`
+	Line     int    `json:"line,omitempty"`
+	Severity string `json:"severity"`
+	Code     string `json:"code"`
+	Message  string `json:"message"`
+}
+
+// problemsLock guards the problem file's name and the entries bound for it.
+var (
+	problemsLock sync.Mutex
+	problemsFile string
+	entries      []Entry
+)
+
+// SetProblemsFile asks that every warning, problem, and error reported from now on be recorded,
+// to be written to the named file, as a JSON array of Entry objects, by WriteProblems.
+// Editors and CI systems can read the file to show each problem beside the source at fault.
+// An empty name stops the recording.
+func SetProblemsFile(filename string) {
+	problemsLock.Lock()
+	problemsFile = filename
+	problemsLock.Unlock()
+}
+
+// ProblemsValue lets a command-line flag name the problem file, by way of SetProblemsFile;
+// it satisfies the flag.Value interface.
+type ProblemsValue struct{}
+
+// String answers the name of the problem file, if there is one.
+func (ProblemsValue) String() string {
+	problemsLock.Lock()
+	defer problemsLock.Unlock()
+	return problemsFile
+}
+
+// Set names the problem file.
+func (ProblemsValue) Set(filename string) error {
+	SetProblemsFile(filename)
+	return nil
+}
+
+// WriteProblems writes the problems recorded so far to the problem file, if one was named,
+// as an empty array if there were none, so a clean run leaves no stale problems behind.
+func WriteProblems() error {
+	problemsLock.Lock()
+	defer problemsLock.Unlock()
+	if problemsFile == "" {
+		return nil
+	}
+	list := entries
+	if list == nil {
+		list = []Entry{}
+	}
+	var content bytes.Buffer
+	encoder := json.NewEncoder(&content)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	err := encoder.Encode(list)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(problemsFile, content.Bytes(), 0644)
+}
+
+// record adds an entry to the problem file, if there is one.
+func record(e Entry) {
+	problemsLock.Lock()
+	defer problemsLock.Unlock()
+	if problemsFile != "" {
+		entries = append(entries, e)
+	}
+}
+
+// Patterns locating the errors of templates, as text/template and html/template describe them, and of other files
+// whose errors begin with the file and line at fault.
+var (
+	templateLocation = regexp.MustCompile(`^(?:html/)?template: ([^:\s]+):(\d+):`)
+	fileLocation     = regexp.MustCompile(`^([^:\s]+):(\d+):`)
+)
+
+// recordError adds an error to the problem file, locating it as best it can:
+// by the file and line its message begins with, if any, or by the path of the file an os.PathError concerns.
+func recordError(err error) {
+	e := Entry{Severity: SeverityError, Code: failure.Classify(err).String(), Message: err.Error()}
+	if m := templateLocation.FindStringSubmatch(e.Message); m != nil {
+		e.Path = m[1]
+		e.Line, _ = strconv.Atoi(m[2])
+	} else if m := fileLocation.FindStringSubmatch(e.Message); m != nil {
+		e.Path = m[1]
+		e.Line, _ = strconv.Atoi(m[2])
+	} else {
+		var pathErr *os.PathError
+		if errors.As(err, &pathErr) {
+			e.Path = pathErr.Path
+		}
+	}
+	record(e)
+}
@@ -0,0 +1,213 @@
+/*
+The report package tells the user what a command is doing, in either of two formats.
+
+In the text format, the default, reports are lines of prose meant for people:
+warnings and problems go to standard error, prefixed as "warning:" or with the file and line concerned,
+and everything else goes to standard output.
+
+In the JSON format, meant for programs wrapping SiteHammer, such as CI scripts and editors,
+every report is a single JSON object on a line of its own, written to standard output.
+Each object has an "event" field naming what kind of report it is, and a "message" field holding the same text the text format shows;
+other fields depend on the event:
+
+	{"event":"warning","message":"blog page index.html replaces the static file of the same name"}
+	{"event":"problem","message":"img lacks an alt attribute","file":"_site/about.html","line":12}
+	{"event":"dry-run","message":"would create _site/about.html (new)","verb":"create","path":"_site/about.html","reason":"new"}
+	{"event":"remove","message":"removing _site/old.html (orphaned)","path":"_site/old.html","reason":"orphaned"}
+	{"event":"hook","message":"[pre-build] npm run css"}
+	{"event":"summary","message":"Built in 1.2s: ...","elapsed_ms":1200,"bytes":4096,"counts":{"pages rendered":3},...}
+	{"event":"error","message":"Undefined variable site_name.","class":"content"}
+
+Fields appear in no particular order.
+Every error carries a "class" field naming its class of failure, as the failure package describes.
+Errors describing a problem with something in particular, such as an article descriptor,
+carry "subject", "field", and "suggestion" fields as well.
+
+On a terminal, text reports are colored, and the problems of each article or other subject
+are grouped into a block, listing the field at fault, the problem, and how to fix it.
+Set NO_COLOR, or TERM=dumb, for plain text; output that's piped or redirected is always plain.
+Reports are written whole, one at a time, so those made concurrently never interleave.
+
+Whatever the format, warnings, problems, and errors may be recorded in a problem file as well; see SetProblemsFile.
+*/
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/sam-falvo/sitehammer/failure"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Formats in which reports may be written.
+const (
+	Text = "text"
+	JSON = "json"
+)
+
+// Fields holds the details of a report beyond its event and message.
+type Fields map[string]interface{}
+
+// lock guards current, the format in effect, and serializes reports.
+var (
+	lock    sync.Mutex
+	current = Text
+)
+
+// SetFormat chooses the format of subsequent reports: Text or JSON.
+func SetFormat(f string) error {
+	if f != Text && f != JSON {
+		return fmt.Errorf("Log format must be %s or %s, not %q.", Text, JSON, f)
+	}
+	lock.Lock()
+	current = f
+	lock.Unlock()
+	return nil
+}
+
+// IsJSON answers true if reports are being written as JSON.
+func IsJSON() bool {
+	lock.Lock()
+	defer lock.Unlock()
+	return current == JSON
+}
+
+// FormatValue lets a command-line flag choose the format, by way of SetFormat;
+// it satisfies the flag.Value interface.
+type FormatValue struct{}
+
+// String answers the format in effect.
+func (FormatValue) String() string {
+	lock.Lock()
+	defer lock.Unlock()
+	return current
+}
+
+// Set chooses the format.
+func (FormatValue) Set(f string) error {
+	return SetFormat(f)
+}
+
+// Event reports an event of the given kind, described by message and, in the JSON format, by fields as well.
+// In the text format, message alone appears on standard output.
+func Event(kind, message string, fields Fields) {
+	emit(os.Stdout, kind, message, fields)
+}
+
+// Warning reports a problem which doesn't stop the command.
+// In the text format, it appears on standard error, prefixed with "warning: ".
+func Warning(format string, args ...interface{}) {
+	message := fmt.Sprintf(format, args...)
+	record(Entry{Severity: SeverityWarning, Code: "warning", Message: message})
+	lock.Lock()
+	defer lock.Unlock()
+	if current == JSON {
+		writeJSON("warning", message, nil)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "%s %s\n", paint(os.Stderr, yellow, "warning:"), message)
+}
+
+// Problem reports a problem found at a particular line of a file, such as invalid HTML.
+// In the text format, it appears on standard error, as file:line: message.
+func Problem(file string, line int, message string) {
+	record(Entry{Path: file, Line: line, Severity: SeverityWarning, Code: "html", Message: message})
+	lock.Lock()
+	defer lock.Unlock()
+	if current == JSON {
+		writeJSON("problem", message, Fields{"file": file, "line": line})
+		return
+	}
+	fmt.Fprintf(os.Stderr, "%s %s\n", paint(os.Stderr, bold, fmt.Sprintf("%s:%d:", file, line)), message)
+}
+
+// Error reports the error which stopped a command.
+// In the text format, it appears on standard output, as commands always have printed their errors;
+// on a terminal, it's colored, and the Detailed errors of a list of errors (see the errlist package) are grouped by subject.
+// In the JSON format, each error of a list of errors gets an event of its own, with its class (see the failure package) as a field,
+// along with the subject, field, and suggestion of a Detailed error.
+func Error(err error) {
+	errors := []error{err}
+	if list, ok := err.(interface{ Errors() []error }); ok {
+		errors = list.Errors()
+	}
+	for _, e := range errors {
+		recordError(e)
+	}
+	if !IsJSON() {
+		if colorful(os.Stdout) {
+			emit(os.Stdout, "error", strings.TrimSuffix(renderErrors(os.Stdout, errors), "\n"), nil)
+		} else {
+			emit(os.Stdout, "error", err.Error(), nil)
+		}
+		return
+	}
+	for _, e := range errors {
+		fields := Fields{"class": failure.Classify(e).String()}
+		if d, ok := e.(Detailed); ok {
+			details := d.Details()
+			fields["subject"] = details.Subject
+			fields["field"] = details.Field
+			fields["suggestion"] = details.Suggestion
+		}
+		emit(os.Stdout, "error", e.Error(), fields)
+	}
+}
+
+// Lines answers a writer which reports each line written to it as an event of the given kind, for relaying the output of other programs.
+// In the text format, it's simply standard output.
+func Lines(kind string) io.Writer {
+	if !IsJSON() {
+		return os.Stdout
+	}
+	return &lineWriter{kind: kind}
+}
+
+// lineWriter reports each line written to it as an event.
+type lineWriter struct {
+	kind    string
+	partial []byte
+}
+
+// Write reports every complete line written, holding back any incomplete line until it's finished.
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.partial = append(w.partial, p...)
+	for {
+		i := bytes.IndexByte(w.partial, '\n')
+		if i < 0 {
+			return len(p), nil
+		}
+		Event(w.kind, string(w.partial[:i]), nil)
+		w.partial = w.partial[i+1:]
+	}
+}
+
+// emit writes a report to w in the text format, or to standard output in the JSON format.
+func emit(w io.Writer, kind, message string, fields Fields) {
+	lock.Lock()
+	defer lock.Unlock()
+	if current == JSON {
+		writeJSON(kind, message, fields)
+		return
+	}
+	fmt.Fprintln(w, message)
+}
+
+// writeJSON writes a report as a line of JSON to standard output; the caller must hold the lock.
+func writeJSON(kind, message string, fields Fields) {
+	object := Fields{}
+	for k, v := range fields {
+		object[k] = v
+	}
+	object["event"] = kind
+	object["message"] = message
+	line, err := json.Marshal(object)
+	if err != nil {
+		line, _ = json.Marshal(Fields{"event": kind, "message": message})
+	}
+	os.Stdout.Write(append(line, '\n'))
+}
@@ -0,0 +1,134 @@
+package scaffold
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"github.com/sam-falvo/sitehammer/failure"
+	"github.com/sam-falvo/sitehammer/weblog"
+	"io"
+	"strings"
+	"time"
+)
+
+// FixOptions describes an interactive repair of a descriptor file.
+// Filename names the descriptor file.
+// Questions are written to Out, and their answers read, a line apiece, from In.
+// Author suggests the author of articles lacking one; Published, the publication date of those lacking one,
+// which defaults to the current date.
+type FixOptions struct {
+	Filename  string
+	In        io.Reader
+	Out       io.Writer
+	Author    string
+	Published time.Time
+}
+
+// FixDescriptors asks, for every descriptor lacking a title, an author, or a publication date, what it should be,
+// and writes the answers back into the descriptor file.
+// A blank answer accepts the suggestion shown in brackets, if there is one, or leaves the field empty otherwise;
+// when In runs dry, the question it leaves unanswered and any remaining go unasked.
+// The descriptors' other fields, including any the Descriptor type doesn't know about, are kept.
+// Problems a field can't fix, such as two articles sharing an ID, are left for the user to fix by hand.
+// FixDescriptors answers how many descriptors it changed.
+func FixDescriptors(opts FixOptions) (int, error) {
+	if opts.Published.IsZero() {
+		opts.Published = time.Now()
+	}
+	raw, err := readDescriptors(opts.Filename)
+	if err != nil {
+		return 0, err
+	}
+
+	in := bufio.NewReader(opts.In)
+	fixed := 0
+	done := false
+	for i, r := range raw {
+		if done {
+			break
+		}
+		var d weblog.Descriptor
+		err = json.Unmarshal(r, &d)
+		if err != nil {
+			return 0, failure.Wrap(failure.Validation, fmt.Errorf("%s: %v", opts.Filename, err))
+		}
+		questions := []struct {
+			field, description, value, suggestion string
+		}{
+			{"Title", "title", d.Title, ""},
+			{"Author", "author", d.Author, opts.Author},
+			{"Published", "publication date", d.Published, opts.Published.Format(DateLayout)},
+		}
+
+		answers := make(map[string]string)
+		for _, q := range questions {
+			if q.value != "" {
+				continue
+			}
+			var answer string
+			answer, done = ask(in, opts.Out, fmt.Sprintf("Article %d has no %s; %s", d.Id, q.description, q.field), q.suggestion)
+			if answer != "" {
+				answers[q.field] = answer
+			}
+			if done {
+				break
+			}
+		}
+		if len(answers) > 0 {
+			raw[i], err = setFields(r, answers)
+			if err != nil {
+				return 0, err
+			}
+			fixed++
+		}
+	}
+
+	if fixed == 0 {
+		return 0, nil
+	}
+	return fixed, writeDescriptors(opts.Filename, raw)
+}
+
+// ask writes a question, with its suggested answer, if any, in brackets, and answers the reply,
+// or the suggestion if the reply is blank.
+// It answers done as true once there's nothing more to read.
+func ask(in *bufio.Reader, out io.Writer, question, suggestion string) (answer string, done bool) {
+	if suggestion != "" {
+		fmt.Fprintf(out, "%s [%s]: ", question, suggestion)
+	} else {
+		fmt.Fprintf(out, "%s: ", question)
+	}
+	line, err := in.ReadString('\n')
+	if err != nil && line == "" {
+		fmt.Fprintln(out)
+		return "", true
+	}
+	answer = strings.TrimSpace(line)
+	if answer == "" {
+		answer = suggestion
+	}
+	return answer, err != nil
+}
+
+// setFields answers the raw descriptor r with the named string fields set, keeping its other fields.
+// As when decoding a Descriptor, field names are matched regardless of case, so an empty "title" field is replaced, not joined by a "Title".
+func setFields(r json.RawMessage, values map[string]string) (json.RawMessage, error) {
+	var fields map[string]json.RawMessage
+	err := json.Unmarshal(r, &fields)
+	if err != nil {
+		return nil, err
+	}
+	for name, value := range values {
+		for key := range fields {
+			if strings.EqualFold(key, name) {
+				name = key
+				break
+			}
+		}
+		fields[name], err = json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return json.Marshal(fields)
+}
@@ -0,0 +1,198 @@
+/*
+The scaffold package lays down the files a new site or a new article needs, so nobody need set them up by hand.
+
+NewSite creates a skeleton site which builds as it stands:
+a configuration file, templates for the blog's articles and index page and for Markdown pages,
+a stylesheet, a Markdown page, and a sample article,
+along with a .gitignore file listing what builds generate and a .hammerignore file keeping .gitignore from being published.
+
+NewPost allocates the next free article ID, creates the article's source directory with stub abstract and body files,
+and appends a descriptor for the article to the descriptor file, creating that file if need be.
+The stubs are HTML fragments, like any other abstract or body, waiting to be written.
+AddArticles does the same for articles whose content already exists, such as those imported from another blogging system.
+
+FixDescriptors repairs an existing descriptor file, asking for the title, author, or publication date of each article lacking one.
+*/
+package scaffold
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/sam-falvo/sitehammer/config"
+	"github.com/sam-falvo/sitehammer/directory"
+	"github.com/sam-falvo/sitehammer/failure"
+	"github.com/sam-falvo/sitehammer/weblog"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// DateLayout gives the layout, in the manner of the time package, of the publication dates NewPost fills in,
+// as in 2012-Jan-01.
+const DateLayout = "2006-Jan-02"
+
+// The initial content of a new article's abstract and body.
+const (
+	stubAbstract = "<p>Summarize the article here; the blog's index page shows this.</p>\n"
+	stubBody     = "<p>Write the article here.</p>\n"
+)
+
+// PostOptions describes a new article.
+// Author and Email default to those of the blog table of the configuration; Published defaults to the current date.
+type PostOptions struct {
+	Config    *config.Config
+	Title     string
+	Author    string
+	Email     string
+	Published time.Time
+}
+
+// NewPost creates a new article as described, answering its descriptor.
+// The article takes the ID following the highest in use, whether by a descriptor or by a source directory.
+// Nothing is created if the article lacks a title or an author, or if the descriptor file won't parse.
+func NewPost(opts PostOptions) (weblog.Descriptor, error) {
+	cfg := opts.Config.Blog
+	d := weblog.Descriptor{
+		Title:     opts.Title,
+		Author:    opts.Author,
+		Email:     opts.Email,
+		Published: opts.Published.Format(DateLayout),
+	}
+	if d.Author == "" {
+		d.Author = cfg.Author
+	}
+	if d.Email == "" {
+		d.Email = cfg.Email
+	}
+	if opts.Published.IsZero() {
+		d.Published = time.Now().Format(DateLayout)
+	}
+	if d.Title == "" {
+		return d, failure.Wrap(failure.Usage, fmt.Errorf("A new article needs a title."))
+	}
+	if d.Author == "" {
+		return d, failure.Wrap(failure.Usage, fmt.Errorf("A new article needs an author; set author in the blog table of %s.", config.Filename))
+	}
+
+	added, err := AddArticles(opts.Config, []Article{{Descriptor: d, Abstract: stubAbstract, Body: stubBody}})
+	if err != nil {
+		return d, err
+	}
+	return added[0], nil
+}
+
+// Article describes an article to be added to the blog:
+// its descriptor, whose ID is assigned as it's added, and its abstract and body, as HTML fragments.
+// An article with an empty body gets no body file.
+type Article struct {
+	Descriptor weblog.Descriptor
+	Abstract   string
+	Body       string
+}
+
+// AddArticles adds articles to the blog, in order, answering their descriptors as added.
+// Each takes the ID following the highest in use, whether by a descriptor or by a source directory,
+// and gets a source directory holding its abstract and body; their descriptors are appended to the descriptor file together.
+// Nothing is added if the descriptor file won't parse.
+func AddArticles(cfg *config.Config, articles []Article) ([]weblog.Descriptor, error) {
+	raw, err := readDescriptors(cfg.Blog.Descriptors)
+	if err != nil {
+		return nil, err
+	}
+	id, err := nextId(cfg.Blog.Sources, raw)
+	if err != nil {
+		return nil, err
+	}
+
+	var added []weblog.Descriptor
+	for _, a := range articles {
+		d := a.Descriptor
+		d.Id = id
+		id++
+		dir := filepath.Join(cfg.Blog.Sources, strconv.FormatUint(uint64(d.Id), 10))
+		err = directory.EnsureDirAll(dir, 0755)
+		if err != nil {
+			return nil, err
+		}
+		for name, content := range map[string]string{"abstract": a.Abstract, "body": a.Body} {
+			if content == "" {
+				continue
+			}
+			err = ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644)
+			if err != nil {
+				return nil, err
+			}
+		}
+		entry, err := json.Marshal(d)
+		if err != nil {
+			return nil, err
+		}
+		raw = append(raw, entry)
+		added = append(added, d)
+	}
+	return added, writeDescriptors(cfg.Blog.Descriptors, raw)
+}
+
+// readDescriptors reads the named descriptor file, leaving each descriptor as raw JSON,
+// so that rewriting the file keeps any fields the Descriptor type doesn't know about.
+// A missing file holds no descriptors.
+func readDescriptors(filename string) ([]json.RawMessage, error) {
+	content, err := ioutil.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var raw []json.RawMessage
+	err = json.Unmarshal(content, &raw)
+	if err != nil {
+		return nil, failure.Wrap(failure.Validation, fmt.Errorf("%s: %v", filename, err))
+	}
+	return raw, nil
+}
+
+// writeDescriptors replaces the named descriptor file with the given descriptors, indented for legibility.
+func writeDescriptors(filename string, raw []json.RawMessage) error {
+	content, err := json.MarshalIndent(raw, "", "  ")
+	if err != nil {
+		return err
+	}
+	err = directory.EnsureDirAll(filepath.Dir(filename), 0755)
+	if err != nil {
+		return err
+	}
+	return directory.WriteFileAtomic(filename, append(content, '\n'), 0644)
+}
+
+// nextId answers the ID following the highest used by any of the descriptors, or by any numerically named subdirectory of sources.
+func nextId(sources string, raw []json.RawMessage) (uint, error) {
+	var highest uint
+	used := false
+	for _, r := range raw {
+		var d weblog.Descriptor
+		err := json.Unmarshal(r, &d)
+		if err != nil {
+			return 0, err
+		}
+		if !used || d.Id > highest {
+			highest, used = d.Id, true
+		}
+	}
+	err := directory.ForEachEntry(sources, func(fi os.FileInfo) error {
+		id, err := strconv.ParseUint(fi.Name(), 10, 0)
+		if err == nil && fi.IsDir() && (!used || uint(id) > highest) {
+			highest, used = uint(id), true
+		}
+		return nil
+	})
+	if err != nil && !os.IsNotExist(err) {
+		return 0, err
+	}
+	if !used {
+		return 1, nil
+	}
+	return highest + 1, nil
+}
@@ -0,0 +1,210 @@
+package scaffold
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/sam-falvo/sitehammer/config"
+	"github.com/sam-falvo/sitehammer/directory"
+	"github.com/sam-falvo/sitehammer/failure"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// SiteOptions describes a new site.
+// Dir names the directory to create it in, which must be empty or not yet exist.
+// Author and Email become the blog's default author, and are credited with the sample article.
+// BaseUrl gives the URL at which the site is to be published; it defaults to that of sitehammer serve.
+// Published, the sample article's publication date, defaults to the current date.
+type SiteOptions struct {
+	Dir       string
+	Author    string
+	Email     string
+	BaseUrl   string
+	Published time.Time
+}
+
+// skeleton gives the files of a new site, with their content, relative to the site's directory.
+// Content refers to the site's settings as @@author@@, @@email@@, @@base_url@@, and @@published@@,
+// each of which becomes a quoted string, as JSON and TOML alike spell them.
+var skeleton = []struct {
+	name    string
+	content string
+}{
+	{config.Filename, `# SiteHammer configuration; see the config package for every setting.
+
+[output]
+dir = "_site"
+
+[blog]
+# The URL at which the site is published, with no trailing slash.
+base_url = @@base_url@@
+descriptors = "src/descs.json"
+sources = "src"
+article_template = "templates/blog-article.html"
+index_template = "templates/blog-index.html"
+# The author of articles created by sitehammer new post.
+author = @@author@@
+email = @@email@@
+
+[markdown]
+layout = "templates/page.html"
+`},
+	{".gitignore", `/_site/
+/_site.inprogress/
+/.sitehammer-cache/
+/_assets.json
+/_manifest.json
+`},
+	{".hammerignore", `# Files not to publish, written as in .gitignore.
+.gitignore
+`},
+	{"about.md", `# About
+
+This site is built with SiteHammer.
+Edit about.md to say something about yourself.
+`},
+	{"theme/_config.toml", `# This file marks the theme directory as one to publish.
+`},
+	{"theme/site.css", `body {
+  font-family: sans-serif;
+  line-height: 1.5;
+  margin: 0 auto;
+  max-width: 40em;
+  padding: 1em;
+}
+
+header a {
+  color: inherit;
+  text-decoration: none;
+}
+
+nav {
+  display: flex;
+  justify-content: space-between;
+}
+`},
+	{"templates/page.html", `<!DOCTYPE html>
+<html lang="en">
+ <head>
+  <meta charset="utf-8">
+  <title>{{.Title}}</title>
+  <link rel="stylesheet" href="{{Asset "/theme/site.css"}}">
+ </head>
+ <body>
+  <header><a href="/">Home</a></header>
+  <main>
+{{.Content}}
+  </main>
+ </body>
+</html>
+`},
+	{"templates/blog-index.html", `<!DOCTYPE html>
+<html lang="en">
+ <head>
+  <meta charset="utf-8">
+  <title>My Blog</title>
+  <link rel="stylesheet" href="{{Asset "/theme/site.css"}}">
+ </head>
+ <body>
+  <header><h1>My Blog</h1></header>
+  <main>
+{{range .}}
+   <article>
+    <h2><a href="/articles/{{.Id}}">{{.Title}}</a></h2>
+    <p>{{.Published}} &mdash; {{.Author}}</p>
+    {{.Abstract}}
+    {{if .HasBody}}<p><a href="/articles/{{.Id}}">Continue reading&hellip;</a></p>{{end}}
+   </article>
+{{end}}
+  </main>
+  <footer><a href="/about.html">About</a></footer>
+ </body>
+</html>
+`},
+	{"templates/blog-article.html", `<!DOCTYPE html>
+<html lang="en">
+ <head>
+  <meta charset="utf-8">
+  <title>{{.a.Title}}</title>
+  <link rel="stylesheet" href="{{Asset "/theme/site.css"}}">
+ </head>
+ <body>
+  <header><a href="{{.home}}/">My Blog</a></header>
+  <main>
+   <article>
+    <h1>{{.a.Title}}</h1>
+    <p>{{.a.Published}} &mdash; {{.a.Author}}</p>
+    {{.a.Abstract}}
+    {{.a.Body}}
+   </article>
+  </main>
+  <nav>
+   {{if HasPrevLink .i}}<a href="{{PrevArticle .i | Url}}">&larr; {{with PrevArticle .i}}{{.Title}}{{end}}</a>{{else}}<span></span>{{end}}
+   {{if HasNextLink .i .last}}<a href="{{NextArticle .i | Url}}">{{with NextArticle .i}}{{.Title}}{{end}} &rarr;</a>{{end}}
+  </nav>
+ </body>
+</html>
+`},
+	{"src/descs.json", `[
+  {
+    "Id": 1,
+    "Title": "Hello, world",
+    "Author": @@author@@,
+    "Email": @@email@@,
+    "Published": @@published@@
+  }
+]
+`},
+	{"src/1/abstract", `<p>This is the abstract of the site's first article, which the blog's index page shows.</p>
+`},
+	{"src/1/body", `<p>This is the rest of the article.
+Edit src/1/abstract and src/1/body to change it,
+or run sitehammer new post to start another.</p>
+`},
+}
+
+// NewSite creates a skeleton site which builds as it stands:
+// a configuration file, templates for the blog and for Markdown pages, a stylesheet, a Markdown page, and a sample article.
+func NewSite(opts SiteOptions) error {
+	entries, err := ioutil.ReadDir(opts.Dir)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if len(entries) > 0 {
+		return failure.Wrap(failure.Usage, fmt.Errorf("Cannot create a site in %s: it isn't empty.", opts.Dir))
+	}
+	if opts.BaseUrl == "" {
+		opts.BaseUrl = "http://localhost:8000"
+	}
+	if opts.Published.IsZero() {
+		opts.Published = time.Now()
+	}
+	settings := strings.NewReplacer(
+		"@@author@@", quote(opts.Author),
+		"@@email@@", quote(opts.Email),
+		"@@base_url@@", quote(strings.TrimSuffix(opts.BaseUrl, "/")),
+		"@@published@@", quote(opts.Published.Format(DateLayout)),
+	)
+
+	for _, f := range skeleton {
+		filename := filepath.Join(opts.Dir, filepath.FromSlash(f.name))
+		err = directory.EnsureDirAll(filepath.Dir(filename), 0755)
+		if err != nil {
+			return err
+		}
+		err = ioutil.WriteFile(filename, []byte(settings.Replace(f.content)), 0644)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// quote answers s as a quoted string, escaped as both JSON and TOML require.
+func quote(s string) string {
+	q, _ := json.Marshal(s)
+	return string(q)
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"github.com/sam-falvo/sitehammer/assets"
+	"github.com/sam-falvo/sitehammer/buildcache"
+	"github.com/sam-falvo/sitehammer/config"
+	"github.com/sam-falvo/sitehammer/htmlcheck"
+	"github.com/sam-falvo/sitehammer/prune"
+	"github.com/sam-falvo/sitehammer/staging"
+	"github.com/sam-falvo/sitehammer/weblog"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// articlePages selects, from the pages the blog produced, those within the articles directory,
+// naming them relative to that directory.
+func articlePages(produced map[string]bool) map[string]bool {
+	pages := make(map[string]bool)
+	for name := range produced {
+		if strings.HasPrefix(name, weblog.ArticleDirName+"/") {
+			pages[strings.TrimPrefix(name, weblog.ArticleDirName+"/")] = true
+		}
+	}
+	return pages
+}
+
+// parseIds parses a comma-separated list of article IDs, as the -only flag takes.
+func parseIds(list string) ([]uint, error) {
+	var ids []uint
+	for _, field := range strings.Split(list, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		id, err := strconv.ParseUint(field, 10, 0)
+		if err != nil {
+			return nil, usageError("Article IDs are whole numbers, like 1234, not %q.", field)
+		}
+		ids = append(ids, uint(id))
+	}
+	return ids, nil
+}
+
+// checkBlogPages runs a check over the rendered articles, wherever they were staged, and the index page.
+func checkBlogPages(opts weblog.Options, articleDir string, result *weblog.Result, mode string, check htmlcheck.Checker, what string) error {
+	if mode == htmlcheck.Off {
+		return nil
+	}
+	problems, err := htmlcheck.CheckOutputs(opts.ArticleDir, articleDir, articlePages(result.Produced), check)
+	if err != nil {
+		return err
+	}
+	n, err := htmlcheck.CheckOutputs(opts.OutputDir, opts.OutputDir, map[string]bool{weblog.IndexFilename: true}, check)
+	if err != nil {
+		return err
+	}
+	return htmlcheck.Enforce(mode, problems+n, what)
+}
+
+// runBlog implements the blog subcommand, rendering the blog alone into the output directory.
+func runBlog(cfg *config.Config, args []string) error {
+	flags := newFlagSet("blog", "[-base-url url] [-dry-run] [-atomic] [-prune | -prune-dry-run] [-validate off|warn|strict] [-a11y off|warn|strict] [-quiet] [-keep-going] [-resume] [-force] [-only id,...] [-since date] [-cpuprofile file] [-memprofile file] [-trace file] [-timings] [-debug-template] [descs.json]")
+	addBaseUrlFlags(flags, cfg)
+	pruneOrphans := flags.Bool("prune", false, "Removes rendered pages of articles no longer described.")
+	pruneDryRun := flags.Bool("prune-dry-run", false, "Lists the pages -prune would remove, without removing them.")
+	dryRun := flags.Bool("dry-run", false, "Reports what would be created, overwritten, or removed, without changing anything.")
+	atomic := flags.Bool("atomic", false, "Renders into a staging directory, replacing the articles directory only if rendering succeeds.")
+	var validate, a11y string
+	addCheckFlags(flags, &validate, &a11y)
+	quiet := flags.Bool("quiet", false, "Suppresses the progress indicator and the summary of work done.")
+	keepGoing := flags.Bool("keep-going", false, "Carries on past articles that fail to render, reporting every failure at the end.")
+	resume := flags.Bool("resume", false, "Resumes a failed rendering, rendering again only the articles that failed, weren't reached, or have changed since.")
+	force := flags.Bool("force", false, "Renders every article, even those unchanged since the last rendering.")
+	only := flags.String("only", "", "Renders only the articles with the given comma-separated `IDs`, and their neighbors.")
+	since := flags.String("since", "", "Renders only the articles changed after the given `date`, and their neighbors.")
+	profile := addProfileFlags(flags)
+	debug := addDebugTemplateFlag(flags)
+	flags.Parse(args)
+	err := checkModes(validate, a11y)
+	if err != nil {
+		return err
+	}
+	if *resume && *atomic {
+		return usageError("A staged rendering leaves nothing behind to resume; use -resume or -atomic, not both.")
+	}
+	ids, err := parseIds(*only)
+	if err != nil {
+		return err
+	}
+	var changedSince time.Time
+	if *since != "" {
+		date, ok := weblog.Descriptor{Published: *since}.Date()
+		if !ok {
+			return usageError("Cannot understand the date %q; write it like %s.", *since, weblog.DateLayouts[0])
+		}
+		changedSince = date
+	}
+	descriptors := cfg.Blog.Descriptors
+	switch flags.NArg() {
+	case 0:
+	case 1:
+		descriptors = flags.Arg(0)
+	default:
+		return usageError("The blog command takes at most one descriptor file, but was given %d.", flags.NArg())
+	}
+
+	if !*dryRun {
+		_, err = offerFixes(cfg, descriptors)
+		if err != nil {
+			return err
+		}
+	}
+	assetMap, err := assets.LoadMap(assets.MapFilename)
+	if err != nil {
+		return err
+	}
+
+	articleDir := filepath.Join(cfg.Output.Dir, weblog.ArticleDirName)
+	opts := weblog.Options{
+		Config:      cfg,
+		Descriptors: descriptors,
+		OutputDir:   cfg.Output.Dir,
+		ArticleDir:  articleDir,
+		BaseUrl:     cfg.Blog.BaseUrl,
+		Assets:      assetMap,
+		DryRun:      *dryRun,
+		Stats:       profile.startStats(*quiet, *dryRun),
+		KeepGoing:   *keepGoing,
+		Only:        ids,
+		Since:       changedSince,
+		Resume:      *resume,
+		Cache:       buildcache.Open(filepath.Join(buildcache.Dir, weblog.CacheFilename)),
+		Force:       *force,
+	}
+	stopProfiling, err := profile.start()
+	if err != nil {
+		return err
+	}
+	defer stopProfiling()
+	if *debug {
+		// Articles found unchanged aren't rendered, and so would have no data to dump.
+		opts.Force = true
+		opts.DebugTemplates, err = startTemplateDump()
+		if err != nil {
+			return err
+		}
+	}
+	opts.Progress = startProgress(*resume, *atomic)
+	ctx, stop := interruptible()
+	defer stop()
+	opts.Context = ctx
+	removeLeftovers(articleDir, *dryRun)
+
+	var area *staging.Area
+	if *atomic && !*dryRun {
+		area, err = staging.Begin(articleDir)
+		if err != nil {
+			return err
+		}
+		opts.ArticleDir = area.Dir
+	}
+	result, err := weblog.Build(opts)
+	if opts.Progress != nil && !*dryRun {
+		finishProgress(opts.Progress, err)
+	}
+	if err == nil {
+		err = checkBlogPages(opts, articleDir, result, validate, htmlcheck.Validate, "HTML")
+	}
+	if err == nil {
+		err = checkBlogPages(opts, articleDir, result, a11y, htmlcheck.Accessibility, "accessibility")
+	}
+	if err == nil && (*pruneOrphans || *pruneDryRun) {
+		_, err = prune.Orphans(opts.ArticleDir, articleDir, articlePages(result.Produced), *pruneDryRun || *dryRun)
+	}
+	if err == nil && area != nil {
+		err = area.Commit()
+	}
+	if err == nil && !*dryRun {
+		err = opts.Cache.Save()
+	}
+	if err != nil {
+		if area != nil {
+			area.Abort()
+		}
+		return interrupted(ctx, err)
+	}
+	opts.Stats.Report()
+	if profile.timings {
+		opts.Stats.ReportTimings()
+	}
+	return nil
+}
@@ -0,0 +1,451 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/sam-falvo/sitehammer/blogroll"
+	"github.com/sam-falvo/sitehammer/buildcache"
+	"github.com/sam-falvo/sitehammer/config"
+	"github.com/sam-falvo/sitehammer/contentindex"
+	"github.com/sam-falvo/sitehammer/errlist"
+	"github.com/sam-falvo/sitehammer/failure"
+	"github.com/sam-falvo/sitehammer/hooks"
+	"github.com/sam-falvo/sitehammer/hosting"
+	"github.com/sam-falvo/sitehammer/htmlcheck"
+	"github.com/sam-falvo/sitehammer/manifest"
+	"github.com/sam-falvo/sitehammer/offline"
+	"github.com/sam-falvo/sitehammer/precompress"
+	"github.com/sam-falvo/sitehammer/prune"
+	"github.com/sam-falvo/sitehammer/report"
+	"github.com/sam-falvo/sitehammer/staging"
+	"github.com/sam-falvo/sitehammer/static"
+	"github.com/sam-falvo/sitehammer/stats"
+	"github.com/sam-falvo/sitehammer/templatedump"
+	"github.com/sam-falvo/sitehammer/weblog"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// buildOptions collects the settings governing a whole-site build.
+type buildOptions struct {
+	Config         *config.Config
+	DryRun         bool
+	Atomic         bool
+	Force          bool
+	Prune          bool
+	PruneDryRun    bool
+	Fingerprint    bool
+	Symlinks       string
+	Validate       string
+	A11y           string
+	Stats          *stats.Stats
+	KeepGoing      bool
+	Context        context.Context
+	Resume         bool
+	DebugTemplates string
+}
+
+// build runs the static pass and then the blog pass into a common output directory,
+// pruning orphans and swapping a staged build into place as requested.
+func build(opts buildOptions) (err error) {
+	outputDir := opts.Config.Output.Dir
+	var area *staging.Area
+	if opts.Atomic && !opts.DryRun {
+		area, err = staging.Begin(outputDir)
+		if err != nil {
+			return
+		}
+		outputDir = area.Dir
+		defer func() {
+			if err != nil {
+				area.Abort()
+			}
+		}()
+	}
+
+	removeLeftovers(outputDir, opts.DryRun)
+	progress := startProgress(opts.Resume, opts.Atomic)
+	if progress != nil && !opts.DryRun {
+		defer func() { finishProgress(progress, err) }()
+	}
+	git := buildcache.OpenGit(buildcache.Dir, ".")
+	if !opts.DryRun {
+		err = git.Begin()
+		if err != nil {
+			return
+		}
+	}
+
+	began := time.Now()
+	staticResult, err := static.Build(static.Options{
+		Config:         opts.Config,
+		SourceDir:      ".",
+		OutputDir:      outputDir,
+		Force:          opts.Force,
+		DryRun:         opts.DryRun,
+		Fingerprint:    opts.Fingerprint,
+		Symlinks:       opts.Symlinks,
+		Stats:          opts.Stats,
+		KeepGoing:      opts.KeepGoing,
+		Context:        opts.Context,
+		SaveOnFailure:  progress != nil,
+		DebugTemplates: opts.DebugTemplates,
+		Git:            git,
+	})
+	if staticResult == nil {
+		return
+	}
+	var failed errlist.List
+	failed.Add(err)
+	opts.Stats.Phase("static pass", began)
+	produced := staticResult.Produced
+	sources := staticResult.Sources
+	var articles []weblog.Article
+	blogCache := buildcache.Open(filepath.Join(buildcache.Dir, weblog.CacheFilename))
+
+	if hasBlog(opts.Config) {
+		began = time.Now()
+		var blogResult *weblog.Result
+		blogResult, err = weblog.Build(weblog.Options{
+			Config:         opts.Config,
+			Descriptors:    opts.Config.Blog.Descriptors,
+			OutputDir:      outputDir,
+			BaseUrl:        opts.Config.Blog.BaseUrl,
+			Assets:         staticResult.Assets,
+			DryRun:         opts.DryRun,
+			Stats:          opts.Stats,
+			KeepGoing:      opts.KeepGoing,
+			Context:        opts.Context,
+			Progress:       progress,
+			Resume:         opts.Resume,
+			Cache:          blogCache,
+			Force:          opts.Force,
+			DebugTemplates: opts.DebugTemplates,
+		})
+		if blogResult == nil {
+			return
+		}
+		failed.Add(err)
+		opts.Stats.Phase("blog", began)
+		articles = blogResult.Articles
+		for name := range blogResult.Produced {
+			if produced[name] {
+				report.Warning("blog page %s replaces the static file of the same name", name)
+			}
+			produced[name] = true
+			sources[name] = blogResult.Sources[name]
+		}
+		err = static.CheckCase(produced)
+		if err != nil {
+			return
+		}
+	}
+	err = failed.Err()
+	if err != nil {
+		return
+	}
+
+	if opts.Config.Offline.Enabled {
+		var files []string
+		files, err = offline.Generate(offline.Options{
+			Config:     opts.Config,
+			SourceDir:  ".",
+			OutputDir:  outputDir,
+			DisplayDir: opts.Config.Output.Dir,
+			DryRun:     opts.DryRun,
+		}, produced)
+		if err != nil {
+			return
+		}
+		for _, name := range files {
+			produced[name] = true
+			sources[name] = []string{configFilename}
+		}
+	}
+	if len(opts.Config.Redirects) > 0 || len(opts.Config.Hosting.Hosts) > 0 {
+		var files []string
+		files, err = hosting.Generate(hosting.Options{
+			Config:     opts.Config,
+			OutputDir:  outputDir,
+			DisplayDir: opts.Config.Output.Dir,
+			DryRun:     opts.DryRun,
+		}, produced)
+		if err != nil {
+			return
+		}
+		for _, name := range files {
+			produced[name] = true
+			sources[name] = []string{configFilename}
+		}
+	}
+
+	if opts.Config.Blogroll.Opml != "" {
+		var files []string
+		files, err = blogroll.Generate(blogroll.Options{
+			Config:     opts.Config,
+			OutputDir:  outputDir,
+			DisplayDir: opts.Config.Output.Dir,
+			DryRun:     opts.DryRun,
+		}, produced)
+		if err != nil {
+			return
+		}
+		for _, name := range files {
+			produced[name] = true
+			sources[name] = []string{opts.Config.Blogroll.File}
+		}
+	}
+	if opts.Config.ContentIndex.Enabled {
+		var files []string
+		files, err = contentindex.Generate(contentindex.Options{
+			Config:     opts.Config,
+			OutputDir:  outputDir,
+			DisplayDir: opts.Config.Output.Dir,
+			DryRun:     opts.DryRun,
+			Sources:    sources,
+			Articles:   articles,
+		}, produced)
+		if err != nil {
+			return
+		}
+		for _, name := range files {
+			produced[name] = true
+			sources[name] = []string{configFilename}
+		}
+	}
+
+	began = time.Now()
+	variants, err := precompress.Variants(precompress.Options{
+		Config:     opts.Config,
+		SourceDir:  ".",
+		OutputDir:  outputDir,
+		DisplayDir: opts.Config.Output.Dir,
+		DryRun:     opts.DryRun,
+	}, produced)
+	if err != nil {
+		return
+	}
+	for variant, original := range variants {
+		produced[variant] = true
+		sources[variant] = sources[original]
+	}
+	opts.Stats.Phase("precompression", began)
+
+	began = time.Now()
+
+	err = checkPages(outputDir, opts.Config.Output.Dir, produced, opts.Validate, htmlcheck.Validate, "HTML")
+	if err != nil {
+		return
+	}
+	err = checkPages(outputDir, opts.Config.Output.Dir, produced, opts.A11y, htmlcheck.Accessibility, "accessibility")
+	if err != nil {
+		return
+	}
+	if opts.Validate != htmlcheck.Off || opts.A11y != htmlcheck.Off {
+		opts.Stats.Phase("checks", began)
+	}
+
+	var pruned []string
+	if opts.Prune || opts.PruneDryRun {
+		pruned, err = prune.Orphans(outputDir, opts.Config.Output.Dir, produced, opts.PruneDryRun || opts.DryRun)
+		if err != nil {
+			return
+		}
+	}
+	if area != nil {
+		err = area.Commit()
+		if err != nil {
+			return
+		}
+	}
+	err = staticResult.Save(pruned)
+	if err != nil || opts.DryRun {
+		return
+	}
+	metadata := buildcache.OpenMetadata(buildcache.Dir)
+	metadata.Git = git
+	err = metadata.Record(sources)
+	if err == nil {
+		err = metadata.Save()
+	}
+	if err == nil && hasBlog(opts.Config) {
+		err = blogCache.Save()
+	}
+	if err == nil {
+		err = git.Save()
+	}
+	if err != nil || opts.Config.Output.Manifest == "" {
+		return
+	}
+	return writeManifest(opts.Config, sources)
+}
+
+// startProgress opens the record of a failed build's progress, ready to record this build's,
+// reporting what there is to resume from if resume is true.
+// It answers nil if the build is staged, since a failed staged build leaves nothing to resume from.
+func startProgress(resume, staged bool) *buildcache.Progress {
+	if staged {
+		return nil
+	}
+	progress := buildcache.OpenProgress(buildcache.Dir)
+	if resume {
+		rendered, failed := progress.Counts()
+		if rendered+failed == 0 {
+			report.Event("resume", "no failed build to resume; building everything", report.Fields{"rendered": 0, "failed": 0})
+		} else {
+			report.Event("resume", fmt.Sprintf("resuming: %d article(s) rendered before are skipped unless changed; %d failed and are retried", rendered, failed),
+				report.Fields{"rendered": rendered, "failed": failed})
+		}
+	}
+	progress.Reset()
+	return progress
+}
+
+// finishProgress saves the build's progress if it failed, so a later build can resume, or discards it if it succeeded.
+func finishProgress(progress *buildcache.Progress, err error) {
+	var saveErr error
+	if err != nil {
+		saveErr = progress.Save()
+	} else {
+		saveErr = progress.Remove()
+	}
+	if saveErr != nil {
+		report.Warning("cannot record the build's progress: %v", saveErr)
+	}
+}
+
+// addDebugTemplateFlag adds the -debug-template flag, which the build and blog subcommands share, to flags.
+func addDebugTemplateFlag(flags *flag.FlagSet) *bool {
+	return flags.Bool("debug-template", false, "Writes the data each page's template is executed with, as JSON, to a file per page beneath "+filepath.Join(buildcache.Dir, templatedump.DirName)+".")
+}
+
+// startTemplateDump empties the directory receiving the data of each template executed, of the data of an earlier build,
+// answering its name.
+func startTemplateDump() (string, error) {
+	dir := filepath.Join(buildcache.Dir, templatedump.DirName)
+	return dir, os.RemoveAll(dir)
+}
+
+// checkPages runs a check over the generated pages in the given mode, failing in strict mode if any problems are found.
+func checkPages(outputDir, displayDir string, produced map[string]bool, mode string, check htmlcheck.Checker, what string) error {
+	if mode == htmlcheck.Off {
+		return nil
+	}
+	problems, err := htmlcheck.CheckOutputs(outputDir, displayDir, produced, check)
+	if err != nil {
+		return err
+	}
+	return htmlcheck.Enforce(mode, problems, what)
+}
+
+// writeManifest describes the generated files in the manifest file, noting which changed since the previous build.
+func writeManifest(cfg *config.Config, sources map[string][]string) error {
+	previous, err := manifest.Load(cfg.Output.Manifest)
+	if err != nil {
+		return err
+	}
+	m, err := manifest.Build(cfg.Output.Dir, cfg.Output.Dir, sources)
+	if err != nil {
+		return err
+	}
+	m.MarkChanges(previous)
+	return m.Save(cfg.Output.Manifest)
+}
+
+// hasBlog answers true if the site has a blog, which is to say, if its descriptor file exists.
+func hasBlog(cfg *config.Config) bool {
+	_, err := os.Stat(cfg.Blog.Descriptors)
+	return err == nil
+}
+
+// runBuild implements the build subcommand, building the whole site.
+func runBuild(cfg *config.Config, args []string) error {
+	opts := buildOptions{Config: cfg}
+	flags := newFlagSet("build", "[-dry-run] [-atomic] [-force] [-prune | -prune-dry-run] [-fingerprint] [-symlinks follow|link|skip] [-validate off|warn|strict] [-a11y off|warn|strict] [-quiet] [-keep-going] [-resume] [-cpuprofile file] [-memprofile file] [-trace file] [-timings] [-debug-template] [-diff] [-base-url url]")
+	flags.BoolVar(&opts.DryRun, "dry-run", false, "Reports what would be created, overwritten, or removed, without changing anything.")
+	flags.BoolVar(&opts.Atomic, "atomic", false, "Builds into a staging directory, replacing the output directory only if the build succeeds.")
+	flags.BoolVar(&opts.Force, "force", false, "Rebuilds every output, ignoring the build cache.")
+	flags.BoolVar(&opts.Prune, "prune", false, "Removes outputs produced by neither the static pass nor the blog.")
+	flags.BoolVar(&opts.PruneDryRun, "prune-dry-run", false, "Lists the outputs -prune would remove, without removing them.")
+	flags.BoolVar(&opts.Fingerprint, "fingerprint", cfg.Assets.Fingerprint, "Publishes stylesheets, scripts, and images under content-hashed names.")
+	flags.StringVar(&opts.Symlinks, "symlinks", cfg.Files.Symlinks, "Chooses whether symbolic links are followed, recreated as links, or skipped.")
+	addCheckFlags(flags, &opts.Validate, &opts.A11y)
+	addBaseUrlFlags(flags, cfg)
+	quiet := flags.Bool("quiet", false, "Suppresses the progress indicator and the summary of work done.")
+	flags.BoolVar(&opts.KeepGoing, "keep-going", false, "Carries on past files and articles that fail to build, reporting every failure at the end.")
+	flags.BoolVar(&opts.Resume, "resume", false, "Resumes a failed build, rendering again only the articles that failed, weren't reached, or have changed since.")
+	profile := addProfileFlags(flags)
+	debug := addDebugTemplateFlag(flags)
+	diff := flags.Bool("diff", false, "Lists the outputs which differ from the last build's, and why, for review before deploying.")
+	flags.Parse(args)
+	if flags.NArg() > 0 {
+		return usageError("The build command takes no arguments, but was given %q.", flags.Arg(0))
+	}
+	if *diff && opts.DryRun {
+		return usageError("A dry run already lists each output it would change; use -dry-run or -diff, not both.")
+	}
+	if opts.Resume && opts.Atomic {
+		return usageError("A staged build leaves nothing behind to resume; use -resume or -atomic, not both.")
+	}
+	err := checkModes(opts.Validate, opts.A11y)
+	if err != nil {
+		return err
+	}
+	err = config.ValidateSymlinkPolicy(opts.Symlinks)
+	if err != nil {
+		return failure.Wrap(failure.Usage, err)
+	}
+	if *debug {
+		// Pages found up to date aren't rendered, and so would have no data to dump.
+		opts.Force = true
+		opts.DebugTemplates, err = startTemplateDump()
+		if err != nil {
+			return err
+		}
+	}
+
+	stopProfiling, err := profile.start()
+	if err != nil {
+		return err
+	}
+	defer stopProfiling()
+	ctx, stop := interruptible()
+	defer stop()
+	opts.Context = ctx
+	hookOpts := hooks.Options{Output: report.Lines("hook"), OutputDir: cfg.Output.Dir, DryRun: opts.DryRun, Context: ctx}
+	err = hooks.Run(hookOpts, hooks.Pre, cfg.Hooks.Pre)
+	if err != nil {
+		return interrupted(ctx, err)
+	}
+	if hasBlog(cfg) && !opts.DryRun {
+		_, err = offerFixes(cfg, cfg.Blog.Descriptors)
+		if err != nil {
+			return err
+		}
+	}
+	opts.Stats = profile.startStats(*quiet, opts.DryRun)
+	var changes *outputDiff
+	if *diff {
+		changes, err = startDiff(cfg)
+		if err != nil {
+			return err
+		}
+	}
+	err = build(opts)
+	if err != nil {
+		return interrupted(ctx, err)
+	}
+	opts.Stats.Report()
+	if changes != nil {
+		err = changes.report()
+		if err != nil {
+			return err
+		}
+	}
+	if profile.timings {
+		opts.Stats.ReportTimings()
+	}
+	return interrupted(ctx, hooks.Run(hookOpts, hooks.Post, cfg.Hooks.Post))
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"github.com/sam-falvo/sitehammer/buildcache"
+	"github.com/sam-falvo/sitehammer/config"
+	"github.com/sam-falvo/sitehammer/dryrun"
+	"github.com/sam-falvo/sitehammer/report"
+	"os"
+)
+
+// runCache implements the cache subcommand, managing the caches SiteHammer keeps between builds.
+func runCache(cfg *config.Config, args []string) error {
+	if len(args) == 0 {
+		return usageError("The cache command needs to know what to do: clean.")
+	}
+	switch args[0] {
+	case "-h", "-help":
+		// As from sitehammer help cache.
+		fmt.Println("USAGE: sitehammer cache clean [-dry-run]")
+		return nil
+	case "clean":
+		return cleanCache(args[1:])
+	}
+	return usageError("The cache command cannot %q; it can only clean.", args[0])
+}
+
+// cleanCache removes the cache directory.
+func cleanCache(args []string) error {
+	flags := newFlagSet("cache clean", "[-dry-run]")
+	dryRun := flags.Bool("dry-run", false, "Reports what would be removed, without removing anything.")
+	flags.Parse(args)
+	if flags.NArg() > 0 {
+		return usageError("The cache clean command takes no arguments, but was given %q.", flags.Arg(0))
+	}
+	return removeCache(*dryRun)
+}
+
+// removeCache removes the cache directory and everything in it, so the next build starts from scratch.
+func removeCache(dryRun bool) error {
+	if _, err := os.Stat(buildcache.Dir); os.IsNotExist(err) {
+		report.Event("cleaned", fmt.Sprintf("no cache to remove at %s", buildcache.Dir), report.Fields{"path": buildcache.Dir, "removed": 0})
+		return nil
+	}
+	if dryRun {
+		dryrun.Report(dryrun.Remove, buildcache.Dir, dryrun.Generated)
+		return nil
+	}
+	report.Event("remove", fmt.Sprintf("removing %s (generated)", buildcache.Dir), report.Fields{"path": buildcache.Dir, "reason": dryrun.Generated})
+	return os.RemoveAll(buildcache.Dir)
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"github.com/sam-falvo/sitehammer/config"
+	"github.com/sam-falvo/sitehammer/errlist"
+	"github.com/sam-falvo/sitehammer/report"
+	"github.com/sam-falvo/sitehammer/static"
+	"github.com/sam-falvo/sitehammer/weblog"
+)
+
+// runCheck implements the check subcommand, looking for mistakes a build would stumble on, without building anything.
+// The configuration has already been loaded, and so found sound, by the time runCheck is called.
+func runCheck(cfg *config.Config, args []string) error {
+	flags := newFlagSet("check", "")
+	flags.Parse(args)
+	if flags.NArg() > 0 {
+		return usageError("The check command takes no arguments, but was given %q.", flags.Arg(0))
+	}
+
+	var problems errlist.List
+	problems.Add(static.Check(static.Options{
+		Config:      cfg,
+		SourceDir:   ".",
+		OutputDir:   cfg.Output.Dir,
+		Fingerprint: cfg.Assets.Fingerprint,
+		Symlinks:    cfg.Files.Symlinks,
+	}))
+	articles := 0
+	if hasBlog(cfg) {
+		_, err := offerFixes(cfg, cfg.Blog.Descriptors)
+		if err != nil {
+			return err
+		}
+		ds, err := weblog.LoadDescriptors(cfg.Blog.Descriptors)
+		if err != nil {
+			return fmt.Errorf("Cannot read %s: %w", cfg.Blog.Descriptors, err)
+		}
+		articles = len(ds)
+		problems.Add(weblog.Check(weblog.Options{
+			Config:      cfg,
+			Descriptors: cfg.Blog.Descriptors,
+			OutputDir:   cfg.Output.Dir,
+			BaseUrl:     cfg.Blog.BaseUrl,
+		}))
+	}
+
+	fields := report.Fields{"articles": articles, "problems": problems.Len()}
+	if problems.Len() > 0 {
+		report.Event("checked", "the site has problems a build would stumble on:", fields)
+		return problems.Err()
+	}
+	if articles == 0 {
+		report.Event("checked", "no problems found; the site has no blog", fields)
+		return nil
+	}
+	report.Event("checked", fmt.Sprintf("no problems found; %d articles described in %s", articles, cfg.Blog.Descriptors), fields)
+	return nil
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"fmt"
+	"github.com/sam-falvo/sitehammer/buildcache"
+	"github.com/sam-falvo/sitehammer/config"
+	"github.com/sam-falvo/sitehammer/prune"
+	"github.com/sam-falvo/sitehammer/report"
+)
+
+// runClean implements the clean subcommand, removing the outputs the most recent build recorded.
+func runClean(cfg *config.Config, args []string) error {
+	flags := newFlagSet("clean", "[-dry-run] [-cache]")
+	dryRun := flags.Bool("dry-run", false, "Lists what would be removed, without removing anything.")
+	cache := flags.Bool("cache", false, "Removes the build caches as well.")
+	flags.Parse(args)
+	if flags.NArg() > 0 {
+		return usageError("The clean command takes no arguments, but was given %q.", flags.Arg(0))
+	}
+
+	outputs := buildcache.OpenMetadata(buildcache.Dir).Outputs()
+	removed, err := prune.Outputs(cfg.Output.Dir, cfg.Output.Dir, outputs, *dryRun)
+	if err != nil {
+		return err
+	}
+	if !*cache {
+		report.Event("cleaned", fmt.Sprintf("removed %d outputs", len(removed)), report.Fields{"removed": len(removed)})
+		return nil
+	}
+	return removeCache(*dryRun)
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"github.com/sam-falvo/sitehammer/config"
+	"github.com/sam-falvo/sitehammer/deploy"
+	"github.com/sam-falvo/sitehammer/manifest"
+	"github.com/sam-falvo/sitehammer/report"
+	"sort"
+	"strings"
+)
+
+// deployers maps each deployment target to the function deploying the site there.
+var deployers = map[string]func(config.DeployProfile, deploy.Options) (*deploy.Result, error){
+	config.TargetS3:        deploy.S3,
+	config.TargetRsync:     deploy.Rsync,
+	config.TargetGitHub:    deploy.GitHub,
+	config.TargetNetlify:   deploy.Netlify,
+	config.TargetNeocities: deploy.Neocities,
+	config.TargetSftp:      deploy.Sftp,
+	config.TargetFtp:       deploy.Ftp,
+	config.TargetIpfs:      deploy.Ipfs,
+}
+
+// runDeploy implements the deploy subcommand, publishing the built site as a deployment profile says.
+func runDeploy(cfg *config.Config, args []string) error {
+	names := make([]string, 0, len(cfg.Deploy))
+	for name := range cfg.Deploy {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if len(args) == 0 {
+		if len(names) == 0 {
+			return usageError("No deploy profiles are configured; describe one, such as [deploy.production], in the configuration.")
+		}
+		return usageError("The deploy command needs to know which profile to deploy: %s.", strings.Join(names, ", "))
+	}
+	if args[0] == "-h" || args[0] == "-help" {
+		// As from sitehammer help deploy.
+		fmt.Println("USAGE: sitehammer deploy profile [-dry-run] [-draft]")
+		return nil
+	}
+	name := args[0]
+	profile, ok := cfg.Deploy.Profile(name)
+	if !ok {
+		if len(names) == 0 {
+			return usageError("No deploy profiles are configured; describe %s as [deploy.%s] in the configuration.", name, name)
+		}
+		return usageError("No deploy profile is called %q; the configuration describes %s.", name, strings.Join(names, ", "))
+	}
+
+	flags := newFlagSet("deploy "+name, "[-dry-run] [-draft]")
+	dryRun := flags.Bool("dry-run", false, "Lists the files that would be uploaded or deleted, and anything else that would be done, without changing anything.")
+	draft := flags.Bool("draft", false, "Publishes a draft, at an address of its own, for preview, rather than the live site; only netlify profiles support drafts.")
+	flags.Parse(args[1:])
+	if flags.NArg() != 0 {
+		return usageError("The deploy %s command takes no arguments, but was given %d.", name, flags.NArg())
+	}
+	if *draft && profile.Target != config.TargetNetlify {
+		return usageError("Deploy profile %s deploys to %s, which has no drafts; only netlify profiles support -draft.", name, profile.Target)
+	}
+	m, err := builtManifest(cfg)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := interruptible()
+	defer stop()
+	opts := deploy.Options{Profile: name, BaseUrl: cfg.Blog.BaseUrl, OutputDir: cfg.Output.Dir, Manifest: m, DryRun: *dryRun, Draft: *draft, Context: ctx}
+	result, err := deployers[profile.Target](profile, opts)
+	if err != nil {
+		return interrupted(ctx, err)
+	}
+	verb := "deployed"
+	if *dryRun {
+		verb = "would deploy"
+	}
+	message := fmt.Sprintf("%s %s (%s): %d uploaded, %d deleted, %d unchanged",
+		verb, name, profile.Target, result.Uploaded, result.Deleted, result.Unchanged)
+	if result.Cid != "" {
+		message += "; CID " + result.Cid
+	}
+	if result.Url != "" {
+		message += "; see " + result.Url
+	}
+	report.Event("deploy", message,
+		report.Fields{"profile": name, "target": profile.Target, "uploaded": result.Uploaded, "deleted": result.Deleted, "unchanged": result.Unchanged,
+			"invalidation": result.Invalidation, "cid": result.Cid, "url": result.Url, "dry_run": *dryRun})
+	return nil
+}
+
+// builtManifest answers the manifest of the last build, which lists the files deployed.
+func builtManifest(cfg *config.Config) (*manifest.Manifest, error) {
+	if cfg.Output.Manifest == "" {
+		return nil, usageError("Deploying needs the build's manifest, which the output table of the configuration disables.")
+	}
+	m, err := manifest.Load(cfg.Output.Manifest)
+	if err != nil {
+		return nil, err
+	}
+	if len(m.Files) == 0 {
+		return nil, usageError("The manifest, %s, lists no files; build the site before deploying it.", cfg.Output.Manifest)
+	}
+	return m, nil
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"github.com/sam-falvo/sitehammer/buildcache"
+	"github.com/sam-falvo/sitehammer/config"
+	"github.com/sam-falvo/sitehammer/manifest"
+	"github.com/sam-falvo/sitehammer/report"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// outputDiff compares a build's outputs with those of the build before it, for the build subcommand's -diff flag.
+// It's begun before the build, while the metadata store still describes the previous build, and reported after.
+type outputDiff struct {
+	cfg      *config.Config
+	previous *manifest.Manifest
+	metadata *buildcache.Metadata
+}
+
+// startDiff takes a picture of the outputs of the last successful build, as the metadata store records them,
+// as they stand in the output directory before the build replaces them.
+func startDiff(cfg *config.Config) (*outputDiff, error) {
+	metadata := buildcache.OpenMetadata(buildcache.Dir)
+	previous, err := describeOutputs(cfg.Output.Dir, metadata)
+	if err != nil {
+		return nil, err
+	}
+	return &outputDiff{cfg: cfg, previous: previous, metadata: metadata}, nil
+}
+
+// describeOutputs describes the outputs the metadata store records which exist in the output directory.
+func describeOutputs(outputDir string, metadata *buildcache.Metadata) (*manifest.Manifest, error) {
+	sources := metadata.OutputSources()
+	for name := range sources {
+		_, err := os.Lstat(filepath.Join(outputDir, filepath.FromSlash(name)))
+		if err != nil {
+			delete(sources, name)
+		}
+	}
+	return manifest.Build(outputDir, outputDir, sources)
+}
+
+// report describes each output of the build just finished that differs from the previous build's, and why,
+// followed by a summary.
+func (d *outputDiff) report() error {
+	current, err := describeOutputs(d.cfg.Output.Dir, buildcache.OpenMetadata(buildcache.Dir))
+	if err != nil {
+		return err
+	}
+	differences, unchanged := current.Diff(d.previous)
+	counts := make(map[string]int)
+	for _, diff := range differences {
+		counts[diff.Kind]++
+		reason, changed := d.reasonFor(diff)
+		report.Event("diff", fmt.Sprintf("%-7s %s (%s)", diff.Kind, diff.Path, reason),
+			report.Fields{"path": diff.Path, "change": diff.Kind, "reason": reason, "sources": changed})
+	}
+	report.Event("diff-summary",
+		fmt.Sprintf("%d outputs differ from the last build: %d new, %d changed, %d removed; %d unchanged",
+			len(differences), counts[manifest.Added], counts[manifest.Changed], counts[manifest.Removed], unchanged),
+		report.Fields{"new": counts[manifest.Added], "changed": counts[manifest.Changed], "removed": counts[manifest.Removed], "unchanged": unchanged})
+	return nil
+}
+
+// reasonFor explains why an output differs from the previous build's, answering the explanation
+// along with the sources which changed since, if any.
+// A changed output is put down to its changed sources: its content, its template, or the configuration.
+// One whose sources are all unchanged was affected by something else, such as a neighboring article or a renamed asset.
+func (d *outputDiff) reasonFor(diff manifest.Difference) (string, []string) {
+	switch diff.Kind {
+	case manifest.Added:
+		return "new", []string{}
+	case manifest.Removed:
+		return "no longer built", []string{}
+	}
+	changed := []string{}
+	var content, templates, configuration bool
+	for _, source := range diff.Sources {
+		if modified, err := d.metadata.Changed(source); err == nil && !modified {
+			continue
+		}
+		changed = append(changed, source)
+		switch {
+		case source == cleanSource(configFilename):
+			configuration = true
+		case d.isTemplate(source):
+			templates = true
+		default:
+			content = true
+		}
+	}
+	var reasons []string
+	if content {
+		reasons = append(reasons, "content changed")
+	}
+	if templates {
+		reasons = append(reasons, "template changed")
+	}
+	if configuration {
+		reasons = append(reasons, "configuration changed")
+	}
+	if len(reasons) == 0 {
+		return "rebuilt with unchanged sources", changed
+	}
+	return strings.Join(reasons, ", ") + ": " + strings.Join(changed, ", "), changed
+}
+
+// isTemplate answers true if the named source is one of the templates the configuration names,
+// into which other sources are poured: the Markdown layout, or the blog's article or index template.
+func (d *outputDiff) isTemplate(source string) bool {
+	for _, t := range []string{d.cfg.Markdown.Layout, d.cfg.Blog.ArticleTemplate, d.cfg.Blog.IndexTemplate} {
+		if t != "" && cleanSource(t) == source {
+			return true
+		}
+	}
+	return false
+}
+
+// cleanSource answers a filename as the metadata store names sources: slash-separated, and relative to the source directory.
+func cleanSource(name string) string {
+	return path.Clean(filepath.ToSlash(name))
+}
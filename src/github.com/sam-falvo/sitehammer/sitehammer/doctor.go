@@ -0,0 +1,305 @@
+package main
+
+import (
+	"fmt"
+	"github.com/sam-falvo/sitehammer/buildcache"
+	"github.com/sam-falvo/sitehammer/config"
+	"github.com/sam-falvo/sitehammer/failure"
+	"github.com/sam-falvo/sitehammer/report"
+	"github.com/sam-falvo/sitehammer/weblog"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// diagnosis records the outcome of one of the doctor's checks.
+// A failed check carries the problem found and a fix for it.
+type diagnosis struct {
+	name    string
+	problem string
+	fix     string
+}
+
+// doctor accumulates the outcomes of its checks, reporting each as it's made.
+type doctor struct {
+	failed int
+}
+
+// pass reports that the named check found nothing wrong.
+func (d *doctor) pass(name, detail string) {
+	message := name
+	if detail != "" {
+		message += ": " + detail
+	}
+	if !report.IsJSON() {
+		message = "ok    " + message
+	}
+	report.Event("diagnosis", message, report.Fields{"check": name, "ok": true})
+}
+
+// fail reports a problem found by the named check, along with a fix for it.
+func (d *doctor) fail(dx diagnosis) {
+	d.failed++
+	message := fmt.Sprintf("FAIL  %s: %s\n      fix: %s", dx.name, dx.problem, dx.fix)
+	if report.IsJSON() {
+		message = fmt.Sprintf("%s: %s", dx.name, dx.problem)
+	}
+	report.Event("diagnosis", message, report.Fields{"check": dx.name, "ok": false, "problem": dx.problem, "fix": dx.fix})
+}
+
+// runDoctor implements the doctor subcommand, checking that the site is laid out as sitehammer expects
+// and that the tools it needs are at hand.
+// Unlike the other subcommands, it's given the error loading the configuration, if there was one, rather than being denied the chance to run.
+func runDoctor(cfg *config.Config, loadErr error, args []string) error {
+	flags := newFlagSet("doctor", "")
+	flags.Parse(args)
+	if flags.NArg() != 0 {
+		return usageError("The doctor command takes no arguments, but was given %d.", flags.NArg())
+	}
+
+	d := &doctor{}
+	switch {
+	case loadErr != nil:
+		d.fail(diagnosis{"configuration", loadErr.Error(), fmt.Sprintf("correct %s; the remaining checks assume the default settings", configFilename)})
+		cfg = config.Default()
+	case fileExists(configFilename):
+		d.pass("configuration", configFilename+" is valid")
+	default:
+		d.pass("configuration", "no "+configFilename+"; using the defaults")
+	}
+
+	d.checkLayout(cfg)
+	d.checkBlog(cfg)
+	d.checkWritable("output directory", cfg.Output.Dir)
+	d.checkWritable("build cache", buildcache.Dir)
+	d.checkTools(cfg)
+
+	if d.failed > 0 {
+		return failure.Wrap(failure.General, fmt.Errorf("%d check(s) failed; see the fixes above.", d.failed))
+	}
+	report.Event("healthy", "everything sitehammer needs is in place", nil)
+	return nil
+}
+
+// checkLayout checks that the Markdown layout, if one is configured, exists.
+func (d *doctor) checkLayout(cfg *config.Config) {
+	layout := cfg.Markdown.Layout
+	if layout == "" {
+		return
+	}
+	if !fileExists(layout) {
+		d.fail(diagnosis{"markdown layout", layout + " doesn't exist", fmt.Sprintf("create %s, or change layout in the [markdown] table of %s", layout, configFilename)})
+		return
+	}
+	d.pass("markdown layout", layout)
+}
+
+// checkBlog checks, if the site has a blog, that its templates exist and parse, that its descriptors are valid,
+// and that the article directories under its sources match the descriptors.
+func (d *doctor) checkBlog(cfg *config.Config) {
+	if !hasBlog(cfg) {
+		d.pass("blog", "none; "+cfg.Blog.Descriptors+" doesn't exist")
+		return
+	}
+
+	missing := false
+	for _, t := range []string{cfg.Blog.IndexTemplate, cfg.Blog.ArticleTemplate} {
+		if !fileExists(t) {
+			missing = true
+			d.fail(diagnosis{"blog templates", t + " doesn't exist", fmt.Sprintf("create %s (sitehammer new site writes a starting point), or change the [blog] table of %s", t, configFilename)})
+		}
+	}
+	if !missing {
+		if err := weblog.CheckTemplates(weblog.Options{Config: cfg}); err != nil {
+			for _, e := range errorsIn(err) {
+				d.fail(diagnosis{"blog templates", e.Error(), "correct the template; the message gives the line at fault"})
+			}
+		} else {
+			d.pass("blog templates", cfg.Blog.IndexTemplate+", "+cfg.Blog.ArticleTemplate)
+		}
+	}
+
+	ds, err := weblog.LoadDescriptors(cfg.Blog.Descriptors)
+	if err != nil {
+		d.fail(diagnosis{"descriptors", err.Error(), "correct the JSON in " + cfg.Blog.Descriptors})
+		return
+	}
+	if err := weblog.CheckDescriptors(ds); err != nil {
+		for _, e := range errorsIn(err) {
+			fix := "run sitehammer check on a terminal, which offers to fill in missing fields"
+			if de, ok := e.(*weblog.DescriptorError); ok && de.Suggestion != "" {
+				fix = de.Suggestion
+			}
+			d.fail(diagnosis{"descriptors", e.Error(), fix})
+		}
+	} else {
+		d.pass("descriptors", fmt.Sprintf("%d article(s) in %s", len(ds), cfg.Blog.Descriptors))
+	}
+	d.checkSources(cfg, ds)
+}
+
+// checkSources checks that every described article has an abstract under the blog's sources,
+// and that every article directory there is described.
+func (d *doctor) checkSources(cfg *config.Config, ds []weblog.Descriptor) {
+	sources := filepath.FromSlash(cfg.Blog.Sources)
+	described := make(map[string]bool)
+	ok := true
+	for _, desc := range ds {
+		id := strconv.FormatUint(uint64(desc.Id), 10)
+		described[id] = true
+		abstract := filepath.Join(sources, id, "abstract")
+		if !fileExists(abstract) {
+			ok = false
+			d.fail(diagnosis{"article sources", fmt.Sprintf("article %s has no abstract", id), "create " + abstract + ", or remove the article from " + cfg.Blog.Descriptors})
+		}
+	}
+
+	entries, err := ioutil.ReadDir(sources)
+	if err != nil {
+		d.fail(diagnosis{"article sources", err.Error(), fmt.Sprintf("create %s, or change sources in the [blog] table of %s", sources, configFilename)})
+		return
+	}
+	var orphans []string
+	for _, e := range entries {
+		if _, err := strconv.ParseUint(e.Name(), 10, 0); err == nil && e.IsDir() && !described[e.Name()] {
+			orphans = append(orphans, e.Name())
+		}
+	}
+	sort.Strings(orphans)
+	for _, id := range orphans {
+		ok = false
+		d.fail(diagnosis{"article sources", fmt.Sprintf("%s has no descriptor, so it's never published", filepath.Join(sources, id)), "describe article " + id + " in " + cfg.Blog.Descriptors + ", or remove the directory"})
+	}
+	if ok {
+		d.pass("article sources", "every article has an abstract, and every article directory a descriptor")
+	}
+}
+
+// checkWritable checks that files can be created in the named directory, or in its nearest existing ancestor
+// if it doesn't exist yet, as a build would create it.
+func (d *doctor) checkWritable(name, dir string) {
+	existing := dir
+	for !fileExists(existing) {
+		parent := filepath.Dir(existing)
+		if parent == existing {
+			break
+		}
+		existing = parent
+	}
+	if info, err := os.Stat(existing); err == nil && !info.IsDir() {
+		d.fail(diagnosis{name, existing + " is a file, not a directory", "remove or rename " + existing})
+		return
+	}
+	f, err := ioutil.TempFile(existing, ".sitehammer-doctor-")
+	if err != nil {
+		d.fail(diagnosis{name, fmt.Sprintf("%s isn't writable: %v", existing, err), "grant yourself write permission on " + existing + ", or build somewhere else"})
+		return
+	}
+	f.Close()
+	os.Remove(f.Name())
+	d.pass(name, dir+" is writable")
+}
+
+// checkTools checks that the external commands the configuration calls for can be found.
+// The Sass compiler is only needed if the site has Sass stylesheets.
+func (d *doctor) checkTools(cfg *config.Config) {
+	type tool struct {
+		purpose, command, setting string
+	}
+	var tools []tool
+	if hasSass(cfg) {
+		tools = append(tools, tool{"Sass stylesheets", cfg.Sass.Command, "command in the [sass] table"})
+	}
+	if cfg.Images.Optimize {
+		var exts []string
+		for ext := range cfg.Images.Commands {
+			exts = append(exts, ext)
+		}
+		sort.Strings(exts)
+		for _, ext := range exts {
+			tools = append(tools, tool{ext + " images", cfg.Images.Commands[ext], "the [images.commands] table"})
+		}
+	}
+	if cfg.Compress.Brotli {
+		tools = append(tools, tool{"Brotli compression", cfg.Compress.BrotliCommand, "brotli_command in the [compress] table"})
+	}
+	if cfg.Blog.GitHistory {
+		tools = append(tools, tool{"articles' histories", "git", "git_history in the [blog] table"})
+	}
+	var profiles []string
+	for name := range cfg.Deploy {
+		profiles = append(profiles, name)
+	}
+	sort.Strings(profiles)
+	for _, name := range profiles {
+		p, _ := cfg.Deploy.Profile(name)
+		table := "the [deploy." + name + "] table"
+		switch p.Target {
+		case config.TargetRsync:
+			tools = append(tools, tool{"deploying " + name, "rsync", "the target of " + table})
+		case config.TargetGitHub:
+			tools = append(tools, tool{"deploying " + name, "git", "the target of " + table})
+		case config.TargetSftp:
+			tools = append(tools, tool{"deploying " + name, "sftp", "the target of " + table})
+		}
+		if p.Target == config.TargetRsync && p.Ssh != "" {
+			tools = append(tools, tool{"deploying " + name, p.Ssh, "ssh in " + table})
+		}
+	}
+
+	for _, t := range tools {
+		fields := strings.Fields(t.command)
+		if len(fields) == 0 {
+			d.fail(diagnosis{"tools", "no command is configured for " + t.purpose, fmt.Sprintf("set %s of %s", t.setting, configFilename)})
+			continue
+		}
+		path, err := exec.LookPath(fields[0])
+		if err != nil {
+			d.fail(diagnosis{"tools", fmt.Sprintf("%s, needed for %s, can't be found", fields[0], t.purpose), fmt.Sprintf("install %s, or change %s of %s", fields[0], t.setting, configFilename)})
+			continue
+		}
+		d.pass("tools", fmt.Sprintf("%s, for %s, is %s", fields[0], t.purpose, path))
+	}
+}
+
+// hasSass answers true if the site's sources hold any Sass stylesheets.
+// The output directory, the build cache, and hidden directories aren't searched.
+func hasSass(cfg *config.Config) bool {
+	found := false
+	filepath.Walk(".", func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		if info.IsDir() {
+			if path != "." && (strings.HasPrefix(info.Name(), ".") || filepath.Clean(path) == filepath.Clean(cfg.Output.Dir)) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		switch filepath.Ext(path) {
+		case ".scss", ".sass":
+			found = true
+			return filepath.SkipAll
+		}
+		return nil
+	})
+	return found
+}
+
+// errorsIn answers the errors err holds, if it's a list of errors, or err alone otherwise.
+func errorsIn(err error) []error {
+	if list, ok := err.(interface{ Errors() []error }); ok {
+		return list.Errors()
+	}
+	return []error{err}
+}
+
+// fileExists answers true if the named file or directory exists.
+func fileExists(name string) bool {
+	_, err := os.Stat(name)
+	return err == nil
+}
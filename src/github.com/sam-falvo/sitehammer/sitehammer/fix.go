@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/sam-falvo/sitehammer/config"
+	"github.com/sam-falvo/sitehammer/report"
+	"github.com/sam-falvo/sitehammer/scaffold"
+	"github.com/sam-falvo/sitehammer/weblog"
+	"os"
+	"strings"
+)
+
+// interactive answers true if sitehammer may ask the user questions:
+// if both standard input and standard output are terminals, and reports are written as text.
+func interactive() bool {
+	return !report.IsJSON() && report.IsTerminal(os.Stdin) && report.IsTerminal(os.Stdout)
+}
+
+// offerFixes checks the named descriptor file and, if any article lacks a title, author, or publication date,
+// offers to fix them by asking for the missing fields, writing the answers back to the file.
+// Problems left unfixed are for the caller to report.
+// It does nothing unless sitehammer is running interactively, and says nothing of a file it can't read,
+// leaving the error to whatever reads the file next.
+// It answers true if it changed the file.
+func offerFixes(cfg *config.Config, filename string) (bool, error) {
+	if !interactive() {
+		return false, nil
+	}
+	ds, err := weblog.LoadDescriptors(filename)
+	if err != nil {
+		return false, nil
+	}
+	n := fixable(weblog.CheckDescriptors(ds))
+	if n == 0 {
+		return false, nil
+	}
+
+	fmt.Printf("%d article(s) in %s lack a title, author, or publication date. Fill them in now? [Y/n] ", n, filename)
+	in := bufio.NewReader(os.Stdin)
+	line, _ := in.ReadString('\n')
+	if answer := strings.ToLower(strings.TrimSpace(line)); answer != "" && answer != "y" && answer != "yes" {
+		return false, nil
+	}
+	fixed, err := scaffold.FixDescriptors(scaffold.FixOptions{
+		Filename: filename,
+		In:       in,
+		Out:      os.Stdout,
+		Author:   cfg.Blog.Author,
+	})
+	if err != nil || fixed == 0 {
+		return false, err
+	}
+	report.Event("fixed", fmt.Sprintf("fixed %d article descriptor(s) in %s", fixed, filename), report.Fields{"path": filename, "fixed": fixed})
+	return true, nil
+}
+
+// fixable answers how many articles have problems, among those weblog.CheckDescriptors answers in err,
+// that FixDescriptors can fix: those of a missing title, author, or publication date.
+func fixable(err error) int {
+	errors := []error{err}
+	if list, ok := err.(interface{ Errors() []error }); ok {
+		errors = list.Errors()
+	}
+	articles := make(map[uint]bool)
+	for _, e := range errors {
+		if d, ok := e.(*weblog.DescriptorError); ok && d.Field != "Id" {
+			articles[d.Id] = true
+		}
+	}
+	return len(articles)
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"fmt"
+	"github.com/sam-falvo/sitehammer/config"
+	"github.com/sam-falvo/sitehammer/directory"
+	"github.com/sam-falvo/sitehammer/failure"
+	"github.com/sam-falvo/sitehammer/importer"
+	"github.com/sam-falvo/sitehammer/report"
+	"github.com/sam-falvo/sitehammer/scaffold"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// importers maps each format the import subcommand understands to the function reading it, given the export's name.
+var importers = map[string]func(string, importer.Options) (*importer.Result, error){
+	"feed":   fromSource(importer.Feed),
+	"ghost":  fromSource(importer.Ghost),
+	"tumblr": importer.Tumblr,
+}
+
+// fetchTimeout limits how long an export given by URL may take to download.
+const fetchTimeout = time.Minute
+
+// fromSource adapts an importer reading an export from a reader to one given the export's name:
+// a file, or an http or https URL, which is downloaded.
+func fromSource(read func(io.Reader, importer.Options) (*importer.Result, error)) func(string, importer.Options) (*importer.Result, error) {
+	return func(name string, opts importer.Options) (*importer.Result, error) {
+		if !strings.HasPrefix(name, "http://") && !strings.HasPrefix(name, "https://") {
+			f, err := os.Open(name)
+			if err != nil {
+				return nil, err
+			}
+			defer f.Close()
+			return read(f, opts)
+		}
+		req, err := http.NewRequest("GET", name, nil)
+		if err != nil {
+			return nil, usageError("Cannot understand the URL %q: %v", name, err)
+		}
+		req.Header.Set("User-Agent", "SiteHammer importer")
+		resp, err := (&http.Client{Timeout: fetchTimeout}).Do(req)
+		if err != nil {
+			return nil, failure.Wrap(failure.IO, fmt.Errorf("Cannot download %s: %v", name, err))
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, failure.Wrap(failure.IO, fmt.Errorf("Cannot download %s: the server answered %s.", name, resp.Status))
+		}
+		return read(resp.Body, opts)
+	}
+}
+
+// runImport implements the import subcommand, adding the posts exported from another blogging system to the blog.
+func runImport(cfg *config.Config, args []string) error {
+	if len(args) == 0 {
+		return usageError("The import command needs to know what to import: feed, ghost, or tumblr.")
+	}
+	if args[0] == "-h" || args[0] == "-help" {
+		// As from sitehammer help import.
+		fmt.Println("USAGE: sitehammer import feed|ghost|tumblr [-dry-run] [-drafts] [-author name] [-email address] [-media dir] export")
+		return nil
+	}
+	read, ok := importers[args[0]]
+	if !ok {
+		return usageError("The import command cannot import %q; it imports feed, ghost, or tumblr.", args[0])
+	}
+
+	format := args[0]
+	flags := newFlagSet("import "+format, "[-dry-run] [-drafts] [-author name] [-email address] [-media dir] export")
+	dryRun := flags.Bool("dry-run", false, "Lists the articles that would be added, without adding them.")
+	var opts importer.Options
+	flags.BoolVar(&opts.Drafts, "drafts", false, "Imports drafts and scheduled posts too, dated as of their last update.")
+	flags.StringVar(&opts.Author, "author", cfg.Blog.Author, "Names the author of posts for which the export names none.")
+	flags.StringVar(&opts.Email, "email", cfg.Blog.Email, "Gives the email address of that author.")
+	mediaDir := flags.String("media", "media", "Names the directory of the site into which the media files the export holds are copied.")
+	flags.Parse(args[1:])
+	if flags.NArg() != 1 {
+		return usageError("The import %s command takes one export, but was given %d.", format, flags.NArg())
+	}
+	dir := filepath.ToSlash(filepath.Clean(*mediaDir))
+	if filepath.IsAbs(*mediaDir) || dir == "." || dir == ".." || strings.HasPrefix(dir, "../") {
+		return usageError("The -media directory must lie within the site, not %q.", *mediaDir)
+	}
+	opts.MediaUrl = "/" + dir
+	opts.Markdown = cfg.Markdown.Extensions()
+
+	result, err := read(flags.Arg(0), opts)
+	if err != nil {
+		return err
+	}
+	for _, s := range result.Skipped {
+		report.Event("skip", fmt.Sprintf("skipped %s: %s", s.Name, s.Reason), report.Fields{"name": s.Name, "reason": s.Reason})
+	}
+
+	added := make([]scaffold.Article, 0, len(result.Articles))
+	for _, a := range result.Articles {
+		if a.Descriptor.Author == "" {
+			report.Warning("skipped %q: the export names no author; give one with -author", a.Descriptor.Title)
+			continue
+		}
+		added = append(added, a)
+	}
+	if *dryRun {
+		for _, a := range added {
+			d := a.Descriptor
+			message := fmt.Sprintf("would add %q, by %s, published %s", d.Title, d.Author, d.Published)
+			if len(d.Tags) > 0 {
+				message += ", tagged " + strings.Join(d.Tags, ", ")
+			}
+			report.Event("dry-run", message,
+				report.Fields{"verb": "import", "title": d.Title, "author": d.Author, "published": d.Published, "tags": d.Tags})
+		}
+	} else {
+		descriptors, err := scaffold.AddArticles(cfg, added)
+		if err != nil {
+			return err
+		}
+		for _, d := range descriptors {
+			report.Event("import", fmt.Sprintf("added article %d, %q", d.Id, d.Title), report.Fields{"id": d.Id, "title": d.Title})
+		}
+	}
+	if len(result.Files) > 0 {
+		names := make([]string, 0, len(result.Files))
+		for name := range result.Files {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		if !*dryRun {
+			err := copyMedia(dir, result.Files)
+			if err != nil {
+				return err
+			}
+		}
+		verb := "copied"
+		if *dryRun {
+			verb = "would copy"
+		}
+		report.Event("copy-media", fmt.Sprintf("%s %d media files into %s", verb, len(names), dir),
+			report.Fields{"dir": dir, "files": names, "dry_run": *dryRun})
+	}
+	if len(result.Media) > 0 {
+		report.Event("media", fmt.Sprintf("the articles refer to %d media files, which must be copied into the site by hand:\n\t%s", len(result.Media), strings.Join(result.Media, "\n\t")),
+			report.Fields{"media": result.Media})
+	}
+	skipped := len(result.Skipped) + len(result.Articles) - len(added)
+	verb := "imported"
+	if *dryRun {
+		verb = "would be imported"
+	}
+	report.Event("imported", fmt.Sprintf("%d articles %s from %s; %d entries skipped", len(added), verb, format, skipped),
+		report.Fields{"format": format, "imported": len(added), "skipped": skipped, "dry_run": *dryRun})
+	return nil
+}
+
+// copyMedia writes the media files an export holds into the site's directory dir,
+// adding a directory configuration file to each directory on the way to it lacking one, so that they're published.
+// Files already there are overwritten.
+func copyMedia(dir string, files map[string][]byte) error {
+	err := os.MkdirAll(filepath.FromSlash(dir), 0755)
+	if err != nil {
+		return err
+	}
+	for d := dir; d != "."; d = path.Dir(d) {
+		marker := filepath.Join(filepath.FromSlash(d), config.DirectoryFilename)
+		if _, err := os.Stat(marker); os.IsNotExist(err) {
+			err = ioutil.WriteFile(marker, []byte("# This file marks the directory as one to publish.\n"), 0644)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	for name, content := range files {
+		err = directory.WriteFileAtomic(filepath.Join(filepath.FromSlash(dir), name), content, 0644)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/sam-falvo/sitehammer/directory"
+	"github.com/sam-falvo/sitehammer/failure"
+	"github.com/sam-falvo/sitehammer/report"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// interruptible answers a context cancelled by an interrupt (SIGINT) or a termination request (SIGTERM),
+// for a command to stop at the next convenient point, once it has cleaned up after itself.
+// A second signal stops the command at once.
+// The caller must call the answered function once the command is done, to stop listening for signals.
+func interruptible() (context.Context, func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	done := make(chan struct{})
+	go func() {
+		select {
+		case sig := <-signals:
+			// Restore the default handling, so a second signal stops the command at once.
+			signal.Reset(os.Interrupt, syscall.SIGTERM)
+			report.Warning("%v; stopping once the work in hand is finished (signal again to stop at once)", sig)
+			cancel()
+		case <-done:
+		}
+	}()
+	return ctx, func() {
+		signal.Stop(signals)
+		close(done)
+		cancel()
+	}
+}
+
+// interrupted answers err as an interruption if ctx was cancelled by a signal, since whatever failed
+// (a hook killed, or a build abandoned partway) failed because of it; otherwise, it answers err unchanged.
+func interrupted(ctx context.Context, err error) error {
+	if err == nil || ctx.Err() == nil {
+		return err
+	}
+	return failure.Wrap(failure.Interrupted, fmt.Errorf("Interrupted; every page written is complete, and partial work has been cleaned up. (%v)", err))
+}
+
+// removeLeftovers removes the temporary files a build killed outright left in the output directory, reporting each.
+func removeLeftovers(outputDir string, dryRun bool) {
+	if dryRun {
+		return
+	}
+	removed, err := directory.RemoveLeftovers(outputDir)
+	if err != nil {
+		report.Warning("cannot remove the leftovers of an earlier build: %v", err)
+	}
+	for _, name := range removed {
+		report.Event("remove", fmt.Sprintf("removed %s (left over from an interrupted build)", name), report.Fields{"path": name, "reason": "leftover"})
+	}
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/sam-falvo/sitehammer/config"
+	"github.com/sam-falvo/sitehammer/report"
+	"github.com/sam-falvo/sitehammer/weblog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Statuses of an article, as the list subcommand shows them.
+const (
+	statusBuilt   = "built"
+	statusUnbuilt = "unbuilt"
+	statusInvalid = "invalid"
+)
+
+// listFilter selects the articles the list subcommand shows; empty criteria select everything.
+type listFilter struct {
+	tag    string
+	author string
+	status string
+	since  time.Time
+}
+
+// selects answers true if the filter selects the article with the given descriptor and status.
+func (f listFilter) selects(d weblog.Descriptor, status string) bool {
+	if f.author != "" && !strings.EqualFold(d.Author, f.author) {
+		return false
+	}
+	if f.status != "" && status != f.status {
+		return false
+	}
+	if !f.since.IsZero() {
+		date, ok := d.Date()
+		if !ok || date.Before(f.since) {
+			return false
+		}
+	}
+	if f.tag == "" {
+		return true
+	}
+	for _, tag := range d.Tags {
+		if strings.EqualFold(tag, f.tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// runList implements the list subcommand, showing the blog's articles in a table.
+func runList(cfg *config.Config, args []string) error {
+	flags := newFlagSet("list", "[-tag tag] [-author name] [-since date] [-status built|unbuilt|invalid] [descs.json]")
+	var filter listFilter
+	var since string
+	flags.StringVar(&filter.tag, "tag", "", "Lists only articles bearing the given tag.")
+	flags.StringVar(&filter.author, "author", "", "Lists only articles by the given author.")
+	flags.StringVar(&since, "since", "", "Lists only articles published on or after the given `date`, such as 2012-Jan-01 or 2012-01-01.")
+	flags.StringVar(&filter.status, "status", "", "Lists only articles with the given status: built, unbuilt, or invalid.")
+	flags.Parse(args)
+	descriptors := cfg.Blog.Descriptors
+	switch flags.NArg() {
+	case 0:
+	case 1:
+		descriptors = flags.Arg(0)
+	default:
+		return usageError("The list command takes at most one descriptor file, but was given %d.", flags.NArg())
+	}
+	switch filter.status {
+	case "", statusBuilt, statusUnbuilt, statusInvalid:
+	default:
+		return usageError("Status must be %s, %s, or %s, not %q.", statusBuilt, statusUnbuilt, statusInvalid, filter.status)
+	}
+	if since != "" {
+		date, ok := weblog.Descriptor{Published: since}.Date()
+		if !ok {
+			return usageError("Cannot understand the date %q; write it like %s.", since, weblog.DateLayouts[0])
+		}
+		filter.since = date
+	}
+	if flags.NArg() == 0 && !hasBlog(cfg) {
+		return usageError("The site has no blog to list; %s doesn't exist.", descriptors)
+	}
+
+	ds, err := weblog.LoadDescriptors(descriptors)
+	if err != nil {
+		return err
+	}
+	invalid := invalidArticles(cfg, ds)
+
+	var table bytes.Buffer
+	w := tabwriter.NewWriter(&table, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tDATE\tTITLE\tSTATUS\tTAGS")
+	listed := 0
+	for _, d := range ds {
+		status := articleStatus(cfg, d, invalid)
+		if !filter.selects(d, status) {
+			continue
+		}
+		listed++
+		row := fmt.Sprintf("%d\t%s\t%s\t%s\t%s", d.Id, d.Published, d.Title, status, strings.Join(d.Tags, ", "))
+		if report.IsJSON() {
+			report.Event("article", row, report.Fields{"id": d.Id, "published": d.Published, "title": d.Title, "author": d.Author, "status": status, "tags": d.Tags})
+		}
+		fmt.Fprintln(w, row)
+	}
+	w.Flush()
+	if !report.IsJSON() {
+		fmt.Print(table.String())
+	}
+	report.Event("listed", fmt.Sprintf("%d of %d articles", listed, len(ds)), report.Fields{"listed": listed, "articles": len(ds)})
+	return nil
+}
+
+// invalidArticles answers the IDs of the articles whose descriptors are invalid, or which lack abstracts.
+func invalidArticles(cfg *config.Config, ds []weblog.Descriptor) map[uint]bool {
+	invalid := make(map[uint]bool)
+	problems := []error{weblog.CheckDescriptors(ds)}
+	if list, ok := problems[0].(interface{ Errors() []error }); ok {
+		problems = list.Errors()
+	}
+	for _, err := range problems {
+		if d, ok := err.(*weblog.DescriptorError); ok {
+			invalid[d.Id] = true
+		}
+	}
+	for _, d := range ds {
+		abstract := filepath.Join(filepath.FromSlash(cfg.Blog.Sources), strconv.FormatUint(uint64(d.Id), 10), "abstract")
+		if _, err := os.Stat(abstract); err != nil {
+			invalid[d.Id] = true
+		}
+	}
+	return invalid
+}
+
+// articleStatus answers the status of an article:
+// invalid, if its descriptor is invalid or it lacks an abstract; built, if its page is in the output directory; or unbuilt.
+func articleStatus(cfg *config.Config, d weblog.Descriptor, invalid map[uint]bool) string {
+	if invalid[d.Id] {
+		return statusInvalid
+	}
+	page := filepath.Join(cfg.Output.Dir, weblog.ArticleDirName, strconv.FormatUint(uint64(d.Id), 10), weblog.IndexFilename)
+	if _, err := os.Stat(page); err != nil {
+		return statusUnbuilt
+	}
+	return statusBuilt
+}
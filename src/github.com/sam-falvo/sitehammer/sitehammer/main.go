@@ -0,0 +1,662 @@
+/*
+The sitehammer command builds, previews, and maintains a site.
+It does its work through subcommands:
+
+USAGE: sitehammer [command] [flags] [arguments]
+
+	build   builds the whole site: the static files of the source directory, followed by the blog
+	blog    renders only the blog
+	serve   serves the output directory over HTTP, optionally rebuilding as sources change
+	new     creates a new blog article, a new site, or a configuration file spelling out every setting
+	clean   removes the outputs of earlier builds
+	cache   manages the caches kept between builds, such as by removing them
+	import  imports the posts exported from another blogging system, such as Ghost or Tumblr, or from a feed, into the blog
+	deploy  publishes the built site where a deployment profile says: to an Amazon S3 bucket, over rsync, to GitHub Pages, to Netlify or Neocities, over SFTP or FTP, or to IPFS
+	check   checks the configuration, templates, and article descriptors for mistakes, without building
+	stats   summarizes the blog's content: words, dates, and tags
+	list    lists the blog's articles, optionally filtered by tag, author, date, or status
+	open    opens a built page, such as an article's, in a web browser
+	doctor  diagnoses why a build might fail, checking the site's layout, permissions, and tools
+	help    describes the commands
+
+Without a command, or when the first argument is a flag, sitehammer builds the site, just as it always has,
+so that sitehammer -dry-run means sitehammer build -dry-run.
+Every command reads the same configuration, from sitehammer.toml if it exists (see the config package),
+and each takes its own flags, which sitehammer help command lists.
+The -config flag, which every command takes, and which may come before the command name, names the configuration file outright;
+without it, sitehammer looks for ./sitehammer.toml, then ./_config/sitehammer.toml,
+then sitehammer/sitehammer.toml under $XDG_CONFIG_HOME (or ~/.config), taking the first it finds.
+Either way, the paths the configuration holds are relative to the current directory.
+A missing file named by -config is an error; finding no file at all on the search path is not.
+
+Every command also takes the -log-format flag.
+With -log-format json, everything a command reports, from warnings, problems found by -validate and -a11y,
+and dry-run notices to the build's summary and any error that stops it, is written to standard output as one JSON object per line,
+for CI scripts, editors, and other wrappers to read; see the report package for the events reported.
+Hook output is relayed as hook events, and the progress indicator is suppressed.
+
+Every command also takes the -problems flag, naming a file into which it writes, as it finishes, whether it succeeds or fails,
+a JSON array of the warnings and errors it reported, each with the path and line at fault, where known,
+its severity (error or warning), a code classifying it, and its message:
+
+	[
+	  {"path": "_site/about.html", "line": 12, "severity": "warning", "code": "html", "message": "<img> has no alt attribute"},
+	  {"path": "templates/blog-index.html", "line": 3, "severity": "error", "code": "template", "message": "..."}
+	]
+
+Editor integrations and CI annotators can read it to show each problem beside the source at fault.
+A run with no problems writes an empty array; see report.Entry for the codes.
+
+A command which fails exits with a status telling what kind of failure stopped it,
+so that wrapper scripts can react accordingly:
+
+	1    a failure of no particular class, such as a hook which failed, or a bug in sitehammer itself
+	2    a usage error: bad flags or arguments, or a mistake in sitehammer.toml
+	3    a validation failure: invalid article descriptors, or HTML problems found by -validate or -a11y in strict mode
+	4    a content error, such as an article with no abstract, or a reference to an undefined variable
+	5    a template error: a template which doesn't parse, or fails while rendering
+	6    an I/O failure: a file which can't be read or written
+	130  an interrupt (SIGINT) or termination request (SIGTERM), once the command has cleaned up
+
+When the failures of a -keep-going build are of several classes, the status is 1.
+With -log-format json, each error event's class field names its class.
+
+# Build
+
+USAGE: sitehammer build [-dry-run] [-atomic] [-force] [-prune | -prune-dry-run] [-fingerprint] [-symlinks follow|link|skip] [-validate off|warn|strict] [-a11y off|warn|strict] [-quiet] [-keep-going] [-resume] [-cpuprofile file] [-memprofile file] [-trace file] [-timings] [-debug-template] [-diff] [-base-url url]
+
+The build command builds an entire site in one go: the static files of the source directory, followed by the blog.
+Both passes write into the same output directory, ./_site unless configured otherwise.
+The static pass runs first, so that when the blog renders, its templates' Asset function sees the names of freshly fingerprinted assets.
+The blog's index page lands at the root of the output directory, with its articles beneath the articles subdirectory.
+If the blog table's json_api setting is true, each article's page has an index.json beside it,
+and articles.json and tags.json sit beside the index page, for tools reading the blog as JSON; see the weblog package.
+If the configured descriptor file doesn't exist, the site has no blog, and only the static pass runs.
+Should a static file and a blog page share a name (typically index.html), the blog page wins, and a warning is printed.
+Outputs whose names differ only by case, like Index.html and index.html, are an error,
+since they'd overwrite each other on a case-insensitive filesystem.
+
+Builds are incremental; the -force flag ignores the build caches and rebuilds everything.
+When -fingerprint is given, stylesheets, scripts, and images are published under names carrying a hash of their contents
+(e.g., css.css becomes css.0123456789.css), so web servers may tell browsers to cache them indefinitely.
+The names chosen are recorded in _assets.json, where the blog's Asset template function finds them.
+After copying files, the static pass builds each bundle the configuration declares,
+concatenating and (unless disabled) minifying its constituent stylesheets or scripts into a single output file.
+
+Files that once were published but no longer correspond to any source file or article linger in the output directory until removed.
+The -prune flag removes them, along with any directories left empty, after a successful build.
+The -prune-dry-run flag lists what -prune would remove, without removing anything.
+Normally, the output directory is updated in place, so a build failing partway through leaves it half-updated.
+The -atomic flag builds into ./_site.inprogress instead, starting from a copy of ./_site,
+and swaps the result into place only once the build has succeeded; a failed build discards ./_site.inprogress.
+The -dry-run flag previews a build without touching the filesystem,
+printing each output it would create or overwrite, and with -prune, each it would remove,
+along with the reason: new, changed, or orphaned.
+
+The -symlinks flag decides what becomes of symbolic links in the source directory.
+With follow (the default), the file a link refers to is published under the link's name;
+links to directories and dangling links are skipped with a warning.
+With link, the link itself is recreated in the output directory, pointing wherever the original points.
+With skip, symbolic links are ignored.
+FIFOs, sockets, devices, and other special files are never published; they're skipped with a warning.
+
+The -validate flag checks every HTML page built for structural problems,
+such as unclosed tags, duplicate ids, and invalid nesting; see the htmlcheck package.
+With warn, problems are reported and the build carries on; with strict, any problem fails the build
+(and, with -atomic, leaves the output directory untouched).
+The -a11y flag, in the same way, checks HTML pages for accessibility problems,
+such as images without alt text, links without text, skipped heading levels, and missing lang attributes.
+
+The -base-url flag (or -u) overrides the base_url setting of sitehammer.toml for this build alone,
+say for a preview published at a different address than usual.
+Everything derived from the base URL honors the override: article permalinks and the blog's home link,
+and the @@base_url@@ substitution variable of text assets, which are rebuilt to match.
+Since both passes share one output directory, -prune removes only those files that neither pass produced,
+and -atomic swaps the whole output directory into place only once both passes have succeeded.
+
+After a successful build, sitehammer writes a manifest of every generated file, with its sources, size, and content hash,
+to _manifest.json unless configured otherwise; see the manifest package.
+It also records every source the build used, with its content hash and the outputs built from it,
+in .sitehammer-cache/metadata.json, for later incremental builds and cleanups to consult.
+If the site is kept in git, the commit built is recorded too, in .sitehammer-cache/git.json;
+the next build asks git which files changed since, and trusts the hashes recorded of the rest without reading them again,
+though their modification times have changed, as they all have in a fresh clone.
+The blog's article pages are recorded in .sitehammer-cache/blog.json, each with a signature of everything it was rendered from:
+its descriptor, abstract, and body, those of its neighbors, which its next and previous links tell of, its position, the article template,
+the base URL, the asset map, and the blogroll.
+The next build renders again only the articles whose signatures have changed, or whose pages are missing,
+so editing one article renders its page and its neighbors' pages, and editing the article template renders them all;
+the index page is always rendered. The -force flag renders every article regardless.
+So a CI service restoring .sitehammer-cache and the output directory between runs builds only what each push changed.
+Dry runs write none of these.
+
+If the configuration enables offline reading, a service worker and asset manifest covering both passes' outputs
+are generated next; see the offline package.
+The configuration's redirects follow, as the _redirects, vercel.json, or .htaccess files of the hosts its hosting table names,
+or, if it names none, as pages refreshing to their destinations; Apache's .htaccess also names error pages,
+and gives compression and caching headers; see the hosting package.
+If the site has a blogroll, its links are published as an OPML file too, blogroll.opml unless configured otherwise,
+so feed readers subscribe to the same sites its pages list; see the blogroll package.
+If the configuration enables the content index, every page, article, and tag, and the links between them,
+are described in content-index.json, unless configured otherwise, for other tools to query; see the contentindex package.
+If the configuration enables gzip or brotli precompression,
+compressed variants of the static files and blog pages alike are written once both passes are done.
+
+Once done, sitehammer summarizes its work: pages rendered, assets copied, bundles built, outputs found up to date,
+bytes written, the time taken by each phase of the build, and the slowest files and articles to process.
+While building, it shows its progress on standard error, if that's a terminal.
+The -quiet flag suppresses both.
+
+Commands listed in the hooks table of sitehammer.toml run before the build starts and after it succeeds;
+a failing hook fails the build, and a dry run only lists the hooks it would run.
+
+Normally, the first file or article that fails to build stops sitehammer.
+With -keep-going, both passes carry on past failures, and every failure is reported together at the end;
+sitehammer still exits with status 1, and with -atomic, the output directory is left untouched.
+
+A build that fails, unless it's staged with -atomic, remembers how far it got:
+the static pass keeps its record of the outputs it finished, so the next build needn't produce them again,
+and .sitehammer-cache/progress.json records each article rendered, with a signature of everything it was rendered from,
+along with those that failed.
+The next build run with -resume renders again only the articles that failed, that weren't reached,
+or whose descriptors, abstracts, bodies, neighbors, or template have changed since; the index page is always rendered.
+A successful build discards the record.
+
+An interrupt (SIGINT) or termination request (SIGTERM) stops a build or blog run once the file or article in hand is written:
+a staged build discards its staging area, an unstaged one records its progress for -resume,
+and sitehammer exits with status 130. A second signal stops it at once.
+Each build first removes the temporary files, and the article directories left empty, of a build killed outright.
+
+To investigate a slow build, -cpuprofile, -memprofile, and -trace write a CPU profile, a profile of memory allocated,
+and an execution trace of the whole command, for go tool pprof and go tool trace.
+The -timings flag reports, once the build succeeds, the time taken by each phase,
+and the total time spent, and how many times, on each activity across both passes: parsing descriptors, sources, and templates;
+rendering pages; copying files; processing images; and writing outputs.
+It reports them even with -quiet, and in JSON as a timings event; see stats.Stats.ReportTimings.
+The blog command takes the same flags.
+
+The -debug-template flag writes the data each page's template is executed with, as JSON,
+to a file per page beneath .sitehammer-cache/template-data, named after the page with .json appended,
+so that the fields a template sees can be read rather than guessed at; see the templatedump package.
+It covers templates (.tmpl), Markdown pages rendered through the layout, article pages, and the index page.
+A build with -debug-template renders every page, as with -force, and discards the data of earlier builds.
+
+The -diff flag lists, once the build succeeds, each output whose content differs from that of the last successful build,
+as recorded in .sitehammer-cache/metadata.json, for review before deploying:
+
+	new     docs/faq.html (new)
+	changed about.html (content changed: about.md)
+	changed articles/12/index.html (template changed: templates/blog-article.html)
+	changed articles/11/index.html (rebuilt with unchanged sources)
+	removed old.html (no longer built)
+	5 outputs differ from the last build: 1 new, 3 changed, 1 removed; 40 unchanged
+
+An output is put down to whichever of its sources changed: its content, the template it's poured into, or sitehammer.toml.
+One whose sources are all unchanged was affected by something else, such as a neighboring article, a renamed asset, or the date.
+In JSON, each output is a diff event, and the summary a diff-summary event.
+
+# Blog
+
+USAGE: sitehammer blog [-base-url url] [-dry-run] [-atomic] [-prune | -prune-dry-run] [-validate off|warn|strict] [-a11y off|warn|strict] [-quiet] [-keep-going] [-resume] [-force] [-only id,...] [-since date] [-cpuprofile file] [-memprofile file] [-trace file] [-timings] [-debug-template] [descs.json]
+
+The blog command renders the blog alone, into the output directory, much as the blog pass of a build does,
+leaving the static files as they are.
+Templates' Asset function consults the _assets.json file written by the most recent build.
+The descriptor file defaults to the one sitehammer.toml names.
+Its -prune flag removes only pages beneath the articles subdirectory not belonging to a described article,
+and its -atomic flag stages only the articles subdirectory.
+Otherwise, its flags mean the same as the build command's.
+
+The -only and -since flags rerender just a few articles, as after editing them:
+-only takes a comma-separated list of article IDs, and -since selects the articles whose abstracts or bodies changed after the given date,
+in any of the formats of weblog.DateLayouts, such as 2012-01-01 or 2012-01-01 15:04.
+Given both, an article either selects is rendered.
+The neighbors of each article rendered are rendered too, so their next and previous links stay right,
+as are articles never rendered before; the index page is always rendered.
+So are the articles whose pages blog.json records as rendered from inputs that have since changed,
+such as the former neighbors of an article removed, or the neighbors of one retitled.
+The other articles' pages are left as they are.
+Like a build, the blog command renders only articles changed since the last successful rendering, whether by a build or by itself,
+and records those it renders in .sitehammer-cache/blog.json; -force renders every article chosen, changed or not.
+
+# Serve
+
+USAGE: sitehammer serve [-addr host:port] [-watch] [-rebuild-hook] [-base-url url]
+
+The serve command serves the output directory over HTTP, at http://localhost:8000/ unless -addr says otherwise,
+for previewing the site as a browser will see it.
+With -watch, it also watches the source directory, and rebuilds the site whenever a source changes;
+rebuilds work like sitehammer build -quiet, and a failed rebuild is reported without stopping the server.
+The operating system tells of changes as they happen, so nothing is scanned while the site sits unchanged;
+the output and cache directories, and whatever .hammerignore files ignore, aren't watched at all.
+Changing a .hammerignore file, or a directory configuration, has the sources watched anew, as well as rebuilding the site.
+The -base-url flag overrides the base URL for those rebuilds, as it does for build;
+sitehammer serve -watch -base-url http://localhost:8000 makes the site's absolute links lead back to the preview.
+
+With -rebuild-hook, a long-running server rebuilds the site whenever it's asked to, by a POST request to /-/rebuild,
+so a git host's push webhook, or a CMS, can have the site regenerated without anyone shelling in.
+Requests must prove they know the secret the SITEHAMMER_REBUILD_TOKEN environment variable gives, which must be set:
+GitHub's, by signing their bodies with it, as its webhooks do when given it as their secret,
+and others', by giving it as a bearer token, as in Authorization: Bearer secret, or in an X-Gitlab-Token header, as GitLab's webhooks do.
+Requests without it are refused with 401 Unauthorized; the rest are answered with 202 Accepted at once, and the site rebuilt afterwards,
+as -watch rebuilds it, one rebuild at a time, requests arriving while one waits being merged with it.
+To build what was pushed, pull it first in a pre hook, such as git pull --ff-only.
+The server speaks plain HTTP, so put it behind a proxy terminating TLS before exposing it beyond localhost.
+
+# New
+
+USAGE: sitehammer new post [-author name] [-email address] title
+
+The new post command starts a new blog article with the given title.
+It allocates the article the ID following the highest already in use, creates the article's source directory,
+such as ./src/1237, holding stub abstract and body files to fill in,
+and appends a descriptor for the article, published today, to the descriptor file.
+The author and email address default to the author and email settings of the blog table of sitehammer.toml.
+
+USAGE: sitehammer new site [-author name] [-email address] [-base-url url] dir
+
+The new site command creates a skeleton site in the given directory, which must be empty or not yet exist:
+a sitehammer.toml, templates for the blog and for Markdown pages, a stylesheet, a Markdown page, and a sample article,
+which together build as they stand.
+The author defaults to the author setting of the current sitehammer.toml, if any, or else to the user running the command;
+the base URL defaults to that of sitehammer serve.
+
+USAGE: sitehammer new config [-force] [file]
+
+The new config command writes a configuration file, sitehammer.toml unless another is named, spelling out every setting:
+those of the current sitehammer.toml, if it exists, and the defaults of the rest, such as the base URL,
+the blog's templates and sources, and the output directory.
+It helps sites that relied on sitehammer's built-in defaults pin them down, so a release changing a default doesn't change the site,
+and shows at a glance what can be configured.
+It won't overwrite an existing file without -force; name the file - to write to standard output instead.
+Settings written with environment variable references, such as ${SITE_URL:-http://localhost:8000}, are written as they were,
+references and all, so values kept out of the configuration, such as secrets, stay out of the file written.
+
+See the scaffold package for details of posts and sites.
+
+# Clean
+
+USAGE: sitehammer clean [-dry-run] [-cache]
+
+The clean command removes every output the most recent build recorded in .sitehammer-cache/metadata.json,
+along with any directories left empty, leaving files it didn't generate alone.
+With -cache, it removes the cache directory as well, as sitehammer cache clean does.
+The -dry-run flag lists what would be removed, without removing anything.
+
+# Cache
+
+USAGE: sitehammer cache clean [-dry-run]
+
+SiteHammer keeps what it remembers between builds in a cache directory, .sitehammer-cache unless sitehammer.toml's cache table names another:
+the build caches that spare unchanged outputs, optimized images among them, from being built again;
+the metadata store; the record of the git commit last built; the record of a failed build's progress; the link checker's results;
+and the HTML converted from article bodies written in other formats, such as AsciiDoc or reStructuredText.
+Wherever the documentation mentions .sitehammer-cache, it means the configured directory.
+
+The cache clean command removes the cache directory, so the next build starts from scratch,
+rebuilding every output and rechecking every link; the outputs themselves are left alone.
+The -dry-run flag reports what would be removed, without removing anything.
+
+# Import
+
+USAGE: sitehammer import feed|ghost|tumblr [-dry-run] [-drafts] [-author name] [-email address] [-media dir] export
+
+The import command adds the posts exported from another blogging system to the blog, as new articles, oldest first,
+each taking the next free article ID, as with sitehammer new post; see the importer package.
+It reads the JSON export of Ghost, made in Ghost's Labs settings, taking each post's tags and primary author along with it,
+or a Tumblr backup, a directory or zip archive holding either the export made in Tumblr's blog settings or the posts' JSON,
+as saved by backup tools, taking each post's tags along with it,
+or an RSS or Atom feed, for platforms with no proper export but a feed giving the posts' full content,
+taking each entry's title, date, content, categories, and author along with it; a feed may be given by its URL.
+Tumblr's text, photo, quote, link, chat, video, and audio posts become plain HTML: photos become figures, quotes block quotes.
+Pages, drafts, and scheduled posts are skipped, though -drafts imports drafts and scheduled posts too.
+Media files the backup holds are copied into the site's directory given by -media, media by default,
+which is given a directory configuration file, if it lacks one, so that it's published.
+Posts for which the export names no author are credited to the author given by -author,
+which defaults to the one in the blog table of sitehammer.toml, and are skipped if there's none.
+Once done, the command lists the media the articles refer to which the export doesn't hold; they must be copied into the site by hand.
+The -dry-run flag lists the articles that would be added, without adding them.
+Importing the same export twice adds its posts twice.
+
+# Deploy
+
+USAGE: sitehammer deploy profile [-dry-run] [-draft]
+
+The deploy command publishes the site, as last built, where it's served, as the named deployment profile says;
+the deploy table of sitehammer.toml holds the profiles, so that sitehammer deploy production publishes the site
+as the [deploy.production] table describes. See the config package for their settings, and the deploy package.
+It publishes exactly the files the build's manifest lists, so the manifest mustn't be disabled, and the site must be built first.
+Each file uploaded or deleted is reported, followed by a summary.
+
+A profile whose target is s3 copies the site into the Amazon S3 bucket it names,
+beneath its prefix, uploading only the files whose objects are missing or hold different content,
+each with a Content-Type suited to its extension and the Cache-Control header of the first cache_control rule matching it.
+With delete set, objects under the prefix that the build no longer produces are deleted.
+If the table names a CloudFront distribution, the paths of the objects changed or deleted are then invalidated in it,
+an index page's directory along with the page itself; a deployment changing more than a thousand paths invalidates them all.
+Credentials come from the AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY, and AWS_SESSION_TOKEN environment variables.
+
+A profile whose target is rsync copies the site to its destination with rsync, usually over SSH,
+with the ssh command it gives, if any, leaving rsync to copy only what changed.
+With delete set, files at the destination that the build no longer produces are deleted, as rsync's --delete does;
+files matching the profile's exclude patterns are neither copied nor deleted.
+
+A profile whose target is github publishes the site with GitHub Pages, committing it to the profile's branch, gh-pages by default,
+of its repository, by default the one the current directory's origin remote refers to, and pushing the branch.
+The site goes at the root of the branch, or, with dir set, in that directory, such as docs, the rest of the branch being left alone.
+Alongside it go a .nojekyll file, so GitHub doesn't run the site through Jekyll,
+and a CNAME file naming its domain, that of the base URL unless the profile's cname says otherwise.
+With delete set, files there that the build no longer produces are deleted.
+Nothing is committed if nothing changed.
+
+A profile whose target is netlify publishes the site to Netlify as a new deploy, through Netlify's API,
+uploading only the files whose content Netlify doesn't already hold; files the build no longer produces drop out of the deploy.
+The site is the one the profile names, or else the one NETLIFY_SITE_ID does, and NETLIFY_AUTH_TOKEN gives the access token.
+If the profile's draft is set, or -draft is given, the deploy is a draft, published at an address of its own for preview,
+leaving the live site alone; the summary gives the address at which a deploy may be seen, draft or not.
+
+A profile whose target is neocities publishes the site to the Neocities site whose API key NEOCITIES_API_KEY gives,
+uploading only the files whose content differs from the site's, but no precompressed variants, which Neocities refuses;
+with delete set, the site's files that the build doesn't produce are deleted.
+
+A profile whose target is sftp or ftp uploads the site to the directory its destination URL names, such as sftp://user@host/~/public_html,
+with the sftp command, which must log in with a key, or over plain FTP, with the password FTP_PASSWORD gives.
+The manifest deployed is kept there as .sitehammer-deployed.json, and compared with the build's next time,
+so only the files changed since are uploaded; with delete set, files deployed before that the build no longer produces are deleted.
+
+A profile whose target is ipfs adds the site to IPFS through the API of the node its endpoint names, or else a local node's, pinning it there,
+and the summary gives the CID of the directory holding it; IPFS addresses content, so delete means nothing.
+If the profile's ipns names one of the node's keys, the key's IPNS name is published as the new CID;
+if its dnslink names a domain, the domain's DNSLink record, held by Cloudflare in the profile's zone, is pointed at it,
+with the API token CLOUDFLARE_API_TOKEN gives.
+
+The -dry-run flag lists the files that would be uploaded or deleted, and the paths that would be invalidated,
+without changing anything; it still reads the bucket's listing, runs rsync with --dry-run, fetches the branch,
+lists the Netlify or Neocities site's files, or reads the record of the last SFTP or FTP deployment, comparing the build with the live site, so it needs credentials too.
+An ipfs dry run has the node work out the CID the site would have, without storing it, or publishing anything.
+
+# Check
+
+USAGE: sitehammer check
+
+The check command looks for the mistakes a build would stumble on, without writing anything,
+quickly enough to serve as a pre-commit hook.
+It checks that the configuration and every directory configuration file parse,
+that every template (.tmpl), the Markdown layout, and the blog's templates parse,
+that text assets refer only to defined build variables, that the files bundles name exist,
+and, if the site has a blog, that its article descriptors are valid and every article has an abstract.
+It reports every problem found, not just the first, and exits with the status of their class (see above),
+or 1 if they're of several classes.
+On a terminal, descriptor problems are colored and grouped by article, each with a suggestion for fixing it.
+
+When run interactively, with both standard input and standard output terminals, the check, build, and blog commands
+offer to fill in the title, author, or publication date of any article lacking one, asking for each in turn,
+then write the answers back to the descriptor file and carry on.
+A blank answer accepts the suggestion shown in brackets: the configured author, or today's date.
+Dry runs and JSON reports never ask.
+
+# Stats
+
+USAGE: sitehammer stats [-words] [-top n] [descs.json]
+
+The stats command summarizes the blog's content, as the build reads it:
+how many articles and words there are, with the longest and shortest articles (or, with -words, the length of every article),
+how many articles were published each year and month, how many bear each tag, and how often articles appear,
+on average, along with the longest gap between them.
+Words are counted in each article's abstract and body, not counting HTML markup.
+Dates come from the Published fields of the descriptors; see weblog.DateLayouts for the formats understood.
+Articles with invalid descriptors or missing abstracts are left out, with a warning.
+With -log-format json, the summary is a single stats event holding every figure.
+
+# List
+
+USAGE: sitehammer list [-tag tag] [-author name] [-since date] [-status built|unbuilt|invalid] [descs.json]
+
+The list command shows the blog's articles in a table, in the order the descriptor file lists them:
+each article's ID, publication date, title, status, and tags.
+An article's status is invalid if its descriptor has problems (see sitehammer check) or it lacks an abstract,
+built if its page is in the output directory, and unbuilt otherwise.
+The -tag, -author, -since, and -status flags list only the articles matching them;
+tags and authors are matched regardless of case, and -since takes dates in any of the formats of weblog.DateLayouts.
+With -log-format json, each article listed is an article event.
+
+# Open
+
+USAGE: sitehammer open [-addr host:port] [-print] [id | path]
+
+The open command opens a built page in the default web browser:
+the site's home page, with no argument; an article's page, given the article's ID;
+or the page built from a source file, such as about.tmpl or post.md, or found at a path within the output directory.
+If sitehammer serve is listening at the -addr address, localhost:8000 by default, the page is opened through it;
+otherwise, it's opened as a file:// URL.
+The BROWSER environment variable, if set, names the command that opens the browser.
+The -print flag prints the page's URL rather than opening it.
+The page must already be built.
+
+# Doctor
+
+USAGE: sitehammer doctor
+
+The doctor command diagnoses builds that fail mysteriously, such as on a newly set up machine.
+It checks that the configuration is valid, that the Markdown layout exists, and, if the site has a blog,
+that its templates exist and parse, its descriptors are valid, every article has an abstract,
+and every numbered directory under the blog's sources has a descriptor.
+It also checks that the output directory and the build cache are writable,
+and that the external commands the configuration calls for, such as the Sass compiler, image optimizers, brotli, rsync, and git, can be found.
+Each check reports ok or FAIL, and every failure comes with a fix.
+Unlike the other commands, doctor runs even when the configuration can't be loaded, assuming the defaults for the rest of its checks.
+It exits with status 1 if any check fails.
+With -log-format json, each check is a diagnosis event.
+*/
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/sam-falvo/sitehammer/buildcache"
+	"github.com/sam-falvo/sitehammer/config"
+	"github.com/sam-falvo/sitehammer/failure"
+	"github.com/sam-falvo/sitehammer/htmlcheck"
+	"github.com/sam-falvo/sitehammer/report"
+	"os"
+	"runtime/debug"
+	"strings"
+)
+
+// command describes one of sitehammer's subcommands.
+type command struct {
+	name    string
+	summary string
+	run     func(cfg *config.Config, args []string) error
+}
+
+// commands lists sitehammer's subcommands, in the order help lists them.
+var commands = []command{
+	{"build", "Builds the whole site.", runBuild},
+	{"blog", "Renders only the blog.", runBlog},
+	{"serve", "Serves the output directory over HTTP, optionally rebuilding as sources change.", runServe},
+	{"new", "Creates a new blog article, site, or configuration file.", runNew},
+	{"clean", "Removes the outputs of earlier builds.", runClean},
+	{"cache", "Manages the caches kept between builds.", runCache},
+	{"import", "Imports the posts exported from another blogging system.", runImport},
+	{"deploy", "Publishes the built site where it's served.", runDeploy},
+	{"check", "Checks the configuration, templates, and article descriptors for mistakes, without building.", runCheck},
+	{"stats", "Summarizes the blog's content.", runStats},
+	{"list", "Lists the blog's articles.", runList},
+	{"open", "Opens a built page in a web browser.", runOpen},
+	{"doctor", "Diagnoses why a build might fail, suggesting fixes.", func(cfg *config.Config, args []string) error { return runDoctor(cfg, nil, args) }},
+}
+
+// abend abnormally ends the program, usually as a result of some blocking error.
+// The specified diagnostic is printed before terminating the program.
+// The program stops with a shell result code according to the class of the error; see the failure package.
+func abend(reason error) {
+	if reason != nil {
+		report.Error(reason)
+		writeProblems()
+		os.Exit(failure.ExitStatus(reason))
+	}
+}
+
+// writeProblems writes the problem file, if the -problems flag asked for one, warning if it can't.
+func writeProblems() {
+	if err := report.WriteProblems(); err != nil {
+		report.Warning("cannot write the problem file: %v", err)
+	}
+}
+
+// usageError answers an error describing a mistake in how sitehammer was invoked.
+func usageError(format string, args ...interface{}) error {
+	return failure.Wrap(failure.Usage, fmt.Errorf(format, args...))
+}
+
+// newFlagSet answers an empty set of flags for the named subcommand, whose usage message shows the given synopsis.
+func newFlagSet(name, synopsis string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ExitOnError)
+	flags.Var(report.FormatValue{}, "log-format", "Chooses the `format` of reports on progress, problems, and results: text, or json for programs to read.")
+	flags.Var(report.ProblemsValue{}, "problems", "Writes every warning and error, with the file and line at fault where known, to the given JSON `file`, for editors and CI systems.")
+	// Already taken by takeConfigFlag; declared here so that -help lists it.
+	flags.String("config", configFilename, "Reads the configuration from the given `file`, rather than the first found on the search path.")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "USAGE: sitehammer %s %s\n", name, synopsis)
+		flags.PrintDefaults()
+	}
+	return flags
+}
+
+// addCheckFlags adds the -validate and -a11y flags, which the build and blog subcommands share, to flags.
+func addCheckFlags(flags *flag.FlagSet, validate, a11y *string) {
+	flags.StringVar(validate, "validate", htmlcheck.Off, "Checks generated HTML for structural problems: off, warn, or strict.")
+	flags.StringVar(a11y, "a11y", htmlcheck.Off, "Checks generated HTML for accessibility problems: off, warn, or strict.")
+}
+
+// addBaseUrlFlags adds the -base-url flag, which overrides the configured base URL for a single run, to flags,
+// along with -u, its older spelling.
+func addBaseUrlFlags(flags *flag.FlagSet, cfg *config.Config) {
+	flags.Var(config.BaseUrlValue{Config: cfg}, "base-url", "Overrides the base `URL` at which the site is published.")
+	flags.Var(config.BaseUrlValue{Config: cfg}, "u", "Overrides the base `URL`, like -base-url.")
+}
+
+// checkModes answers an error if either mode given by the -validate and -a11y flags isn't one htmlcheck knows.
+func checkModes(validate, a11y string) error {
+	err := htmlcheck.ValidateMode(validate)
+	if err != nil {
+		return err
+	}
+	return htmlcheck.ValidateMode(a11y)
+}
+
+// lookup answers the named subcommand, or nil if there's no such subcommand.
+func lookup(name string) *command {
+	for i := range commands {
+		if commands[i].name == name {
+			return &commands[i]
+		}
+	}
+	return nil
+}
+
+// usage prints a summary of the subcommands.
+func usage() {
+	fmt.Println("USAGE: sitehammer [command] [flags] [arguments]")
+	fmt.Println()
+	for _, c := range commands {
+		fmt.Printf("  %-7s %s\n", c.name, c.summary)
+	}
+	fmt.Println()
+	fmt.Println("Without a command, sitehammer builds the site.")
+	fmt.Println("Use sitehammer help <command> for the flags a command takes.")
+}
+
+// help describes the subcommand named in args, or all of them if none is named.
+func help(cfg *config.Config, args []string) error {
+	if len(args) == 0 {
+		usage()
+		return nil
+	}
+	c := lookup(args[0])
+	if c == nil {
+		return usageError("Unknown command %q; sitehammer help lists the commands.", args[0])
+	}
+	return c.run(cfg, []string{"-help"})
+}
+
+// configFilename names the configuration file in effect: the one given by -config, or else the one config.Find finds.
+var configFilename string
+
+// takeConfigFlag removes the -config flag, which every command takes, and its value from args, wherever they appear before any --.
+// It answers the file named, or "" if there's no -config flag, along with the remaining arguments.
+// Taking the flag before the command's own flags are parsed lets the configuration load before the command runs,
+// and lets the flag come before the command name, as in sitehammer -config site.toml build.
+func takeConfigFlag(args []string) (string, []string, error) {
+	var filename string
+	var rest []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			rest = append(rest, args[i:]...)
+			break
+		}
+		switch {
+		case arg == "-config" || arg == "--config":
+			if i+1 == len(args) {
+				return "", nil, usageError("The -config flag needs the name of a configuration file.")
+			}
+			filename = args[i+1]
+			i++
+		case strings.HasPrefix(arg, "-config=") || strings.HasPrefix(arg, "--config="):
+			filename = arg[strings.IndexByte(arg, '=')+1:]
+		default:
+			rest = append(rest, arg)
+		}
+	}
+	return filename, rest, nil
+}
+
+// crashed reports a panic, which means a bug in sitehammer, as a failure of no particular class, along with the stack for the bug report,
+// rather than letting the runtime exit with status 2, which would pass it off as a usage error.
+func crashed() {
+	if r := recover(); r != nil {
+		os.Stderr.Write(debug.Stack())
+		abend(fmt.Errorf("Internal error: %v", r))
+	}
+}
+
+func main() {
+	defer crashed()
+	filename, args, err := takeConfigFlag(os.Args[1:])
+	abend(err)
+	name := "build"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+
+	configFilename = config.Find()
+	if filename != "" {
+		configFilename = filename
+		if _, err := os.Stat(filename); err != nil {
+			abend(failure.Wrap(failure.Usage, fmt.Errorf("Cannot read the configuration given by -config: %v", err)))
+		}
+	}
+	cfg, err := config.Load(configFilename)
+	if cfg != nil {
+		buildcache.Dir = cfg.Cache.Dir
+	}
+	if name == "doctor" {
+		abend(runDoctor(cfg, err, args))
+		writeProblems()
+		return
+	}
+	abend(failure.Default(failure.Usage, err))
+
+	if name == "help" {
+		abend(help(cfg, args))
+		return
+	}
+	c := lookup(name)
+	if c == nil {
+		abend(usageError("Unknown command %q; sitehammer help lists the commands.", name))
+	}
+	abend(c.run(cfg, args))
+	writeProblems()
+}
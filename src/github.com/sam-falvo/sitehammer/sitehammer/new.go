@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"github.com/sam-falvo/sitehammer/config"
+	"github.com/sam-falvo/sitehammer/directory"
+	"github.com/sam-falvo/sitehammer/report"
+	"github.com/sam-falvo/sitehammer/scaffold"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// runNew implements the new subcommand, which creates things from scratch; what it creates is named by its first argument.
+func runNew(cfg *config.Config, args []string) error {
+	if len(args) == 0 {
+		return usageError("The new command needs to know what to create: post, site, or config.")
+	}
+	switch args[0] {
+	case "-h", "-help":
+		// As from sitehammer help new.
+		fmt.Println("USAGE: sitehammer new post [-author name] [-email address] title")
+		fmt.Println("       sitehammer new site [-author name] [-email address] [-base-url url] dir")
+		fmt.Println("       sitehammer new config [-force] [file]")
+		return nil
+	case "post":
+		return newPost(cfg, args[1:])
+	case "site":
+		return newSite(cfg, args[1:])
+	case "config":
+		return newConfig(cfg, args[1:])
+	}
+	return usageError("The new command cannot create a %q; it creates posts, sites, and configurations.", args[0])
+}
+
+// newPost creates a new blog article, with stub sources and a descriptor.
+func newPost(cfg *config.Config, args []string) error {
+	flags := newFlagSet("new post", "[-author name] [-email address] title")
+	opts := scaffold.PostOptions{Config: cfg}
+	flags.StringVar(&opts.Author, "author", cfg.Blog.Author, "Names the article's author.")
+	flags.StringVar(&opts.Email, "email", cfg.Blog.Email, "Gives the author's email address.")
+	flags.Parse(args)
+	opts.Title = strings.Join(flags.Args(), " ")
+
+	d, err := scaffold.NewPost(opts)
+	if err != nil {
+		return err
+	}
+	dir := filepath.Join(cfg.Blog.Sources, strconv.FormatUint(uint64(d.Id), 10))
+	report.Event("created", fmt.Sprintf("created article %d, %q, in %s", d.Id, d.Title, dir), report.Fields{"id": d.Id, "title": d.Title, "path": dir})
+	return nil
+}
+
+// newSite creates a skeleton site in a new directory.
+func newSite(cfg *config.Config, args []string) error {
+	flags := newFlagSet("new site", "[-author name] [-email address] [-base-url url] dir")
+	var opts scaffold.SiteOptions
+	flags.StringVar(&opts.Author, "author", defaultAuthor(cfg), "Names the blog's author.")
+	flags.StringVar(&opts.Email, "email", cfg.Blog.Email, "Gives the author's email address.")
+	flags.StringVar(&opts.BaseUrl, "base-url", "", "Gives the URL at which the site is to be published.")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		return usageError("The new site command needs exactly one directory in which to create the site.")
+	}
+	opts.Dir = flags.Arg(0)
+
+	err := scaffold.NewSite(opts)
+	if err != nil {
+		return err
+	}
+	report.Event("created", fmt.Sprintf("created a new site in %s; to preview it, run sitehammer serve -watch there", opts.Dir), report.Fields{"path": opts.Dir})
+	return nil
+}
+
+// configHeader opens every configuration file written by newConfig.
+const configHeader = `# SiteHammer configuration, written by sitehammer new config.
+# Every setting is spelled out, holding either the value it had in the configuration it was written from,
+# or the default that applies when it's left out.
+# Settings may be removed to let them take their defaults again; see the config package for what each means.
+
+`
+
+// newConfig writes a configuration file spelling out every setting in effect:
+// those of sitehammer.toml, if it exists, and the defaults of the rest.
+func newConfig(cfg *config.Config, args []string) error {
+	flags := newFlagSet("new config", "[-force] [file]")
+	force := flags.Bool("force", false, "Overwrites the file if it already exists.")
+	flags.Parse(args)
+	filename := config.Filename
+	switch flags.NArg() {
+	case 0:
+	case 1:
+		filename = flags.Arg(0)
+	default:
+		return usageError("The new config command writes at most one file, but was given %d.", flags.NArg())
+	}
+
+	content := append([]byte(configHeader), cfg.Encode()...)
+	if filename == "-" {
+		_, err := os.Stdout.Write(content)
+		return err
+	}
+	if _, err := os.Stat(filename); err == nil && !*force {
+		return usageError("Cannot write %s: it already exists; use -force to overwrite it, or give another file name, or - for standard output.", filename)
+	}
+	err := directory.WriteFileAtomic(filename, content, 0644)
+	if err != nil {
+		return err
+	}
+	report.Event("created", fmt.Sprintf("wrote every setting to %s", filename), report.Fields{"path": filename})
+	return nil
+}
+
+// defaultAuthor answers the author to credit with a new site's articles, absent any other instructions:
+// the configured author, if there is one, or else the user running the command.
+func defaultAuthor(cfg *config.Config) string {
+	if cfg.Blog.Author != "" {
+		return cfg.Blog.Author
+	}
+	u, err := user.Current()
+	if err != nil {
+		return ""
+	}
+	if u.Name != "" {
+		return u.Name
+	}
+	return u.Username
+}
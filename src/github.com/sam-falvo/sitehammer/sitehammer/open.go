@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"github.com/sam-falvo/sitehammer/config"
+	"github.com/sam-falvo/sitehammer/report"
+	"github.com/sam-falvo/sitehammer/static"
+	"github.com/sam-falvo/sitehammer/weblog"
+	"net"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// runOpen implements the open subcommand, opening a built page in a web browser.
+func runOpen(cfg *config.Config, args []string) error {
+	flags := newFlagSet("open", "[-addr host:port] [-print] [id | path]")
+	addr := flags.String("addr", "localhost:8000", "Gives the address of sitehammer serve; if it's listening there, the page is opened through it.")
+	printOnly := flags.Bool("print", false, "Prints the page's URL, without opening it.")
+	flags.Parse(args)
+	target := ""
+	switch flags.NArg() {
+	case 0:
+	case 1:
+		target = flags.Arg(0)
+	default:
+		return usageError("The open command opens one page at a time, but was given %d.", flags.NArg())
+	}
+
+	name, err := resolveOutput(cfg, target)
+	if err != nil {
+		return err
+	}
+	var u string
+	if listening(*addr) {
+		u = (&url.URL{Scheme: "http", Host: *addr, Path: "/" + name}).String()
+	} else {
+		abs, err := filepath.Abs(filepath.Join(cfg.Output.Dir, filepath.FromSlash(name)))
+		if err != nil {
+			return err
+		}
+		u = (&url.URL{Scheme: "file", Path: filepath.ToSlash(abs)}).String()
+	}
+
+	if *printOnly {
+		report.Event("url", u, report.Fields{"path": name, "url": u})
+		return nil
+	}
+	report.Event("open", "opening "+u, report.Fields{"path": name, "url": u})
+	return openBrowser(u)
+}
+
+// resolveOutput answers the slash-separated name, relative to the output directory, of the page target refers to:
+// the site's home page, if target is empty; an article's page, if target is an article ID;
+// or else the output of the named source file, or the named output itself, whether or not it's given with the output directory.
+// A directory stands for its index page.
+// The page must have been built.
+func resolveOutput(cfg *config.Config, target string) (string, error) {
+	var candidates []string
+	switch _, err := strconv.ParseUint(target, 10, 0); {
+	case target == "":
+		candidates = []string{weblog.IndexFilename}
+	case err == nil:
+		candidates = []string{path.Join(weblog.ArticleDirName, target, weblog.IndexFilename)}
+	default:
+		name := filepath.ToSlash(filepath.Clean(target))
+		outputDir := filepath.ToSlash(filepath.Clean(cfg.Output.Dir))
+		if strings.HasPrefix(name, outputDir+"/") {
+			name = strings.TrimPrefix(name, outputDir+"/")
+		}
+		candidates = []string{name, static.DefaultRegistry().For(name).OutputName(name), path.Join(name, weblog.IndexFilename)}
+	}
+
+	for _, name := range candidates {
+		info, err := os.Stat(filepath.Join(cfg.Output.Dir, filepath.FromSlash(name)))
+		if err == nil && !info.IsDir() {
+			return name, nil
+		}
+	}
+	if target == "" {
+		return "", usageError("The site hasn't been built; %s doesn't exist. Run sitehammer build first.", filepath.Join(cfg.Output.Dir, weblog.IndexFilename))
+	}
+	return "", usageError("Cannot find the page for %q in %s; it may not be built yet, in which case run sitehammer build first.", target, cfg.Output.Dir)
+}
+
+// listening answers true if something, presumably sitehammer serve, accepts connections at addr.
+func listening(addr string) bool {
+	conn, err := net.DialTimeout("tcp", addr, 250*time.Millisecond)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+// openBrowser opens u in the user's web browser: the command named by $BROWSER, if it's set,
+// or else the operating system's own means of opening URLs.
+func openBrowser(u string) error {
+	var cmd *exec.Cmd
+	switch browser := os.Getenv("BROWSER"); {
+	case browser != "":
+		fields := strings.Fields(browser)
+		cmd = exec.Command(fields[0], append(fields[1:], u)...)
+	case runtime.GOOS == "darwin":
+		cmd = exec.Command("open", u)
+	case runtime.GOOS == "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", u)
+	default:
+		cmd = exec.Command("xdg-open", u)
+	}
+	err := cmd.Start()
+	if err != nil {
+		return fmt.Errorf("Cannot open a web browser: %v; open %s yourself, or set BROWSER to the command that opens one.", err, u)
+	}
+	return cmd.Process.Release()
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"flag"
+	"github.com/sam-falvo/sitehammer/report"
+	"github.com/sam-falvo/sitehammer/stats"
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"runtime/trace"
+)
+
+// profiling holds the settings of the flags for investigating slow builds, which the build and blog subcommands share:
+// the files receiving the CPU profile, memory profile, and execution trace, and whether to report timings.
+type profiling struct {
+	cpu     string
+	mem     string
+	trace   string
+	timings bool
+}
+
+// addProfileFlags adds the -cpuprofile, -memprofile, -trace, and -timings flags to flags.
+func addProfileFlags(flags *flag.FlagSet) *profiling {
+	p := new(profiling)
+	flags.StringVar(&p.cpu, "cpuprofile", "", "Writes a CPU profile of the command to the given `file`, for go tool pprof.")
+	flags.StringVar(&p.mem, "memprofile", "", "Writes a profile of memory allocated by the command to the given `file`, for go tool pprof.")
+	flags.StringVar(&p.trace, "trace", "", "Writes an execution trace of the command to the given `file`, for go tool trace.")
+	flags.BoolVar(&p.timings, "timings", false, "Reports the time spent in each phase, and on parsing, rendering, copying, images, and writing, even with -quiet.")
+	return p
+}
+
+// startStats begins collecting the command's statistics, which -quiet suppresses unless timings are to be reported,
+// and which summarize a dry run as such.
+func (p *profiling) startStats(quiet, dryRun bool) *stats.Stats {
+	start := stats.Start
+	if p.timings {
+		start = stats.StartTimed
+	}
+	s := start(quiet)
+	if dryRun {
+		s.SetDryRun()
+	}
+	return s
+}
+
+// start begins the CPU profile and execution trace asked for, answering a function which ends them,
+// and writes the memory profile, once the command is done.
+// Problems finishing the profiles are reported as warnings, since they don't make the command itself fail.
+func (p *profiling) start() (func(), error) {
+	var cpuFile, traceFile *os.File
+	stop := func() {
+		if cpuFile != nil {
+			pprof.StopCPUProfile()
+			closeProfile(cpuFile)
+		}
+		if traceFile != nil {
+			trace.Stop()
+			closeProfile(traceFile)
+		}
+		if p.mem != "" {
+			writeMemProfile(p.mem)
+		}
+	}
+
+	var err error
+	if p.cpu != "" {
+		cpuFile, err = os.Create(p.cpu)
+		if err != nil {
+			return nil, err
+		}
+		err = pprof.StartCPUProfile(cpuFile)
+		if err != nil {
+			cpuFile.Close()
+			return nil, err
+		}
+	}
+	if p.trace != "" {
+		traceFile, err = os.Create(p.trace)
+		if err == nil {
+			err = trace.Start(traceFile)
+			if err != nil {
+				traceFile.Close()
+				traceFile = nil
+			}
+		}
+		if err != nil {
+			p.mem = ""
+			stop()
+			return nil, err
+		}
+	}
+	return stop, nil
+}
+
+// closeProfile closes a profile's file, warning if it couldn't be written in full.
+func closeProfile(f *os.File) {
+	err := f.Close()
+	if err != nil {
+		report.Warning("cannot write the profile %s: %v", f.Name(), err)
+	}
+}
+
+// writeMemProfile writes a profile of the memory allocated so far to the named file.
+func writeMemProfile(filename string) {
+	f, err := os.Create(filename)
+	if err != nil {
+		report.Warning("cannot write the memory profile: %v", err)
+		return
+	}
+	runtime.GC()
+	err = pprof.Lookup("allocs").WriteTo(f, 0)
+	if err != nil {
+		report.Warning("cannot write the memory profile %s: %v", filename, err)
+	}
+	closeProfile(f)
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"github.com/sam-falvo/sitehammer/assets"
+	"github.com/sam-falvo/sitehammer/buildcache"
+	"github.com/sam-falvo/sitehammer/config"
+	"github.com/sam-falvo/sitehammer/directory"
+	"github.com/sam-falvo/sitehammer/hooks"
+	"github.com/sam-falvo/sitehammer/htmlcheck"
+	"github.com/sam-falvo/sitehammer/report"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// rebuildPath is the path at which serve -rebuild-hook accepts requests to rebuild the site.
+const rebuildPath = "/-/rebuild"
+
+// rebuildTokenVar names the environment variable giving the secret with which requests to rebuild the site are authenticated.
+const rebuildTokenVar = "SITEHAMMER_REBUILD_TOKEN"
+
+// maxRebuildRequest limits the size of the body of a request to rebuild, which is read only to check its signature.
+const maxRebuildRequest = 1 << 20
+
+// runServe implements the serve subcommand, serving the output directory over HTTP.
+func runServe(cfg *config.Config, args []string) error {
+	flags := newFlagSet("serve", "[-addr host:port] [-watch] [-rebuild-hook] [-base-url url]")
+	addr := flags.String("addr", "localhost:8000", "Sets the address at which to serve the site.")
+	watch := flags.Bool("watch", false, "Rebuilds the site whenever a source file changes.")
+	hook := flags.Bool("rebuild-hook", false, "Rebuilds the site whenever a webhook posts to "+rebuildPath+" with the secret "+rebuildTokenVar+" gives.")
+	addBaseUrlFlags(flags, cfg)
+	flags.Parse(args)
+	if flags.NArg() > 0 {
+		return usageError("The serve command takes no arguments, but was given %q.", flags.Arg(0))
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/", http.FileServer(http.Dir(cfg.Output.Dir)))
+	// A nil channel is never ready, so without the hook, no request to rebuild is ever received.
+	var requested chan struct{}
+	if *hook {
+		token := os.Getenv(rebuildTokenVar)
+		if token == "" {
+			return usageError("No secret is set to authenticate requests to rebuild the site; set %s.", rebuildTokenVar)
+		}
+		requested = make(chan struct{}, 1)
+		mux.Handle(rebuildPath, rebuildHandler(token, requested))
+	}
+	served := make(chan error, 1)
+	go func() {
+		served <- http.ListenAndServe(*addr, mux)
+	}()
+	report.Event("serve", fmt.Sprintf("serving %s at http://%s/", cfg.Output.Dir, *addr), report.Fields{"dir": cfg.Output.Dir, "url": "http://" + *addr + "/"})
+	if *hook {
+		report.Event("hook", fmt.Sprintf("accepting requests to rebuild at http://%s%s", *addr, rebuildPath), report.Fields{"url": "http://" + *addr + rebuildPath})
+	}
+	if !*watch && !*hook {
+		return <-served
+	}
+
+	var w *directory.Watcher
+	var events <-chan []directory.Event
+	var errors <-chan error
+	if *watch {
+		rebuild(cfg)
+		var err error
+		w, err = watchSources(cfg)
+		if err != nil {
+			return err
+		}
+		defer func() { w.Close() }()
+		events, errors = w.Events, w.Errors
+	}
+	for {
+		select {
+		case err := <-served:
+			return err
+		case err := <-errors:
+			report.Warning("%v", err)
+		case changes := <-events:
+			var sources []directory.Event
+			for _, e := range changes {
+				if isSource(cfg, e.Path) {
+					sources = append(sources, e)
+				}
+			}
+			if len(sources) == 0 {
+				continue
+			}
+			if rescans(sources) {
+				// Start watching anew before rebuilding, so that changes made meanwhile aren't missed.
+				rescanned, err := watchSources(cfg)
+				if err != nil {
+					report.Warning("cannot watch the sources anew, so they're watched as before: %v", err)
+				} else {
+					w.Close()
+					w, events, errors = rescanned, rescanned.Events, rescanned.Errors
+				}
+			}
+			e := sources[0]
+			report.Event("change", fmt.Sprintf("%s %s; rebuilding", e.Path, e.Op), report.Fields{"path": e.Path, "op": e.Op.String()})
+			rebuild(cfg)
+		case <-requested:
+			report.Event("rebuild", "rebuilding as requested", nil)
+			rebuild(cfg)
+		}
+	}
+}
+
+// rebuildHandler answers a handler accepting authenticated POST requests to rebuild the site, passing each on to requested.
+// Since requested holds at most one request, those arriving while one is pending are merged with it,
+// so a burst of pushes rebuilds the site once, but one arriving during a rebuild causes another, which sees whatever it announced.
+// The rebuild happens after the request is answered, with 202 Accepted; its outcome is reported as any rebuild's is.
+func rebuildHandler(token string, requested chan<- struct{}) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			w.Header().Set("Allow", "POST")
+			http.Error(w, "Rebuilds are requested with POST.", http.StatusMethodNotAllowed)
+			return
+		}
+		body, err := ioutil.ReadAll(io.LimitReader(r.Body, maxRebuildRequest))
+		if err != nil {
+			http.Error(w, "Cannot read the request.", http.StatusBadRequest)
+			return
+		}
+		if !authorized(r, body, token) {
+			report.Warning("refused a request to rebuild from %s, without the right secret", r.RemoteAddr)
+			http.Error(w, "The request lacks the right secret.", http.StatusUnauthorized)
+			return
+		}
+		select {
+		case requested <- struct{}{}:
+		default:
+		}
+		w.WriteHeader(http.StatusAccepted)
+		fmt.Fprintln(w, "Rebuild requested.")
+	})
+}
+
+// authorized answers true if the request, with the given body, proves it knows the secret token:
+// by signing the body with it, as GitHub does, in an X-Hub-Signature-256 header, or by giving the token itself,
+// in an Authorization header, as a bearer token, or in an X-Gitlab-Token header, as GitLab does.
+// Comparisons take the same time however much of the secret is right, so they can't be used to guess it.
+func authorized(r *http.Request, body []byte, token string) bool {
+	if signature := r.Header.Get("X-Hub-Signature-256"); signature != "" {
+		mac := hmac.New(sha256.New, []byte(token))
+		mac.Write(body)
+		return hmac.Equal([]byte(signature), []byte("sha256="+hex.EncodeToString(mac.Sum(nil))))
+	}
+	given := r.Header.Get("X-Gitlab-Token")
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		given = strings.TrimPrefix(auth, "Bearer ")
+	}
+	return given != "" && subtle.ConstantTimeCompare([]byte(given), []byte(token)) == 1
+}
+
+// watchSources begins watching the source directory, leaving out what a build never reads:
+// what .hammerignore files ignore, and the output and cache directories, which every build rewrites.
+func watchSources(cfg *config.Config) (*directory.Watcher, error) {
+	var skip []string
+	for _, dir := range []string{cfg.Output.Dir, buildcache.Dir} {
+		dir = filepath.ToSlash(filepath.Clean(dir))
+		if !filepath.IsAbs(dir) && dir != ".." && !strings.HasPrefix(dir, "../") {
+			skip = append(skip, dir)
+		}
+	}
+	return directory.WatchWith(".", directory.WatchOptions{IgnoreFiles: []string{directory.HammerIgnoreFilename}, Skip: skip})
+}
+
+// isSource answers true if the slash-separated path names a file a build might read,
+// as opposed to one the build writes, such as an output or a cache, or one nothing reads, such as a hidden file;
+// .hammerignore files, though hidden, are read.
+func isSource(cfg *config.Config, p string) bool {
+	for _, dir := range []string{cfg.Output.Dir, buildcache.Dir} {
+		dir = filepath.ToSlash(filepath.Clean(dir))
+		if p == dir || strings.HasPrefix(p, dir+"/") {
+			return false
+		}
+	}
+	if p == assets.MapFilename || p == filepath.ToSlash(filepath.Clean(cfg.Output.Manifest)) {
+		return false
+	}
+	parts := strings.Split(p, "/")
+	for i, part := range parts {
+		if strings.HasPrefix(part, ".") && (i < len(parts)-1 || part != directory.HammerIgnoreFilename) {
+			return false
+		}
+	}
+	return true
+}
+
+// rescans answers true if any of the changes calls for watching the sources anew:
+// a change to a .hammerignore file, which decides what's watched, or to a directory configuration, which decides what a subdirectory publishes.
+func rescans(changes []directory.Event) bool {
+	for _, e := range changes {
+		if name := path.Base(e.Path); name == directory.HammerIgnoreFilename || name == config.DirectoryFilename {
+			return true
+		}
+	}
+	return false
+}
+
+// rebuild builds the site quietly on behalf of the serve subcommand, running the configured hooks,
+// and reports whether it succeeded.
+func rebuild(cfg *config.Config) {
+	opts := buildOptions{
+		Config:      cfg,
+		Fingerprint: cfg.Assets.Fingerprint,
+		Symlinks:    cfg.Files.Symlinks,
+		Validate:    htmlcheck.Off,
+		A11y:        htmlcheck.Off,
+	}
+	hookOpts := hooks.Options{Output: report.Lines("hook"), OutputDir: cfg.Output.Dir}
+	err := hooks.Run(hookOpts, hooks.Pre, cfg.Hooks.Pre)
+	if err == nil {
+		err = build(opts)
+	}
+	if err == nil {
+		err = hooks.Run(hookOpts, hooks.Post, cfg.Hooks.Post)
+	}
+	if err != nil {
+		report.Error(err)
+		return
+	}
+	report.Event("built", "build succeeded", nil)
+}
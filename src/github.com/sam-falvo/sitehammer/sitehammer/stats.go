@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/sam-falvo/sitehammer/analytics"
+	"github.com/sam-falvo/sitehammer/config"
+	"github.com/sam-falvo/sitehammer/report"
+	"github.com/sam-falvo/sitehammer/weblog"
+	"strings"
+)
+
+// runStats implements the stats subcommand, summarizing the blog's content.
+func runStats(cfg *config.Config, args []string) error {
+	flags := newFlagSet("stats", "[-words] [-top n] [descs.json]")
+	listWords := flags.Bool("words", false, "Lists the length of every article, rather than only the longest and shortest.")
+	top := flags.Int("top", 3, "Sets how many of the longest and shortest articles, and of the most popular tags, are listed.")
+	flags.Parse(args)
+	descriptors := cfg.Blog.Descriptors
+	switch flags.NArg() {
+	case 0:
+	case 1:
+		descriptors = flags.Arg(0)
+	default:
+		return usageError("The stats command takes at most one descriptor file, but was given %d.", flags.NArg())
+	}
+	if flags.NArg() == 0 && !hasBlog(cfg) {
+		return usageError("The site has no blog to summarize; %s doesn't exist.", descriptors)
+	}
+
+	articles, err := weblog.LoadArticles(weblog.Options{Config: cfg, Descriptors: descriptors, KeepGoing: true})
+	if articles == nil && err != nil {
+		return err
+	}
+	if err != nil {
+		report.Warning("%d article(s) left out of the statistics, having problems; sitehammer check lists them", countErrors(err))
+	}
+	r := analytics.Analyze(articles)
+	message := describeStats(r, *listWords, *top)
+	if report.IsJSON() {
+		// The fields hold everything else.
+		message = strings.SplitN(message, "\n", 2)[0]
+	}
+	report.Event("stats", message, statsFields(r))
+	return nil
+}
+
+// countErrors answers how many errors err holds, if it's a list of errors, or 1 otherwise.
+func countErrors(err error) int {
+	if list, ok := err.(interface{ Errors() []error }); ok {
+		return len(list.Errors())
+	}
+	return 1
+}
+
+// describeStats describes the analysis for people, listing the lengths of every article if all is true,
+// or only the top longest and shortest otherwise.
+func describeStats(r *analytics.Report, all bool, top int) string {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "%d articles, %d words: %d words on average, %d the median\n", r.Articles, r.Words, r.MeanWords(), r.MedianWords())
+	if r.Undated > 0 {
+		fmt.Fprintf(&b, "%d articles have publication dates that can't be understood, and are left out of the figures by date\n", r.Undated)
+	}
+
+	if all {
+		b.WriteString("\nWords per article:\n")
+		writeLengths(&b, r.Lengths)
+	} else if len(r.Lengths) > 0 {
+		n := top
+		if n > len(r.Lengths) {
+			n = len(r.Lengths)
+		}
+		b.WriteString("\nLongest:\n")
+		writeLengths(&b, r.Lengths[:n])
+		b.WriteString("\nShortest:\n")
+		shortest := make([]analytics.Length, n)
+		for i := range shortest {
+			shortest[i] = r.Lengths[len(r.Lengths)-1-i]
+		}
+		writeLengths(&b, shortest)
+	}
+
+	writeCounts(&b, "Per year", r.Years, 0)
+	writeCounts(&b, "Per month", r.Months, 0)
+	writeCounts(&b, "Tags", r.Tags, top)
+
+	if !r.First.IsZero() {
+		fmt.Fprintf(&b, "\nFirst published %s; last published %s\n", r.First.Format(weblog.DateLayouts[0]), r.Last.Format(weblog.DateLayouts[0]))
+	}
+	if r.MeanGap > 0 {
+		fmt.Fprintf(&b, "An article every %.1f days on average; the longest gap, %d days, ran from %s to %s\n",
+			r.MeanGap.Hours()/24, r.LongestGap.Days(), r.LongestGap.From.Format(weblog.DateLayouts[0]), r.LongestGap.To.Format(weblog.DateLayouts[0]))
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// writeLengths writes a line for each article's length.
+func writeLengths(b *bytes.Buffer, lengths []analytics.Length) {
+	for _, l := range lengths {
+		fmt.Fprintf(b, "  %8d  %6d  %s\n", l.Words, l.Id, l.Title)
+	}
+}
+
+// writeCounts writes a titled table of counts, unless there are none, listing only the first max of them if max is positive.
+func writeCounts(b *bytes.Buffer, title string, counts []analytics.Count, max int) {
+	if len(counts) == 0 {
+		return
+	}
+	fmt.Fprintf(b, "\n%s:\n", title)
+	for i, c := range counts {
+		if max > 0 && i == max {
+			fmt.Fprintf(b, "  (%d more)\n", len(counts)-max)
+			break
+		}
+		fmt.Fprintf(b, "  %-12s %d\n", c.Label, c.Articles)
+	}
+}
+
+// statsFields gives the whole analysis as fields of a JSON report.
+func statsFields(r *analytics.Report) report.Fields {
+	fields := report.Fields{
+		"articles":     r.Articles,
+		"undated":      r.Undated,
+		"words":        r.Words,
+		"mean_words":   r.MeanWords(),
+		"median_words": r.MedianWords(),
+		"lengths":      r.Lengths,
+		"years":        r.Years,
+		"months":       r.Months,
+		"tags":         r.Tags,
+	}
+	if !r.First.IsZero() {
+		fields["first"] = r.First.Format("2006-01-02")
+		fields["last"] = r.Last.Format("2006-01-02")
+	}
+	if r.MeanGap > 0 {
+		fields["mean_gap_days"] = r.MeanGap.Hours() / 24
+		fields["longest_gap_days"] = r.LongestGap.Days()
+	}
+	return fields
+}
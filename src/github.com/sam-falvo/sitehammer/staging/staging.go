@@ -0,0 +1,76 @@
+/*
+The staging package lets a command build a new version of a directory off to the side, then swap it into place,
+so a failed or interrupted build never leaves a half-updated directory behind.
+
+This generalizes to whole directories the trick directory.WriteFileAtomic plays on single files:
+write the new version off to the side, and rename it over the old only once it's complete.
+*/
+package staging
+
+import (
+	"github.com/sam-falvo/sitehammer/directory"
+	"os"
+)
+
+// Suffix is appended to a directory's name to name its staging area.
+const Suffix = ".inprogress"
+
+// oldSuffix is appended to a directory's name to name the previous version of the directory while it's being swapped out.
+const oldSuffix = ".old"
+
+// Area describes a staging area for a target directory.
+// Commands write their output into Dir instead of Target.
+type Area struct {
+	Target string
+	Dir    string
+}
+
+// Begin creates a staging area for the target directory.
+// The staging area starts out as a copy of the target, if it exists, so incremental builds have something to increment upon.
+// Leftovers from an earlier, interrupted build are discarded first.
+func Begin(target string) (*Area, error) {
+	a := &Area{Target: target, Dir: target + Suffix}
+	err := os.RemoveAll(a.Dir)
+	if err != nil {
+		return nil, err
+	}
+	_, err = os.Lstat(target)
+	if os.IsNotExist(err) {
+		return a, directory.EnsureDirAll(a.Dir, 0755)
+	}
+	if err != nil {
+		return nil, err
+	}
+	err = directory.CopyTree(target, a.Dir, directory.CopyOptions{})
+	if err != nil {
+		os.RemoveAll(a.Dir)
+		return nil, err
+	}
+	return a, nil
+}
+
+// Commit swaps the staging area into place.
+// The target is first renamed aside, then the staging area renamed to replace it, and finally the old target removed.
+// Each rename is atomic, so at every instant, either the old or the new directory is complete, save for the brief moment between renames when neither exists.
+func (a *Area) Commit() error {
+	old := a.Target + oldSuffix
+	err := os.RemoveAll(old)
+	if err != nil {
+		return err
+	}
+	err = os.Rename(a.Target, old)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	err = os.Rename(a.Dir, a.Target)
+	if err != nil {
+		os.Rename(old, a.Target)
+		return err
+	}
+	return os.RemoveAll(old)
+}
+
+// Abort discards the staging area, leaving the target untouched.
+func (a *Area) Abort() error {
+	return os.RemoveAll(a.Dir)
+}
@@ -0,0 +1,88 @@
+package static
+
+import (
+	"fmt"
+	"github.com/sam-falvo/sitehammer/failure"
+	htmltemplate "html/template"
+	"io/ioutil"
+	"os"
+	"path"
+	texttemplate "text/template"
+)
+
+// A Checker processor can look a source file over for mistakes without processing it; see Check.
+type Checker interface {
+	Check(env *Env, sourceName string, content []byte) error
+}
+
+// Check looks the source directory over for mistakes a build would stumble on, without writing anything:
+// directory configuration files which don't parse, templates and Markdown layouts which don't parse,
+// references to undefined build variables, and files named by bundles which don't exist.
+// Unlike Build, Check doesn't compile stylesheets, optimize images, or render templates, so it's quick.
+// Every mistake found is answered, in an errlist.List if there's more than one.
+func Check(opts Options) error {
+	b, err := newBuilder(opts)
+	if err != nil {
+		return err
+	}
+	b.checking = true
+	b.KeepGoing = true
+
+	err = b.processDir("", b.env)
+	if err != nil {
+		return err
+	}
+	for _, bundle := range opts.Config.Bundles {
+		for _, fn := range bundle.Files {
+			if _, err := os.Stat(b.sourceNameFor(fn)); err != nil {
+				b.failed.Add(fmt.Errorf("Bundle %s: %w", bundle.Name, err))
+			}
+		}
+	}
+	return b.failed.Err()
+}
+
+// checkSourceFile checks a regular file for mistakes, if its processor is a Checker or build variables are substituted into it.
+func (b *builder) checkSourceFile(src source) error {
+	checker, ok := b.Processors.For(src.name).(Checker)
+	if !ok && !src.env.substitutes(src.name) {
+		return nil
+	}
+	content, err := b.readSource(src.env, src.name)
+	if err != nil || !ok {
+		return err
+	}
+	err = checker.Check(src.env, src.name, content)
+	if err != nil {
+		return fmt.Errorf("%s: %w", src.name, err)
+	}
+	return nil
+}
+
+// Check parses the template, without rendering it.
+func (t TemplateProcessor) Check(env *Env, name string, content []byte) error {
+	if path.Ext(t.OutputName(name)) == ".html" {
+		return parseHtml(env, name, string(content))
+	}
+	_, err := texttemplate.New(name).Funcs(texttemplate.FuncMap{"Asset": env.Assets.Lookup}).Parse(string(content))
+	return failure.Wrap(failure.Template, err)
+}
+
+// Check parses the configured layout, if there is one; any Markdown text at all converts into HTML.
+func (MarkdownProcessor) Check(env *Env, name string, content []byte) error {
+	layout := env.Config.Markdown.Layout
+	if layout == "" {
+		return nil
+	}
+	text, err := ioutil.ReadFile(path.Join(env.SourceDir, layout))
+	if err != nil {
+		return err
+	}
+	return parseHtml(env, layout, string(text))
+}
+
+// parseHtml parses text as an HTML template, as renderHtml would, without rendering it.
+func parseHtml(env *Env, name, text string) error {
+	_, err := htmltemplate.New(name).Funcs(htmltemplate.FuncMap{"Asset": env.Assets.Lookup}).Parse(text)
+	return failure.Wrap(failure.Template, err)
+}
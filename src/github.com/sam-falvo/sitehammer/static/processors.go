@@ -0,0 +1,282 @@
+package static
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/sam-falvo/sitehammer/assets"
+	"github.com/sam-falvo/sitehammer/blogroll"
+	"github.com/sam-falvo/sitehammer/config"
+	"github.com/sam-falvo/sitehammer/failure"
+	"github.com/sam-falvo/sitehammer/imageopt"
+	"github.com/sam-falvo/sitehammer/markdown"
+	"github.com/sam-falvo/sitehammer/templatedump"
+	htmltemplate "html/template"
+	"io/ioutil"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	texttemplate "text/template"
+)
+
+// A Processor turns one kind of source file into its published output.
+type Processor interface {
+	// Name identifies the processor in the build cache, so outputs are rebuilt if a file changes hands.
+	Name() string
+
+	// OutputName answers the name under which a source file's output is published, before any fingerprinting.
+	OutputName(sourceName string) string
+
+	// Process transforms a source file's content into its output's content.
+	Process(env *Env, sourceName string, content []byte) ([]byte, error)
+}
+
+// A Dependent processor's output depends on more than its source file's content:
+// Dependencies answers the other files, relative to the source directory, whose content it reads.
+// Changing any of them rebuilds every output the processor produces.
+type Dependent interface {
+	Dependencies(env *Env) []string
+}
+
+// An AssetUser processor's output refers to other files by their published names, through the Asset template function.
+// Files handled by an AssetUser are processed after every other file, once the asset map is complete,
+// and are rebuilt whenever the asset map changes.
+type AssetUser interface {
+	UsesAssets() bool
+}
+
+// Env gives processors access to the build they're part of.
+// Config reflects any directory configuration applying to the file being processed.
+// Variables holds the values substituted into text assets; see Variables.
+// DebugTemplates, if not empty, names the directory into which processors dump the data their templates are executed with.
+// Blogroll holds the site's blogroll, for templates' Blogroll function, if it has one.
+type Env struct {
+	Config         *config.Config
+	SourceDir      string
+	Assets         assets.Map
+	Variables      map[string]string
+	DebugTemplates string
+	Blogroll       *blogroll.Blogroll
+
+	configSignature string
+	varsSignature   string
+}
+
+// Registry maps filename extensions, including the leading dot and in lower case, to the processors responsible for them.
+// Files whose extensions aren't registered are copied verbatim.
+type Registry map[string]Processor
+
+// DefaultRegistry answers a registry of SiteHammer's built-in processors:
+// templates (.tmpl), Markdown (.md, .markdown), Sass (.scss, .sass), and images, which are copied.
+func DefaultRegistry() Registry {
+	r := make(Registry)
+	r.Register(TemplateProcessor{}, ".tmpl")
+	r.Register(MarkdownProcessor{}, ".md", ".markdown")
+	r.Register(SassProcessor{}, ".scss", ".sass")
+	r.Register(ImageProcessor{}, ".png", ".jpg", ".jpeg", ".gif", ".svg", ".webp", ".ico")
+	return r
+}
+
+// Register makes p responsible for files with any of the given extensions, replacing whatever processor handled them before.
+func (r Registry) Register(p Processor, extensions ...string) {
+	for _, ext := range extensions {
+		r[strings.ToLower(ext)] = p
+	}
+}
+
+// For answers the processor responsible for the named file.
+func (r Registry) For(name string) Processor {
+	if p, ok := r[strings.ToLower(path.Ext(name))]; ok {
+		return p
+	}
+	return CopyProcessor{}
+}
+
+// CopyProcessor publishes files exactly as they are.
+type CopyProcessor struct{}
+
+func (CopyProcessor) Name() string                  { return "copy" }
+func (CopyProcessor) OutputName(name string) string { return name }
+
+func (CopyProcessor) Process(env *Env, name string, content []byte) ([]byte, error) {
+	return content, nil
+}
+
+// ImageProcessor publishes images.
+// When the images table enables optimization, images are shrunk losslessly; otherwise, they're copied verbatim.
+type ImageProcessor struct{}
+
+func (ImageProcessor) Name() string                  { return "image" }
+func (ImageProcessor) OutputName(name string) string { return name }
+
+func (ImageProcessor) Process(env *Env, name string, content []byte) ([]byte, error) {
+	if !env.Config.Images.Optimize {
+		return content, nil
+	}
+	return imageopt.Optimize(name, content, env.Config.Images.Commands)
+}
+
+// TemplateProcessor renders files ending in .tmpl as Go templates, publishing them without the .tmpl extension.
+// Thus, about.html.tmpl becomes about.html; a template with no other extension, like about.tmpl, becomes about.html.
+// HTML outputs are rendered with html/template, so interpolated values are escaped properly; anything else, with text/template.
+//
+// Templates may use the Asset function to refer to assets by their logical names, and the Blogroll function to see the blogroll,
+// and see the site's configuration as .Config.
+type TemplateProcessor struct{}
+
+func (TemplateProcessor) Name() string     { return "template" }
+func (TemplateProcessor) UsesAssets() bool { return true }
+
+// Dependencies answers the blogroll's data file, if there is one, since any template might render the blogroll.
+func (TemplateProcessor) Dependencies(env *Env) []string {
+	return blogrollDependencies(env)
+}
+
+func (TemplateProcessor) OutputName(name string) string {
+	name = strings.TrimSuffix(name, path.Ext(name))
+	if path.Ext(name) == "" {
+		name += ".html"
+	}
+	return name
+}
+
+func (t TemplateProcessor) Process(env *Env, name string, content []byte) ([]byte, error) {
+	data := map[string]interface{}{
+		"Config": env.Config,
+		"Name":   t.OutputName(name),
+	}
+	err := templatedump.Dump(env.DebugTemplates, t.OutputName(name), data)
+	if err != nil {
+		return nil, err
+	}
+	if path.Ext(t.OutputName(name)) == ".html" {
+		return renderHtml(env, name, string(content), data)
+	}
+	tmpl, err := texttemplate.New(name).Funcs(texttemplate.FuncMap{"Asset": env.Assets.Lookup, "Blogroll": env.blogroll}).Parse(string(content))
+	if err != nil {
+		return nil, failure.Wrap(failure.Template, err)
+	}
+	var out bytes.Buffer
+	err = tmpl.Execute(&out, data)
+	return out.Bytes(), failure.Wrap(failure.Template, err)
+}
+
+// renderHtml renders text as an HTML template, providing the Asset and Blogroll functions.
+func renderHtml(env *Env, name, text string, data interface{}) ([]byte, error) {
+	tmpl, err := htmltemplate.New(name).Funcs(htmltemplate.FuncMap{"Asset": env.Assets.Lookup, "Blogroll": env.blogroll}).Parse(text)
+	if err != nil {
+		return nil, failure.Wrap(failure.Template, err)
+	}
+	var out bytes.Buffer
+	err = tmpl.Execute(&out, data)
+	return out.Bytes(), failure.Wrap(failure.Template, err)
+}
+
+// MarkdownProcessor converts Markdown files into HTML, publishing them with an .html extension.
+// If the configuration names a layout, the converted page is rendered through it:
+// the layout sees the page's HTML as .Content, the text of its first top-level heading as .Title, and the site's configuration as .Config.
+type MarkdownProcessor struct{}
+
+func (MarkdownProcessor) Name() string     { return "markdown" }
+func (MarkdownProcessor) UsesAssets() bool { return true }
+
+func (MarkdownProcessor) OutputName(name string) string {
+	return strings.TrimSuffix(name, path.Ext(name)) + ".html"
+}
+
+func (MarkdownProcessor) Dependencies(env *Env) []string {
+	if env.Config.Markdown.Layout == "" {
+		return nil
+	}
+	return append([]string{env.Config.Markdown.Layout}, blogrollDependencies(env)...)
+}
+
+// blogrollDependencies answers the blogroll's data file, if there is one, for the processors whose templates might render it.
+func blogrollDependencies(env *Env) []string {
+	if env.Blogroll == nil {
+		return nil
+	}
+	return []string{env.Config.Blogroll.File}
+}
+
+// blogroll answers the blogroll, for templates' Blogroll function.
+func (env *Env) blogroll() *blogroll.Blogroll {
+	return env.Blogroll
+}
+
+var (
+	firstHeading = regexp.MustCompile(`(?s)<h1>(.*?)</h1>`)
+	markupTag    = regexp.MustCompile(`<[^>]*>`)
+)
+
+func (m MarkdownProcessor) Process(env *Env, name string, content []byte) ([]byte, error) {
+	body := markdown.ToHtmlWith(content, env.Config.Markdown.Extensions())
+	layout := env.Config.Markdown.Layout
+	if layout == "" {
+		return body, nil
+	}
+	text, err := ioutil.ReadFile(path.Join(env.SourceDir, layout))
+	if err != nil {
+		return nil, err
+	}
+	title := ""
+	if h := firstHeading.FindSubmatch(body); h != nil {
+		title = string(markupTag.ReplaceAll(h[1], nil))
+	}
+	data := map[string]interface{}{
+		"Config":  env.Config,
+		"Name":    m.OutputName(name),
+		"Title":   htmltemplate.HTML(title),
+		"Content": htmltemplate.HTML(body),
+	}
+	err = templatedump.Dump(env.DebugTemplates, m.OutputName(name), data)
+	if err != nil {
+		return nil, err
+	}
+	return renderHtml(env, layout, string(text), data)
+}
+
+// SassProcessor compiles Sass stylesheets into CSS by running the configured Sass command,
+// publishing them with a .css extension.
+// Sass partials, whose names begin with an underscore, aren't published in their own right,
+// as with any other file whose name begins with an underscore.
+type SassProcessor struct{}
+
+func (SassProcessor) Name() string { return "sass" }
+
+// Dependencies answers the site's Sass partials, since any stylesheet might import any of them.
+func (SassProcessor) Dependencies(env *Env) []string {
+	var partials []string
+	for _, pattern := range []string{"_*.scss", "_*.sass"} {
+		matches, _ := filepath.Glob(filepath.Join(env.SourceDir, pattern))
+		for _, m := range matches {
+			partials = append(partials, filepath.Base(m))
+		}
+	}
+	return partials
+}
+
+func (SassProcessor) OutputName(name string) string {
+	return strings.TrimSuffix(name, path.Ext(name)) + ".css"
+}
+
+func (SassProcessor) Process(env *Env, name string, content []byte) ([]byte, error) {
+	args := strings.Fields(env.Config.Sass.Command)
+	if len(args) == 0 {
+		return nil, failure.Wrap(failure.Usage, fmt.Errorf("No Sass command is configured to compile %s.", name))
+	}
+	if path.Ext(name) == ".sass" {
+		args = append(args, "--indented")
+	}
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Dir = env.SourceDir
+	cmd.Stdin = bytes.NewReader(content)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, failure.Wrap(failure.Content, fmt.Errorf("Cannot compile %s: %s %s", name, err, strings.TrimSpace(stderr.String())))
+	}
+	return out, nil
+}
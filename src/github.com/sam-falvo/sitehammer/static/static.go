@@ -0,0 +1,791 @@
+/*
+The static package implements SiteHammer's static-file pass:
+publishing the files found in a source directory into an output directory,
+fingerprinting assets and building bundles along the way.
+
+Each file is published by the Processor its extension is registered to (see DefaultRegistry);
+files with unregistered extensions are copied verbatim.
+Templates (.tmpl) are rendered, and may render the blogroll (see the blogroll package), Markdown files (.md, .markdown) are converted into HTML pages,
+Sass stylesheets (.scss, .sass) are compiled into CSS, and images are copied, optimized if so configured.
+Files whose processors refer to assets by name, like templates and Markdown pages, are processed last,
+once every asset's published name is known.
+Text assets named by the substitution table have build variables, like @@base_url@@, substituted as they're read;
+see Variables.
+
+Files whose names begin with an underscore are never published, nor is the configuration file.
+Neither are files matched by the rules of .hammerignore files, which follow the conventions of .gitignore files:
+those of a subdirectory's .hammerignore apply beneath it, taking precedence over those of the directories above,
+and an ignored directory isn't even read.  See directory.IgnoreRules.
+Subdirectories are published only if they hold a directory configuration file (_config.toml),
+whose settings apply to everything beneath them; see Config.Override in the config package.
+Symbolic links are handled according to the configured symlink policy;
+FIFOs, sockets, devices, and other special files are skipped with a warning.
+Two files whose outputs' names differ only by case, like Logo.png and logo.png, stop the build with an error,
+since the site couldn't be deployed to a case-insensitive filesystem intact.
+
+Builds are incremental.
+The static pass remembers the content hash of every file it publishes, along with the configuration in effect at the time,
+in .sitehammer-cache/hammer.json.
+An output whose source content and configuration haven't changed since the last build isn't written again.
+Source files whose size and modification time haven't changed aren't even re-read,
+nor, in a git repository, are those git knows to be unchanged since the last build's commit, whatever their modification times.
+*/
+package static
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/sam-falvo/sitehammer/assets"
+	"github.com/sam-falvo/sitehammer/blogroll"
+	"github.com/sam-falvo/sitehammer/buildcache"
+	"github.com/sam-falvo/sitehammer/config"
+	"github.com/sam-falvo/sitehammer/directory"
+	"github.com/sam-falvo/sitehammer/dryrun"
+	"github.com/sam-falvo/sitehammer/errlist"
+	"github.com/sam-falvo/sitehammer/failure"
+	"github.com/sam-falvo/sitehammer/minify"
+	"github.com/sam-falvo/sitehammer/report"
+	"github.com/sam-falvo/sitehammer/stats"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// cacheFilename names the static pass's build cache, relative to the cache directory.
+const cacheFilename = "hammer.json"
+
+// Options controls a static build.
+//
+// SourceDir names the directory whose files are published.
+// OutputDir names the directory into which they're written; this may be a staging area rather than the published directory.
+// Force rebuilds every output regardless of the build cache.
+// DryRun reports what would be written instead of writing it.
+// Fingerprint and Symlinks override the corresponding configuration settings.
+// Processors chooses the processor for each source file; if nil, DefaultRegistry is used.
+// Stats, if not nil, counts the work done.
+// KeepGoing carries on past files that fail to process; Build then answers its Result along with an errlist.List of every failure.
+// Context, if not nil, cancels the build: once it's done, no further files are processed, and Build answers the context's error.
+// Outputs already written are complete, since each is written atomically.
+// SaveOnFailure saves the build cache even when the build fails, so a later build needn't produce again the outputs finished before the failure;
+// it's only safe when OutputDir is the published directory itself, not a staging area that a failure discards.
+// DebugTemplates, if not empty, names the directory into which the data of every template executed is dumped; see the templatedump package.
+// Only pages actually rendered have their data dumped, so it's best combined with Force.
+// Git, if not nil, tells which source files git knows to be unchanged since the last successful build,
+// so their hashes are trusted even if their modification times changed; see buildcache.Git.
+type Options struct {
+	Config         *config.Config
+	SourceDir      string
+	OutputDir      string
+	Force          bool
+	DryRun         bool
+	Fingerprint    bool
+	Symlinks       string
+	Processors     Registry
+	Stats          *stats.Stats
+	KeepGoing      bool
+	Context        context.Context
+	SaveOnFailure  bool
+	DebugTemplates string
+	Git            *buildcache.Git
+}
+
+// Result describes the outcome of a static build.
+//
+// Produced holds the published name, relative to the output directory, of every output the build is responsible for,
+// whether or not it was rewritten.
+// Sources maps each of those names to the source files, relative to the source directory, from which the output was built.
+// Assets maps the logical names of fingerprinted assets and bundles to their published names.
+type Result struct {
+	Produced map[string]bool
+	Sources  map[string][]string
+	Assets   assets.Map
+
+	opts  Options
+	cache *buildcache.Cache
+}
+
+// builder holds the state of a static build in progress.
+type builder struct {
+	Options
+	*Result
+	previousAssets assets.Map
+	env            *Env
+	started        time.Time
+
+	// written maps the content hash of each output written or found up to date in this build to the output's filename, for deduplication.
+	written map[string]string
+
+	// rewritten holds the published names of the outputs whose content this build wrote, rather than finding it up to date.
+	rewritten map[string]bool
+
+	// folded maps the lower-cased form of each published name to the name itself, to catch names differing only by case.
+	folded map[string]string
+
+	// failed collects the errors set aside when the build keeps going after errors.
+	failed errlist.List
+
+	// deferred holds the files whose processors use the asset map, to be processed once it's complete.
+	deferred []source
+
+	// ignores holds the ignore rules in effect within each directory read, by its name relative to the source directory,
+	// "." being the source directory itself; see loadIgnoreFile.
+	ignores map[string]*directory.IgnoreRules
+
+	// checking is true when the source directory is merely being checked for mistakes; see Check.
+	checking bool
+}
+
+// source describes a source file awaiting processing:
+// its slash-separated name relative to the source directory, its FileInfo,
+// and the environment in which it's processed, which reflects any directory configuration applying to it.
+type source struct {
+	name string
+	info os.FileInfo
+	env  *Env
+}
+
+// Build runs the static pass.
+// Nothing is remembered of the build until the Result's Save method is called;
+// callers who build into a staging area should save only after the staging area has been committed.
+// The exceptions are a failed build with SaveOnFailure set, which saves the build cache before answering,
+// and the cache of outputs' content hashes, whose records of the outputs rewritten are dropped whether or not the build succeeds;
+// see buildcache.ForgetHashes.
+func Build(opts Options) (result *Result, err error) {
+	b, err := newBuilder(opts)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil && opts.SaveOnFailure && !opts.DryRun {
+			b.cache.Save()
+		}
+		forgetErr := buildcache.ForgetHashes(buildcache.DirIn(opts.SourceDir), b.rewritten)
+		if err == nil {
+			err = forgetErr
+		}
+	}()
+
+	err = b.processDir("", b.env)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, bundle := range opts.Config.Bundles {
+		err = b.interrupted()
+		if err != nil {
+			return nil, err
+		}
+		err = b.tolerate(b.buildBundle(bundle))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, src := range b.deferred {
+		err = b.interrupted()
+		if err != nil {
+			return nil, err
+		}
+		err = b.tolerate(b.processSourceFile(src))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return b.Result, b.failed.Err()
+}
+
+// newBuilder readies a static build, loading the ignore file and the previous build's asset map.
+func newBuilder(opts Options) (*builder, error) {
+	err := config.ValidateSymlinkPolicy(opts.Symlinks)
+	if err != nil {
+		return nil, err
+	}
+	b := &builder{
+		Options: opts,
+		Result: &Result{
+			Produced: make(map[string]bool),
+			Sources:  make(map[string][]string),
+			Assets:   make(assets.Map),
+			opts:     opts,
+			cache:    buildcache.Open(filepath.Join(buildcache.DirIn(opts.SourceDir), cacheFilename)),
+		},
+	}
+	b.cache.Root = opts.OutputDir
+	b.cache.Git = opts.Git
+	b.folded = make(map[string]string)
+	b.written = make(map[string]string)
+	b.rewritten = make(map[string]bool)
+	if b.Processors == nil {
+		b.Processors = DefaultRegistry()
+	}
+	b.started = time.Now()
+	b.env, err = b.newEnv(opts.Config)
+	if err != nil {
+		return nil, err
+	}
+	b.ignores = make(map[string]*directory.IgnoreRules)
+	b.previousAssets, err = assets.LoadMap(filepath.Join(opts.SourceDir, assets.MapFilename))
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// interrupted answers the context's error if the build has been cancelled, or nil otherwise.
+func (b *builder) interrupted() error {
+	if b.Context == nil {
+		return nil
+	}
+	return b.Context.Err()
+}
+
+// tolerate answers err, unless the build is to keep going after errors, in which case err is set aside for the final report.
+// Cancellation is never tolerated.
+func (b *builder) tolerate(err error) error {
+	if err != nil && b.KeepGoing && b.interrupted() == nil {
+		b.failed.Add(err)
+		return nil
+	}
+	return err
+}
+
+// Save records the asset map and the build cache, so the blog pass can find fingerprinted assets and later builds can skip unchanged outputs.
+// Outputs removed by pruning should be passed along, so the cache forgets them.
+// Dry runs save nothing.
+func (r *Result) Save(pruned []string) error {
+	if r.opts.DryRun {
+		return nil
+	}
+	for _, name := range pruned {
+		r.cache.Forget(name)
+	}
+	err := r.Assets.Save(filepath.Join(r.opts.SourceDir, assets.MapFilename))
+	if err != nil {
+		return err
+	}
+	return r.cache.Save()
+}
+
+// sourceNameFor computes the filename of a source file, given its name relative to the source directory.
+func (b *builder) sourceNameFor(fn string) string {
+	return filepath.Join(b.SourceDir, filepath.FromSlash(fn))
+}
+
+// outputNameFor computes the filename in the output directory which corresponds to the given published name.
+// See cleanName for the names accepted.
+func (b *builder) outputNameFor(fn string) (string, error) {
+	name, err := cleanName(fn)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(b.OutputDir, filepath.FromSlash(name)), nil
+}
+
+// cleanName canonicalizes a slash-separated published name, which is relative to the output directory.
+// Redundant slashes and . components are dropped, and an absolute name is taken relative to the output directory,
+// so /theme/site.css and theme//./site.css both name theme/site.css.
+// A name may use .. to climb out of a subdirectory, but never out of the output directory itself,
+// not even on Windows, where backslashes separate its components too, and a volume name leads elsewhere.
+func cleanName(fn string) (string, error) {
+	name := path.Clean(strings.TrimLeft(fn, "/"))
+	if name == "." || name == ".." || strings.HasPrefix(name, "../") || !filepath.IsLocal(filepath.FromSlash(name)) {
+		return "", failure.Wrap(failure.Content, fmt.Errorf("Published name %q lies outside the output directory.", fn))
+	}
+	return name, nil
+}
+
+// publishedNameFor decides the name under which a source file, whose content has the given hash, is published.
+// Unless fingerprinting is enabled and the file is a fingerprintable asset, this is the file's own name.
+func (b *builder) publishedNameFor(fn string, hash string) string {
+	if !b.Fingerprint || !assets.IsFingerprintable(fn) {
+		return fn
+	}
+	name := assets.FingerprintHash(fn, hash)
+	b.Assets[fn] = name
+	return name
+}
+
+// produce notes that the build is responsible for the output with the given published name, built from the given sources.
+// It fails if another output's name differs from this one only by case.
+func (b *builder) produce(publishedName string, sources ...string) error {
+	folded := strings.ToLower(publishedName)
+	if other, ok := b.folded[folded]; ok && other != publishedName {
+		return caseCollision(other, publishedName)
+	}
+	b.folded[folded] = publishedName
+	b.Produced[publishedName] = true
+	b.Sources[publishedName] = sources
+	return nil
+}
+
+// CheckCase fails if any two of the given output names differ only by case.
+// Such outputs can't coexist on case-insensitive filesystems, like those of macOS and Windows,
+// so a site containing them breaks when deployed to, or checked out on, such a system.
+func CheckCase(produced map[string]bool) error {
+	var names []string
+	for name := range produced {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	folded := make(map[string]string)
+	for _, name := range names {
+		if other, ok := folded[strings.ToLower(name)]; ok {
+			return caseCollision(other, name)
+		}
+		folded[strings.ToLower(name)] = name
+	}
+	return nil
+}
+
+func caseCollision(a, b string) error {
+	return failure.Wrap(failure.Content, fmt.Errorf("Outputs %s and %s differ only by case; they would overwrite each other on case-insensitive filesystems.", a, b))
+}
+
+// warn reports a problem which doesn't stop the build.
+func warn(format string, args ...interface{}) {
+	report.Warning(format, args...)
+}
+
+// isIgnored answers true for source files which are never published:
+// those whose names begin with an underscore, the configuration file, ignore files, the cache directory,
+// and anything the ignore files' rules match.
+func (b *builder) isIgnored(src source) bool {
+	name := src.info.Name()
+	if name[0] == '_' || name == config.Filename || name == directory.HammerIgnoreFilename || src.name == path.Clean(filepath.ToSlash(buildcache.Dir)) {
+		return true
+	}
+	return b.ignores[path.Dir(src.name)].Ignores(src.name, src.info)
+}
+
+// loadIgnoreFile reads the .hammerignore file of a directory, named relative to the source directory, if it holds one,
+// adding its rules to those in effect in the directory above.
+func (b *builder) loadIgnoreFile(dir string) error {
+	dir = path.Clean(dir)
+	nested, err := directory.LoadIgnoreFile(filepath.Join(b.sourceNameFor(dir), directory.HammerIgnoreFilename))
+	if err != nil {
+		return err
+	}
+	b.ignores[dir] = b.ignores[path.Dir(dir)].Nest(dir, nested)
+	return nil
+}
+
+// processDir processes the entries of a directory, named relative to the source directory, in the given environment.
+func (b *builder) processDir(dir string, env *Env) error {
+	err := b.loadIgnoreFile(dir)
+	if err != nil {
+		return err
+	}
+	return directory.ForEachEntry(b.sourceNameFor(dir), func(e os.FileInfo) error {
+		if err := b.interrupted(); err != nil {
+			return err
+		}
+		return b.tolerate(b.processEntry(source{path.Join(dir, e.Name()), e, env}))
+	})
+}
+
+// processSubdir processes a subdirectory of the source directory.
+// A subdirectory of the source directory itself is published only if it holds a directory configuration file;
+// its own subdirectories are then published as well.
+// The directory configuration's settings apply to everything beneath the directory, overridden in turn by any deeper directory configurations.
+func (b *builder) processSubdir(src source) error {
+	fragment := b.sourceNameFor(path.Join(src.name, config.DirectoryFilename))
+	if path.Dir(src.name) == "." {
+		if _, err := os.Stat(fragment); os.IsNotExist(err) {
+			return nil
+		}
+	}
+	cfg, err := src.env.Config.Override(fragment)
+	if err != nil {
+		return err
+	}
+	env := src.env
+	if cfg != src.env.Config {
+		env, err = b.newEnv(cfg)
+		if err != nil {
+			return err
+		}
+	}
+	return b.processDir(src.name, env)
+}
+
+// processEntry dispatches a directory entry according to its type.
+// Regular files are processed by processRegularFile, directories by processSubdir, symbolic links according to the symlink policy,
+// and special files are skipped with a warning.
+func (b *builder) processEntry(src source) error {
+	if b.isIgnored(src) {
+		return nil
+	}
+	mode := src.info.Mode()
+	if mode&os.ModeSymlink != 0 {
+		return b.processSymlink(src)
+	}
+	if mode.IsDir() {
+		return b.processSubdir(src)
+	}
+	if !mode.IsRegular() {
+		warn("skipping %s: special file (%s)", src.name, mode.Type())
+		return nil
+	}
+	return b.processRegularFile(src)
+}
+
+// processRegularFile processes a regular file, unless its processor uses the asset map,
+// in which case it's set aside until every other file has been processed.
+func (b *builder) processRegularFile(src source) error {
+	if b.checking {
+		return b.checkSourceFile(src)
+	}
+	if user, ok := b.Processors.For(src.name).(AssetUser); ok && user.UsesAssets() {
+		b.deferred = append(b.deferred, src)
+		return nil
+	}
+	return b.processSourceFile(src)
+}
+
+// processSymlink handles a symbolic link according to the configured policy.
+func (b *builder) processSymlink(src source) error {
+	switch b.Symlinks {
+	case config.SymlinksSkip:
+		return nil
+
+	case config.SymlinksLink:
+		if b.checking {
+			return nil
+		}
+		target, err := os.Readlink(b.sourceNameFor(src.name))
+		if err != nil {
+			return err
+		}
+		outputName, err := b.outputNameFor(src.name)
+		if err != nil {
+			return err
+		}
+		err = b.produce(src.name, src.name)
+		if err != nil {
+			return err
+		}
+		if existing, err := os.Readlink(outputName); err == nil && existing == target {
+			return nil
+		}
+		if b.DryRun {
+			dryrun.ReportWrite(outputName)
+			return nil
+		}
+		err = directory.EnsureDirAll(filepath.Dir(outputName), b.Config.Output.DirPerm())
+		if err != nil {
+			return err
+		}
+		err = os.Remove(outputName)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return os.Symlink(target, outputName)
+	}
+
+	target, err := os.Stat(b.sourceNameFor(src.name))
+	if err != nil {
+		warn("skipping %s: cannot follow symbolic link (%s)", src.name, err)
+		return nil
+	}
+	if !target.Mode().IsRegular() {
+		warn("skipping %s: symbolic link to something other than a regular file", src.name)
+		return nil
+	}
+	src.info = target
+	return b.processRegularFile(src)
+}
+
+// processSourceFile processes a regular file.
+// Unless the build cache shows the corresponding output to be up to date,
+// the file is read into memory, transformed by its processor, and written out into the corresponding location in the output directory.
+// Returns either an error or nil, the latter indicating a successful operation.
+func (b *builder) processSourceFile(src source) error {
+	began := time.Now()
+	p := b.Processors.For(src.name)
+	inputName := b.sourceNameFor(src.name)
+	hash, err := b.cache.HashFile(inputName, src.info)
+	if err != nil {
+		return err
+	}
+	publishedName := b.publishedNameFor(p.OutputName(src.name), hash)
+	err = b.produce(publishedName, src.name)
+	if err != nil {
+		return err
+	}
+	parts := []string{hash, src.info.Mode().String()}
+	if src.env.substitutes(src.name) {
+		parts = append(parts, src.env.varsSignature)
+	}
+	signature, err := b.processorSignature(src.env, p, parts...)
+	if err != nil {
+		return err
+	}
+	if !b.Force && b.cache.Fresh(publishedName, signature) {
+		b.Stats.Count(stats.Fresh)
+		return nil
+	}
+
+	rawData, err := b.readSource(src.env, src.name)
+	if err != nil {
+		return err
+	}
+	processed := time.Now()
+	data, err := p.Process(src.env, src.name, rawData)
+	if err != nil {
+		return fmt.Errorf("%s: %w", src.name, err)
+	}
+	b.Stats.Time(activityOf(p), processed)
+	err = b.writeOutput(publishedName, signature, data, src.info.Mode(), src.info.ModTime())
+	if err != nil {
+		return err
+	}
+	if path.Ext(publishedName) == ".html" {
+		b.Stats.Count(stats.Rendered)
+	} else {
+		b.Stats.Count(stats.Copied)
+	}
+	b.Stats.Step(src.name, began)
+	return nil
+}
+
+// activityOf answers the activity, as the timings report names it, which processing a file with p amounts to:
+// copying, processing an image, or rendering, which covers everything else.
+func activityOf(p Processor) string {
+	switch p.(type) {
+	case CopyProcessor:
+		return stats.Copy
+	case ImageProcessor:
+		return stats.Images
+	}
+	return stats.Render
+}
+
+// processorSignature identifies everything that goes into a processed output:
+// its source's content hash and mode, the configuration, the processor, the content of any files the processor depends upon,
+// and, for processors using the asset map, the map itself.
+func (b *builder) processorSignature(env *Env, p Processor, parts ...string) (string, error) {
+	parts = append(parts, env.configSignature, p.Name())
+	if d, ok := p.(Dependent); ok {
+		for _, fn := range d.Dependencies(env) {
+			fn = b.sourceNameFor(fn)
+			fi, err := os.Stat(fn)
+			if err != nil {
+				return "", err
+			}
+			hash, err := b.cache.HashFile(fn, fi)
+			if err != nil {
+				return "", err
+			}
+			parts = append(parts, hash)
+		}
+	}
+	if user, ok := p.(AssetUser); ok && user.UsesAssets() {
+		raw, err := json.Marshal(b.Assets)
+		if err != nil {
+			return "", err
+		}
+		parts = append(parts, buildcache.Hash(raw))
+	}
+	return buildcache.Signature(parts...), nil
+}
+
+// writeOutput writes data to the output with the given published name, creating directories as needed, and records the write in the build cache.
+// The output gets the configured permissions, or else the given mode;
+// if so configured, it also gets the given modification time, that of its newest source.
+// In a dry run, it only reports what it would have written.
+func (b *builder) writeOutput(publishedName, signature string, data []byte, mode os.FileMode, modTime time.Time) error {
+	outputName, err := b.outputNameFor(publishedName)
+	if err != nil {
+		return err
+	}
+	if b.DryRun {
+		dryrun.ReportWriteIfChanged(outputName, data)
+		return nil
+	}
+	defer b.Stats.Time(stats.Write, time.Now())
+	err = directory.EnsureDirAll(filepath.Dir(outputName), b.Config.Output.DirPerm())
+	if err != nil {
+		return err
+	}
+	perm := b.Config.Output.FilePerm(mode)
+	hash := buildcache.Hash(data)
+	original, duplicate := b.written[hash]
+	switch {
+	case b.Config.Output.Dedupe && duplicate:
+		if !sameFile(original, outputName) {
+			err = replaceWithLink(original, outputName)
+			b.rewritten[publishedName] = true
+		}
+	case buildcache.Unchanged(outputName, data):
+		// Rewriting identical content would only disturb the output's modification time.
+	default:
+		err = directory.WriteFileAtomic(outputName, data, perm)
+		if err == nil {
+			b.Stats.Wrote(len(data))
+		}
+		b.rewritten[publishedName] = true
+	}
+	if err == nil {
+		if !duplicate {
+			b.written[hash] = outputName
+		}
+		// An up-to-date or linked output may have the wrong permissions.
+		err = os.Chmod(outputName, perm)
+	}
+	if err == nil && b.Config.Output.PreserveMtimes {
+		err = os.Chtimes(outputName, time.Now(), modTime)
+	}
+	return b.recordWrite(publishedName, signature, err)
+}
+
+// sameFile answers true if both names refer to the same file, as hard links do.
+func sameFile(a, b string) bool {
+	fa, err := os.Stat(a)
+	if err != nil {
+		return false
+	}
+	fb, err := os.Stat(b)
+	return err == nil && os.SameFile(fa, fb)
+}
+
+// replaceWithLink replaces the named file with a hard link to original.
+func replaceWithLink(original, filename string) error {
+	err := os.Remove(filename)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return os.Link(original, filename)
+}
+
+// recordWrite updates the build cache after an attempt to write an output.
+// A successful write records the signature of the output's inputs; a failed one erases any record of the output.
+// The write's error, if any, is returned.
+func (b *builder) recordWrite(publishedName, signature string, err error) error {
+	if err != nil {
+		b.cache.Forget(publishedName)
+		return err
+	}
+	b.cache.Record(publishedName, signature)
+	return nil
+}
+
+// bundleSignature identifies everything that goes into building a bundle: its name, configuration, and constituents' content.
+func (b *builder) bundleSignature(bundle config.Bundle) (string, error) {
+	parts := []string{bundle.Name, b.env.configSignature}
+	for _, fn := range bundle.Files {
+		fn = b.sourceNameFor(fn)
+		fi, err := os.Stat(fn)
+		if err != nil {
+			return "", err
+		}
+		hash, err := b.cache.HashFile(fn, fi)
+		if err != nil {
+			return "", err
+		}
+		parts = append(parts, hash)
+		if b.env.substitutes(fn) {
+			parts = append(parts, b.env.varsSignature)
+		}
+	}
+	return buildcache.Signature(parts...), nil
+}
+
+// readSource reads the named source file, substituting variables into it if it's subject to substitution in the given environment.
+func (b *builder) readSource(env *Env, fn string) ([]byte, error) {
+	content, err := ioutil.ReadFile(b.sourceNameFor(fn))
+	if err != nil || !env.substitutes(fn) {
+		return content, err
+	}
+	content, err = substitute(content, env.Variables)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", fn, err)
+	}
+	return content, nil
+}
+
+// buildBundle concatenates a bundle's files, minifies the result if so configured, and writes it into the output directory.
+// Scripts are joined with a semicolon and line break, so a file lacking a final semicolon can't merge with the next file's first statement.
+// If none of the bundle's constituents changed since the previous build, the bundle published then is kept.
+func (b *builder) buildBundle(bundle config.Bundle) error {
+	name, err := cleanName(bundle.Name)
+	if err != nil {
+		return err
+	}
+	bundle.Name = name
+	signature, err := b.bundleSignature(bundle)
+	if err != nil {
+		return err
+	}
+	previousName := b.previousAssets.Lookup(bundle.Name)
+	if !b.Force && b.cache.Fresh(previousName, signature) {
+		b.Stats.Count(stats.Fresh)
+		if previousName != bundle.Name {
+			b.Assets[bundle.Name] = previousName
+		}
+		return b.produce(previousName, bundle.Files...)
+	}
+
+	separator := []byte("\n")
+	if path.Ext(bundle.Name) == ".js" {
+		separator = []byte(";\n")
+	}
+
+	var contents [][]byte
+	var newest time.Time
+	for _, fn := range bundle.Files {
+		fi, err := os.Stat(b.sourceNameFor(fn))
+		if err != nil {
+			return err
+		}
+		if fi.ModTime().After(newest) {
+			newest = fi.ModTime()
+		}
+		rawData, err := b.readSource(b.env, fn)
+		if err != nil {
+			return err
+		}
+		contents = append(contents, rawData)
+	}
+	data := bytes.Join(contents, separator)
+	if b.Config.Assets.Minify {
+		data = minify.ForName(bundle.Name, data)
+	}
+
+	publishedName := b.publishedNameFor(bundle.Name, buildcache.Hash(data))
+	err = b.produce(publishedName, bundle.Files...)
+	if err != nil {
+		return err
+	}
+	b.Stats.Count(stats.Bundled)
+	return b.writeOutput(publishedName, signature, data, 0644, newest)
+}
+
+// newEnv answers the environment in which files governed by the given configuration are processed.
+func (b *builder) newEnv(cfg *config.Config) (env *Env, err error) {
+	env = &Env{Config: cfg, SourceDir: b.SourceDir, Assets: b.Assets, Variables: Variables(cfg, b.started), DebugTemplates: b.DebugTemplates}
+	env.configSignature, err = b.signatureOfConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	env.Blogroll, err = blogroll.Load(cfg)
+	if err != nil {
+		return nil, err
+	}
+	env.varsSignature, err = signatureOfVariables(env.Variables)
+	return env, err
+}
+
+// signatureOfConfig identifies the parts of a configuration bearing on the static pass, together with the options that override them.
+func (b *builder) signatureOfConfig(cfg *config.Config) (string, error) {
+	raw, err := json.Marshal([]interface{}{cfg.Output, cfg.Assets, cfg.Files, cfg.Markdown, cfg.Sass, cfg.Images, cfg.Bundles, cfg.Blogroll})
+	if err != nil {
+		return "", err
+	}
+	return buildcache.Signature(buildcache.Hash(raw), fmt.Sprint(b.Fingerprint), b.Symlinks), nil
+}
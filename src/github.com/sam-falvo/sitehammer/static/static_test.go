@@ -0,0 +1,131 @@
+package static
+
+import (
+	"github.com/sam-falvo/sitehammer/buildcache"
+	"github.com/sam-falvo/sitehammer/config"
+	"github.com/sam-falvo/sitehammer/directory"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestCleanName(t *testing.T) {
+	tests := []struct {
+		fn   string
+		want string
+		// windows is true if Windows, which also separates components with backslashes, refuses the name, though others accept it.
+		windows bool
+	}{
+		{"index.html", "index.html", false},
+		{"/theme/site.css", "theme/site.css", false},
+		{"theme//./site.css", "theme/site.css", false},
+		{"theme/../site.css", "site.css", false},
+		{`theme\site.css`, `theme\site.css`, false},
+		{`..\outside.css`, `..\outside.css`, true},
+		{`theme\..\..\outside.css`, `theme\..\..\outside.css`, true},
+		{`C:\x`, `C:\x`, true},
+		{"C:x", "C:x", true},
+		{`\\host\share\x`, `\\host\share\x`, true},
+		{"..", "", true},
+		{"../outside.css", "", true},
+		{"theme/../../outside.css", "", true},
+		{".", "", true},
+		{"/", "", true},
+	}
+	for _, tc := range tests {
+		want, wantErr := tc.want, tc.want == ""
+		if tc.windows && runtime.GOOS == "windows" {
+			want, wantErr = "", true
+		}
+		got, err := cleanName(tc.fn)
+		if (err != nil) != wantErr || got != want {
+			t.Errorf("cleanName(%q) = %q, %v; want %q, error %v", tc.fn, got, err, want, wantErr)
+		}
+	}
+}
+
+func TestSourceNameFor(t *testing.T) {
+	tests := []struct {
+		sourceDir, fn, want string
+	}{
+		{".", "index.html", "index.html"},
+		{".", "theme/site.css", filepath.FromSlash("theme/site.css")},
+		{"site", "theme/site.css", filepath.FromSlash("site/theme/site.css")},
+		{filepath.FromSlash("../site"), "theme/site.css", filepath.FromSlash("../site/theme/site.css")},
+		{filepath.FromSlash("site/sub/.."), "a/b.html", filepath.FromSlash("site/a/b.html")},
+	}
+	for _, tc := range tests {
+		b := &builder{Options: Options{SourceDir: tc.sourceDir}}
+		if got := b.sourceNameFor(tc.fn); got != tc.want {
+			t.Errorf("sourceNameFor(%q) in %q = %q, want %q", tc.fn, tc.sourceDir, got, tc.want)
+		}
+	}
+}
+
+func TestOutputNameFor(t *testing.T) {
+	out := filepath.FromSlash("build/_site")
+	tests := []struct {
+		fn      string
+		want    string
+		windows bool
+	}{
+		{"index.html", filepath.Join(out, "index.html"), false},
+		{"/theme/site.css", filepath.Join(out, "theme", "site.css"), false},
+		{"theme/../site.css", filepath.Join(out, "site.css"), false},
+		{`..\outside.css`, filepath.Join(out, `..\outside.css`), true},
+		{`C:\x`, filepath.Join(out, `C:\x`), true},
+		{"../outside.css", "", true},
+	}
+	b := &builder{Options: Options{OutputDir: out}}
+	for _, tc := range tests {
+		want, wantErr := tc.want, tc.want == ""
+		if tc.windows && runtime.GOOS == "windows" {
+			want, wantErr = "", true
+		}
+		got, err := b.outputNameFor(tc.fn)
+		if (err != nil) != wantErr || got != want {
+			t.Errorf("outputNameFor(%q) = %q, %v; want %q, error %v", tc.fn, got, err, want, wantErr)
+		}
+	}
+}
+
+// TestBuildIgnoreFiles checks that the rules of a subdirectory's .hammerignore apply beneath it, over those of the source directory's.
+func TestBuildIgnoreFiles(t *testing.T) {
+	dir := t.TempDir()
+	defer func(saved string) { buildcache.Dir = saved }(buildcache.Dir)
+	buildcache.Dir = filepath.Join(dir, "cache")
+	sourceDir, outputDir := filepath.Join(dir, "src"), filepath.Join(dir, "_site")
+	files := map[string]string{
+		directory.HammerIgnoreFilename:           "*.tmp\n",
+		"a.tmp":                                  "",
+		"a.html":                                 "",
+		"docs/" + config.DirectoryFilename:       "",
+		"docs/" + directory.HammerIgnoreFilename: "!keep.tmp\n",
+		"docs/keep.tmp":                          "",
+		"docs/other.tmp":                         "",
+	}
+	for name, content := range files {
+		filename := filepath.Join(sourceDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filename, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, err := Build(Options{Config: config.Default(), SourceDir: sourceDir, OutputDir: outputDir, Symlinks: config.SymlinksFollow})
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+	for name, want := range map[string]bool{
+		"a.html": true, "a.tmp": false, "docs/keep.tmp": true, "docs/other.tmp": false,
+		directory.HammerIgnoreFilename: false, "docs/" + directory.HammerIgnoreFilename: false,
+	} {
+		_, err := os.Stat(filepath.Join(outputDir, filepath.FromSlash(name)))
+		if got := err == nil; got != want {
+			t.Errorf("%s published: %v, want %v", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package static
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/sam-falvo/sitehammer/buildcache"
+	"github.com/sam-falvo/sitehammer/config"
+	"github.com/sam-falvo/sitehammer/directory"
+	"github.com/sam-falvo/sitehammer/failure"
+	"regexp"
+	"time"
+)
+
+// variableReference matches a reference to a build variable, like @@base_url@@.
+var variableReference = regexp.MustCompile(`@@([A-Za-z_][A-Za-z0-9_]*)@@`)
+
+// Variables answers the variables available for substitution into text assets when building at the given time:
+// base_url, the blog's base URL; version, the configured version string; build_date, the date of the build;
+// and any variables configured in the substitution table, which may override the others.
+func Variables(cfg *config.Config, now time.Time) map[string]string {
+	vars := map[string]string{
+		"base_url":   cfg.Blog.BaseUrl,
+		"version":    cfg.Substitution.Version,
+		"build_date": now.Format("2006-01-02"),
+	}
+	for name, value := range cfg.Substitution.Vars {
+		vars[name] = value
+	}
+	return vars
+}
+
+// substitutes answers true if the named source file is subject to variable substitution.
+func (env *Env) substitutes(name string) bool {
+	for _, pattern := range env.Config.Substitution.Files {
+		if matched, _ := directory.MatchGlob(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// substitute replaces every variable reference in content with the variable's value.
+// A reference to an undefined variable is an error, so typos don't reach the published site.
+func substitute(content []byte, vars map[string]string) ([]byte, error) {
+	var err error
+	content = variableReference.ReplaceAllFunc(content, func(ref []byte) []byte {
+		name := string(ref[2 : len(ref)-2])
+		value, ok := vars[name]
+		if !ok {
+			if err == nil {
+				err = failure.Wrap(failure.Content, fmt.Errorf("Undefined variable %s.", name))
+			}
+			return ref
+		}
+		return []byte(value)
+	})
+	return content, err
+}
+
+// signatureOfVariables identifies the variables' values, so files subject to substitution are rebuilt when they change.
+func signatureOfVariables(vars map[string]string) (string, error) {
+	raw, err := json.Marshal(vars)
+	if err != nil {
+		return "", err
+	}
+	return buildcache.Hash(raw), nil
+}
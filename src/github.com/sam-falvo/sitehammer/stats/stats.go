@@ -0,0 +1,406 @@
+/*
+The stats package keeps count of what a build does, so the build can show its progress while running
+and summarize its work once done: what it produced, how many bytes it wrote, how long it took, and where the time went.
+
+All methods are safe to call on a nil *Stats, which does nothing,
+so code reporting statistics needn't check whether anybody's collecting them.
+*/
+package stats
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/sam-falvo/sitehammer/report"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Kinds of work counted, as the summary describes more than one unit of each.
+const (
+	Rendered = "pages rendered"
+	Copied   = "assets copied"
+	Bundled  = "bundles built"
+	Fresh    = "outputs up to date"
+)
+
+// singular describes a single unit of each kind of work counted.
+var singular = map[string]string{
+	Rendered: "page rendered",
+	Copied:   "asset copied",
+	Bundled:  "bundle built",
+	Fresh:    "output up to date",
+}
+
+// Activities timed across the whole build, whichever pass does them, for the timings report:
+// parsing descriptors, sources, and templates; rendering pages; copying files verbatim;
+// processing images; and writing outputs.
+const (
+	Parse  = "parse"
+	Render = "render"
+	Copy   = "copy"
+	Images = "images"
+	Write  = "write"
+)
+
+// slowest says how many of the slowest steps the summary lists.
+const slowest = 5
+
+// progressInterval limits how often the progress indicator is redrawn.
+const progressInterval = 100 * time.Millisecond
+
+// Step records how long one step of the build took, such as processing a single file.
+type Step struct {
+	Name    string
+	Elapsed time.Duration
+}
+
+// Timing records the total time spent on one activity, summed over the Count times it was done.
+type Timing struct {
+	Activity string
+	Elapsed  time.Duration
+	Count    int
+}
+
+// Stats collects the statistics of one build.
+type Stats struct {
+	lock       sync.Mutex
+	started    time.Time
+	counts     map[string]int
+	kinds      []string
+	written    int64
+	phases     []Step
+	steps      []Step
+	timings    map[string]*Timing
+	activities []string
+	progress   io.Writer
+	drawn      time.Time
+	items      int
+	quiet      bool
+	dryRun     bool
+}
+
+// New starts collecting statistics.
+// If progress isn't nil, a progress indicator is kept up to date on it as work is counted.
+func New(progress io.Writer) *Stats {
+	return &Stats{
+		started:  time.Now(),
+		counts:   make(map[string]int),
+		kinds:    []string{Rendered, Copied, Bundled, Fresh},
+		timings:  make(map[string]*Timing),
+		progress: progress,
+	}
+}
+
+// Start begins collecting statistics for a command, unless quiet is true, in which case it answers nil.
+// The progress indicator appears on standard error, but only if that's a terminal, so it doesn't clutter logs,
+// and only if reports are written as text.
+func Start(quiet bool) *Stats {
+	if quiet {
+		return nil
+	}
+	var progress io.Writer
+	if IsTerminal(os.Stderr) && !report.IsJSON() {
+		progress = os.Stderr
+	}
+	return New(progress)
+}
+
+// StartTimed works like Start, but collects statistics even if quiet is true, so that their timings can be reported;
+// quiet then suppresses only the progress indicator and the summary.
+func StartTimed(quiet bool) *Stats {
+	if !quiet {
+		return Start(false)
+	}
+	s := New(nil)
+	s.quiet = true
+	return s
+}
+
+// SetDryRun notes that the build is a dry run, which writes nothing, so that the summary tells what a build would have done.
+func (s *Stats) SetDryRun() {
+	if s == nil {
+		return
+	}
+	s.lock.Lock()
+	s.dryRun = true
+	s.lock.Unlock()
+}
+
+// IsTerminal answers true if f is a terminal, and so a fit place for a progress indicator.
+func IsTerminal(f *os.File) bool {
+	return report.IsTerminal(f)
+}
+
+// Count notes that one more unit of work of the given kind was done, such as rendering a page.
+func (s *Stats) Count(kind string) {
+	if s == nil {
+		return
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if _, ok := s.counts[kind]; !ok && !s.known(kind) {
+		s.kinds = append(s.kinds, kind)
+	}
+	s.counts[kind]++
+	s.items++
+	if s.progress != nil && time.Since(s.drawn) >= progressInterval {
+		s.drawn = time.Now()
+		fmt.Fprintf(s.progress, "\r%s, %s written, %s elapsed ", plural(s.items, "output", "outputs"), Bytes(s.written), round(time.Since(s.started)))
+	}
+}
+
+// known answers true if the kind of work is listed in the summary already.
+func (s *Stats) known(kind string) bool {
+	for _, k := range s.kinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// Wrote notes that n bytes were written to the output directory.
+func (s *Stats) Wrote(n int) {
+	if s == nil {
+		return
+	}
+	s.lock.Lock()
+	s.written += int64(n)
+	s.lock.Unlock()
+}
+
+// Step notes that the named step, begun at the given time, is done.
+func (s *Stats) Step(name string, began time.Time) {
+	if s == nil {
+		return
+	}
+	s.lock.Lock()
+	s.steps = append(s.steps, Step{name, time.Since(began)})
+	s.lock.Unlock()
+}
+
+// Phase notes that the named phase of the build, such as the static pass, begun at the given time, is done.
+func (s *Stats) Phase(name string, began time.Time) {
+	if s == nil {
+		return
+	}
+	s.lock.Lock()
+	s.phases = append(s.phases, Step{name, time.Since(began)})
+	s.lock.Unlock()
+}
+
+// Time notes that one more instance of the given activity, such as rendering a page, begun at the given time, is done.
+// Unlike steps and phases, activities overlap one another's phases: the static pass and the blog both render pages.
+func (s *Stats) Time(activity string, began time.Time) {
+	if s == nil {
+		return
+	}
+	elapsed := time.Since(began)
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	t, ok := s.timings[activity]
+	if !ok {
+		t = &Timing{Activity: activity}
+		s.timings[activity] = t
+		s.activities = append(s.activities, activity)
+	}
+	t.Elapsed += elapsed
+	t.Count++
+}
+
+// Timings answers the total time spent on each activity timed so far, the most time-consuming first.
+func (s *Stats) Timings() []Timing {
+	if s == nil {
+		return nil
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	var timings []Timing
+	for _, activity := range s.activities {
+		timings = append(timings, *s.timings[activity])
+	}
+	sort.SliceStable(timings, func(i, j int) bool { return timings[i].Elapsed > timings[j].Elapsed })
+	return timings
+}
+
+// ReportTimings reports the time spent on each activity, along with the build's phases, through the report package,
+// so that a slow build can be investigated: as a table on standard output in the text format,
+// or in the JSON format as a timings event, with elapsed_ms, phases (as in the summary event), and activities,
+// a list of activities each with its name, elapsed_ms, and count.
+// When several pages are rendered at once, the time spent on an activity can exceed the time the build took.
+func (s *Stats) ReportTimings() {
+	if s == nil {
+		return
+	}
+	timings := s.Timings()
+	s.lock.Lock()
+	elapsed := time.Since(s.started)
+	phases := append([]Step(nil), s.phases...)
+	s.lock.Unlock()
+
+	if !report.IsJSON() {
+		fmt.Fprintf(os.Stdout, "Timings (%s in all):\n", round(elapsed))
+		for _, ph := range phases {
+			fmt.Fprintf(os.Stdout, "  phase %-16s %10s\n", ph.Name, round(ph.Elapsed))
+		}
+		for _, t := range timings {
+			fmt.Fprintf(os.Stdout, "  %-22s %10s  %s, %s each on average\n", t.Activity, round(t.Elapsed), plural(t.Count, "time", "times"), round(t.Elapsed/time.Duration(t.Count)))
+		}
+		return
+	}
+
+	activities := []report.Fields{}
+	for _, t := range timings {
+		activities = append(activities, report.Fields{"name": t.Activity, "elapsed_ms": milliseconds(t.Elapsed), "count": t.Count})
+	}
+	report.Event("timings", fmt.Sprintf("timings of %d activities over %s", len(timings), round(elapsed)), report.Fields{
+		"elapsed_ms": milliseconds(elapsed),
+		"phases":     stepFields(phases),
+		"activities": activities,
+	})
+}
+
+// Summarize writes a summary of the build to w, clearing the progress indicator first.
+func (s *Stats) Summarize(w io.Writer) {
+	if s == nil {
+		return
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.progress != nil && !s.drawn.IsZero() {
+		fmt.Fprintf(s.progress, "\r%s\r", strings.Repeat(" ", 60))
+	}
+
+	var counts []string
+	for _, kind := range s.kinds {
+		if n := s.counts[kind]; n > 0 {
+			counts = append(counts, counted(n, kind))
+		}
+	}
+	if len(counts) == 0 {
+		counts = append(counts, "nothing to do")
+	}
+	if s.dryRun {
+		fmt.Fprintf(w, "Dry run in %s, writing nothing; a build would have %s.\n", round(time.Since(s.started)), strings.Join(counts, ", "))
+	} else {
+		fmt.Fprintf(w, "Built in %s: %s; %s written.\n", round(time.Since(s.started)), strings.Join(counts, ", "), Bytes(s.written))
+	}
+	if len(s.phases) > 0 {
+		fmt.Fprintf(w, "  %s\n", describe(s.phases))
+	}
+
+	steps := s.slowestSteps()
+	if len(steps) > 0 {
+		fmt.Fprintf(w, "  slowest: %s\n", describe(steps))
+	}
+}
+
+// Report summarizes the build through the report package.
+// In the text format, the summary is that of Summarize, on standard output;
+// in the JSON format, it's a summary event, whose fields give the figures in full:
+// elapsed_ms, bytes, counts (by kind of work), phases, and slowest (each a list of steps with their name and elapsed_ms),
+// and dry_run, true if the counts tell what a dry run found to do.
+// Statistics collected quietly, by StartTimed, are not summarized.
+func (s *Stats) Report() {
+	if s == nil || s.quiet {
+		return
+	}
+	var text bytes.Buffer
+	s.Summarize(&text)
+	if !report.IsJSON() {
+		os.Stdout.Write(text.Bytes())
+		return
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	counts := make(map[string]int)
+	for kind, n := range s.counts {
+		counts[kind] = n
+	}
+	message := strings.SplitN(text.String(), "\n", 2)[0]
+	report.Event("summary", message, report.Fields{
+		"elapsed_ms": milliseconds(time.Since(s.started)),
+		"bytes":      s.written,
+		"counts":     counts,
+		"phases":     stepFields(s.phases),
+		"slowest":    stepFields(s.slowestSteps()),
+		"dry_run":    s.dryRun,
+	})
+}
+
+// stepFields describes steps for a JSON report.
+func stepFields(steps []Step) []report.Fields {
+	fields := []report.Fields{}
+	for _, st := range steps {
+		fields = append(fields, report.Fields{"name": st.Name, "elapsed_ms": milliseconds(st.Elapsed)})
+	}
+	return fields
+}
+
+// counted describes n units of the given kind of work, as in "1 page rendered" or "2 pages rendered".
+// A kind without a singular form is described as it's named, whatever n is.
+func counted(n int, kind string) string {
+	if one, ok := singular[kind]; ok {
+		return plural(n, one, kind)
+	}
+	return fmt.Sprintf("%d %s", n, kind)
+}
+
+// plural describes n of something, in the singular form one if n is 1, or the plural form many otherwise.
+func plural(n int, one, many string) string {
+	if n == 1 {
+		return "1 " + one
+	}
+	return fmt.Sprintf("%d %s", n, many)
+}
+
+// milliseconds answers a duration in milliseconds, with a fractional part.
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// slowestSteps answers the slowest steps, slowest first; the caller must hold the lock.
+func (s *Stats) slowestSteps() []Step {
+	steps := append([]Step(nil), s.steps...)
+	sort.SliceStable(steps, func(i, j int) bool { return steps[i].Elapsed > steps[j].Elapsed })
+	if len(steps) > slowest {
+		steps = steps[:slowest]
+	}
+	return steps
+}
+
+// describe lists steps and their durations.
+func describe(steps []Step) string {
+	var parts []string
+	for _, st := range steps {
+		parts = append(parts, fmt.Sprintf("%s %s", st.Name, round(st.Elapsed)))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// round answers a duration rounded for display.
+func round(d time.Duration) time.Duration {
+	if d >= time.Second {
+		return d.Round(10 * time.Millisecond)
+	}
+	return d.Round(100 * time.Microsecond)
+}
+
+// Bytes describes a byte count, like "1.4 MB".
+func Bytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return plural(int(n), "byte", "bytes")
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,66 @@
+package stats
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestSummarizeCounts(t *testing.T) {
+	tests := []struct {
+		counts map[string]int
+		want   string
+	}{
+		{map[string]int{}, ": nothing to do; 0 bytes written."},
+		{map[string]int{Rendered: 1}, ": 1 page rendered; 0 bytes written."},
+		{map[string]int{Rendered: 2, Copied: 1, Fresh: 1}, ": 2 pages rendered, 1 asset copied, 1 output up to date; 0 bytes written."},
+		{map[string]int{Bundled: 1, Fresh: 3}, ": 1 bundle built, 3 outputs up to date; 0 bytes written."},
+		{map[string]int{"feeds written": 1}, ": 1 feeds written; 0 bytes written."},
+	}
+	for _, tc := range tests {
+		s := New(nil)
+		for kind, n := range tc.counts {
+			for i := 0; i < n; i++ {
+				s.Count(kind)
+			}
+		}
+		var summary bytes.Buffer
+		s.Summarize(&summary)
+		if got := strings.SplitN(summary.String(), "\n", 2)[0]; !strings.HasSuffix(got, tc.want) {
+			t.Errorf("counting %v summarized %q, want it to end %q", tc.counts, got, tc.want)
+		}
+	}
+}
+
+func TestBytes(t *testing.T) {
+	tests := []struct {
+		n    int64
+		want string
+	}{
+		{0, "0 bytes"},
+		{1, "1 byte"},
+		{1023, "1023 bytes"},
+		{1024, "1.0 KB"},
+		{1536, "1.5 KB"},
+		{5 << 20, "5.0 MB"},
+	}
+	for _, tc := range tests {
+		if got := Bytes(tc.n); got != tc.want {
+			t.Errorf("Bytes(%d) = %q, want %q", tc.n, got, tc.want)
+		}
+	}
+}
+
+func TestSummarizeDryRun(t *testing.T) {
+	s := New(nil)
+	s.SetDryRun()
+	s.Count(Rendered)
+	s.Count(Copied)
+	s.Count(Copied)
+	var summary bytes.Buffer
+	s.Summarize(&summary)
+	got := strings.SplitN(summary.String(), "\n", 2)[0]
+	if want := ", writing nothing; a build would have 1 page rendered, 2 assets copied."; !strings.HasPrefix(got, "Dry run in ") || !strings.HasSuffix(got, want) {
+		t.Errorf("a dry run summarized %q, want it to begin \"Dry run in\" and end %q", got, want)
+	}
+}
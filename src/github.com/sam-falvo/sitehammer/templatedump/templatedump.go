@@ -0,0 +1,50 @@
+/*
+The templatedump package records the data each page's template was executed with, as JSON,
+so that a question like "why is this field empty?" can be answered by reading the data, rather than by print-debugging the template.
+
+Each page's data goes to a file of its own beneath the dump directory, named after the page, with .json appended:
+the data of _site/articles/1234/index.html, for instance, goes to articles/1234/index.html.json.
+Values the template sees as HTML appear as JSON strings; the template functions, such as Asset, don't appear at all.
+*/
+package templatedump
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/sam-falvo/sitehammer/directory"
+	"github.com/sam-falvo/sitehammer/report"
+	"path/filepath"
+)
+
+// DirName names the dump directory, relative to the cache directory.
+const DirName = "template-data"
+
+// Dump writes data, with which a template was executed to render the named page, to the page's file beneath dir,
+// reporting the file written.
+// The page is named by a slash-separated path relative to the output directory.
+// Nothing is written if dir is empty.
+func Dump(dir, page string, data interface{}) error {
+	if dir == "" {
+		return nil
+	}
+	var content bytes.Buffer
+	encoder := json.NewEncoder(&content)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	err := encoder.Encode(data)
+	if err != nil {
+		return fmt.Errorf("Cannot record the template data of %s: %v", page, err)
+	}
+	filename := filepath.Join(dir, filepath.FromSlash(page)) + ".json"
+	err = directory.EnsureDirAll(filepath.Dir(filename), 0755)
+	if err != nil {
+		return err
+	}
+	err = directory.WriteFileAtomic(filename, content.Bytes(), 0644)
+	if err != nil {
+		return err
+	}
+	report.Event("template-data", fmt.Sprintf("template data of %s in %s", page, filename), report.Fields{"page": page, "path": filename})
+	return nil
+}
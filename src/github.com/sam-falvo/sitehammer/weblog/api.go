@@ -0,0 +1,172 @@
+package weblog
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/sam-falvo/sitehammer/dryrun"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// ArticleJsonFilename names the JSON document of an article, beside its page in the article's directory,
+// when the blog table's json_api setting is true.
+const ArticleJsonFilename = "index.json"
+
+// ArticlesJsonFilename names the JSON list of every article, relative to the blog's output directory, when the JSON API is enabled.
+const ArticlesJsonFilename = "articles.json"
+
+// TagsJsonFilename names the JSON list of every tag and the articles under it, relative to the blog's output directory, when the JSON API is enabled.
+const TagsJsonFilename = "tags.json"
+
+// apiArticle is what the JSON API tells of an article.
+// Published is as the descriptor gives it, and Date, the same in RFC 3339 form, if Date can make sense of it.
+// Body is told only by the article's own document, not the list of every article.
+// Email addresses, whether authors' or contributors', are left out, since pages show them only if their templates choose to.
+type apiArticle struct {
+	Id        uint     `json:"id"`
+	Title     string   `json:"title"`
+	Author    string   `json:"author"`
+	Published string   `json:"published"`
+	Date      string   `json:"date,omitempty"`
+	Tags      []string `json:"tags"`
+	Url       string   `json:"url"`
+	Json      string   `json:"json"`
+	Abstract  string   `json:"abstract"`
+	Body      string   `json:"body,omitempty"`
+	Previous  *apiLink `json:"previous,omitempty"`
+	Next      *apiLink `json:"next,omitempty"`
+	History   *History `json:"history,omitempty"`
+}
+
+// apiLink refers to an article from another's document, or from a tag.
+type apiLink struct {
+	Id    uint   `json:"id"`
+	Title string `json:"title"`
+	Url   string `json:"url"`
+	Json  string `json:"json"`
+}
+
+// apiTag is what the JSON API tells of a tag: its name, and the articles under it, in the order the descriptors list them.
+type apiTag struct {
+	Name     string    `json:"name"`
+	Count    int       `json:"count"`
+	Articles []apiLink `json:"articles"`
+}
+
+// jsonUrlFor answers the URL of an article's JSON document.
+func (b *blog) jsonUrlFor(a Article) string {
+	return b.urlFor(a) + "/" + ArticleJsonFilename
+}
+
+// apiLinkTo answers a reference to the article.
+func (b *blog) apiLinkTo(a Article) *apiLink {
+	return &apiLink{a.Id, a.Title, b.urlFor(a), b.jsonUrlFor(a)}
+}
+
+// apiArticleFor answers what the JSON API tells of the article, without its body.
+func (b *blog) apiArticleFor(a Article) apiArticle {
+	doc := apiArticle{
+		Id:        a.Id,
+		Title:     a.Title,
+		Author:    a.Author,
+		Published: a.Published,
+		Tags:      a.Tags,
+		Url:       b.urlFor(a),
+		Json:      b.jsonUrlFor(a),
+		Abstract:  string(a.Abstract),
+	}
+	if doc.Tags == nil {
+		doc.Tags = []string{}
+	}
+	if a.History != nil {
+		h := *a.History
+		h.Contributors = make([]Contributor, len(a.History.Contributors))
+		for i, c := range a.History.Contributors {
+			h.Contributors[i] = Contributor{Name: c.Name, Commits: c.Commits}
+		}
+		doc.History = &h
+	}
+	if t, ok := a.Date(); ok {
+		doc.Date = t.Format(time.RFC3339)
+	}
+	return doc
+}
+
+// emitJsonForArticle writes the JSON document of the article at index, beside its page:
+// everything apiArticleFor tells, along with its body, and references to the articles before and after it.
+func (b *blog) emitJsonForArticle(articles []Article, index int) error {
+	article := articles[index]
+	doc := b.apiArticleFor(article)
+	doc.Body = string(article.Body)
+	if index > 0 {
+		doc.Previous = b.apiLinkTo(articles[index-1])
+	}
+	if index+1 < len(articles) {
+		doc.Next = b.apiLinkTo(articles[index+1])
+	}
+	return b.writeJson(b.jsonNameFor(article), b.outputFilenameFor(article.Id, ArticleJsonFilename), doc, article.modTime)
+}
+
+// emitJsonCollections writes the lists of every article and every tag, beside the blog's index page.
+// Articles are listed in the order the descriptors list them, and tags, by name.
+func (b *blog) emitJsonCollections(articles []Article) error {
+	list := make([]apiArticle, 0, len(articles))
+	tags := make(map[string]*apiTag)
+	sources := []string{b.Descriptors}
+	var newest time.Time
+	for _, a := range articles {
+		list = append(list, b.apiArticleFor(a))
+		for _, name := range a.Tags {
+			t := tags[name]
+			if t == nil {
+				t = &apiTag{Name: name, Articles: []apiLink{}}
+				tags[name] = t
+			}
+			t.Count++
+			t.Articles = append(t.Articles, *b.apiLinkTo(a))
+		}
+		sources = append(sources, filepath.ToSlash(b.inputFilenameFor(a.Id, "abstract")))
+		if a.modTime.After(newest) {
+			newest = a.modTime
+		}
+	}
+	byName := make([]*apiTag, 0, len(tags))
+	for _, t := range tags {
+		byName = append(byName, t)
+	}
+	sort.Slice(byName, func(i, j int) bool { return byName[i].Name < byName[j].Name })
+
+	b.produce(ArticlesJsonFilename, sources...)
+	err := b.writeJson(ArticlesJsonFilename, filepath.Join(b.OutputDir, ArticlesJsonFilename), list, newest)
+	if err != nil {
+		return err
+	}
+	b.produce(TagsJsonFilename, b.Descriptors)
+	return b.writeJson(TagsJsonFilename, filepath.Join(b.OutputDir, TagsJsonFilename), byName, time.Time{})
+}
+
+// writeJson writes a document of the JSON API, as writePage writes a page; a dry run reports whether it would change instead.
+// The HTML of abstracts and bodies is written as it is, rather than escaped, so it stays readable.
+func (b *blog) writeJson(name, filename string, doc interface{}, modTime time.Time) error {
+	var out bytes.Buffer
+	encoder := json.NewEncoder(&out)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", " ")
+	err := encoder.Encode(doc)
+	if err != nil {
+		return fmt.Errorf("Cannot encode %s: %v", filepath.ToSlash(filename), err)
+	}
+	content := out.Bytes()
+	if b.DryRun {
+		dryrun.ReportWriteIfChanged(filename, content)
+		return nil
+	}
+	return b.writePage(name, filename, content, modTime)
+}
+
+// jsonNameFor answers the slash-separated name, relative to the output directory, of the article's JSON document.
+func (b *blog) jsonNameFor(a Article) string {
+	return fmt.Sprintf("%s/%d/%s", ArticleDirName, a.Id, ArticleJsonFilename)
+}
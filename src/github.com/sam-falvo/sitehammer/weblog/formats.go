@@ -0,0 +1,160 @@
+package weblog
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/sam-falvo/sitehammer/buildcache"
+	"github.com/sam-falvo/sitehammer/config"
+	"github.com/sam-falvo/sitehammer/failure"
+	"github.com/sam-falvo/sitehammer/orgmode"
+	"html"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// bodyFormat describes a format in which an article's body may be written: the name of the file holding it,
+// and how it's converted into HTML, if it isn't HTML already.
+type bodyFormat struct {
+	filename string
+	convert  func(b *blog, filename string, content []byte) ([]byte, error)
+}
+
+// builtinFormats lists the formats in which an article's body may be written, without any converters configured,
+// in the order they're looked for.
+var builtinFormats = []bodyFormat{
+	{"body", nil},
+	{"body.adoc", (*blog).asciidocToHtml},
+	{"body.rst", (*blog).rstToHtml},
+	{"body.org", orgToHtml},
+	{"body.txt", textToHtml},
+}
+
+// bodyFormats answers the formats in which an article's body may be written, in the order they're looked for:
+// the built-in formats, with those whose extensions have converters configured converted by them instead,
+// followed by the formats of the rest of the converters configured, in order of their extensions.
+// An article's body is written in one of them at most.
+func bodyFormats(cfg *config.Config) []bodyFormat {
+	formats := append([]bodyFormat(nil), builtinFormats...)
+	extensions := make([]string, 0, len(cfg.Converters))
+	for ext := range cfg.Converters {
+		extensions = append(extensions, ext)
+	}
+	sort.Strings(extensions)
+	for _, ext := range extensions {
+		f := bodyFormat{"body" + ext, converter(cfg.Converters[ext], ext)}
+		replaced := false
+		for i := range formats {
+			if formats[i].filename == f.filename {
+				formats[i], replaced = f, true
+			}
+		}
+		if !replaced {
+			formats = append(formats, f)
+		}
+	}
+	return formats
+}
+
+// BodyFilenames answers the names of the files in which an article's body may be written, in the order they're looked for:
+// body, holding HTML, body.adoc, holding AsciiDoc, body.rst, holding reStructuredText, body.org, holding Org-mode text,
+// body.txt, holding plain text, and those with the extensions of the converters configured.
+func BodyFilenames(cfg *config.Config) []string {
+	formats := bodyFormats(cfg)
+	names := make([]string, len(formats))
+	for i, f := range formats {
+		names[i] = f.filename
+	}
+	return names
+}
+
+// converter answers the conversion of bodies with the given extension by the given configured command.
+func converter(command, ext string) func(b *blog, filename string, content []byte) ([]byte, error) {
+	return func(b *blog, filename string, content []byte) ([]byte, error) {
+		return convertWith(command, ext, filename, content)
+	}
+}
+
+// asciidocToHtml converts a body written in AsciiDoc into HTML with the configured AsciiDoc command.
+func (b *blog) asciidocToHtml(filename string, content []byte) ([]byte, error) {
+	return convertWith(b.Config.AsciiDoc.Command, "AsciiDoc", filename, content)
+}
+
+// rstToHtml converts a body written in reStructuredText into HTML with the configured reStructuredText command.
+func (b *blog) rstToHtml(filename string, content []byte) ([]byte, error) {
+	return convertWith(b.Config.Rst.Command, "reStructuredText", filename, content)
+}
+
+// orgToHtml converts a body written in Org-mode into HTML; see the orgmode package.
+func orgToHtml(b *blog, filename string, content []byte) ([]byte, error) {
+	return orgmode.ToHtml(content), nil
+}
+
+var (
+	// textParagraphBreak matches the blank lines separating paragraphs of plain text.
+	textParagraphBreak = regexp.MustCompile(`\n[ \t]*\n\s*`)
+
+	// textUrl matches a URL within plain text, along with any punctuation trailing it.
+	textUrl = regexp.MustCompile(`(?:https?://|www\.)[^\s<>"]+`)
+)
+
+// textToHtml converts a body written as plain text into HTML, for notes written without markup:
+// runs of lines separated by blank lines become paragraphs, and URLs become links, the text being escaped otherwise.
+// Punctuation ending a sentence after a URL isn't taken as part of it, nor is a closing parenthesis it doesn't open.
+func textToHtml(b *blog, filename string, content []byte) ([]byte, error) {
+	var out bytes.Buffer
+	text := strings.Replace(string(content), "\r\n", "\n", -1)
+	for _, paragraph := range textParagraphBreak.Split(strings.TrimSpace(text), -1) {
+		if paragraph == "" {
+			continue
+		}
+		out.WriteString("<p>")
+		last := 0
+		for _, loc := range textUrl.FindAllStringIndex(paragraph, -1) {
+			url := strings.TrimRight(paragraph[loc[0]:loc[1]], ".,:;!?'")
+			if strings.HasSuffix(url, ")") && strings.Count(url, "(") < strings.Count(url, ")") {
+				url = url[:len(url)-1]
+			}
+			href := url
+			if strings.HasPrefix(url, "www.") {
+				href = "http://" + url
+			}
+			out.WriteString(html.EscapeString(paragraph[last:loc[0]]))
+			out.WriteString(`<a href="` + html.EscapeString(href) + `">` + html.EscapeString(url) + "</a>")
+			last = loc[0] + len(url)
+		}
+		out.WriteString(html.EscapeString(paragraph[last:]))
+		out.WriteString("</p>\n")
+	}
+	return out.Bytes(), nil
+}
+
+// documentBody matches the content of a whole HTML document's body element.
+var documentBody = regexp.MustCompile(`(?is)<body[^>]*>(.*)</body>`)
+
+// convertWith converts a body into HTML by running command, which reads the body on standard input and writes HTML on standard output.
+// If the command writes a whole HTML document, only the content of its body element is kept.
+// Conversions are cached, keyed on the command and the body's content, so an unchanged body isn't converted again;
+// see buildcache.Convert.
+func convertWith(command, format, filename string, content []byte) ([]byte, error) {
+	args := strings.Fields(command)
+	if len(args) == 0 {
+		return nil, failure.Wrap(failure.Usage, fmt.Errorf("No %s command is configured to convert %s.", format, filename))
+	}
+	signature := buildcache.Signature(format, command, buildcache.Hash(content))
+	return buildcache.Convert(buildcache.DirIn("."), signature, func() ([]byte, error) {
+		cmd := exec.Command(args[0], args[1:]...)
+		cmd.Stdin = bytes.NewReader(content)
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		out, err := cmd.Output()
+		if err != nil {
+			return nil, failure.Wrap(failure.Content, fmt.Errorf("Cannot convert %s: %s %s", filename, err, strings.TrimSpace(stderr.String())))
+		}
+		if m := documentBody.FindSubmatch(out); m != nil {
+			out = append(bytes.TrimSpace(m[1]), '\n')
+		}
+		return out, nil
+	})
+}
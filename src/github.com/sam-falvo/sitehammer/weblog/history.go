@@ -0,0 +1,106 @@
+package weblog
+
+import (
+	"bytes"
+	"github.com/sam-falvo/sitehammer/report"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// History describes an article's history, as the git repository holding its sources records it:
+// when its directory was first and last committed to, how many commits changed it, and who made them.
+// Templates see it as .History, which is nil unless the blog table's git_history setting is true,
+// or if nothing in the article's directory was ever committed.
+type History struct {
+	Created      time.Time     `json:"created"`
+	Updated      time.Time     `json:"updated"`
+	Commits      int           `json:"commits"`
+	Contributors []Contributor `json:"contributors"`
+}
+
+// Contributor names someone who committed changes to an article, as the repository's .mailmap file has it, if it has one,
+// along with the number of commits they made.
+type Contributor struct {
+	Name    string `json:"name"`
+	Email   string `json:"email,omitempty"`
+	Commits int    `json:"commits"`
+}
+
+// Separators of the fields of the git log lines historiesIn reads: a record separator begins each commit, and a unit separator parts its fields.
+const (
+	commitSeparator = "\x1e"
+	fieldSeparator  = "\x1f"
+)
+
+// historiesIn answers the history of each article, by ID, whose directory lies in the source directory dir,
+// from a single git log of the files within it.
+// Contributors are listed in the order of their first commits; merges, which change nothing themselves, don't count.
+// If dir isn't within a git repository, or git can't be run, a warning is reported, and no article has a history.
+func historiesIn(dir string) map[uint]*History {
+	histories := make(map[uint]*History)
+	cmd := exec.Command("git", "-c", "core.quotePath=false", "log", "--no-merges", "--relative", "--name-only",
+		"--format="+commitSeparator+"%aI"+fieldSeparator+"%aN"+fieldSeparator+"%aE", "--", ".")
+	cmd.Dir = dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		message := strings.TrimSpace(stderr.String())
+		if message == "" {
+			message = err.Error()
+		}
+		report.Warning("articles have no history, since git can't read that of %s: %s", filepath.ToSlash(dir), message)
+		return histories
+	}
+
+	// Commits come newest first, so the first seen to change an article is its latest, and the last seen, its earliest.
+	for _, commit := range strings.Split(string(out), commitSeparator)[1:] {
+		lines := strings.Split(commit, "\n")
+		fields := strings.Split(lines[0], fieldSeparator)
+		if len(fields) != 3 {
+			continue
+		}
+		when, err := time.Parse(time.RFC3339, fields[0])
+		if err != nil {
+			continue
+		}
+		changed := make(map[uint]bool)
+		for _, name := range lines[1:] {
+			first := strings.SplitN(name, "/", 2)
+			if len(first) < 2 {
+				continue
+			}
+			if id, err := strconv.ParseUint(first[0], 10, 0); err == nil {
+				changed[uint(id)] = true
+			}
+		}
+		for id := range changed {
+			h := histories[id]
+			if h == nil {
+				h = &History{Updated: when}
+				histories[id] = h
+			}
+			h.Created = when
+			h.Commits++
+			h.credit(fields[1], fields[2])
+		}
+	}
+	return histories
+}
+
+// credit counts a commit by the named contributor, the earliest commit yet, since commits are met newest first;
+// contributors are told apart by their email addresses, and known by the names of their latest commits.
+func (h *History) credit(name, email string) {
+	for i, c := range h.Contributors {
+		if strings.EqualFold(c.Email, email) {
+			// The contributor moves to the front, having made the earliest commit yet.
+			copy(h.Contributors[1:i+1], h.Contributors[:i])
+			h.Contributors[0] = Contributor{c.Name, c.Email, c.Commits + 1}
+			return
+		}
+	}
+	h.Contributors = append([]Contributor{{name, email, 1}}, h.Contributors...)
+}
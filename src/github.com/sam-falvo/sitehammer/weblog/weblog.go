@@ -0,0 +1,1166 @@
+/*
+The weblog package renders static HTML for one or more blog articles, along with the blog's index page.
+
+Blog articles are rendered in an output directory called articles.
+Each article rendered exists in a subdirectory named after the numeric article ID.
+For example, articles/1024/index.html.
+This allows easy linking to the articles.
+The blog's index page, index.html, sits beside the articles directory.
+
+The source material for each article appears in a source directory, ./src unless configured otherwise.
+Traditionally, the descriptor file also appears inside ./src, but doesn't have to.
+When looking for abstracts or bodies for each article,
+the blog looks in a directory named for the article ID.
+E.g., ./src/1024/abstract or ./src/1024/body.
+Both are HTML fragments.
+A body may instead be written in AsciiDoc, as body.adoc, or reStructuredText, as body.rst,
+converted into HTML by the command in the asciidoc or rst table of the configuration;
+conversions are cached, so an unchanged body isn't converted again.
+A body may also be written in Org-mode, as body.org, converted as the blog is built; see the orgmode package.
+Finally, a body may be plain text, as body.txt, for notes written without markup:
+its paragraphs, separated by blank lines, become HTML paragraphs, and URLs in it, links.
+Bodies in any other format may be converted by external commands, such as pandoc, named by extension in the converters table of the configuration.
+
+The descriptor file contains a JSON description of the set of articles to appear on the blog.
+Below is a sample descriptor file:
+
+	[
+	  {
+	    "Id": 1234,
+	    "Title": "Hello",
+	    "Author": "Sam",
+	    "Published": "2012-Jan-01",
+	    "Email": "kc5tja@arrl.net"
+	  }, {
+	    "Id": 1235,
+	    "Title": "World",
+	    "Author": "Sam",
+	    "Published": "2012-Jan-01",
+	    "Email": "kc5tja@arrl.net"
+	  }, {
+	    "Id": 1236,
+	    "Title": "Who are you?",
+	    "Author": "The Who",
+	    "Published": "2012-Jan-02",
+	    "Email": "ptownsend@thewho.com"
+	  },
+	]
+
+At present six fields may be specified about each article.
+The Id field numerically identifies the post.
+The Title field gives the post a human-readable name.
+This name appears in links leading to the article, for example.
+The Author field tells who wrote the article.
+The Published field indicates when the article was first published.
+Email provides contact information for the author.
+Finally, the optional Tags field lists topics the article falls under, like ["go", "tools"];
+templates see it as .Tags.
+
+If the sources are kept in git, and the blog table's git_history setting is true, templates see each article's history as .History,
+read from the repository as the blog is built: when the article's directory was first and last committed to, as .History.Created and .History.Updated,
+how many commits changed it, as .History.Commits, and who made them, as .History.Contributors, each with a Name, Email, and number of Commits.
+It suits a footer like this, and credit to everyone who edited the article, without fields kept by hand:
+
+	{{with .a.History}}<p>Last edited {{.Updated.Format "January 2, 2006"}}.</p>{{end}}
+
+An article never committed has no history, so templates should test for it, as with does.
+
+If the blog table's json_api setting is true, the blog is published as a read-only JSON API as well, for client-side apps and other tools.
+Each article's directory holds index.json beside index.html, giving the article's id, title, author, published date as written,
+date in RFC 3339 form (if Date understands the published date), tags, url, json (the URL of the document itself),
+abstract, and body, both as rendered HTML, along with its history, if it has one, and the id, title, url, and json of the previous and next articles.
+Beside the blog's index page, articles.json lists every article in the descriptors' order, as the articles' own documents do, but without their bodies,
+and tags.json lists every tag by name, each with its count and the articles under it.
+Email addresses, whether authors' or contributors', are never published this way; templates decide whether pages show them.
+
+Templates may call the Asset function to learn the published name of a stylesheet, script, or image,
+e.g., {{Asset "/theme/css.css"}}.
+If the static pass fingerprinted the asset, the fingerprinted name results;
+otherwise, the name passes through unchanged.
+They may call the Blogroll function to render the blogroll, say in a sidebar; see the blogroll package.
+*/
+package weblog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/sam-falvo/sitehammer/assets"
+	"github.com/sam-falvo/sitehammer/blogroll"
+	"github.com/sam-falvo/sitehammer/buildcache"
+	"github.com/sam-falvo/sitehammer/config"
+	"github.com/sam-falvo/sitehammer/directory"
+	"github.com/sam-falvo/sitehammer/dryrun"
+	"github.com/sam-falvo/sitehammer/errlist"
+	"github.com/sam-falvo/sitehammer/failure"
+	"github.com/sam-falvo/sitehammer/report"
+	"github.com/sam-falvo/sitehammer/stats"
+	"github.com/sam-falvo/sitehammer/templatedump"
+	"html/template"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+)
+
+// ArticleDirName names the directory, relative to the blog's output directory, in which articles are placed.
+const ArticleDirName = "articles"
+
+// IndexFilename names the blog's front matter/home page, relative to the blog's output directory.
+const IndexFilename = "index.html"
+
+// CacheFilename names the record, relative to the cache directory, of the signature of every article page the last successful build rendered;
+// see Options.Cache.
+// It's kept apart from the metadata store, as the static pass's and precompression's caches are:
+// the store records source files, and a descriptor file serves every article, so it can't tell which articles' pages an edit to it concerns;
+// and the blog command, which keeps this cache too, leaves the store alone.
+const CacheFilename = "blog.json"
+
+// pageOverhead estimates how much an article's page holds beyond the article's abstract and body: the article template's own markup.
+const pageOverhead = 16 << 10
+
+// The number of articles to show on the index page.
+// TODO(sfalvo): Make this a user-configurable setting.
+const numberOfArticlesOnIndexPage = 5
+
+// Descriptor describes a single article in the blog.
+// When running the blog generator, the article descriptors file contains an array of these structures, encoded in JSON format.
+//
+// The Id field uniquely identifies the blog article amongst others as far as the blog generator and external hyperlinks are concerned.
+// No two articles share the same Id.
+// The Id must be greater than or equal to zero.
+// Title identifies to the human reader the name of the article.
+// Author identifies who wrote the article.
+// Published tells when the article was published, in the date format of the author's choosing;
+// Date understands the commonest formats.
+// Tags, which are optional, classify the article by topic.
+//
+// Note that neither Title, Author, Published, nor Tags hold any significance to the blog generator, except their use in filling out an HTML template.
+type Descriptor struct {
+	Id        uint
+	Title     string
+	Author    string
+	Email     string
+	Published string
+	Tags      []string `json:",omitempty"`
+}
+
+// DateLayouts lists the layouts, in the manner of the time package, in which Date understands publication dates,
+// starting with that of 2012-Jan-01, as sitehammer new post writes them.
+var DateLayouts = []string{
+	"2006-Jan-02",
+	"2006-01-02",
+	time.RFC3339,
+	"2006-01-02 15:04",
+	"January 2, 2006",
+	"Jan 2, 2006",
+	"2 January 2006",
+	"2 Jan 2006",
+}
+
+// Date answers when the article was published, as given by its Published field,
+// and true, if the field is written in one of the DateLayouts; otherwise, it answers false.
+func (d Descriptor) Date() (time.Time, bool) {
+	for _, layout := range DateLayouts {
+		t, err := time.Parse(layout, d.Published)
+		if err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// Article describes a full article, like a descriptor; unlike a descriptor,
+// however, the abstract and body data are included.
+// Observe that the body is optional (can be nil).
+// History, if the configuration asks for it, gives the article's history in git; see History.
+// Articles are what the templates see; see LoadArticles for other uses.
+type Article struct {
+	Descriptor
+	Abstract template.HTML
+	Body     template.HTML
+	HasBody  bool
+	History  *History
+
+	// modTime records when the article's abstract or body last changed, whichever is later.
+	modTime time.Time
+
+	// bodyFile names the file holding the article's body, if it has one.
+	bodyFile string
+
+	// abstractSum and bodySum hold the hashes of the abstract and body, for signatures, if progress or the cache is recorded.
+	abstractSum, bodySum string
+}
+
+// Options controls rendering of the blog.
+//
+// Descriptors names the article descriptor file.
+// OutputDir names the directory receiving the index page.
+// ArticleDir names the directory receiving the articles; it defaults to the articles subdirectory of OutputDir,
+// but may name a staging area instead.
+// BaseUrl gives the URL at which OutputDir is published, without a trailing slash.
+// Assets resolves logical asset names for the Asset template function.
+// DryRun reports what would be written instead of writing it, listing unchanged and skipped articles too,
+// and finishing with a count of the articles that would be rendered.
+// Context, if not nil, cancels rendering: once it's done, no further articles are rendered, and Build answers the context's error.
+// Pages already written are complete, since each is written atomically.
+//
+// Only and Since select the articles to render, for a quick rebuild after editing a few:
+// Only lists the IDs of articles to render, and Since selects those whose abstracts or bodies changed after it.
+// If either is given, an article is rendered only if one of them selects it, or it neighbors one that's selected,
+// so that the next and previous links of its neighbors stay right, or its page has never been rendered at all,
+// or Cache records its page as rendered from inputs that have since changed.
+// The last renders, whatever Only and Since select, the former neighbors of an article that's been removed,
+// and the neighbors of one whose descriptor alone was edited, such as by retitling it, which changes no file's modification time.
+// The rest keep the pages they have, which still count among the blog's pages.
+// The index page is always rendered.
+//
+// Progress, if not nil, records each article page rendered, with the signature of everything it was rendered from,
+// along with each that fails to render; see buildcache.Progress.
+// With Resume set, an article whose page Progress records as rendered from the same inputs, and which still exists, isn't rendered again,
+// so a build resuming after a failure retries only what failed and what it never reached.
+//
+// Cache, if not nil, records the signature of each article page rendered, as Progress does, but for the caller to save once the build succeeds.
+// An article whose page Cache records as rendered from the same inputs, and which still exists, isn't rendered again, unless Force is set;
+// so editing an article renders its page again, along with its neighbors', whose next and previous links tell of it,
+// and editing the article template, the blogroll, or the asset map renders every page.
+// Records of articles no longer described, or which fail to render, are dropped.
+//
+// DebugTemplates, if not empty, names the directory into which the data of every page's template is dumped,
+// for each page rendered; see the templatedump package.
+type Options struct {
+	Config         *config.Config
+	Descriptors    string
+	OutputDir      string
+	ArticleDir     string
+	BaseUrl        string
+	Assets         assets.Map
+	DryRun         bool
+	Stats          *stats.Stats
+	KeepGoing      bool
+	Context        context.Context
+	Only           []uint
+	Since          time.Time
+	Progress       *buildcache.Progress
+	Resume         bool
+	Cache          *buildcache.Cache
+	Force          bool
+	DebugTemplates string
+}
+
+// Result describes the outcome of rendering the blog.
+// Produced holds the slash-separated name, relative to the output directory, of every page the blog is responsible for.
+// Sources maps each of those names to the files the page was rendered from: descriptors, abstracts, bodies, and templates.
+// Articles holds the articles the blog was built from, in the descriptors' order, for whatever describes the built site.
+type Result struct {
+	Produced map[string]bool
+	Sources  map[string][]string
+	Articles []Article
+}
+
+// blog holds the state of a blog rendering in progress.
+type blog struct {
+	Options
+	*Result
+
+	// failed collects the errors set aside when rendering keeps going after errors.
+	failed errlist.List
+
+	// blogroll holds the site's blogroll, for templates' Blogroll function, if it has one.
+	blogroll *blogroll.Blogroll
+
+	// articleTemplate holds the article template, once it's been parsed, since every article's page is rendered with it,
+	// and signatureBase, the parts of every article's signature not particular to the article, once they've been worked out.
+	articleTemplate *template.Template
+	signatureBase   []string
+
+	// rewritten holds the names of the pages whose content was written, rather than found up to date.
+	rewritten map[string]bool
+
+	// rendered, unchanged, skipped, and unselected count the articles a dry run would render, would leave alone as unchanged,
+	// would pass over because of errors, and would pass over because Only and Since don't select them, for the dry run's summary.
+	rendered, unchanged, skipped, unselected int
+}
+
+// Build renders every article described in the descriptor file, followed by the blog's index page.
+// Whether or not it succeeds, it drops the records the cache of outputs' content hashes holds of the pages it rewrote;
+// see buildcache.ForgetHashes.
+func Build(opts Options) (result *Result, err error) {
+	if opts.ArticleDir == "" {
+		opts.ArticleDir = filepath.Join(opts.OutputDir, ArticleDirName)
+	}
+	if opts.Assets == nil {
+		opts.Assets = make(assets.Map)
+	}
+	b := &blog{Options: opts, Result: &Result{Produced: make(map[string]bool), Sources: make(map[string][]string)}, rewritten: make(map[string]bool)}
+	defer func() {
+		forgetErr := buildcache.ForgetHashes(buildcache.DirIn("."), b.rewritten)
+		if err == nil {
+			err = forgetErr
+		}
+	}()
+
+	began := time.Now()
+	descriptors, err := LoadDescriptors(opts.Descriptors)
+	if err != nil {
+		return nil, err
+	}
+	b.blogroll, err = blogroll.Load(opts.Config)
+	if err != nil {
+		return nil, err
+	}
+	described := len(descriptors)
+	if opts.KeepGoing {
+		descriptors = b.validDescriptors(descriptors)
+	} else {
+		err = ValidateDescriptors(descriptors)
+		if err != nil {
+			return nil, err
+		}
+	}
+	articles, err := b.retrieveAbstractsAndBodies(descriptors)
+	if err != nil {
+		return nil, err
+	}
+	b.Stats.Time(stats.Parse, began)
+	err = b.ensureIsDir(opts.OutputDir)
+	if err != nil {
+		return nil, err
+	}
+	err = b.generateArticlePages(articles)
+	if err != nil {
+		return nil, err
+	}
+	err = b.interrupted()
+	if err != nil {
+		return nil, err
+	}
+	err = b.emitStaticHTMLForFrontMatter(articles)
+	if err != nil {
+		return nil, err
+	}
+	if b.Config.Blog.JsonApi {
+		err = b.emitJsonCollections(articles)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if b.DryRun {
+		b.summarizeDryRun(described)
+	}
+	b.Articles = articles
+	return b.Result, b.failed.Err()
+}
+
+// summarizeDryRun reports what a dry run found the blog's articles to need.
+func (b *blog) summarizeDryRun(described int) {
+	message := fmt.Sprintf("would render %d of %d articles: %d unchanged, %d skipped because of errors", b.rendered, described, b.unchanged, b.skipped)
+	if b.selective() {
+		message += fmt.Sprintf(", %d not selected", b.unselected)
+	}
+	report.Event("dry-run", message,
+		report.Fields{"verb": dryrun.Render, "articles": described, "rendered": b.rendered, "unchanged": b.unchanged, "skipped": b.skipped, "unselected": b.unselected})
+}
+
+// skip notes, during a dry run, that the article with the given ID would be passed over, and why.
+func (b *blog) skip(id uint, reason string) {
+	if b.DryRun {
+		dryrun.Report(dryrun.Skip, b.outputFilenameFor(id, "index.html"), reason)
+		b.skipped++
+	}
+}
+
+// Check looks the blog over for mistakes a build would stumble on, without rendering anything:
+// invalid descriptors (see CheckDescriptors), articles lacking abstracts, and templates which don't parse.
+// Every mistake found is answered, in an errlist.List if there's more than one.
+func Check(opts Options) error {
+	if opts.Assets == nil {
+		opts.Assets = make(assets.Map)
+	}
+	b := &blog{Options: opts}
+	descriptors, err := LoadDescriptors(opts.Descriptors)
+	if err != nil {
+		return err
+	}
+	var problems errlist.List
+	problems.Add(CheckTemplates(opts))
+	for _, d := range checkDescriptors(descriptors, &problems, nil) {
+		filename := b.inputFilenameFor(d.Id, "abstract")
+		_, err = os.Stat(filename)
+		if os.IsNotExist(err) {
+			err = failure.Wrap(failure.Content, fmt.Errorf("Article ID %d has no abstract; %s doesn't exist.", d.Id, filename))
+		}
+		problems.Add(err)
+	}
+	return problems.Err()
+}
+
+// CheckTemplates reads and parses the blog's index and article templates, without rendering them,
+// answering every problem found, in an errlist.List if there's more than one.
+func CheckTemplates(opts Options) error {
+	if opts.Assets == nil {
+		opts.Assets = make(assets.Map)
+	}
+	b := &blog{Options: opts}
+	templates := []struct {
+		filename string
+		funcs    template.FuncMap
+	}{
+		{b.Config.Blog.IndexTemplate, b.indexFuncs()},
+		{b.Config.Blog.ArticleTemplate, b.articleFuncs(nil)},
+	}
+	var problems errlist.List
+	for _, t := range templates {
+		text, err := blogTemplateFor(t.filename)
+		if err == nil {
+			_, err = template.New(t.filename).Funcs(t.funcs).Parse(text)
+			err = failure.Wrap(failure.Template, err)
+		}
+		problems.Add(err)
+	}
+	return problems.Err()
+}
+
+// interrupted answers the context's error if rendering has been cancelled, or nil otherwise.
+func (b *blog) interrupted() error {
+	if b.Context == nil {
+		return nil
+	}
+	return b.Context.Err()
+}
+
+// tolerate answers err, unless rendering is to keep going after errors, in which case err is set aside for the final report.
+// Cancellation is never tolerated.
+func (b *blog) tolerate(err error) error {
+	if err != nil && b.KeepGoing && b.interrupted() == nil {
+		b.failed.Add(err)
+		return nil
+	}
+	return err
+}
+
+// LoadDescriptors reads the named article descriptor file.
+func LoadDescriptors(filename string) (ds []Descriptor, err error) {
+	raw, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(raw, &ds)
+	if err != nil {
+		err = failure.Wrap(failure.Validation, err)
+	}
+	return
+}
+
+// DescriptorError describes a problem with an article descriptor:
+// the article's Id, the Field at fault, the Problem with it, and a Suggestion for putting it right.
+type DescriptorError struct {
+	Id         uint
+	Field      string
+	Problem    string
+	Suggestion string
+}
+
+// Error describes the problem in a sentence.
+func (e *DescriptorError) Error() string {
+	if e.Field == "Id" {
+		return fmt.Sprintf("More than one article with ID %d", e.Id)
+	}
+	return fmt.Sprintf("Article ID %d has %s.", e.Id, e.Problem)
+}
+
+// Class answers failure.Validation, since the descriptor fails validation.
+func (e *DescriptorError) Class() failure.Class {
+	return failure.Validation
+}
+
+// Details describes the problem for a readable diagnostic, grouped with others concerning the same article.
+func (e *DescriptorError) Details() report.Details {
+	return report.Details{
+		Subject:    fmt.Sprintf("article %d", e.Id),
+		Field:      e.Field,
+		Problem:    e.Problem,
+		Suggestion: e.Suggestion,
+	}
+}
+
+// duplicateId answers the error for a second article with the given ID.
+func duplicateId(id uint) error {
+	return &DescriptorError{id, "Id", "an ID another article has already", "Give each article an ID of its own; sitehammer new post picks the next free one."}
+}
+
+// ValidateDescriptors performs a sanity check over the set of descriptors.
+// An error is returned if at least one of the following conditions exists:
+// (1) Greater than one article descriptor shares a common Id.
+// (2) Title, author, or published fields have zero length.
+// The problems of the first faulty descriptor are answered; use CheckDescriptors to learn of every problem.
+// Each problem is a *DescriptorError.
+func ValidateDescriptors(ds []Descriptor) error {
+	for i, d := range ds {
+		err := validateDescriptor(d)
+		if err != nil {
+			return err
+		}
+
+		for _, e := range ds[i+1 : len(ds)] {
+			if d.Id == e.Id {
+				return duplicateId(d.Id)
+			}
+		}
+	}
+	return nil
+}
+
+// CheckDescriptors works like ValidateDescriptors, but answers every problem found, rather than only the first.
+func CheckDescriptors(ds []Descriptor) error {
+	var problems errlist.List
+	checkDescriptors(ds, &problems, nil)
+	return problems.Err()
+}
+
+// validateDescriptor checks that a descriptor's title, author, and published fields aren't empty,
+// answering a problem for each that is.
+func validateDescriptor(d Descriptor) error {
+	var problems errlist.List
+	if len(d.Title) == 0 {
+		problems.Add(&DescriptorError{d.Id, "Title", "zero-length title", `Give the article a title, as in "Title": "Hello".`})
+	}
+	if len(d.Author) == 0 {
+		problems.Add(&DescriptorError{d.Id, "Author", "zero-length author", `Name the article's author, as in "Author": "Sam".`})
+	}
+	if len(d.Published) == 0 {
+		problems.Add(&DescriptorError{d.Id, "Published", "zero-length publication timestamp", `Say when the article was published, as in "Published": "2012-Jan-01".`})
+	}
+	return problems.Err()
+}
+
+// checkDescriptors answers the descriptors passing the checks of ValidateDescriptors, adding to problems an error for each that doesn't,
+// and passing each such descriptor to rejected, unless rejected is nil.
+// Of several descriptors sharing an ID, only the first is kept.
+func checkDescriptors(ds []Descriptor, problems *errlist.List, rejected func(Descriptor)) []Descriptor {
+	var valid []Descriptor
+	seen := make(map[uint]bool)
+	for _, d := range ds {
+		if seen[d.Id] {
+			problems.Add(duplicateId(d.Id))
+			if rejected != nil {
+				rejected(d)
+			}
+			continue
+		}
+		seen[d.Id] = true
+		if err := validateDescriptor(d); err != nil {
+			problems.Add(err)
+			if rejected != nil {
+				rejected(d)
+			}
+			continue
+		}
+		valid = append(valid, d)
+	}
+	return valid
+}
+
+// validDescriptors answers the descriptors passing the checks of ValidateDescriptors, setting aside an error for each that doesn't.
+// Of several descriptors sharing an ID, only the first is kept.
+func (b *blog) validDescriptors(ds []Descriptor) []Descriptor {
+	return checkDescriptors(ds, &b.failed, func(d Descriptor) { b.skip(d.Id, dryrun.Invalid) })
+}
+
+// LoadArticles reads the descriptor file, along with each article's abstract and body, just as Build does, but renders nothing;
+// it's for commands which analyze or list the blog's content.
+// With KeepGoing set, articles with invalid descriptors or missing abstracts are left out,
+// and an errlist.List of their problems is answered along with the rest.
+func LoadArticles(opts Options) ([]Article, error) {
+	b := &blog{Options: opts}
+	descriptors, err := LoadDescriptors(opts.Descriptors)
+	if err != nil {
+		return nil, err
+	}
+	if opts.KeepGoing {
+		descriptors = b.validDescriptors(descriptors)
+	} else {
+		err = ValidateDescriptors(descriptors)
+		if err != nil {
+			return nil, err
+		}
+	}
+	articles, err := b.retrieveAbstractsAndBodies(descriptors)
+	if err != nil {
+		return nil, err
+	}
+	return articles, b.failed.Err()
+}
+
+// retrieveAbstractsAndBodies maps article descriptors to their corresponding abstracts and, optionally, bodies.
+// Each abstract and body is read, or converted, into a single buffer, which becomes the article's text with one copy,
+// so even a body of many megabytes, such as one embedding images as data URIs, is held no more than twice.
+func (b *blog) retrieveAbstractsAndBodies(ds []Descriptor) (articles []Article, err error) {
+	var abstract, body []byte
+	var bodyFile string
+
+	err = nil
+	var histories map[uint]*History
+	if b.Config.Blog.GitHistory {
+		histories = historiesIn(filepath.FromSlash(b.Config.Blog.Sources))
+	}
+	articles = make([]Article, 0, len(ds))
+	for _, d := range ds {
+		err = b.interrupted()
+		if err != nil {
+			return
+		}
+		abstract, err = b.abstractFor(d.Id)
+		if err != nil {
+			b.fail(fmt.Sprintf("%s/%d/index.html", ArticleDirName, d.Id))
+			err = b.tolerate(err)
+			if err != nil {
+				return
+			}
+			b.skip(d.Id, dryrun.Failed)
+			continue
+		}
+		body, bodyFile, err = b.bodyFor(d.Id)
+		if err != nil {
+			b.fail(fmt.Sprintf("%s/%d/index.html", ArticleDirName, d.Id))
+			err = b.tolerate(err)
+			if err != nil {
+				return
+			}
+			b.skip(d.Id, dryrun.Failed)
+			continue
+		}
+		articles = append(articles, Article{
+			Descriptor:  d,
+			Abstract:    template.HTML(abstract),
+			Body:        template.HTML(body),
+			HasBody:     bodyFile != "",
+			History:     histories[d.Id],
+			modTime:     b.modTimeFor(d.Id, bodyFile),
+			bodyFile:    bodyFile,
+			abstractSum: b.sum(abstract),
+			bodySum:     b.sum(body),
+		})
+	}
+	return
+}
+
+// sum answers the hash of an abstract's or body's content, for signatures, if they're wanted; otherwise, no time is spent on it.
+func (b *blog) sum(content []byte) string {
+	if !b.signing() {
+		return ""
+	}
+	return buildcache.Hash(content)
+}
+
+// generateArticlePages creates a directory structure for each article passed in.
+// Each article appears as an index.html file within a directory named after the article ID.
+// If an error occurs while processing the article, its directory and index file will be removed.
+func (b *blog) generateArticlePages(articles []Article) (err error) {
+	err = b.ensureIsDir(b.ArticleDir)
+	if err != nil {
+		return
+	}
+	chosen := b.chosen(articles)
+	for i, a := range articles {
+		err = b.interrupted()
+		if err != nil {
+			return
+		}
+		if !chosen[i] {
+			b.keep(a, dryrun.NotChosen)
+			continue
+		}
+		name := fmt.Sprintf("%s/%d/index.html", ArticleDirName, a.Id)
+		signature := b.signatureFor(articles, i)
+		if b.resumable(a, name, signature) {
+			b.keep(a, dryrun.Resumed)
+			b.record(name, signature)
+			continue
+		}
+		if b.fresh(a, name, signature) {
+			b.keep(a, dryrun.Unchanged)
+			b.Stats.Count(stats.Fresh)
+			continue
+		}
+		began := time.Now()
+		err = b.ensureIsDir(b.outputFilenameFor(a.Id, ""))
+		if err != nil {
+			return
+		}
+		err = b.emitStaticHTMLForArticle(articles, i, len(articles))
+		if err == nil && b.Config.Blog.JsonApi {
+			err = b.emitJsonForArticle(articles, i)
+		}
+		if err != nil {
+			err2 := b.unlinkHtmlAndDir(a.Id)
+			if err2 != nil {
+				err = fmt.Errorf("%s (while recovering from %s)", err2.Error(), err.Error())
+			}
+			b.fail(name)
+			err = b.tolerate(fmt.Errorf("Article %d: %w", a.Id, err))
+			if err != nil {
+				return err
+			}
+			b.skip(a.Id, dryrun.Failed)
+			continue
+		}
+		b.record(name, signature)
+		b.produce(name, append([]string{b.Descriptors, b.Config.Blog.ArticleTemplate}, b.sourcesFor(a)...)...)
+		if b.Config.Blog.JsonApi {
+			b.produce(b.jsonNameFor(a), append([]string{b.Descriptors}, b.sourcesFor(a)...)...)
+		}
+		b.Stats.Step(fmt.Sprintf("article %d", a.Id), began)
+	}
+	b.forgetUndescribed()
+	return nil
+}
+
+// record notes that the named page has just been rendered from inputs with the given signature, in Progress and Cache, whichever are kept.
+func (b *blog) record(name, signature string) {
+	if b.DryRun {
+		return
+	}
+	if b.Progress != nil {
+		b.Progress.Record(name, signature)
+	}
+	if b.Cache != nil {
+		b.Cache.Record(name, signature)
+	}
+}
+
+// fail notes that the named page failed to render, in Progress and Cache, whichever are kept, so that it's rendered again next time.
+func (b *blog) fail(name string) {
+	if b.DryRun {
+		return
+	}
+	if b.Progress != nil {
+		b.Progress.Fail(name)
+	}
+	if b.Cache != nil {
+		b.Cache.Forget(name)
+	}
+}
+
+// forgetUndescribed drops Cache's records of pages the blog is no longer responsible for, such as those of articles no longer described.
+func (b *blog) forgetUndescribed() {
+	if b.Cache == nil || b.DryRun {
+		return
+	}
+	for name := range b.Cache.Outputs {
+		if !b.Produced[name] {
+			b.Cache.Forget(name)
+		}
+	}
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// mostRecent delivers the most recent articles posted to the blog as an array for easy iteration in a template file.
+func mostRecent(articles []Article) (as []Article) {
+	last := len(articles)
+	first := max(0, last-5)
+	as = articles[first:last]
+	return
+}
+
+// emitStaticHTMLForFrontMatter creates the index.html file for the blog's initial landing page.
+func (b *blog) emitStaticHTMLForFrontMatter(articles []Article) error {
+	templateFileContents, err := b.blogIndexTemplate()
+	if err != nil {
+		return err
+	}
+	began := time.Now()
+	tmpl, err := template.New("SiteHammer Blog Index").Funcs(b.indexFuncs()).Parse(templateFileContents)
+	if err != nil {
+		return failure.Wrap(failure.Template, err)
+	}
+	b.Stats.Time(stats.Parse, began)
+	began = time.Now()
+	err = templatedump.Dump(b.DebugTemplates, IndexFilename, mostRecent(articles))
+	if err != nil {
+		return err
+	}
+	outputWriter := new(bytes.Buffer)
+	err = tmpl.Execute(outputWriter, mostRecent(articles))
+	if err != nil {
+		return failure.Wrap(failure.Template, err)
+	}
+	b.Stats.Time(stats.Render, began)
+	sources := []string{b.Descriptors, b.Config.Blog.IndexTemplate}
+	for _, a := range mostRecent(articles) {
+		sources = append(sources, b.sourcesFor(a)...)
+	}
+	b.produce(IndexFilename, sources...)
+	outputIndexFile := filepath.Join(b.OutputDir, IndexFilename)
+	if b.DryRun {
+		dryrun.ReportRender(outputIndexFile, outputWriter.Bytes())
+		return nil
+	}
+	var newest time.Time
+	for _, a := range mostRecent(articles) {
+		if a.modTime.After(newest) {
+			newest = a.modTime
+		}
+	}
+	return b.writePage(IndexFilename, outputIndexFile, outputWriter.Bytes(), newest)
+}
+
+// writePage writes a rendered page, with the given name, to filename, with the configured permissions.
+// The page is replaced atomically, so a failure midway never leaves a partial page behind;
+// a page whose content hasn't changed isn't rewritten at all, so its modification time stays put.
+// If so configured, the page's modification time is set to modTime, that of its newest source.
+func (b *blog) writePage(name, filename string, content []byte, modTime time.Time) error {
+	defer b.Stats.Time(stats.Write, time.Now())
+	perm := b.Config.Output.FilePerm(0644)
+	if !buildcache.Unchanged(filename, content) {
+		err := directory.WriteFileAtomic(filename, content, perm)
+		if err != nil {
+			return err
+		}
+		b.Stats.Wrote(len(content))
+		b.rewritten[name] = true
+	}
+	err := os.Chmod(filename, perm)
+	if err != nil || !b.Config.Output.PreserveMtimes || modTime.IsZero() {
+		return err
+	}
+	return os.Chtimes(filename, time.Now(), modTime)
+}
+
+// produce notes that the blog is responsible for the named page, rendered from the given sources.
+func (b *blog) produce(name string, sources ...string) {
+	b.claim(name, sources...)
+	b.Stats.Count(stats.Rendered)
+}
+
+// claim notes that the blog is responsible for the named page, made from the given sources, whether or not it was rendered this time.
+func (b *blog) claim(name string, sources ...string) {
+	b.Produced[name] = true
+	b.Sources[name] = sources
+}
+
+// selective answers true if Only or Since limit the articles rendered.
+func (b *blog) selective() bool {
+	return len(b.Only) > 0 || !b.Since.IsZero()
+}
+
+// chosen answers, for each of the articles, whether it's to be rendered:
+// every article, unless Only or Since limit them to those selected, their neighbors, those never rendered before,
+// and those whose pages are stale.
+func (b *blog) chosen(articles []Article) []bool {
+	chosen := make([]bool, len(articles))
+	if !b.selective() {
+		for i := range chosen {
+			chosen[i] = true
+		}
+		return chosen
+	}
+	only := make(map[uint]bool)
+	for _, id := range b.Only {
+		only[id] = true
+	}
+	for i, a := range articles {
+		if b.stale(articles, i) {
+			chosen[i] = true
+		}
+		if !only[a.Id] && (b.Since.IsZero() || !a.modTime.After(b.Since)) && b.pagesExist(a) {
+			continue
+		}
+		for j := max(0, i-1); j <= i+1 && j < len(articles); j++ {
+			chosen[j] = true
+		}
+	}
+	return chosen
+}
+
+// stale answers true if Cache records the page of the article at index i as rendered from inputs other than those it's rendered from now.
+// Since an article's signature covers its neighbors, its page is stale if a neighbor is edited, retitled, added, or removed.
+// A page Cache doesn't record isn't stale; whether it's rendered is up to Only and Since.
+func (b *blog) stale(articles []Article, i int) bool {
+	if b.Cache == nil {
+		return false
+	}
+	recorded, ok := b.Cache.Outputs[fmt.Sprintf("%s/%d/index.html", ArticleDirName, articles[i].Id)]
+	return ok && recorded != b.signatureFor(articles, i)
+}
+
+// keep leaves an article's page as it is, for the given reason, still counting it among the blog's pages.
+func (b *blog) keep(a Article, reason string) {
+	name := fmt.Sprintf("%s/%d/index.html", ArticleDirName, a.Id)
+	b.claim(name, append([]string{b.Descriptors, b.Config.Blog.ArticleTemplate}, b.sourcesFor(a)...)...)
+	if b.Config.Blog.JsonApi {
+		b.claim(b.jsonNameFor(a), append([]string{b.Descriptors}, b.sourcesFor(a)...)...)
+	}
+	if !b.DryRun {
+		return
+	}
+	dryrun.Report(dryrun.Skip, b.outputFilenameFor(a.Id, IndexFilename), reason)
+	if reason == dryrun.NotChosen {
+		b.unselected++
+	} else {
+		b.unchanged++
+	}
+}
+
+// resumable answers true if, resuming a failed build, the named page of the given article needn't be rendered again:
+// if the failed build rendered it from inputs with the same signature, and it still exists.
+func (b *blog) resumable(a Article, name, signature string) bool {
+	if !b.Resume || b.Progress == nil || !b.Progress.Current(name, signature) {
+		return false
+	}
+	return b.pagesExist(a)
+}
+
+// fresh answers true if the named page of the given article needn't be rendered again, since the last successful build:
+// if Cache records it as rendered from inputs with the same signature, and it still exists, and Force isn't set.
+func (b *blog) fresh(a Article, name, signature string) bool {
+	if b.Force || b.Cache == nil || b.Cache.Outputs[name] != signature {
+		return false
+	}
+	return b.pagesExist(a)
+}
+
+// pagesExist answers true if the article's page exists, along with its JSON document, if the JSON API is enabled.
+func (b *blog) pagesExist(a Article) bool {
+	names := []string{IndexFilename}
+	if b.Config.Blog.JsonApi {
+		names = append(names, ArticleJsonFilename)
+	}
+	for _, name := range names {
+		if _, err := os.Stat(b.outputFilenameFor(a.Id, name)); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// signatureFor answers the signature of everything the page of the article at index i is rendered from:
+// the article template, the base URL, the asset map, the blogroll, whether a JSON document accompanies the page,
+// the article's position, and the descriptors, abstracts, bodies, and histories of the article and the neighbors it links to.
+// It's worked out only if Progress or Cache will record it.
+func (b *blog) signatureFor(articles []Article, i int) string {
+	if !b.signing() {
+		return ""
+	}
+	if b.signatureBase == nil {
+		text, _ := b.blogArticleTemplate()
+		assetMap, _ := json.Marshal(b.Assets)
+		links, _ := json.Marshal(b.blogroll)
+		b.signatureBase = []string{buildcache.Hash([]byte(text)), b.BaseUrl, buildcache.Hash(assetMap), buildcache.Hash(links), fmt.Sprint(b.Config.Blog.JsonApi)}
+	}
+	parts := append(append([]string(nil), b.signatureBase...), fmt.Sprint(i, len(articles)))
+	for j := max(0, i-1); j <= i+1 && j < len(articles); j++ {
+		descriptor, _ := json.Marshal(articles[j].Descriptor)
+		history, _ := json.Marshal(articles[j].History)
+		parts = append(parts, string(descriptor), articles[j].abstractSum, articles[j].bodySum, string(history))
+	}
+	return buildcache.Signature(parts...)
+}
+
+// signing answers true if article pages' signatures are recorded, by Progress or Cache.
+func (b *blog) signing() bool {
+	return b.Progress != nil || b.Cache != nil
+}
+
+// sourcesFor answers the files from which an article's content comes: its abstract and, if it has one, its body.
+// Like published names, the filenames are slash-separated, whatever the platform.
+func (b *blog) sourcesFor(a Article) []string {
+	sources := []string{filepath.ToSlash(b.inputFilenameFor(a.Id, "abstract"))}
+	if a.HasBody {
+		sources = append(sources, filepath.ToSlash(a.bodyFile))
+	}
+	return sources
+}
+
+// indexFuncs answers the functions the index page's template may call.
+func (b *blog) indexFuncs() template.FuncMap {
+	return template.FuncMap{
+		"Asset":    b.Assets.Lookup,
+		"Blogroll": b.blogrollFor,
+		"Url":      b.urlFor,
+	}
+}
+
+// articleFuncs answers the functions an article's template may call, given every article rendered.
+func (b *blog) articleFuncs(articles []Article) template.FuncMap {
+	return template.FuncMap{
+		"HasNextLink": func(i, last int) bool { return i+1 != last },
+		"HasPrevLink": func(i int) bool { return i != 0 },
+		"NextArticle": func(i int) Article { return articles[i+1] },
+		"PrevArticle": func(i int) Article { return articles[i-1] },
+		"Url":         b.urlFor,
+		"Asset":       b.Assets.Lookup,
+		"Blogroll":    b.blogrollFor,
+	}
+}
+
+// blogrollFor answers the site's blogroll, for templates' Blogroll function, or nil if it has none.
+func (b *blog) blogrollFor() *blogroll.Blogroll {
+	return b.blogroll
+}
+
+// urlFor returns a string representation of an article's URL.
+func (b *blog) urlFor(a Article) string {
+	return fmt.Sprintf("%s/%s/%d", b.BaseUrl, ArticleDirName, a.Id)
+}
+
+// emitStaticHTMLForArticle does as its name suggests.
+// It will also attempt to create the relevant directories it needs, including article/ and article/{{id}}.
+// If any error occurs while creating the final HTML, all resources related to the article will be removed.
+// This leaves the filesystem in a consistent state.
+func (b *blog) emitStaticHTMLForArticle(articles []Article, index, length int) error {
+	if b.articleTemplate == nil {
+		templateFileContents, err := b.blogArticleTemplate()
+		if err != nil {
+			return err
+		}
+		began := time.Now()
+		tmpl, err := template.New("SiteHammer Blog Article").Funcs(b.articleFuncs(articles)).Parse(templateFileContents)
+		if err != nil {
+			return failure.Wrap(failure.Template, err)
+		}
+		b.Stats.Time(stats.Parse, began)
+		b.articleTemplate = tmpl
+	}
+	began := time.Now()
+	article := articles[index]
+	// The page holds the article's abstract and body, at least, so growing the buffer to hold them at once spares copying them as it grows.
+	outputWriter := new(bytes.Buffer)
+	outputWriter.Grow(len(article.Abstract) + len(article.Body) + pageOverhead)
+	params := map[string]interface{}{
+		"a":    article,
+		"home": b.BaseUrl,
+		"i":    index,
+		"last": length,
+	}
+	err := templatedump.Dump(b.DebugTemplates, path.Join(ArticleDirName, fmt.Sprint(article.Id), IndexFilename), params)
+	if err != nil {
+		return err
+	}
+	err = b.articleTemplate.Execute(outputWriter, params)
+	if err != nil {
+		return failure.Wrap(failure.Template, err)
+	}
+	b.Stats.Time(stats.Render, began)
+	if b.DryRun {
+		if dryrun.ReportRender(b.outputFilenameFor(article.Id, "index.html"), outputWriter.Bytes()) {
+			b.rendered++
+		} else {
+			b.unchanged++
+		}
+		return nil
+	}
+	name := fmt.Sprintf("%s/%d/%s", ArticleDirName, article.Id, IndexFilename)
+	return b.writePage(name, b.outputFilenameFor(article.Id, "index.html"), outputWriter.Bytes(), article.modTime)
+}
+
+// unlinkHtmlAndDir attempts to remove the index.html file and the directory it sits in.
+// It does not attempt, however, to remove the articles directory.
+func (b *blog) unlinkHtmlAndDir(id uint) error {
+	if b.DryRun {
+		return nil
+	}
+	return os.RemoveAll(b.outputFilenameFor(id, ""))
+}
+
+// inputFilenameFor derives a filename in source data filesystem space.
+func (b *blog) inputFilenameFor(id uint, kind string) string {
+	return filepath.Join(filepath.FromSlash(b.Config.Blog.Sources), fmt.Sprint(id), kind)
+}
+
+// abstractFor attempts to locate the abstract for an article.
+// For an article with ID 1234, the blog expects the abstract to appear in the ./src/1234/abstract file.
+// If not found, it returns a relevant error.
+// Otherwise, it returns the raw text contained in the abstract.
+func (b *blog) abstractFor(id uint) (text []byte, err error) {
+	text, err = ioutil.ReadFile(b.inputFilenameFor(id, "abstract"))
+	if os.IsNotExist(err) {
+		// Every article needs an abstract, so its absence is a fault of the content.
+		err = failure.Wrap(failure.Content, err)
+	}
+	return
+}
+
+// bodyFor attempts to locate the body for an article, in any of the formats in which bodies may be written; see BodyFilenames.
+// If no body file can be found, filename will be empty.
+// Otherwise, an HTML string containing the entirety of the body results, converted from its format if need be,
+// along with the name of the file holding it.
+// An article with bodies in more than one format, or whose body can't be converted, is in error.
+func (b *blog) bodyFor(id uint) (body []byte, filename string, err error) {
+	var format bodyFormat
+	for _, f := range bodyFormats(b.Config) {
+		name := b.inputFilenameFor(id, f.filename)
+		if _, statErr := os.Stat(name); statErr != nil {
+			continue
+		}
+		if filename != "" {
+			err = failure.Wrap(failure.Content, fmt.Errorf("Article ID %d has two bodies, %s and %s; remove one.", id, filename, name))
+			return
+		}
+		filename, format = name, f
+	}
+	if filename == "" {
+		return
+	}
+	body, err = ioutil.ReadFile(filename)
+	if err == nil && format.convert != nil {
+		body, err = format.convert(b, filename, body)
+	}
+	if err != nil {
+		body, filename = nil, ""
+	}
+	return
+}
+
+// modTimeFor answers when an article's abstract or body, held in bodyFile, if it has one, last changed, whichever is later.
+func (b *blog) modTimeFor(id uint, bodyFile string) (newest time.Time) {
+	for _, name := range []string{b.inputFilenameFor(id, "abstract"), bodyFile} {
+		fi, err := os.Stat(name)
+		if err == nil && fi.ModTime().After(newest) {
+			newest = fi.ModTime()
+		}
+	}
+	return
+}
+
+// blogTemplateFor retrieves a blog template file, or an error if unsuccessful.
+func blogTemplateFor(filename string) (s string, err error) {
+	contents, err := ioutil.ReadFile(filename)
+	return string(contents), err
+}
+
+// blogIndexTemplate retrieves the blog index.html template, or an error if unsuccessful.
+func (b *blog) blogIndexTemplate() (s string, err error) {
+	return blogTemplateFor(b.Config.Blog.IndexTemplate)
+}
+
+// blogArticleTemplate retrieves the blog article template, or an error if unsuccessful.
+// It's read once for the pages of a build, and once for their signatures, rather than once for each page.
+func (b *blog) blogArticleTemplate() (s string, err error) {
+	return blogTemplateFor(b.Config.Blog.ArticleTemplate)
+}
+
+// ensureIsDir makes sure the given pathname exists as a directory, creating it and any missing parents if need be;
+// see directory.EnsureDirAll.  A dry run merely reports the directories it would create.
+func (b *blog) ensureIsDir(pathname string) error {
+	if b.DryRun {
+		if _, err := os.Stat(pathname); os.IsNotExist(err) {
+			dryrun.Report(dryrun.Create, pathname, dryrun.New)
+			return nil
+		}
+	}
+	return directory.EnsureDirAll(pathname, b.Config.Output.DirPerm())
+}
+
+// outputFilenameFor derives a filename in output data filesystem space.
+func (b *blog) outputFilenameFor(id uint, kind string) string {
+	return filepath.Join(b.ArticleDir, fmt.Sprint(id), kind)
+}
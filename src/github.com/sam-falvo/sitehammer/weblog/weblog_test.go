@@ -0,0 +1,257 @@
+package weblog
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/sam-falvo/sitehammer/buildcache"
+	"github.com/sam-falvo/sitehammer/config"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// blogWithSources answers a blog whose sources lie in the given slash-separated directory, and whose articles are rendered into articleDir.
+func blogWithSources(sources, articleDir string) *blog {
+	cfg := config.Default()
+	cfg.Blog.Sources = sources
+	return &blog{Options: Options{Config: cfg, ArticleDir: articleDir}}
+}
+
+func TestInputFilenameFor(t *testing.T) {
+	tests := []struct {
+		sources string
+		want    string
+	}{
+		{"src", filepath.FromSlash("src/12/abstract")},
+		{"site/src", filepath.FromSlash("site/src/12/abstract")},
+		{"site/src/", filepath.FromSlash("site/src/12/abstract")},
+		{"../shared/src", filepath.FromSlash("../shared/src/12/abstract")},
+		{"site/drafts/../src", filepath.FromSlash("site/src/12/abstract")},
+		{filepath.FromSlash("site/src"), filepath.FromSlash("site/src/12/abstract")},
+	}
+	for _, tc := range tests {
+		b := blogWithSources(tc.sources, "")
+		if got := b.inputFilenameFor(12, "abstract"); got != tc.want {
+			t.Errorf("inputFilenameFor with sources %q = %q, want %q", tc.sources, got, tc.want)
+		}
+	}
+}
+
+func TestOutputFilenameFor(t *testing.T) {
+	tests := []struct {
+		articleDir string
+		kind       string
+		want       string
+	}{
+		{filepath.FromSlash("_site/articles"), IndexFilename, filepath.FromSlash("_site/articles/12/index.html")},
+		{filepath.FromSlash("_site/articles"), "", filepath.FromSlash("_site/articles/12")},
+		{filepath.FromSlash("_site/articles.staging"), ArticleJsonFilename, filepath.FromSlash("_site/articles.staging/12/index.json")},
+		{filepath.FromSlash("../out/_site/articles"), IndexFilename, filepath.FromSlash("../out/_site/articles/12/index.html")},
+	}
+	for _, tc := range tests {
+		b := blogWithSources("src", tc.articleDir)
+		if got := b.outputFilenameFor(12, tc.kind); got != tc.want {
+			t.Errorf("outputFilenameFor(12, %q) in %q = %q, want %q", tc.kind, tc.articleDir, got, tc.want)
+		}
+	}
+}
+
+func TestSourcesFor(t *testing.T) {
+	tests := []struct {
+		sources string
+		article Article
+		want    []string
+	}{
+		{"src", Article{Descriptor: Descriptor{Id: 12}}, []string{"src/12/abstract"}},
+		{"site/src", Article{Descriptor: Descriptor{Id: 12}, HasBody: true, bodyFile: filepath.FromSlash("site/src/12/body.adoc")},
+			[]string{"site/src/12/abstract", "site/src/12/body.adoc"}},
+		{filepath.FromSlash("../shared/src"), Article{Descriptor: Descriptor{Id: 3}, HasBody: true, bodyFile: filepath.FromSlash("../shared/src/3/body")},
+			[]string{"../shared/src/3/abstract", "../shared/src/3/body"}},
+	}
+	for _, tc := range tests {
+		b := blogWithSources(tc.sources, "")
+		got := b.sourcesFor(tc.article)
+		if len(got) != len(tc.want) {
+			t.Errorf("sourcesFor article %d = %q, want %q", tc.article.Id, got, tc.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tc.want[i] {
+				t.Errorf("sourcesFor article %d = %q, want %q", tc.article.Id, got, tc.want)
+				break
+			}
+		}
+	}
+}
+
+// testBlog holds a blog in a temporary directory: its descriptors, and the options with which to build it.
+type testBlog struct {
+	t           *testing.T
+	dir         string
+	descriptors []Descriptor
+	opts        Options
+}
+
+// newTestBlog answers a blog of n articles, numbered from 1, each with an abstract, built with a cache of article pages' signatures.
+// Its article template tells, with each article's title, those of its neighbors.
+func newTestBlog(t *testing.T, n int) *testBlog {
+	dir := t.TempDir()
+	cfg := config.Default()
+	cfg.Blog.Sources = filepath.Join(dir, "src")
+	cfg.Blog.ArticleTemplate = filepath.Join(dir, "article.html")
+	cfg.Blog.IndexTemplate = filepath.Join(dir, "index.html")
+	cfg.Blogroll.File = filepath.Join(dir, "blogroll.json")
+	tb := &testBlog{t: t, dir: dir, opts: Options{
+		Config:      cfg,
+		Descriptors: filepath.Join(dir, "descs.json"),
+		OutputDir:   filepath.Join(dir, "_site"),
+	}}
+	tb.write(cfg.Blog.ArticleTemplate,
+		"{{.a.Title}}{{if HasPrevLink .i}} after {{(PrevArticle .i).Title}}{{end}}{{if HasNextLink .i .last}} before {{(NextArticle .i).Title}}{{end}}")
+	tb.write(cfg.Blog.IndexTemplate, "{{range .}}{{.Title}} {{end}}")
+	for id := uint(1); id <= uint(n); id++ {
+		tb.descriptors = append(tb.descriptors, Descriptor{Id: id, Title: fmt.Sprint("Article ", id), Author: "A", Email: "a@example.com", Published: "2012-01-01"})
+		tb.write(filepath.Join(cfg.Blog.Sources, fmt.Sprint(id), "abstract"), fmt.Sprint("<p>Abstract ", id, "</p>"))
+	}
+	tb.describe()
+	return tb
+}
+
+// write writes content to the named file, creating its directory if need be.
+func (tb *testBlog) write(filename, content string) {
+	tb.t.Helper()
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		tb.t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filename, []byte(content), 0644); err != nil {
+		tb.t.Fatal(err)
+	}
+}
+
+// describe writes the blog's descriptors to its descriptor file.
+func (tb *testBlog) describe() {
+	tb.t.Helper()
+	raw, err := json.Marshal(tb.descriptors)
+	if err != nil {
+		tb.t.Fatal(err)
+	}
+	tb.write(tb.opts.Descriptors, string(raw))
+}
+
+// remove drops the article with the given ID from the blog's descriptors.
+func (tb *testBlog) remove(id uint) {
+	for i, d := range tb.descriptors {
+		if d.Id == id {
+			tb.descriptors = append(tb.descriptors[:i], tb.descriptors[i+1:]...)
+			break
+		}
+	}
+	tb.describe()
+}
+
+// retitle gives the article with the given ID a new title.
+func (tb *testBlog) retitle(id uint, title string) {
+	for i := range tb.descriptors {
+		if tb.descriptors[i].Id == id {
+			tb.descriptors[i].Title = title
+		}
+	}
+	tb.describe()
+}
+
+// build builds the blog with the given options, as build does: opening the cache beforehand, and saving it once the build succeeds.
+func (tb *testBlog) build(opts Options) {
+	tb.t.Helper()
+	opts.Config, opts.Descriptors, opts.OutputDir = tb.opts.Config, tb.opts.Descriptors, tb.opts.OutputDir
+	opts.Cache = buildcache.Open(filepath.Join(tb.dir, "cache", CacheFilename))
+	if _, err := Build(opts); err != nil {
+		tb.t.Fatalf("Build: %v", err)
+	}
+	if err := opts.Cache.Save(); err != nil {
+		tb.t.Fatal(err)
+	}
+}
+
+// rendered answers the IDs of the articles whose pages the latest build rendered, as opposed to leaving them stale,
+// along with the pages' contents, and marks every page stale again.
+func (tb *testBlog) rendered() ([]uint, map[uint]string) {
+	tb.t.Helper()
+	var ids []uint
+	pages := make(map[uint]string)
+	for _, d := range tb.descriptors {
+		filename := filepath.Join(tb.opts.OutputDir, ArticleDirName, fmt.Sprint(d.Id), IndexFilename)
+		raw, err := ioutil.ReadFile(filename)
+		if err != nil {
+			tb.t.Fatal(err)
+		}
+		if string(raw) != "stale" {
+			ids = append(ids, d.Id)
+			pages[d.Id] = string(raw)
+		}
+		tb.write(filename, "stale")
+	}
+	return ids, pages
+}
+
+// TestChosenRendersStaleNeighbors checks that Only and Since, though they select none of them,
+// don't keep the neighbors of articles removed or retitled from being rendered again.
+func TestChosenRendersStaleNeighbors(t *testing.T) {
+	tb := newTestBlog(t, 5)
+	tb.build(Options{})
+	tb.rendered()
+
+	// Removing an article moves those after it, and changes how many there are, which every page's signature covers.
+	tb.remove(3)
+	tb.build(Options{Only: []uint{1}})
+	ids, pages := tb.rendered()
+	if want := []uint{1, 2, 4, 5}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("after removing article 3, building only article 1 rendered articles %v, want %v", ids, want)
+	}
+	if want := "Article 2 after Article 1 before Article 4"; pages[2] != want {
+		t.Errorf("article 2's page = %q, want %q", pages[2], want)
+	}
+
+	tb.retitle(4, "Retitled")
+	tb.build(Options{Since: time.Now().Add(time.Hour)})
+	ids, pages = tb.rendered()
+	if want := []uint{2, 4, 5}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("after retitling article 4, building articles changed since an hour hence rendered articles %v, want %v", ids, want)
+	}
+	if want := "Article 5 after Retitled"; pages[5] != want {
+		t.Errorf("article 5's page = %q, want %q", pages[5], want)
+	}
+}
+
+func TestBuildSkipsUnchangedArticles(t *testing.T) {
+	tb := newTestBlog(t, 5)
+	tb.build(Options{})
+	if ids, _ := tb.rendered(); len(ids) != 5 {
+		t.Fatalf("the first build rendered articles %v, want all 5", ids)
+	}
+
+	tb.build(Options{})
+	if ids, _ := tb.rendered(); len(ids) != 0 {
+		t.Errorf("building again unchanged rendered articles %v, want none", ids)
+	}
+
+	tb.retitle(3, "Retitled")
+	tb.build(Options{})
+	ids, pages := tb.rendered()
+	if want := []uint{2, 3, 4}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("after retitling article 3, building rendered articles %v, want %v", ids, want)
+	}
+	if want := "Article 2 after Article 1 before Retitled"; pages[2] != want {
+		t.Errorf("article 2's page = %q, want %q", pages[2], want)
+	}
+	if want := "Article 4 after Retitled before Article 5"; pages[4] != want {
+		t.Errorf("article 4's page = %q, want %q", pages[4], want)
+	}
+
+	tb.build(Options{Force: true})
+	if ids, _ := tb.rendered(); len(ids) != 5 {
+		t.Errorf("building with Force rendered articles %v, want all 5", ids)
+	}
+}